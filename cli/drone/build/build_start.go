@@ -19,6 +19,10 @@ var buildStartCmd = cli.Command{
 			Name:  "param, p",
 			Usage: "custom parameters to be injected into the job environment. Format: KEY=value",
 		},
+		cli.BoolFlag{
+			Name:  "failed",
+			Usage: "only rerun the failed pipelines and the pipelines depending on them",
+		},
 	},
 }
 
@@ -54,6 +58,9 @@ func buildStart(c *cli.Context) (err error) {
 	}
 
 	params := internal.ParseKeyPair(c.StringSlice("param"))
+	if c.Bool("failed") {
+		params["failed"] = "true"
+	}
 
 	build, err := client.BuildStart(owner, name, number, params)
 	if err != nil {
@@ -295,7 +295,7 @@ func exec(c *cli.Context) error {
 		return err
 	}
 
-	axes, err := matrix.ParseString(string(dat))
+	axes, err := matrix.ParseStringEvent(string(dat), c.String("build-event"))
 	if err != nil {
 		return fmt.Errorf("Parse matrix fail")
 	}
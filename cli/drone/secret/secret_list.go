@@ -3,6 +3,7 @@ package secret
 import (
 	"html/template"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/urfave/cli"
@@ -67,10 +68,21 @@ Images: {{ list .Images }}
 {{- else }}
 Images: <any>
 {{- end }}
+{{- if .Matrix }}
+Matrix: {{ pairs .Matrix }}
+{{- end }}
 `
 
 var secretFuncMap = template.FuncMap{
 	"list": func(s []string) string {
 		return strings.Join(s, ", ")
 	},
+	"pairs": func(m map[string]string) string {
+		var pairs []string
+		for k, v := range m {
+			pairs = append(pairs, k+"="+v)
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ", ")
+	},
 }
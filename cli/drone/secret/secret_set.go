@@ -36,6 +36,18 @@ var secretUpdateCmd = cli.Command{
 			Name:  "image",
 			Usage: "secret limited to these images",
 		},
+		cli.StringFlag{
+			Name:  "registry",
+			Usage: "registry address the secret holds username:password credentials for",
+		},
+		cli.BoolFlag{
+			Name:  "template",
+			Usage: "secret value is a template resolved against other secrets",
+		},
+		cli.StringSliceFlag{
+			Name:  "matrix",
+			Usage: "secret limited to the matrix axes with these values. Format: KEY=value",
+		},
 	},
 }
 
@@ -53,10 +65,13 @@ func secretUpdate(c *cli.Context) error {
 		return err
 	}
 	secret := &drone.Secret{
-		Name:   c.String("name"),
-		Value:  c.String("value"),
-		Images: c.StringSlice("image"),
-		Events: c.StringSlice("event"),
+		Name:     c.String("name"),
+		Value:    c.String("value"),
+		Images:   c.StringSlice("image"),
+		Events:   c.StringSlice("event"),
+		Registry: c.String("registry"),
+		Template: c.Bool("template"),
+		Matrix:   internal.ParseKeyPair(c.StringSlice("matrix")),
 	}
 	if strings.HasPrefix(secret.Value, "@") {
 		path := strings.TrimPrefix(secret.Value, "@")
@@ -111,6 +111,125 @@ var flags = []cli.Flag{
 			"plugins/ecr",
 		},
 	},
+	cli.BoolFlag{
+		EnvVar: "DRONE_VERIFY_COMMIT,WOODPECKER_VERIFY_COMMIT",
+		Name:   "verify-commit",
+		Usage:  "fetch the commit signature verification status for CI_COMMIT_VERIFIED",
+	},
+	cli.IntFlag{
+		EnvVar: "DRONE_CLONE_MAX_DEPTH,WOODPECKER_CLONE_MAX_DEPTH",
+		Name:   "clone-max-depth",
+		Usage:  "maximum clone depth of the default clone step, 0 allows full clones",
+	},
+	cli.BoolFlag{
+		EnvVar: "DRONE_CLONE_LFS,WOODPECKER_CLONE_LFS",
+		Name:   "clone-lfs",
+		Usage:  "fetch the git lfs objects in the default clone step unless a pipeline disables it",
+	},
+	cli.BoolFlag{
+		EnvVar: "DRONE_FAIL_ON_MISSING_SECRETS,WOODPECKER_FAIL_ON_MISSING_SECRETS",
+		Name:   "fail-on-missing-secrets",
+		Usage:  "fail builds referencing secrets that do not exist instead of logging a warning",
+	},
+	cli.StringSliceFlag{
+		EnvVar: "DRONE_TRIGGER_POLICY,WOODPECKER_TRIGGER_POLICY",
+		Name:   "trigger-policy",
+		Usage:  "minimum permission the hook sender needs to trigger a build for an event, e.g. comment=push",
+	},
+	cli.StringSliceFlag{
+		EnvVar: "DRONE_SKIP_CI_MARKERS,WOODPECKER_SKIP_CI_MARKERS",
+		Name:   "skip-ci-markers",
+		Usage:  "case-insensitive commit message markers that skip the build, defaults to [ci skip] and [skip ci]",
+	},
+	cli.StringSliceFlag{
+		EnvVar: "DRONE_SKIP_CI_EVENTS,WOODPECKER_SKIP_CI_EVENTS",
+		Name:   "skip-ci-events",
+		Usage:  "events that can be skipped with a commit message marker, defaults to all events",
+	},
+	cli.IntFlag{
+		EnvVar: "DRONE_MATRIX_LIMIT,WOODPECKER_MATRIX_LIMIT",
+		Name:   "matrix-limit",
+		Usage:  "maximum number of pipelines a matrix may expand to, can be overridden per repository by an admin",
+	},
+	cli.IntFlag{
+		EnvVar: "DRONE_PROC_LIMIT,WOODPECKER_PROC_LIMIT",
+		Name:   "proc-limit",
+		Usage:  "maximum number of procs, pipelines and their steps, a build may create",
+	},
+	cli.StringSliceFlag{
+		EnvVar: "DRONE_ALLOWED_EVENTS,WOODPECKER_ALLOWED_EVENTS",
+		Name:   "allowed-events",
+		Usage:  "build events pipelines can run for, regardless of their when conditions; defaults to all events and can be overridden per repository by an admin",
+	},
+	cli.StringSliceFlag{
+		EnvVar: "DRONE_CONFIG_REPOS,WOODPECKER_CONFIG_REPOS",
+		Name:   "config-repos",
+		Usage:  "external repositories pipeline configurations can be read from, as owner/name patterns",
+	},
+	cli.StringFlag{
+		EnvVar: "DRONE_PIPELINE_NAME_COLLISION,WOODPECKER_PIPELINE_NAME_COLLISION",
+		Name:   "pipeline-name-collision",
+		Usage:  "handling of configuration files resulting in the same pipeline name, allow, fail or disambiguate by the file path. allow keeps the colliding names, depends_on on them is ambiguous",
+		Value:  "allow",
+	},
+	cli.StringFlag{
+		EnvVar: "DRONE_PIPELINE_NAME_PATTERN,WOODPECKER_PIPELINE_NAME_PATTERN",
+		Name:   "pipeline-name-pattern",
+		Usage:  "regular expression replaced in the pipeline names derived from the configuration files, e.g. ^pipelines/",
+	},
+	cli.StringFlag{
+		EnvVar: "DRONE_PIPELINE_NAME_REPLACEMENT,WOODPECKER_PIPELINE_NAME_REPLACEMENT",
+		Name:   "pipeline-name-replacement",
+		Usage:  "replacement of the pipeline name pattern, may reference the submatches, e.g. ${1}",
+	},
+	cli.StringFlag{
+		EnvVar: "DRONE_ENVIRON_SNAPSHOT,WOODPECKER_ENVIRON_SNAPSHOT",
+		Name:   "environ-snapshot",
+		Usage:  "environment recorded on the pipelines for auditing, none, keys or values. Secret values are never recorded",
+		Value:  "keys",
+	},
+	cli.StringFlag{
+		EnvVar: "DRONE_FORK_CONFIG_POLICY,WOODPECKER_FORK_CONFIG_POLICY",
+		Name:   "fork-config-policy",
+		Usage:  "handling of pull requests from forks changing the pipeline configuration, allow, approve or strip-secrets",
+		Value:  "allow",
+	},
+	cli.StringFlag{
+		EnvVar: "DRONE_UNDEFINED_VARIABLES,WOODPECKER_UNDEFINED_VARIABLES",
+		Name:   "undefined-variables",
+		Usage:  "handling of pipelines referencing undefined variables, substituted with an empty string, ignore, warn or fail",
+		Value:  "ignore",
+	},
+	cli.StringFlag{
+		EnvVar: "DRONE_REGISTRY_MIRROR,WOODPECKER_REGISTRY_MIRROR",
+		Name:   "registry-mirror",
+		Usage:  "registry mirror the pipeline images are pulled from, e.g. mirror.internal",
+	},
+	cli.StringSliceFlag{
+		EnvVar: "DRONE_MIRRORED_REGISTRIES,WOODPECKER_MIRRORED_REGISTRIES",
+		Name:   "mirrored-registries",
+		Usage:  "registries whose images are pulled from the registry mirror, docker.io by default",
+	},
+	cli.BoolFlag{
+		EnvVar: "DRONE_TRACE_CONTEXT,WOODPECKER_TRACE_CONTEXT",
+		Name:   "trace-context",
+		Usage:  "expose the w3c trace context of the build to the pipelines as TRACEPARENT",
+	},
+	cli.BoolFlag{
+		EnvVar: "DRONE_CACHE_VOLUMES,WOODPECKER_CACHE_VOLUMES",
+		Name:   "cache-volumes",
+		Usage:  "mount the caches of trusted repositories from named volumes persisted on the agents",
+	},
+	cli.BoolFlag{
+		EnvVar: "DRONE_SKIP_LINT_TRUSTED,WOODPECKER_SKIP_LINT_TRUSTED",
+		Name:   "skip-lint-trusted",
+		Usage:  "skip linting the pipelines of trusted repositories",
+	},
+	cli.StringSliceFlag{
+		EnvVar: "DRONE_IGNORE_AUTHORS,WOODPECKER_IGNORE_AUTHORS",
+		Name:   "ignore-authors",
+		Usage:  "commit authors, e.g. bots, whose builds are skipped unless a pipeline sets allow_ignored_authors; glob patterns are supported",
+	},
 	cli.StringSliceFlag{
 		EnvVar: "DRONE_VOLUME,WOODPECKER_VOLUME",
 		Name:   "volume",
@@ -123,6 +242,32 @@ var flags = []cli.Flag{
 		EnvVar: "DRONE_ENVIRONMENT,WOODPECKER_ENVIRONMENT",
 		Name:   "environment",
 	},
+	cli.StringFlag{
+		EnvVar: "DRONE_ENVIRONMENT_FILE,WOODPECKER_ENVIRONMENT_FILE",
+		Name:   "environment-file",
+		Usage:  "file with KEY=value environment variables added to every build, read again when it changes",
+	},
+	cli.StringSliceFlag{
+		EnvVar: "DRONE_ENVIRONMENT_OVERRIDE,WOODPECKER_ENVIRONMENT_OVERRIDE",
+		Name:   "environment-override",
+		Usage:  "names of global environment variables overriding the variables of the repositories and pipelines, * for all",
+	},
+	cli.StringFlag{
+		EnvVar: "DRONE_CONFIG_SECRET,WOODPECKER_CONFIG_SECRET",
+		Name:   "config-secret",
+		Usage:  "name of the secret holding the base64 encoded, optionally gzip compressed, pipeline configuration of a repository, disabled if empty",
+	},
+	cli.Int64Flag{
+		EnvVar: "DRONE_CONFIG_SECRET_SIZE,WOODPECKER_CONFIG_SECRET_SIZE",
+		Name:   "config-secret-size",
+		Usage:  "maximum size in bytes of a decoded pipeline configuration read from a secret",
+		Value:  512 * 1024,
+	},
+	cli.StringSliceFlag{
+		EnvVar: "DRONE_CLONE_ENVIRONMENT,WOODPECKER_CLONE_ENVIRONMENT",
+		Name:   "clone-environment",
+		Usage:  "environment variables added to the clone step only, e.g. GIT_SSL_NO_VERIFY:true",
+	},
 	cli.StringSliceFlag{
 		EnvVar: "DRONE_NETWORK,WOODPECKER_NETWORK",
 		Name:   "network",
@@ -342,6 +487,48 @@ var flags = []cli.Flag{
 		Name:   "gitea-skip-verify",
 		Usage:  "gitea skip ssl verification",
 	},
+	cli.BoolFlag{
+		EnvVar: "DRONE_GITEA_SKIP_SELF_TEST,WOODPECKER_GITEA_SKIP_SELF_TEST",
+		Name:   "gitea-skip-self-test",
+		Usage:  "gitea skip checking the oauth configuration at startup",
+	},
+	cli.BoolFlag{
+		EnvVar: "DRONE_GITEA_STATUS_DEDUP,WOODPECKER_GITEA_STATUS_DEDUP",
+		Name:   "gitea-status-dedup",
+		Usage:  "gitea skip posting unchanged commit statuses",
+	},
+	cli.BoolFlag{
+		EnvVar: "DRONE_GITEA_SKIPPED_SUCCESS,WOODPECKER_GITEA_SKIPPED_SUCCESS",
+		Name:   "gitea-skipped-success",
+		Usage:  "gitea post a success status for builds with all pipelines skipped, so protected branches can merge",
+	},
+	cli.BoolFlag{
+		EnvVar: "DRONE_GITEA_INCLUDE_ARCHIVED,WOODPECKER_GITEA_INCLUDE_ARCHIVED",
+		Name:   "gitea-include-archived",
+		Usage:  "gitea list archived repositories",
+	},
+	cli.Int64Flag{
+		EnvVar: "DRONE_GITEA_MAX_CONFIG_SIZE,WOODPECKER_GITEA_MAX_CONFIG_SIZE",
+		Name:   "gitea-max-config-size",
+		Usage:  "gitea maximum pipeline config file size in bytes, 0 disables the limit",
+		Value:  1 << 20,
+	},
+	cli.IntFlag{
+		EnvVar: "DRONE_GITEA_MAX_CONFIG_FILES,WOODPECKER_GITEA_MAX_CONFIG_FILES",
+		Name:   "gitea-max-config-files",
+		Usage:  "gitea maximum number of pipeline config files in the config folder, 0 disables the limit",
+	},
+	cli.Int64Flag{
+		EnvVar: "DRONE_GITEA_MAX_ASSET_SIZE,WOODPECKER_GITEA_MAX_ASSET_SIZE",
+		Name:   "gitea-max-asset-size",
+		Usage:  "gitea maximum release asset size in bytes, 100 MiB if not set",
+	},
+	cli.Int64Flag{
+		EnvVar: "DRONE_GITEA_MAX_DIFF_SIZE,WOODPECKER_GITEA_MAX_DIFF_SIZE",
+		Name:   "gitea-max-diff-size",
+		Usage:  "gitea maximum commit diff size in bytes, 0 disables the limit",
+		Value:  1 << 20,
+	},
 	cli.BoolFlag{
 		EnvVar: "DRONE_BITBUCKET,WOODPECKER_BITBUCKET",
 		Name:   "bitbucket",
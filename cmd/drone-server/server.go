@@ -23,6 +23,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
@@ -237,6 +238,72 @@ func setupEvilGlobals(c *cli.Context, v store.Store, r remote.Remote) {
 	droneserver.Config.Pipeline.Networks = c.StringSlice("network")
 	droneserver.Config.Pipeline.Volumes = c.StringSlice("volume")
 	droneserver.Config.Pipeline.Privileged = c.StringSlice("escalate")
+	droneserver.Config.Pipeline.VerifyCommit = c.Bool("verify-commit")
+	droneserver.Config.Pipeline.CloneMaxDepth = c.Int("clone-max-depth")
+	droneserver.Config.Pipeline.CloneLFS = c.Bool("clone-lfs")
+	droneserver.Config.Pipeline.FailOnMissingSecrets = c.Bool("fail-on-missing-secrets")
+	policy, err := droneserver.ParseTriggerPolicy(c.StringSlice("trigger-policy"))
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	droneserver.Config.Pipeline.TriggerPolicy = policy
+	droneserver.Config.Pipeline.IgnoreAuthors = c.StringSlice("ignore-authors")
+	droneserver.Config.Pipeline.SkipMarkers = c.StringSlice("skip-ci-markers")
+	droneserver.Config.Pipeline.SkipEvents = c.StringSlice("skip-ci-events")
+	droneserver.Config.Pipeline.MatrixLimit = c.Int("matrix-limit")
+	droneserver.Config.Pipeline.ProcLimit = c.Int("proc-limit")
+	droneserver.Config.Pipeline.SkipLintTrusted = c.Bool("skip-lint-trusted")
+	droneserver.Config.Pipeline.CacheVolumes = c.Bool("cache-volumes")
+	droneserver.Config.Pipeline.AllowedEvents = c.StringSlice("allowed-events")
+	droneserver.Config.Pipeline.ConfigRepos = c.StringSlice("config-repos")
+	switch collision := c.String("pipeline-name-collision"); collision {
+	case droneserver.NameCollisionAllow, droneserver.NameCollisionFail, droneserver.NameCollisionDisambiguate:
+		droneserver.Config.Pipeline.NameCollision = collision
+	default:
+		logrus.Fatalf("invalid pipeline name collision %s, expected allow, fail or disambiguate", collision)
+	}
+	if pattern := c.String("pipeline-name-pattern"); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			logrus.Fatalf("invalid pipeline name pattern %s: %s", pattern, err)
+		}
+		droneserver.Config.Pipeline.NameTransform = &droneserver.NameTransform{
+			Pattern:     re,
+			Replacement: c.String("pipeline-name-replacement"),
+		}
+	}
+	switch snapshot := c.String("environ-snapshot"); snapshot {
+	case droneserver.EnvironSnapshotNone, droneserver.EnvironSnapshotKeys, droneserver.EnvironSnapshotValues:
+		droneserver.Config.Pipeline.EnvironSnapshot = snapshot
+	default:
+		logrus.Fatalf("invalid environ snapshot %s, expected none, keys or values", snapshot)
+	}
+	switch policy := c.String("fork-config-policy"); policy {
+	case droneserver.ForkConfigAllow, droneserver.ForkConfigApprove, droneserver.ForkConfigStripSecrets:
+		droneserver.Config.Pipeline.ForkConfigPolicy = policy
+	default:
+		logrus.Fatalf("invalid fork config policy %s, expected allow, approve or strip-secrets", policy)
+	}
+	switch mode := c.String("undefined-variables"); mode {
+	case droneserver.UndefinedVariablesIgnore, droneserver.UndefinedVariablesWarn, droneserver.UndefinedVariablesFail:
+		droneserver.Config.Pipeline.UndefinedVariables = mode
+	default:
+		logrus.Fatalf("invalid undefined variables mode %s, expected ignore, warn or fail", mode)
+	}
+	droneserver.Config.Pipeline.RegistryMirror = c.String("registry-mirror")
+	droneserver.Config.Pipeline.MirroredRegistries = c.StringSlice("mirrored-registries")
+	droneserver.Config.Pipeline.EnvironOverride = c.StringSlice("environment-override")
+	droneserver.Config.Pipeline.ConfigSecret = c.String("config-secret")
+	droneserver.Config.Pipeline.ConfigSecretSize = c.Int64("config-secret-size")
+	droneserver.Config.Pipeline.TraceContext = c.Bool("trace-context")
+	droneserver.Config.Pipeline.CloneEnviron = map[string]string{}
+	for _, item := range c.StringSlice("clone-environment") {
+		kvpair := strings.SplitN(item, ":", 2)
+		if len(kvpair) != 2 {
+			logrus.Fatalf("invalid clone environment %s, expected KEY:value", item)
+		}
+		droneserver.Config.Pipeline.CloneEnviron[kvpair[0]] = kvpair[1]
+	}
 
 	// prometheus
 	droneserver.Config.Prometheus.AuthToken = c.String("prometheus-auth-token")
@@ -71,6 +71,12 @@ func setupRegistryService(c *cli.Context, s store.Store) model.RegistryService {
 }
 
 func setupEnvironService(c *cli.Context, s store.Store) model.EnvironService {
+	if c.String("environment-file") != "" {
+		return environments.Combined(
+			environments.File(c.String("environment-file")),
+			environments.Filesystem(c.StringSlice("environment")),
+		)
+	}
 	return environments.Filesystem(c.StringSlice("environment"))
 }
 
@@ -119,23 +125,38 @@ func setupGogs(c *cli.Context) (remote.Remote, error) {
 func setupGitea(c *cli.Context) (remote.Remote, error) {
 	if !c.IsSet("gitea-client") {
 		return gitea.New(gitea.Opts{
-			URL:         c.String("gitea-server"),
-			Context:     c.String("gitea-context"),
-			Username:    c.String("gitea-git-username"),
-			Password:    c.String("gitea-git-password"),
-			PrivateMode: c.Bool("gitea-private-mode"),
-			SkipVerify:  c.Bool("gitea-skip-verify"),
+			URL:             c.String("gitea-server"),
+			Context:         c.String("gitea-context"),
+			Username:        c.String("gitea-git-username"),
+			Password:        c.String("gitea-git-password"),
+			PrivateMode:     c.Bool("gitea-private-mode"),
+			SkipVerify:      c.Bool("gitea-skip-verify"),
+			StatusDedup:     c.Bool("gitea-status-dedup"),
+			SkippedSuccess:  c.Bool("gitea-skipped-success"),
+			IncludeArchived: c.Bool("gitea-include-archived"),
+			MaxConfigSize:   c.Int64("gitea-max-config-size"),
+			MaxConfigFiles:  c.Int("gitea-max-config-files"),
+			MaxAssetSize:    c.Int64("gitea-max-asset-size"),
+			MaxDiffSize:     c.Int64("gitea-max-diff-size"),
 		})
 	}
 	return gitea.NewOauth(gitea.Opts{
-		URL:         c.String("gitea-server"),
-		Context:     c.String("gitea-context"),
-		Username:    c.String("gitea-git-username"),
-		Password:    c.String("gitea-git-password"),
-		Client:      c.String("gitea-client"),
-		Secret:      c.String("gitea-secret"),
-		PrivateMode: c.Bool("gitea-private-mode"),
-		SkipVerify:  c.Bool("gitea-skip-verify"),
+		URL:             c.String("gitea-server"),
+		Context:         c.String("gitea-context"),
+		Username:        c.String("gitea-git-username"),
+		Password:        c.String("gitea-git-password"),
+		Client:          c.String("gitea-client"),
+		Secret:          c.String("gitea-secret"),
+		PrivateMode:     c.Bool("gitea-private-mode"),
+		SkipVerify:      c.Bool("gitea-skip-verify"),
+		StatusDedup:     c.Bool("gitea-status-dedup"),
+		SkippedSuccess:  c.Bool("gitea-skipped-success"),
+		IncludeArchived: c.Bool("gitea-include-archived"),
+		MaxConfigSize:   c.Int64("gitea-max-config-size"),
+		MaxConfigFiles:  c.Int("gitea-max-config-files"),
+		MaxAssetSize:    c.Int64("gitea-max-asset-size"),
+		MaxDiffSize:     c.Int64("gitea-max-diff-size"),
+		SkipSelfTest:    c.Bool("gitea-skip-self-test"),
 	})
 }
 
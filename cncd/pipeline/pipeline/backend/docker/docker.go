@@ -16,6 +16,13 @@ import (
 	"docker.io/go-docker/api/types/volume"
 )
 
+// image pull policies of the steps, pulling images if not present by
+// default.
+const (
+	pullAlways = "always"
+	pullNever  = "never"
+)
+
 type engine struct {
 	client docker.APIClient
 }
@@ -74,7 +81,7 @@ func (e *engine) Exec(ctx context.Context, proc *backend.Step) error {
 
 	// automatically pull the latest version of the image if requested
 	// by the process configuration.
-	if proc.Pull {
+	if proc.Pull || proc.PullPolicy == pullAlways {
 		responseBody, perr := e.client.ImagePull(ctx, config.Image, pullopts)
 		if perr == nil {
 			defer responseBody.Close()
@@ -89,7 +96,7 @@ func (e *engine) Exec(ctx context.Context, proc *backend.Step) error {
 	}
 
 	_, err := e.client.ContainerCreate(ctx, config, hostConfig, nil, proc.Name)
-	if docker.IsErrImageNotFound(err) {
+	if docker.IsErrImageNotFound(err) && proc.PullPolicy != pullNever {
 		// automatically pull and try to re-create the image if the
 		// failure is caused because the image does not exist.
 		responseBody, perr := e.client.ImagePull(ctx, config.Image, pullopts)
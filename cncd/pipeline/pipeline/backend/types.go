@@ -7,6 +7,7 @@ type (
 		Networks []*Network `json:"networks"` // network definitions
 		Volumes  []*Volume  `json:"volumes"`  // volume definitions
 		Secrets  []*Secret  `json:"secrets"`  // secret definitions
+		Caches   []*Cache   `json:"caches"`   // cache mount hints
 	}
 
 	// Stage denotes a collection of one or more steps.
@@ -14,6 +15,9 @@ type (
 		Name  string  `json:"name,omitempty"`
 		Alias string  `json:"alias,omitempty"`
 		Steps []*Step `json:"steps,omitempty"`
+
+		// DisplayName is the name the stage is displayed with.
+		DisplayName string `json:"display_name,omitempty"`
 	}
 
 	// Step defines a container process.
@@ -22,6 +26,7 @@ type (
 		Alias        string            `json:"alias,omitempty"`
 		Image        string            `json:"image,omitempty"`
 		Pull         bool              `json:"pull,omitempty"`
+		PullPolicy   string            `json:"pull_policy,omitempty"`
 		Detached     bool              `json:"detach,omitempty"`
 		Privileged   bool              `json:"privileged,omitempty"`
 		WorkingDir   string            `json:"working_dir,omitempty"`
@@ -48,6 +53,7 @@ type (
 		NetworkMode  string            `json:"network_mode,omitempty"`
 		IpcMode      string            `json:"ipc_mode,omitempty"`
 		Sysctls      map[string]string `json:"sysctls,omitempty"`
+		Artifacts    []string          `json:"artifacts,omitempty"`
 	}
 
 	// Auth defines registry authentication credentials.
@@ -77,6 +83,15 @@ type (
 		DriverOpts map[string]string `json:"driver_opts,omitempty"`
 	}
 
+	// Cache defines a named volume persisted between builds, mounted at
+	// the path in the pipeline steps. Backends without persistent volumes
+	// may ignore it.
+	Cache struct {
+		Name string `json:"name,omitempty"`
+		Key  string `json:"key,omitempty"`
+		Path string `json:"path,omitempty"`
+	}
+
 	// Secret defines a runtime secret
 	Secret struct {
 		Name  string `json:"name,omitempty"`
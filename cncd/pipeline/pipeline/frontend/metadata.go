@@ -1,6 +1,8 @@
 package frontend
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -13,6 +15,7 @@ const (
 	EventPull   = "pull_request"
 	EventTag    = "tag"
 	EventDeploy = "deployment"
+	EventWiki   = "wiki"
 )
 
 type (
@@ -61,6 +64,7 @@ type (
 		Message      string   `json:"message,omitempty"`
 		Author       Author   `json:"author,omitempty"`
 		ChangedFiles []string `json:"changed_files,omitempty"`
+		Verified     bool     `json:"verified,omitempty"`
 	}
 
 	// Author defines runtime metadata for a commit author.
@@ -121,6 +125,7 @@ func (m *Metadata) Environ() map[string]string {
 		"CI_COMMIT_AUTHOR_NAME":        m.Curr.Commit.Author.Name,
 		"CI_COMMIT_AUTHOR_EMAIL":       m.Curr.Commit.Author.Email,
 		"CI_COMMIT_AUTHOR_AVATAR":      m.Curr.Commit.Author.Avatar,
+		"CI_COMMIT_VERIFIED":           strconv.FormatBool(m.Curr.Commit.Verified),
 		"CI_PREV_BUILD_NUMBER":         strconv.Itoa(m.Prev.Number),
 		"CI_PREV_BUILD_CREATED":        strconv.FormatInt(m.Prev.Created, 10),
 		"CI_PREV_BUILD_STARTED":        strconv.FormatInt(m.Prev.Started, 10),
@@ -235,6 +240,23 @@ func (m *Metadata) EnvironDrone() map[string]string {
 
 var pullRegexp = regexp.MustCompile("\\d+")
 
+// CacheKey returns the key of the cache of the path, derived from the branch
+// and a hash of the repository and the path. Pull requests use their own
+// key, so they never write to the cache of a branch.
+func (m *Metadata) CacheKey(path string) string {
+	branch := m.Curr.Commit.Branch
+	switch {
+	case m.Curr.Event == EventPull:
+		branch = "pull_" + pullRegexp.FindString(m.Curr.Commit.Ref)
+	case branch == "":
+		branch = m.Repo.Branch
+	}
+	sum := sha256.Sum256([]byte(m.Repo.Name + "\x00" + path))
+	return cacheKeyRegexp.ReplaceAllString(branch, "_") + "-" + hex.EncodeToString(sum[:8])
+}
+
+var cacheKeyRegexp = regexp.MustCompile("[^a-zA-Z0-9_.-]")
+
 func (m *Metadata) SetPlatform(platform string) {
 	if platform == "" {
 		platform = "linux/amd64"
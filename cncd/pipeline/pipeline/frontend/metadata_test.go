@@ -1 +1,38 @@
 package frontend
+
+import "testing"
+
+func TestCacheKey(t *testing.T) {
+	m := Metadata{
+		Repo: Repo{Name: "octocat/hello-world", Branch: "main"},
+		Curr: Build{Event: EventPush, Commit: Commit{Branch: "feature/login"}},
+	}
+	key := m.CacheKey("node_modules")
+	if key != m.CacheKey("node_modules") {
+		t.Errorf("Want a deterministic cache key")
+	}
+	if want := "feature_login-"; key[:len(want)] != want {
+		t.Errorf("Want cache key prefixed with the branch %s, got %s", want, key)
+	}
+	if key == m.CacheKey("/go/pkg/mod") {
+		t.Errorf("Want distinct cache keys for distinct paths")
+	}
+
+	other := m
+	other.Repo.Name = "octocat/spoon-knife"
+	if key == other.CacheKey("node_modules") {
+		t.Errorf("Want distinct cache keys for distinct repositories")
+	}
+
+	tag := m
+	tag.Curr = Build{Event: EventTag, Commit: Commit{Ref: "refs/tags/v1.0.0"}}
+	if got := tag.CacheKey("node_modules"); got[:5] != "main-" {
+		t.Errorf("Want the default branch cache key without a branch, got %s", got)
+	}
+
+	pull := m
+	pull.Curr = Build{Event: EventPull, Commit: Commit{Branch: "main", Ref: "refs/pull/42/head"}}
+	if got := pull.CacheKey("node_modules"); got[:8] != "pull_42-" {
+		t.Errorf("Want a pull request cache key, got %s", got)
+	}
+}
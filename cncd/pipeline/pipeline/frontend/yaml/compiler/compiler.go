@@ -2,6 +2,9 @@ package compiler
 
 import (
 	"fmt"
+	"path"
+	"sort"
+	"strconv"
 
 	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/backend"
 	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/frontend"
@@ -42,6 +45,9 @@ type Compiler struct {
 	volumes    []string
 	networks   []string
 	env        map[string]string
+	defaultEnv map[string]string
+	cloneEnv   map[string]string
+	overrides  map[string]string
 	base       string
 	path       string
 	metadata   frontend.Metadata
@@ -49,13 +55,23 @@ type Compiler struct {
 	secrets    map[string]Secret
 	cacher     Cacher
 	reslimit   ResourceLimit
+	cloneDepth int
+	cloneTags  bool
+	cloneLFS   bool
+	skipClone  bool
+	cacheVols  bool
+	mirror     string
+	mirrored   []string
 }
 
 // New creates a new Compiler with options.
 func New(opts ...Option) *Compiler {
 	compiler := &Compiler{
-		env:     map[string]string{},
-		secrets: map[string]Secret{},
+		env:        map[string]string{},
+		defaultEnv: map[string]string{},
+		cloneEnv:   map[string]string{},
+		overrides:  map[string]string{},
+		secrets:    map[string]Secret{},
 	}
 	for _, opt := range opts {
 		opt(compiler)
@@ -63,6 +79,19 @@ func New(opts ...Option) *Compiler {
 	return compiler
 }
 
+// Workspace returns the working directory the pipeline steps are compiled
+// with, taking the workspace overrides in the YAML file into account.
+func (c *Compiler) Workspace(conf *yaml.Config) string {
+	base, dir := c.base, c.path
+	if len(conf.Workspace.Base) != 0 {
+		base = conf.Workspace.Base
+	}
+	if len(conf.Workspace.Path) != 0 {
+		dir = conf.Workspace.Path
+	}
+	return path.Join(base, dir)
+}
+
 // Compile compiles the YAML configuration to the pipeline intermediate
 // representation configuration format.
 func (c *Compiler) Compile(conf *yaml.Config) *backend.Config {
@@ -87,8 +116,15 @@ func (c *Compiler) Compile(conf *yaml.Config) *backend.Config {
 		})
 	}
 
-	// create secrets for mask
-	for _, sec := range c.secrets {
+	// create secrets for mask, sorted by name to keep the compiled
+	// configuration reproducible.
+	names := make([]string, 0, len(c.secrets))
+	for name := range c.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sec := c.secrets[name]
 		config.Secrets = append(config.Secrets, &backend.Secret{
 			Name:  sec.Name,
 			Value: sec.Value,
@@ -106,11 +142,17 @@ func (c *Compiler) Compile(conf *yaml.Config) *backend.Config {
 	}
 
 	// add default clone step
-	if c.local == false && len(conf.Clone.Containers) == 0 && !conf.SkipClone {
+	if c.local == false && len(conf.Clone.Containers) == 0 && !conf.SkipClone && !c.skipClone {
 		container := &yaml.Container{
 			Name:  "clone",
 			Image: "plugins/git:latest",
-			Vargs: map[string]interface{}{"depth": "0"},
+			Vargs: map[string]interface{}{"depth": strconv.Itoa(c.cloneDepth)},
+		}
+		if c.cloneTags {
+			container.Vargs["tags"] = true
+		}
+		if c.cloneLFS {
+			container.Vargs["lfs"] = true
 		}
 		switch c.metadata.Sys.Arch {
 		case "linux/arm":
@@ -127,7 +169,7 @@ func (c *Compiler) Compile(conf *yaml.Config) *backend.Config {
 		stage.Steps = append(stage.Steps, step)
 
 		config.Stages = append(config.Stages, stage)
-	} else if c.local == false && !conf.SkipClone {
+	} else if c.local == false && !conf.SkipClone && !c.skipClone {
 		for i, container := range conf.Clone.Containers {
 			if !container.Constraints.Match(c.metadata) {
 				continue
@@ -145,6 +187,7 @@ func (c *Compiler) Compile(conf *yaml.Config) *backend.Config {
 	}
 
 	c.setupCache(conf, config)
+	mounts := c.setupCacheMounts(conf, config)
 
 	// add services steps
 	if len(conf.Services.Containers) != 0 {
@@ -158,11 +201,15 @@ func (c *Compiler) Compile(conf *yaml.Config) *backend.Config {
 			}
 
 			name := fmt.Sprintf("%s_services_%d", c.prefix, i)
-			step := c.createProcess(name, container, "services")
+			step := c.createProcess(name, withEnviron(container, conf.Environment), "services")
 			stage.Steps = append(stage.Steps, step)
+		}
 
+		// services excluded by their constraints, e.g. for a matrix
+		// axis, do not leave an empty stage behind.
+		if len(stage.Steps) != 0 {
+			config.Stages = append(config.Stages, stage)
 		}
-		config.Stages = append(config.Stages, stage)
 	}
 
 	// add pipeline steps. 1 pipeline step per stage, at the moment
@@ -184,11 +231,13 @@ func (c *Compiler) Compile(conf *yaml.Config) *backend.Config {
 			stage = new(backend.Stage)
 			stage.Name = fmt.Sprintf("%s_stage_%v", c.prefix, i)
 			stage.Alias = container.Name
+			stage.DisplayName = stageName(conf, container)
 			config.Stages = append(config.Stages, stage)
 		}
 
 		name := fmt.Sprintf("%s_step_%d", c.prefix, i)
-		step := c.createProcess(name, container, "pipeline")
+		step := c.createProcess(name, withEnviron(container, conf.Environment), "pipeline")
+		step.Volumes = append(step.Volumes, mounts...)
 		stage.Steps = append(stage.Steps, step)
 	}
 
@@ -197,6 +246,20 @@ func (c *Compiler) Compile(conf *yaml.Config) *backend.Config {
 	return config
 }
 
+// stageName returns the display name of the stage the step starts, the name
+// set in the stages of the pipeline or else the group of the step, or its
+// name if it has no group.
+func stageName(conf *yaml.Config, container *yaml.Container) string {
+	key := container.Group
+	if key == "" {
+		key = container.Name
+	}
+	if name, ok := conf.Stages[key]; ok && name != "" {
+		return name
+	}
+	return key
+}
+
 func (c *Compiler) setupCache(conf *yaml.Config, ir *backend.Config) {
 	if c.local || len(conf.Cache) == 0 || c.cacher == nil {
 		return
@@ -214,6 +277,31 @@ func (c *Compiler) setupCache(conf *yaml.Config, ir *backend.Config) {
 	ir.Stages = append(ir.Stages, stage)
 }
 
+// setupCacheMounts adds a cache hint for each cache path if cache volumes
+// are enabled and no cacher restores and rebuilds the caches, and returns
+// the mounts of the caches. Relative paths are relative to the workspace.
+func (c *Compiler) setupCacheMounts(conf *yaml.Config, ir *backend.Config) []string {
+	if c.local || len(conf.Cache) == 0 || c.cacher != nil || !c.cacheVols {
+		return nil
+	}
+	var mounts []string
+	for _, p := range conf.Cache {
+		dest := p
+		if !path.IsAbs(p) {
+			dest = path.Join(c.base, c.path, p)
+		}
+		key := c.metadata.CacheKey(p)
+		cache := &backend.Cache{
+			Name: "woodpecker_cache_" + key,
+			Key:  key,
+			Path: dest,
+		}
+		ir.Caches = append(ir.Caches, cache)
+		mounts = append(mounts, cache.Name+":"+cache.Path)
+	}
+	return mounts
+}
+
 func (c *Compiler) setupCacheRebuild(conf *yaml.Config, ir *backend.Config) {
 	if c.local || len(conf.Cache) == 0 || c.metadata.Curr.Event != "push" || c.cacher == nil {
 		return
@@ -1 +1,435 @@
 package compiler
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/backend"
+	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/frontend"
+	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/frontend/yaml"
+)
+
+func TestCompileCloneDepth(t *testing.T) {
+	conf, err := yaml.ParseString(`
+pipeline:
+  build:
+    image: golang
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ir := New(WithCloneDepth(0)).Compile(conf)
+	clone := ir.Stages[0].Steps[0]
+	if clone.Environment["PLUGIN_DEPTH"] != "0" {
+		t.Errorf("Want clone depth 0 for a full clone, got %q", clone.Environment["PLUGIN_DEPTH"])
+	}
+	if _, ok := clone.Environment["PLUGIN_TAGS"]; ok {
+		t.Errorf("Tags must not be fetched unless requested")
+	}
+
+	ir = New(WithCloneDepth(50), WithCloneTags(true)).Compile(conf)
+	clone = ir.Stages[0].Steps[0]
+	if clone.Environment["PLUGIN_DEPTH"] != "50" {
+		t.Errorf("Want clone depth 50, got %q", clone.Environment["PLUGIN_DEPTH"])
+	}
+	if clone.Environment["PLUGIN_TAGS"] != "true" {
+		t.Errorf("Want tags fetched when requested")
+	}
+}
+
+func TestCompileCloneEnviron(t *testing.T) {
+	conf, err := yaml.ParseString(`
+pipeline:
+  build:
+    image: golang
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ir := New(WithCloneEnviron(map[string]string{"GIT_SSL_NO_VERIFY": "true"})).Compile(conf)
+	clone := ir.Stages[0].Steps[0]
+	if clone.Environment["GIT_SSL_NO_VERIFY"] != "true" {
+		t.Errorf("Want the clone environment in the clone step")
+	}
+	build := ir.Stages[1].Steps[0]
+	if _, ok := build.Environment["GIT_SSL_NO_VERIFY"]; ok {
+		t.Errorf("Want the clone environment only in the clone step")
+	}
+}
+
+func TestCompilePipelineEnviron(t *testing.T) {
+	conf, err := yaml.ParseString(`
+environment:
+  GOOS: linux
+  REGION: eu
+  CI_REPO: octocat/spoon-knife
+pipeline:
+  build:
+    image: golang
+  deploy:
+    image: alpine
+    environment:
+      REGION: us
+services:
+  database:
+    image: mysql
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ir := New(
+		WithDefaultEnviron(map[string]string{"GOOS": "windows", "PROXY": "http://proxy"}),
+		WithEnviron(map[string]string{"CI_REPO": "octocat/hello-world"}),
+	).Compile(conf)
+
+	services := ir.Stages[1].Steps[0]
+	build := ir.Stages[2].Steps[0]
+	deploy := ir.Stages[3].Steps[0]
+	for _, step := range []*backend.Step{services, build, deploy} {
+		if got := step.Environment["GOOS"]; got != "linux" {
+			t.Errorf("Want the pipeline environment to override the defaults in %s, got GOOS %q", step.Alias, got)
+		}
+		if got := step.Environment["PROXY"]; got != "http://proxy" {
+			t.Errorf("Want the defaults in %s, got PROXY %q", step.Alias, got)
+		}
+		if got := step.Environment["CI_REPO"]; got != "octocat/hello-world" {
+			t.Errorf("Want the compiler environment to override the pipeline environment in %s, got CI_REPO %q", step.Alias, got)
+		}
+	}
+	if got := build.Environment["REGION"]; got != "eu" {
+		t.Errorf("Want the pipeline environment in the steps, got REGION %q", got)
+	}
+	if got := deploy.Environment["REGION"]; got != "us" {
+		t.Errorf("Want the step environment to override the pipeline environment, got REGION %q", got)
+	}
+	if _, ok := ir.Stages[0].Steps[0].Environment["REGION"]; ok {
+		t.Errorf("Want the pipeline environment not in the clone step")
+	}
+	if _, ok := conf.Pipeline.Containers[0].Environment["REGION"]; ok {
+		t.Errorf("Want the parsed configuration unchanged")
+	}
+}
+
+func TestCompilerWorkspace(t *testing.T) {
+	c := New(WithWorkspace("/drone", "src/github.com/octocat/hello-world"))
+
+	if got := c.Workspace(&yaml.Config{}); got != "/drone/src/github.com/octocat/hello-world" {
+		t.Errorf("Want the default workspace, got %s", got)
+	}
+
+	conf := &yaml.Config{}
+	conf.Workspace.Base = "/go"
+	if got := c.Workspace(conf); got != "/go/src/github.com/octocat/hello-world" {
+		t.Errorf("Want the workspace base overridden, got %s", got)
+	}
+}
+
+func TestCompileStatus(t *testing.T) {
+	conf, err := yaml.ParseString(`
+pipeline:
+  build:
+    image: golang
+    commands: [ go build ]
+  logs:
+    image: plugins/s3
+    when:
+      status: [ failure ]
+  notify:
+    image: plugins/slack
+    when:
+      status: [ success, failure ]
+  cleanup:
+    image: alpine
+    when:
+      status: { exclude: [ success ] }
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][2]bool{
+		"build":   {true, false},
+		"logs":    {false, true},
+		"notify":  {true, true},
+		"cleanup": {false, true},
+	}
+	ir := New().Compile(conf)
+	for _, stage := range ir.Stages {
+		for _, step := range stage.Steps {
+			gate, ok := want[step.Alias]
+			if !ok {
+				continue
+			}
+			if step.OnSuccess != gate[0] || step.OnFailure != gate[1] {
+				t.Errorf("Want step %s to run on success %v and on failure %v, got %v and %v",
+					step.Alias, gate[0], gate[1], step.OnSuccess, step.OnFailure)
+			}
+			delete(want, step.Alias)
+		}
+	}
+	for name := range want {
+		t.Errorf("Want step %s compiled", name)
+	}
+}
+
+func TestCompileCacheMounts(t *testing.T) {
+	conf, err := yaml.ParseString(`
+cache:
+  - node_modules
+  - /go/pkg/mod
+pipeline:
+  build:
+    image: golang
+  test:
+    image: golang
+services:
+  database:
+    image: postgres
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metadata := frontend.Metadata{
+		Repo: frontend.Repo{Name: "octocat/hello-world"},
+		Curr: frontend.Build{Event: "push", Commit: frontend.Commit{Branch: "main"}},
+	}
+	ir := New(
+		WithMetadata(metadata),
+		WithWorkspace("/drone", "src"),
+		WithCacheVolumes(true),
+	).Compile(conf)
+	if len(ir.Caches) != 2 {
+		t.Fatalf("Want 2 caches, got %d", len(ir.Caches))
+	}
+	if got, want := ir.Caches[0].Path, "/drone/src/node_modules"; got != want {
+		t.Errorf("Want relative cache path %s in the workspace, got %s", want, got)
+	}
+	if got, want := ir.Caches[1].Path, "/go/pkg/mod"; got != want {
+		t.Errorf("Want absolute cache path %s, got %s", want, got)
+	}
+	if got, want := ir.Caches[0].Key, metadata.CacheKey("node_modules"); got != want {
+		t.Errorf("Want cache key %s, got %s", want, got)
+	}
+
+	for _, stage := range ir.Stages {
+		for _, step := range stage.Steps {
+			mounted := 0
+			for _, volume := range step.Volumes {
+				for _, cache := range ir.Caches {
+					if volume == cache.Name+":"+cache.Path {
+						mounted++
+					}
+				}
+			}
+			switch step.Alias {
+			case "build", "test":
+				if mounted != 2 {
+					t.Errorf("Want caches mounted in step %s", step.Alias)
+				}
+			default:
+				if mounted != 0 {
+					t.Errorf("Want no caches mounted in step %s", step.Alias)
+				}
+			}
+		}
+	}
+
+	ir = New(WithMetadata(metadata), WithCacheVolumes(true), WithVolumeCacher("/var/lib/cache")).Compile(conf)
+	if len(ir.Caches) != 0 {
+		t.Errorf("Want no cache hints when a cacher rebuilds the caches")
+	}
+	ir = New(WithMetadata(metadata)).Compile(conf)
+	if len(ir.Caches) != 0 {
+		t.Errorf("Want no cache hints unless cache volumes are enabled")
+	}
+}
+
+func TestCompilePullPolicy(t *testing.T) {
+	conf, err := yaml.ParseString(`
+pipeline:
+  default:
+    image: golang
+  always:
+    image: golang
+    pull: always
+  never:
+    image: golang
+    pull: never
+  legacy:
+    image: golang
+    pull: true
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"clone":   "if-not-present",
+		"default": "if-not-present",
+		"always":  "always",
+		"never":   "never",
+		"legacy":  "always",
+	}
+	ir := New().Compile(conf)
+	for _, stage := range ir.Stages {
+		for _, step := range stage.Steps {
+			if step.PullPolicy != want[step.Alias] {
+				t.Errorf("Want step %s pull policy %s, got %s", step.Alias, want[step.Alias], step.PullPolicy)
+			}
+			if step.Pull != (step.PullPolicy == "always") {
+				t.Errorf("Want step %s pulled only if the policy is always", step.Alias)
+			}
+		}
+	}
+
+	if _, err := yaml.ParseString(`
+pipeline:
+  build:
+    image: golang
+    pull: sometimes
+`); err == nil {
+		t.Errorf("Want an error for an invalid pull policy")
+	}
+}
+
+func TestCompileSecretTarget(t *testing.T) {
+	conf, err := yaml.ParseString(`
+pipeline:
+  publish:
+    image: plugins/github-release
+    secrets:
+      - source: my_token
+        target: github_token
+      - deploy_key
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ir := New(WithSecret(
+		Secret{Name: "my_token", Value: "token"},
+		Secret{Name: "deploy_key", Value: "key"},
+	)).Compile(conf)
+	publish := ir.Stages[1].Steps[0]
+	if publish.Environment["GITHUB_TOKEN"] != "token" {
+		t.Errorf("Want the secret exposed as its target name")
+	}
+	if _, ok := publish.Environment["MY_TOKEN"]; ok {
+		t.Errorf("Want the renamed secret not exposed as its source name")
+	}
+	if publish.Environment["DEPLOY_KEY"] != "key" {
+		t.Errorf("Want the secret exposed as its own name by default")
+	}
+}
+
+func TestCompileCloneLFS(t *testing.T) {
+	conf, err := yaml.ParseString(`
+pipeline:
+  build:
+    image: golang
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ir := New().Compile(conf)
+	if _, ok := ir.Stages[0].Steps[0].Environment["PLUGIN_LFS"]; ok {
+		t.Errorf("LFS objects must not be fetched unless requested")
+	}
+
+	ir = New(WithCloneLFS(true), WithCloneNetrc("octocat", "password", "github.com")).Compile(conf)
+	clone := ir.Stages[0].Steps[0]
+	if clone.Environment["PLUGIN_LFS"] != "true" {
+		t.Errorf("Want LFS objects fetched when requested")
+	}
+	if clone.Environment["CI_NETRC_MACHINE"] != "github.com" {
+		t.Errorf("Want the netrc in the clone step")
+	}
+	if _, ok := ir.Stages[1].Steps[0].Environment["CI_NETRC_PASSWORD"]; ok {
+		t.Errorf("Want the clone netrc only in the clone step")
+	}
+}
+
+func TestCompileRegistryMirror(t *testing.T) {
+	conf, err := yaml.ParseString(`
+pipeline:
+  build:
+    image: golang:1.20
+    secrets: [ token ]
+  publish:
+    image: quay.io/octocat/publish
+
+services:
+  database:
+    image: mysql@sha256:a8e5bb6e8e1d4a4b2f3b7e9e9b8f1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ir := New(
+		WithRegistryMirror("mirror.internal"),
+		WithRegistry(Registry{Hostname: "mirror.internal", Username: "octocat", Password: "password"}),
+		WithSecret(Secret{Name: "token", Value: "secret", Match: []string{"golang"}}),
+	).Compile(conf)
+
+	images := map[string]string{}
+	for _, stage := range ir.Stages {
+		for _, step := range stage.Steps {
+			images[step.Alias] = step.Image
+		}
+	}
+	want := map[string]string{
+		"clone":    "mirror.internal/plugins/git:latest",
+		"database": "mirror.internal/mysql@sha256:a8e5bb6e8e1d4a4b2f3b7e9e9b8f1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b",
+		"build":    "mirror.internal/golang:1.20",
+		"publish":  "quay.io/octocat/publish:latest",
+	}
+	if !reflect.DeepEqual(images, want) {
+		t.Errorf("Want the images pulled from the mirror %v, got %v", want, images)
+	}
+
+	build := ir.Stages[2].Steps[0]
+	if build.AuthConfig.Username != "octocat" {
+		t.Errorf("Want the credentials of the mirror")
+	}
+	if build.Environment["TOKEN"] != "secret" {
+		t.Errorf("Want the secrets matched against the image of the yaml")
+	}
+}
+
+func TestCompileStageNames(t *testing.T) {
+	conf, err := yaml.ParseString(`
+pipeline:
+  lint:
+    image: golang
+  backend:
+    image: golang
+    group: test
+  frontend:
+    image: node
+    group: test
+  integration:
+    image: golang
+    group: e2e
+stages:
+  test: Unit tests
+  lint: Lint
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ir := New().Compile(conf)
+	var names []string
+	for _, stage := range ir.Stages[1:] {
+		names = append(names, stage.DisplayName)
+	}
+	if want := []string{"Lint", "Unit tests", "e2e"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Want the stage names %v, got %v", want, names)
+	}
+}
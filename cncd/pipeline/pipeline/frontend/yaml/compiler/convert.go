@@ -7,8 +7,27 @@ import (
 
 	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/backend"
 	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/frontend/yaml"
+	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/frontend/yaml/types"
 )
 
+// withEnviron returns a copy of the container with the pipeline environment
+// added, the container environment takes precedence.
+func withEnviron(container *yaml.Container, environ map[string]string) *yaml.Container {
+	if len(environ) == 0 {
+		return container
+	}
+	merged := map[string]string{}
+	for k, v := range environ {
+		merged[k] = v
+	}
+	for k, v := range container.Environment {
+		merged[k] = v
+	}
+	copied := *container
+	copied.Environment = merged
+	return &copied
+}
+
 func (c *Compiler) createProcess(name string, container *yaml.Container, section string) *backend.Step {
 	var (
 		detached   bool
@@ -45,8 +64,17 @@ func (c *Compiler) createProcess(name string, container *yaml.Container, section
 		volumes = append(volumes, volume.String())
 	}
 
-	// append default environment variables
+	// append default environment variables, the container environment
+	// overrides the defaults but not the compiler environment.
 	environment := map[string]string{}
+	for k, v := range c.defaultEnv {
+		switch v {
+		case "", "0", "false":
+			continue
+		default:
+			environment[k] = v
+		}
+	}
 	for k, v := range container.Environment {
 		environment[k] = v
 	}
@@ -58,6 +86,14 @@ func (c *Compiler) createProcess(name string, container *yaml.Container, section
 			environment[k] = v
 		}
 	}
+	if section == "clone" {
+		for k, v := range c.cloneEnv {
+			environment[k] = v
+		}
+	}
+	for k, v := range c.overrides {
+		environment[k] = v
+	}
 
 	environment["CI_WORKSPACE"] = path.Join(c.base, c.path)
 	// TODO: This is here for backward compatibility and will eventually be removed.
@@ -68,24 +104,32 @@ func (c *Compiler) createProcess(name string, container *yaml.Container, section
 	}
 
 	if detached == false || len(container.Commands) != 0 {
-		workingdir = path.Join(c.base, c.path)
+		workingdir = path.Join(c.base, c.path, container.Directory)
 	}
 
 	if detached == false {
 		paramsToEnv(container.Vargs, environment)
 	}
 
-	if len(container.Commands) != 0 {
+	commands := container.Commands
+	if section == "pipeline" {
+		outputs := c.outputCommands(container, environment)
+		if len(commands) != 0 {
+			commands = append(outputs, commands...)
+		}
+	}
+
+	if len(commands) != 0 {
 		if c.metadata.Sys.Arch == "windows/amd64" {
 			entrypoint = []string{"powershell", "-noprofile", "-noninteractive", "-command"}
 			command = []string{"[System.Text.Encoding]::UTF8.GetString([System.Convert]::FromBase64String($Env:CI_SCRIPT)) | iex"}
-			environment["CI_SCRIPT"] = generateScriptWindows(container.Commands)
+			environment["CI_SCRIPT"] = generateScriptWindows(commands)
 			environment["HOME"] = "c:\\root"
 			environment["SHELL"] = "powershell.exe"
 		} else {
 			entrypoint = []string{"/bin/sh", "-c"}
 			command = []string{"echo $CI_SCRIPT | base64 -d | /bin/sh -e"}
-			environment["CI_SCRIPT"] = generateScriptPosix(container.Commands)
+			environment["CI_SCRIPT"] = generateScriptPosix(commands)
 			environment["HOME"] = "/root"
 			environment["SHELL"] = "/bin/sh"
 		}
@@ -97,13 +141,17 @@ func (c *Compiler) createProcess(name string, container *yaml.Container, section
 		command = []string{}
 	}
 
+	// secrets and escalation match the image of the yaml, the credentials
+	// are those of the registry the image is pulled from.
+	pulled := mirrorImage(image, c.mirror, c.mirrored)
+
 	authConfig := backend.Auth{
 		Username: container.AuthConfig.Username,
 		Password: container.AuthConfig.Password,
 		Email:    container.AuthConfig.Email,
 	}
 	for _, registry := range c.registries {
-		if matchHostname(image, registry.Hostname) {
+		if matchHostname(pulled, registry.Hostname) {
 			authConfig.Username = registry.Username
 			authConfig.Password = registry.Password
 			authConfig.Email = registry.Email
@@ -134,6 +182,12 @@ func (c *Compiler) createProcess(name string, container *yaml.Container, section
 	if c.reslimit.CPUQuota != 0 {
 		cpuQuota = c.reslimit.CPUQuota
 	}
+	// artifacts are declared relative to the workspace
+	var artifacts []string
+	for _, artifact := range container.Artifacts {
+		artifacts = append(artifacts, path.Join(c.base, c.path, artifact))
+	}
+
 	cpuShares := int64(container.CPUShares)
 	if c.reslimit.CPUShares != 0 {
 		cpuShares = c.reslimit.CPUShares
@@ -146,8 +200,9 @@ func (c *Compiler) createProcess(name string, container *yaml.Container, section
 	return &backend.Step{
 		Name:         name,
 		Alias:        container.Name,
-		Image:        image,
-		Pull:         container.Pull,
+		Image:        pulled,
+		Pull:         container.Pull.Policy() == types.PullAlways,
+		PullPolicy:   container.Pull.Policy(),
 		Detached:     detached,
 		Privileged:   privileged,
 		WorkingDir:   workingdir,
@@ -170,6 +225,7 @@ func (c *Compiler) createProcess(name string, container *yaml.Container, section
 		CPUShares:    cpuShares,
 		CPUSet:       cpuSet,
 		AuthConfig:   authConfig,
+		Artifacts:    artifacts,
 		OnSuccess:    container.Constraints.Status.Match("success"),
 		OnFailure: (len(container.Constraints.Status.Include)+
 			len(container.Constraints.Status.Exclude) != 0) &&
@@ -1,6 +1,10 @@
 package compiler
 
-import "github.com/docker/distribution/reference"
+import (
+	"strings"
+
+	"github.com/docker/distribution/reference"
+)
 
 // trimImage returns the short image name without tag.
 func trimImage(name string) string {
@@ -59,3 +63,47 @@ func matchHostname(image, hostname string) bool {
 	}
 	return reference.Domain(named) == hostname
 }
+
+// mirrorImage returns the image pulled from the registry mirror if it is
+// hosted on one of the registries, docker.io if none are given, e.g.
+// golang:1.20 becomes mirror.internal/golang:1.20. The tag and the digest
+// of the image are preserved.
+func mirrorImage(image, mirror string, registries []string) string {
+	if mirror == "" {
+		return image
+	}
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return image
+	}
+	if len(registries) == 0 {
+		registries = []string{"docker.io"}
+	}
+	domain := reference.Domain(named)
+	mirrored := false
+	for _, registry := range registries {
+		if registry == "index.docker.io" {
+			registry = "docker.io"
+		}
+		if registry == domain {
+			mirrored = true
+			break
+		}
+	}
+	if !mirrored {
+		return image
+	}
+
+	path := reference.Path(named)
+	if domain == "docker.io" {
+		path = strings.TrimPrefix(path, "library/")
+	}
+	image = strings.TrimSuffix(mirror, "/") + "/" + path
+	if tagged, ok := named.(reference.Tagged); ok {
+		image += ":" + tagged.Tag()
+	}
+	if digested, ok := named.(reference.Digested); ok {
+		image += "@" + digested.Digest().String()
+	}
+	return image
+}
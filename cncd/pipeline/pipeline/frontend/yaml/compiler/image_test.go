@@ -256,3 +256,66 @@ func Test_matchHostname(t *testing.T) {
 		}
 	}
 }
+
+func Test_mirrorImage(t *testing.T) {
+	digest := "sha256:a8e5bb6e8e1d4a4b2f3b7e9e9b8f1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b"
+	testdata := []struct {
+		image      string
+		mirror     string
+		registries []string
+		want       string
+	}{
+		{
+			image:  "golang:1.20",
+			mirror: "mirror.internal",
+			want:   "mirror.internal/golang:1.20",
+		},
+		{
+			image:  "docker.io/library/golang:1.20",
+			mirror: "mirror.internal/dockerhub/",
+			want:   "mirror.internal/dockerhub/golang:1.20",
+		},
+		{
+			image:  "plugins/docker",
+			mirror: "mirror.internal",
+			want:   "mirror.internal/plugins/docker",
+		},
+		{
+			image:  "golang@" + digest,
+			mirror: "mirror.internal",
+			want:   "mirror.internal/golang@" + digest,
+		},
+		{
+			image:  "golang:1.20@" + digest,
+			mirror: "mirror.internal",
+			want:   "mirror.internal/golang:1.20@" + digest,
+		},
+		{
+			image:  "quay.io/octocat/hello:1.0",
+			mirror: "mirror.internal",
+			want:   "quay.io/octocat/hello:1.0",
+		},
+		{
+			image:      "quay.io/octocat/hello:1.0",
+			mirror:     "mirror.internal",
+			registries: []string{"index.docker.io", "quay.io"},
+			want:       "mirror.internal/octocat/hello:1.0",
+		},
+		{
+			image:      "golang:1.20",
+			mirror:     "mirror.internal",
+			registries: []string{"quay.io"},
+			want:       "golang:1.20",
+		},
+		{
+			image: "golang:1.20",
+			want:  "golang:1.20",
+		},
+	}
+	for _, test := range testdata {
+		got := mirrorImage(test.image, test.mirror, test.registries)
+		if got != test.want {
+			t.Errorf("Want image %q mirrored as %q, got %q", test.image, test.want, got)
+		}
+	}
+}
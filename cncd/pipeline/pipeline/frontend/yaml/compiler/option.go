@@ -71,19 +71,27 @@ func WithMetadata(metadata frontend.Metadata) Option {
 // WithNetrc configures the compiler with netrc authentication
 // credentials added by default to every container in the pipeline.
 func WithNetrc(username, password, machine string) Option {
-	return WithEnviron(
-		map[string]string{
-			"CI_NETRC_USERNAME": username,
-			"CI_NETRC_PASSWORD": password,
-			"CI_NETRC_MACHINE":  machine,
-
-			// TODO: This is present for backward compatibility and should
-			// be removed in a future version.
-			"DRONE_NETRC_USERNAME": username,
-			"DRONE_NETRC_PASSWORD": password,
-			"DRONE_NETRC_MACHINE":  machine,
-		},
-	)
+	return WithEnviron(netrcEnviron(username, password, machine))
+}
+
+// WithCloneNetrc configures the compiler with netrc authentication
+// credentials added to the clone steps only.
+func WithCloneNetrc(username, password, machine string) Option {
+	return WithCloneEnviron(netrcEnviron(username, password, machine))
+}
+
+func netrcEnviron(username, password, machine string) map[string]string {
+	return map[string]string{
+		"CI_NETRC_USERNAME": username,
+		"CI_NETRC_PASSWORD": password,
+		"CI_NETRC_MACHINE":  machine,
+
+		// TODO: This is present for backward compatibility and should
+		// be removed in a future version.
+		"DRONE_NETRC_USERNAME": username,
+		"DRONE_NETRC_PASSWORD": password,
+		"DRONE_NETRC_MACHINE":  machine,
+	}
 }
 
 // WithWorkspace configures the compiler with the workspace base
@@ -109,6 +117,46 @@ func WithWorkspaceFromURL(base, link string) Option {
 	return WithWorkspace(base, path)
 }
 
+// WithCloneDepth configures the compiler with the clone depth of the
+// default clone step. A zero depth clones the full history.
+func WithCloneDepth(depth int) Option {
+	return func(compiler *Compiler) {
+		compiler.cloneDepth = depth
+	}
+}
+
+// WithCloneTags configures the default clone step to fetch tags.
+func WithCloneTags(tags bool) Option {
+	return func(compiler *Compiler) {
+		compiler.cloneTags = tags
+	}
+}
+
+// WithCloneLFS configures the default clone step to fetch the Git LFS
+// objects.
+func WithCloneLFS(lfs bool) Option {
+	return func(compiler *Compiler) {
+		compiler.cloneLFS = lfs
+	}
+}
+
+// WithSkipClone configures the compiler to omit the clone steps, e.g. if
+// the clone settings of the pipeline exclude the build.
+func WithSkipClone(skip bool) Option {
+	return func(compiler *Compiler) {
+		compiler.skipClone = skip
+	}
+}
+
+// WithRegistryMirror configures the compiler to pull the images hosted on
+// the registries, docker.io if none are given, from the registry mirror.
+func WithRegistryMirror(mirror string, registries ...string) Option {
+	return func(compiler *Compiler) {
+		compiler.mirror = mirror
+		compiler.mirrored = registries
+	}
+}
+
 // WithEscalated configures the compiler to automatically execute
 // images as privileged containers if the match the given list.
 func WithEscalated(images ...string) Option {
@@ -135,8 +183,22 @@ func WithLocal(local bool) Option {
 	}
 }
 
+// WithDefaultEnviron configures the compiler with environment variables
+// added to every container in the pipeline, unless the pipeline or the
+// container sets a variable of the same name. Options are merged, a later
+// option overrides the variables of the same name of an earlier one.
+func WithDefaultEnviron(env map[string]string) Option {
+	return func(compiler *Compiler) {
+		for k, v := range env {
+			compiler.defaultEnv[k] = v
+		}
+	}
+}
+
 // WithEnviron configures the compiler with environment variables
-// added by default to every container in the pipeline.
+// added by default to every container in the pipeline, overriding the
+// variables the pipeline or the container sets. Options are merged, a later
+// option overrides the variables of the same name of an earlier one.
 func WithEnviron(env map[string]string) Option {
 	return func(compiler *Compiler) {
 		for k, v := range env {
@@ -145,6 +207,29 @@ func WithEnviron(env map[string]string) Option {
 	}
 }
 
+// WithOverrideEnviron configures the compiler with environment variables
+// that replace all other variables of the same name in every container,
+// including the clone steps. Unlike WithEnviron, empty and false values
+// are set as well.
+func WithOverrideEnviron(env map[string]string) Option {
+	return func(compiler *Compiler) {
+		for k, v := range env {
+			compiler.overrides[k] = v
+		}
+	}
+}
+
+// WithCloneEnviron configures the compiler with environment variables
+// added to the clone steps only, e.g. proxy settings needed to fetch the
+// source code.
+func WithCloneEnviron(env map[string]string) Option {
+	return func(compiler *Compiler) {
+		for k, v := range env {
+			compiler.cloneEnv[k] = v
+		}
+	}
+}
+
 // WithCacher configures the compiler with default cache settings.
 func WithCacher(cacher Cacher) Option {
 	return func(compiler *Compiler) {
@@ -152,6 +237,14 @@ func WithCacher(cacher Cacher) Option {
 	}
 }
 
+// WithCacheVolumes configures the compiler to mount the caches from named
+// volumes persisted on the agent, if no cacher is configured.
+func WithCacheVolumes(enabled bool) Option {
+	return func(compiler *Compiler) {
+		compiler.cacheVols = enabled
+	}
+}
+
 // WithVolumeCacher configures the compiler with default local volume
 // caching enabled.
 func WithVolumeCacher(base string) Option {
@@ -205,6 +205,48 @@ func TestWithEnviron(t *testing.T) {
 	}
 }
 
+func TestWithEnvironMerged(t *testing.T) {
+	compiler := New(
+		WithEnviron(map[string]string{"RACK_ENV": "development", "SHOW": "true"}),
+		WithEnviron(map[string]string{"RACK_ENV": "production"}),
+	)
+	if compiler.env["RACK_ENV"] != "production" {
+		t.Errorf("WithEnviron should override the variables of an earlier option")
+	}
+	if compiler.env["SHOW"] != "true" {
+		t.Errorf("WithEnviron should keep the other variables of an earlier option")
+	}
+}
+
+func TestWithOverrideEnviron(t *testing.T) {
+	compiler := New(
+		WithOverrideEnviron(
+			map[string]string{
+				"HTTP_PROXY": "http://proxy",
+			},
+		),
+	)
+	if compiler.overrides["HTTP_PROXY"] != "http://proxy" {
+		t.Errorf("WithOverrideEnviron should set HTTP_PROXY")
+	}
+}
+
+func TestWithCloneEnviron(t *testing.T) {
+	compiler := New(
+		WithCloneEnviron(
+			map[string]string{
+				"HTTPS_PROXY": "http://proxy:3128",
+			},
+		),
+	)
+	if compiler.cloneEnv["HTTPS_PROXY"] != "http://proxy:3128" {
+		t.Errorf("WithCloneEnviron should set HTTPS_PROXY")
+	}
+	if _, ok := compiler.env["HTTPS_PROXY"]; ok {
+		t.Errorf("WithCloneEnviron should not set HTTPS_PROXY for every container")
+	}
+}
+
 func TestGetenv(t *testing.T) {
 	defer func() {
 		os.Unsetenv("X_TEST_FOO")
@@ -259,3 +301,20 @@ func TestWithS3Cacher(t *testing.T) {
 		t.Errorf("Expected s3 cacher with secret key %s, got %s", want, got)
 	}
 }
+
+func TestWithCloneDepth(t *testing.T) {
+	compiler := New(
+		WithCloneDepth(50),
+		WithCloneTags(true),
+		WithCloneLFS(true),
+	)
+	if compiler.cloneDepth != 50 {
+		t.Errorf("WithCloneDepth must set the clone depth")
+	}
+	if !compiler.cloneTags {
+		t.Errorf("WithCloneTags must enable fetching tags")
+	}
+	if !compiler.cloneLFS {
+		t.Errorf("WithCloneLFS must enable fetching lfs objects")
+	}
+}
@@ -0,0 +1,55 @@
+package compiler
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/frontend/yaml"
+)
+
+// outputsDir returns the directory in the workspace base where the step
+// writes its declared outputs, one file per output.
+func (c *Compiler) outputsDir(step string) string {
+	return path.Join(c.base, ".outputs", step)
+}
+
+// outputCommands returns the commands creating the outputs directory of the
+// step and exporting the outputs it references from previous steps. The
+// output references are removed from the step environment.
+func (c *Compiler) outputCommands(container *yaml.Container, environment map[string]string) []string {
+	var commands []string
+	windows := c.metadata.Sys.Arch == "windows/amd64"
+
+	if len(container.Outputs) != 0 {
+		dir := c.outputsDir(container.Name)
+		environment["CI_STEP_OUTPUTS"] = dir
+		if windows {
+			commands = append(commands, fmt.Sprintf("New-Item -ItemType Directory -Force -Path %q | Out-Null", dir))
+		} else {
+			commands = append(commands, fmt.Sprintf("mkdir -p %q", dir))
+		}
+	}
+
+	var keys []string
+	for k := range container.Environment {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		ref, ok := yaml.ParseOutputRef(container.Environment[k])
+		if !ok {
+			continue
+		}
+		delete(environment, k)
+
+		file := path.Join(c.outputsDir(ref.Step), ref.Name)
+		if windows {
+			commands = append(commands, fmt.Sprintf("$Env:%s = (Get-Content -Raw %q).TrimEnd()", k, file))
+		} else {
+			commands = append(commands, fmt.Sprintf("export %s=\"$(cat %q)\"", k, file))
+		}
+	}
+	return commands
+}
@@ -3,6 +3,7 @@ package compiler
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -11,9 +12,17 @@ import (
 )
 
 // paramsToEnv uses reflection to convert a map[string]interface to a list
-// of environment variables.
+// of environment variables. Parameters are converted in key order, so keys
+// only differing in case always result in the same variable.
 func paramsToEnv(from map[string]interface{}, to map[string]string) error {
-	for k, v := range from {
+	keys := make([]string, 0, len(from))
+	for k := range from {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := from[k]
 		if v == nil {
 			continue
 		}
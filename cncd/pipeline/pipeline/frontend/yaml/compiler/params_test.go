@@ -35,3 +35,18 @@ func TestParamsToEnv(t *testing.T) {
 		pretty.Ldiff(t, want, got)
 	}
 }
+
+func TestParamsToEnvCase(t *testing.T) {
+	from := map[string]interface{}{
+		"tags": "latest",
+		"TAGS": "stable",
+		"Tags": "edge",
+	}
+	for i := 0; i < 20; i++ {
+		got := map[string]string{}
+		paramsToEnv(from, got)
+		if got["PLUGIN_TAGS"] != "latest" {
+			t.Fatalf("Want the parameter last in key order to win, got %q", got["PLUGIN_TAGS"])
+		}
+	}
+}
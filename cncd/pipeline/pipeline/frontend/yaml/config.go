@@ -12,19 +12,80 @@ import (
 type (
 	// Config defines a pipeline configuration.
 	Config struct {
-		Cache     libcompose.Stringorslice
-		Platform  string
-		Branches  Constraint
-		Workspace Workspace
-		Clone     Containers
-		Pipeline  Containers
-		Services  Containers
-		Networks  Networks
-		Volumes   Volumes
-		Labels    libcompose.SliceorMap
-		DependsOn []string `yaml:"depends_on,omitempty"`
-		RunsOn    []string `yaml:"runs_on,omitempty"`
-		SkipClone bool     `yaml:"skip_clone"`
+		Cache       libcompose.Stringorslice
+		Platform    string
+		Branches    Constraint
+		When        Constraints `yaml:"when,omitempty"`
+		Workspace   Workspace
+		Clone       Containers
+		CloneOpts   CloneOpts   `yaml:"clone_settings,omitempty"`
+		Concurrency Concurrency `yaml:"concurrency,omitempty"`
+		Pipeline    Containers
+		Services    Containers
+		Networks    Networks
+		Volumes     Volumes
+		Labels      libcompose.SliceorMap
+		Environment libcompose.SliceorMap
+		DependsOn   []string `yaml:"depends_on,omitempty"`
+		RunsOn      []string `yaml:"runs_on,omitempty"`
+		SkipClone   bool     `yaml:"skip_clone"`
+
+		// ExcludeLabels are the agent labels the pipeline must not be
+		// scheduled on, the negative counterpart of Labels.
+		ExcludeLabels libcompose.SliceorMap `yaml:"exclude_labels,omitempty"`
+
+		// AllowIgnoredAuthors runs the pipeline even if the build author
+		// is ignored by the server, e.g. for dependency update bots.
+		AllowIgnoredAuthors bool `yaml:"allow_ignored_authors,omitempty"`
+
+		// Manual only runs the pipeline for manual builds, e.g. deployments
+		// triggered on demand.
+		Manual bool `yaml:"manual,omitempty"`
+
+		// Paths skips the pipeline if none of the changed files of the
+		// build match. It is evaluated before the pipeline is parsed.
+		Paths ConstraintPath `yaml:"paths,omitempty"`
+
+		// RunIfDepsSkipped runs the pipeline even if all the pipelines it
+		// depends on are skipped, which otherwise skips the pipeline too.
+		RunIfDepsSkipped bool `yaml:"run_if_deps_skipped,omitempty"`
+
+		// FailFast cancels the other pipelines of the build as soon as one
+		// of them fails. It applies to the whole build if any of its
+		// pipelines sets it.
+		FailFast bool `yaml:"fail_fast,omitempty"`
+
+		// Notify are the notifications of the build, sent by the server
+		// instead of a plugin step.
+		Notify []Notify `yaml:"notify,omitempty"`
+
+		// Stages are the names the stages of the pipeline are displayed
+		// with, keyed by the group of the steps running in parallel or the
+		// name of a step without group.
+		Stages map[string]string `yaml:"stages,omitempty"`
+	}
+
+	// CloneOpts defines the settings of the default clone step.
+	CloneOpts struct {
+		// Depth is the clone depth, zero meaning the full history. The
+		// server default is used when unset.
+		Depth *int `yaml:"depth,omitempty"`
+		Tags  bool `yaml:"tags,omitempty"`
+
+		// LFS fetches the Git LFS objects instead of their pointer files.
+		// The server default is used when unset.
+		LFS *bool `yaml:"lfs,omitempty"`
+
+		// When limits the clone steps to the matching builds, e.g. to
+		// skip the clone of cron builds that do not need the repository.
+		// The repository is always cloned when unset.
+		When Constraints `yaml:"when,omitempty"`
+	}
+
+	// Concurrency defines the concurrency group of a pipeline.
+	Concurrency struct {
+		Group            string
+		CancelInProgress bool `yaml:"cancel-in-progress,omitempty"`
 	}
 
 	// Workspace defines a pipeline workspace.
@@ -54,6 +115,18 @@ func ParseBytes(b []byte) (*Config, error) {
 	return out, nil
 }
 
+// ParsePaths parses only the paths of the configuration from bytes b, e.g.
+// to skip a pipeline without parsing it.
+func ParsePaths(b []byte) (*ConstraintPath, error) {
+	out := struct {
+		Paths ConstraintPath `yaml:"paths"`
+	}{}
+	if err := yaml.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return &out.Paths, nil
+}
+
 // ParseString parses the configuration from string s.
 func ParseString(s string) (*Config, error) {
 	return ParseBytes(
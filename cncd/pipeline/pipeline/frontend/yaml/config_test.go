@@ -38,11 +38,24 @@ func TestParse(t *testing.T) {
 				// g.Assert(out.Pipeline.Containers[2].NetworkMode).Equal("container:name")
 				g.Assert(out.Labels["com.example.team"]).Equal("frontend")
 				g.Assert(out.Labels["com.example.type"]).Equal("build")
+				g.Assert(out.ExcludeLabels["platform"]).Equal("linux/arm")
+				g.Assert(out.Environment["GOOS"]).Equal("linux")
 				g.Assert(out.DependsOn[0]).Equal("lint")
 				g.Assert(out.DependsOn[1]).Equal("test")
 				g.Assert(out.RunsOn[0]).Equal("success")
 				g.Assert(out.RunsOn[1]).Equal("failure")
 				g.Assert(out.SkipClone).Equal(false)
+				g.Assert(out.FailFast).Equal(true)
+				g.Assert(out.When.Event.Include).Equal([]string{"push", "pull_request"})
+				g.Assert(out.Paths.Include).Equal([]string{"src/*", "go.mod"})
+			})
+
+			g.It("Should unmarshal only the paths", func() {
+				paths, err := ParsePaths([]byte(sampleYaml))
+				if err != nil {
+					g.Fail(err)
+				}
+				g.Assert(paths.Include).Equal([]string{"src/*", "go.mod"})
 			})
 
 			g.It("Should handle simple yaml anchors", func() {
@@ -66,6 +79,29 @@ func TestParse(t *testing.T) {
 				g.Assert(out.Pipeline.Containers[1].Image).Equal("plugins/slack")
 				g.Assert(out.Pipeline.Containers[1].Constraints.Event.Include).Equal([]string{"success"})
 			})
+
+			g.It("Should resolve merge keys of steps", func() {
+				out, err := ParseString(sampleMergeYaml)
+				if err != nil {
+					g.Fail(err)
+				}
+				g.Assert(len(out.Pipeline.Containers)).Equal(3)
+				g.Assert(out.Pipeline.Containers[0].Name).Equal("lint")
+				g.Assert(out.Pipeline.Containers[0].Image).Equal("golang")
+				g.Assert(out.Pipeline.Containers[0].Commands).Equal(yaml.Stringorslice{"go vet"})
+				g.Assert(out.Pipeline.Containers[1].Name).Equal("test")
+				g.Assert(out.Pipeline.Containers[1].Image).Equal("golang:1.16")
+				g.Assert(out.Pipeline.Containers[1].Commands).Equal(yaml.Stringorslice{"go test"})
+				g.Assert(out.Pipeline.Containers[2].Name).Equal("build")
+				g.Assert(out.Pipeline.Containers[2].Image).Equal("golang")
+				g.Assert(out.Pipeline.Containers[2].Commands).Equal(yaml.Stringorslice{"go build"})
+			})
+
+			g.It("Should fail on a dangling anchor", func() {
+				_, err := ParseString(sampleDanglingYaml)
+				g.Assert(err != nil).IsTrue()
+				g.Assert(err.Error()).Equal("yaml: unknown anchor 'golang' referenced")
+			})
 		})
 	})
 }
@@ -108,12 +144,20 @@ volumes:
 labels:
   com.example.type: "build"
   com.example.team: "frontend"
+exclude_labels:
+  platform: "linux/arm"
+environment:
+  - GOOS=linux
 depends_on:
   - lint
   - test
 runs_on:
   - success
   - failure
+fail_fast: true
+when:
+  event: [push, pull_request]
+paths: [ "src/*", go.mod ]
 `
 
 var simpleYamlAnchors = `
@@ -134,3 +178,30 @@ pipeline:
     when:
       event: success
 `
+
+var sampleMergeYaml = `
+x-golang: &golang
+  image: golang
+x-steps: &steps
+  lint:
+    <<: *golang
+    commands: [ go vet ]
+  test:
+    image: golang:1.16
+pipeline:
+  <<: *steps
+  test:
+    <<: *golang
+    image: golang:1.16
+    commands: [ go test ]
+  build:
+    <<: *golang
+    commands: [ go build ]
+`
+
+var sampleDanglingYaml = `
+pipeline:
+  build:
+    <<: *golang
+    commands: [ go build ]
+`
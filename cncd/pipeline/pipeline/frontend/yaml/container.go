@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	libcompose "github.com/docker/libcompose/yaml"
+	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/frontend/yaml/types"
 	"gopkg.in/yaml.v3"
 )
 
@@ -22,6 +23,7 @@ type (
 
 	// Container defines a container.
 	Container struct {
+		Artifacts     []string                  `yaml:"artifacts,omitempty"`
 		AuthConfig    AuthConfig                `yaml:"auth_config,omitempty"`
 		CapAdd        []string                  `yaml:"cap_add,omitempty"`
 		CapDrop       []string                  `yaml:"cap_drop,omitempty"`
@@ -32,11 +34,13 @@ type (
 		CPUShares     libcompose.StringorInt    `yaml:"cpu_shares,omitempty"`
 		Detached      bool                      `yaml:"detach,omitempty"`
 		Devices       []string                  `yaml:"devices,omitempty"`
+		Directory     string                    `yaml:"directory,omitempty"`
 		Tmpfs         []string                  `yaml:"tmpfs,omitempty"`
 		DNS           libcompose.Stringorslice  `yaml:"dns,omitempty"`
 		DNSSearch     libcompose.Stringorslice  `yaml:"dns_search,omitempty"`
 		Entrypoint    libcompose.Command        `yaml:"entrypoint,omitempty"`
 		Environment   libcompose.SliceorMap     `yaml:"environment,omitempty"`
+		EnvFile       libcompose.Stringorslice  `yaml:"environment_file,omitempty"`
 		ExtraHosts    []string                  `yaml:"extra_hosts,omitempty"`
 		Group         string                    `yaml:"group,omitempty"`
 		Image         string                    `yaml:"image,omitempty"`
@@ -47,10 +51,11 @@ type (
 		MemSwappiness libcompose.MemStringorInt `yaml:"mem_swappiness,omitempty"`
 		Name          string                    `yaml:"name,omitempty"`
 		NetworkMode   string                    `yaml:"network_mode,omitempty"`
+		Outputs       []string                  `yaml:"outputs,omitempty"`
 		IpcMode       string                    `yaml:"ipc_mode,omitempty"`
 		Networks      libcompose.Networks       `yaml:"networks,omitempty"`
 		Privileged    bool                      `yaml:"privileged,omitempty"`
-		Pull          bool                      `yaml:"pull,omitempty"`
+		Pull          types.PullPolicy          `yaml:"pull,omitempty"`
 		ShmSize       libcompose.MemStringorInt `yaml:"shm_size,omitempty"`
 		Ulimits       libcompose.Ulimits        `yaml:"ulimits,omitempty"`
 		Volumes       libcompose.Volumes        `yaml:"volumes,omitempty"`
@@ -69,7 +74,12 @@ func (c *Containers) UnmarshalYAML(value *yaml.Node) error {
 		return err
 	}
 
-	for i, n := range value.Content {
+	content, err := mergeKeys(value)
+	if err != nil {
+		return err
+	}
+
+	for i, n := range content {
 		if i%2 == 1 {
 			container := Container{}
 			err := n.Decode(&container)
@@ -78,10 +88,65 @@ func (c *Containers) UnmarshalYAML(value *yaml.Node) error {
 			}
 
 			if container.Name == "" {
-				container.Name = fmt.Sprintf("%v", value.Content[i-1].Value)
+				container.Name = fmt.Sprintf("%v", content[i-1].Value)
 			}
 			c.Containers = append(c.Containers, &container)
 		}
 	}
 	return nil
 }
+
+// mergeKeys returns the key and value nodes of the mapping, with the merge
+// keys (<<) replaced by the entries of the merged mappings in place. Explicit
+// entries take precedence over merged entries.
+func mergeKeys(value *yaml.Node) ([]*yaml.Node, error) {
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		if key := value.Content[i]; key.ShortTag() != mergeTag {
+			seen[key.Value] = true
+		}
+	}
+
+	var content []*yaml.Node
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		key, val := value.Content[i], value.Content[i+1]
+		if key.ShortTag() != mergeTag {
+			content = append(content, key, val)
+			continue
+		}
+
+		merged := []*yaml.Node{resolveAlias(val)}
+		if merged[0].Kind == yaml.SequenceNode {
+			merged = nil
+			for _, n := range resolveAlias(val).Content {
+				merged = append(merged, resolveAlias(n))
+			}
+		}
+		for _, m := range merged {
+			if m.Kind != yaml.MappingNode {
+				return nil, fmt.Errorf("yaml: line %d: merge key must reference a mapping", key.Line)
+			}
+			entries, err := mergeKeys(m)
+			if err != nil {
+				return nil, err
+			}
+			for j := 0; j+1 < len(entries); j += 2 {
+				if seen[entries[j].Value] {
+					continue
+				}
+				seen[entries[j].Value] = true
+				content = append(content, entries[j], entries[j+1])
+			}
+		}
+	}
+	return content, nil
+}
+
+const mergeTag = "!!merge"
+
+func resolveAlias(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	return n
+}
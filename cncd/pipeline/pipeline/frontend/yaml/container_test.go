@@ -6,6 +6,7 @@ import (
 
 	libcompose "github.com/docker/libcompose/yaml"
 	"github.com/kr/pretty"
+	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/frontend/yaml/types"
 	"gopkg.in/yaml.v3"
 )
 
@@ -94,7 +95,7 @@ func TestUnmarshalContainer(t *testing.T) {
 			},
 		},
 		NetworkMode: "bridge",
-		Pull:        true,
+		Pull:        types.PullAlways,
 		Privileged:  true,
 		ShmSize:     libcompose.MemStringorInt(1024),
 		Tmpfs:       libcompose.Stringorslice{"/var/lib/test"},
@@ -2,6 +2,8 @@ package linter
 
 import (
 	"fmt"
+	"path"
+	"strings"
 
 	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/frontend/yaml"
 )
@@ -31,6 +33,9 @@ func (l *Linter) Lint(c *yaml.Config) error {
 	if len(c.Pipeline.Containers) == 0 {
 		return fmt.Errorf("Invalid or missing pipeline section")
 	}
+	if err := l.lintWorkspace(c.Workspace); err != nil {
+		return err
+	}
 	if err := l.lint(c.Clone.Containers, blockClone); err != nil {
 		return err
 	}
@@ -40,6 +45,12 @@ func (l *Linter) Lint(c *yaml.Config) error {
 	if err := l.lint(c.Services.Containers, blockServices); err != nil {
 		return err
 	}
+	if err := l.lintOutputs(c.Pipeline.Containers); err != nil {
+		return err
+	}
+	if err := l.lintNotify(c.Notify); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -61,10 +72,157 @@ func (l *Linter) lint(containers []*yaml.Container, block uint8) error {
 		if err := l.lintCommands(container); err != nil {
 			return err
 		}
+		if err := l.lintDirectory(container); err != nil {
+			return err
+		}
+		if err := l.lintArtifacts(container); err != nil {
+			return err
+		}
+		if err := l.lintStatus(container); err != nil {
+			return err
+		}
+		if err := l.lintSecrets(container); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lintOutputs checks that steps only reference outputs declared by a step
+// that completed before them.
+func (l *Linter) lintOutputs(containers []*yaml.Container) error {
+	// declared maps the declared outputs to the group of the producer.
+	declared := map[yaml.OutputRef]string{}
+	for _, container := range containers {
+		for _, value := range container.Environment {
+			ref, ok := yaml.ParseOutputRef(value)
+			if !ok {
+				continue
+			}
+			group, ok := declared[ref]
+			if !ok || (group != "" && group == container.Group) {
+				return fmt.Errorf("Invalid output reference %s, outputs must be declared by a previous step", ref)
+			}
+			if len(container.Commands) == 0 {
+				return fmt.Errorf("Cannot reference step outputs without commands")
+			}
+		}
+		for _, name := range container.Outputs {
+			if !yaml.ValidOutputName(name) {
+				return fmt.Errorf("Invalid output name %s", name)
+			}
+			declared[yaml.OutputRef{Step: container.Name, Name: name}] = container.Group
+		}
+	}
+	return nil
+}
+
+func (l *Linter) lintWorkspace(w yaml.Workspace) error {
+	if len(w.Base) != 0 {
+		if !path.IsAbs(w.Base) {
+			return fmt.Errorf("Invalid workspace base, must be an absolute path")
+		}
+		if path.Clean(w.Base) == "/" || hasParentRef(w.Base) {
+			return fmt.Errorf("Invalid workspace base %s", w.Base)
+		}
+	}
+	if len(w.Path) != 0 {
+		if path.IsAbs(w.Path) {
+			return fmt.Errorf("Invalid workspace path, must be relative to the workspace base")
+		}
+		if hasParentRef(w.Path) {
+			return fmt.Errorf("Invalid workspace path, cannot leave the workspace base")
+		}
 	}
 	return nil
 }
 
+func (l *Linter) lintDirectory(c *yaml.Container) error {
+	if len(c.Directory) == 0 {
+		return nil
+	}
+	if path.IsAbs(c.Directory) {
+		return fmt.Errorf("Invalid directory, must be relative to the workspace")
+	}
+	if hasParentRef(c.Directory) {
+		return fmt.Errorf("Invalid directory, cannot leave the workspace")
+	}
+	return nil
+}
+
+func (l *Linter) lintArtifacts(c *yaml.Container) error {
+	for _, artifact := range c.Artifacts {
+		if len(artifact) == 0 {
+			return fmt.Errorf("Invalid artifact, path cannot be empty")
+		}
+		if path.IsAbs(artifact) {
+			return fmt.Errorf("Invalid artifact %s, must be relative to the workspace", artifact)
+		}
+		if hasParentRef(artifact) {
+			return fmt.Errorf("Invalid artifact %s, cannot leave the workspace", artifact)
+		}
+	}
+	return nil
+}
+
+// lintStatus checks that the status constraint only references build
+// states a step can be gated on.
+func (l *Linter) lintStatus(c *yaml.Container) error {
+	status := c.Constraints.Status
+	for _, v := range append(status.Include, status.Exclude...) {
+		switch v {
+		case "success", "failure":
+		default:
+			return fmt.Errorf("Invalid status %s, must be success or failure", v)
+		}
+	}
+	return nil
+}
+
+// lintSecrets checks that the declared secret targets are valid environment
+// variable names. Secrets exposed under their own name are not checked, as
+// their names were always accepted.
+func (l *Linter) lintSecrets(c *yaml.Container) error {
+	for _, secret := range c.Secrets.Secrets {
+		if secret.Source == "" {
+			return fmt.Errorf("Invalid secret, source cannot be empty")
+		}
+		if secret.ExplicitTarget && !yaml.ValidSecretTarget(secret.Target) {
+			return fmt.Errorf("Invalid secret target %s, must be a valid environment variable name", secret.Target)
+		}
+	}
+	return nil
+}
+
+// lintNotify checks that the notifications have valid targets.
+func (l *Linter) lintNotify(notify []yaml.Notify) error {
+	for _, n := range notify {
+		if len(n.Email) == 0 && n.Webhook == "" {
+			return fmt.Errorf("Invalid notification, email or webhook is required")
+		}
+		for _, email := range n.Email {
+			if !yaml.ValidNotifyEmail(email) {
+				return fmt.Errorf("Invalid notification email %s", email)
+			}
+		}
+		if n.Webhook != "" && !yaml.ValidNotifyWebhook(n.Webhook) {
+			return fmt.Errorf("Invalid notification webhook %s, must be an http or https url", n.Webhook)
+		}
+	}
+	return nil
+}
+
+// hasParentRef returns true if the path contains a parent directory
+// reference.
+func hasParentRef(p string) bool {
+	for _, elem := range strings.Split(p, "/") {
+		if elem == ".." {
+			return true
+		}
+	}
+	return false
+}
+
 func (l *Linter) lintImage(c *yaml.Container) error {
 	if len(c.Image) == 0 {
 		return fmt.Errorf("Invalid or missing image")
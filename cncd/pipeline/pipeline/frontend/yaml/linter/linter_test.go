@@ -18,14 +18,26 @@ pipeline:
     commands:
       - go build
       - go test
+    artifacts:
+      - dist/app
+  logs:
+    image: plugins/s3
+    when:
+      status: [ failure ]
   publish:
     image: plugins/docker
     repo: foo/bar
+    secrets: [ docker-password ]
 services:
   redis:
     image: redis
     entrypoint: [ /bin/redis-server ]
     command: [ -v ]
+notify:
+  - email: [ team@example.com ]
+    webhook: https://chat.example.com/hooks/build
+    when:
+      status: [ failure ]
 `
 
 	conf, err := yaml.ParseString(testdata)
@@ -113,6 +125,92 @@ func TestLintErrors(t *testing.T) {
 			from: "pipeline: { publish: { image: plugins/docker, repo: foo/bar, command: [ '/bin/bash' ] } }",
 			want: "Cannot override container command",
 		},
+		// cannot use a workspace outside of the workspace base
+		{
+			from: "workspace: { base: go }\npipeline: { build: { image: golang, commands: [ 'go build' ] } }",
+			want: "Invalid workspace base, must be an absolute path",
+		},
+		{
+			from: "workspace: { base: / }\npipeline: { build: { image: golang, commands: [ 'go build' ] } }",
+			want: "Invalid workspace base /",
+		},
+		{
+			from: "workspace: { base: /go/../etc }\npipeline: { build: { image: golang, commands: [ 'go build' ] } }",
+			want: "Invalid workspace base /go/../etc",
+		},
+		{
+			from: "workspace: { path: /etc }\npipeline: { build: { image: golang, commands: [ 'go build' ] } }",
+			want: "Invalid workspace path, must be relative to the workspace base",
+		},
+		{
+			from: "workspace: { path: src/../../etc }\npipeline: { build: { image: golang, commands: [ 'go build' ] } }",
+			want: "Invalid workspace path, cannot leave the workspace base",
+		},
+		// cannot run commands outside of the workspace
+		{
+			from: "pipeline: { build: { image: golang, directory: /etc, commands: [ 'go build' ] } }",
+			want: "Invalid directory, must be relative to the workspace",
+		},
+		{
+			from: "pipeline: { build: { image: golang, directory: src/../../etc, commands: [ 'go build' ] } }",
+			want: "Invalid directory, cannot leave the workspace",
+		},
+		// cannot declare artifacts outside of the workspace
+		{
+			from: "pipeline: { build: { image: golang, commands: [ 'go build' ], artifacts: [ /etc/passwd ] } }",
+			want: "Invalid artifact /etc/passwd, must be relative to the workspace",
+		},
+		{
+			from: "pipeline: { build: { image: golang, commands: [ 'go build' ], artifacts: [ dist/../../secrets ] } }",
+			want: "Invalid artifact dist/../../secrets, cannot leave the workspace",
+		},
+		// cannot gate steps on unknown build states
+		{
+			from: "pipeline: { notify: { image: plugins/slack, when: { status: [ changed ] } } }",
+			want: "Invalid status changed, must be success or failure",
+		},
+		{
+			from: "pipeline: { notify: { image: plugins/slack, when: { status: { exclude: [ killed ] } } } }",
+			want: "Invalid status killed, must be success or failure",
+		},
+		// cannot reference outputs of a later or parallel step
+		{
+			from: "pipeline: { deploy: { image: golang, commands: [ 'echo $VERSION' ], environment: { VERSION: '{{ steps.build.outputs.version }}' } }, build: { image: golang, commands: [ 'go build' ], outputs: [ version ] } }",
+			want: "Invalid output reference steps.build.outputs.version, outputs must be declared by a previous step",
+		},
+		{
+			from: "pipeline: { build: { image: golang, commands: [ 'go build' ], outputs: [ version ], group: build }, deploy: { image: golang, commands: [ 'echo $VERSION' ], environment: { VERSION: '{{ steps.build.outputs.version }}' }, group: build } }",
+			want: "Invalid output reference steps.build.outputs.version, outputs must be declared by a previous step",
+		},
+		{
+			from: "pipeline: { build: { image: golang, commands: [ 'go build' ], outputs: [ version ] }, publish: { image: plugins/docker, environment: { VERSION: '{{ steps.build.outputs.version }}' } } }",
+			want: "Cannot reference step outputs without commands",
+		},
+		{
+			from: "pipeline: { build: { image: golang, commands: [ 'go build' ], outputs: [ 'image tag' ] } }",
+			want: "Invalid output name image tag",
+		},
+		// cannot expose secrets as invalid environment variables
+		{
+			from: "pipeline: { publish: { image: plugins/docker, secrets: [ { source: docker_prod_password, target: docker-password } ] } }",
+			want: "Invalid secret target docker-password, must be a valid environment variable name",
+		},
+		{
+			from: "pipeline: { publish: { image: plugins/docker, secrets: [ { target: docker_password } ] } }",
+			want: "Invalid secret, source cannot be empty",
+		},
+		{
+			from: "{ pipeline: { build: { image: golang } }, notify: [ { when: { status: failure } } ] }",
+			want: "Invalid notification, email or webhook is required",
+		},
+		{
+			from: "{ pipeline: { build: { image: golang } }, notify: [ { email: [ team@example.com, team ] } ] }",
+			want: "Invalid notification email team",
+		},
+		{
+			from: "{ pipeline: { build: { image: golang } }, notify: [ { webhook: chat.example.com/hooks/build } ] }",
+			want: "Invalid notification webhook chat.example.com/hooks/build, must be an http or https url",
+		},
 	}
 
 	for _, test := range testdata {
@@ -1,6 +1,8 @@
 package matrix
 
 import (
+	"fmt"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -11,6 +13,19 @@ const (
 	limitAxis = 25
 )
 
+// keyAllowed is the reserved matrix key that restricts the values of the
+// matrix axes to an allowed set. The reserved keys hold a mapping, a list of
+// values under the same key is an axis of that name, see reserved.
+const keyAllowed = "allowed"
+
+// keyTopics is the reserved matrix key that sources the values of matrix
+// axes from the repository topics with a prefix, e.g. lang- for lang-go.
+const keyTopics = "topics"
+
+// keyEventPrefix prefixes the top-level keys of the matrices that replace
+// the default matrix for a build event, e.g. matrix_pull_request.
+const keyEventPrefix = "matrix_"
+
 // Matrix represents the build matrix.
 type Matrix map[string][]string
 
@@ -27,9 +42,30 @@ func (a Axis) String() string {
 	return strings.Join(envs, " ")
 }
 
-// Parse parses the Yaml matrix definition.
+// Parse parses the Yaml matrix definition. An error is returned if an axis
+// has a value outside of the allowed values of the matrix.
 func Parse(data []byte) ([]Axis, error) {
+	return parseMatrix(data, nil)
+}
+
+func parseMatrix(data []byte, topics []string) ([]Axis, error) {
+	allowed, err := parseAllowed(data)
+	if err != nil {
+		return nil, err
+	}
+
+	axis, err := parseAxes(data, topics)
+	if err != nil {
+		return nil, err
+	}
+	return axis, validate(axis, allowed)
+}
 
+// parseAxes returns the axes of the matrix, either the included list of
+// axes or the permutations of the matrix values. The values of the axes
+// sourced from topics are the matching topics, the inline values are only
+// used if no topic matches.
+func parseAxes(data []byte, topics []string) ([]Axis, error) {
 	axis, err := parseList(data)
 	if err == nil && len(axis) != 0 {
 		return axis, nil
@@ -40,6 +76,16 @@ func Parse(data []byte) ([]Axis, error) {
 		return nil, err
 	}
 
+	prefixes, err := parseTopics(data)
+	if err != nil {
+		return nil, err
+	}
+	for tag, prefix := range prefixes {
+		if values := topicValues(topics, prefix); len(values) != 0 {
+			matrix[tag] = values
+		}
+	}
+
 	if len(matrix) == 0 {
 		return []Axis{}, nil
 	}
@@ -52,6 +98,59 @@ func ParseString(data string) ([]Axis, error) {
 	return Parse([]byte(data))
 }
 
+// ParseEvent parses the Yaml matrix definition of the build event. The
+// event matrix, e.g. matrix_pull_request, replaces the default matrix if it
+// is defined. The allowed values of the default matrix apply to the event
+// matrix as well.
+func ParseEvent(data []byte, event string) ([]Axis, error) {
+	return ParseEventTopics(data, event, nil)
+}
+
+// ParseEventTopics parses the Yaml matrix definition of the build event,
+// sourcing the values of the axes listed under the topics key from the
+// repository topics.
+func ParseEventTopics(data []byte, event string, topics []string) ([]Axis, error) {
+	doc := map[string]yaml.Node{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	node, ok := doc[keyEventPrefix+event]
+	if !ok {
+		return parseMatrix(data, topics)
+	}
+
+	allowed, err := parseAllowed(data)
+	if err != nil {
+		return nil, err
+	}
+	eventData, err := yaml.Marshal(map[string]*yaml.Node{"matrix": &node})
+	if err != nil {
+		return nil, err
+	}
+	eventAllowed, err := parseAllowed(eventData)
+	if err != nil {
+		return nil, err
+	}
+	if allowed == nil {
+		allowed = Matrix{}
+	}
+	for tag, values := range eventAllowed {
+		allowed[tag] = values
+	}
+
+	axis, err := parseAxes(eventData, topics)
+	if err != nil {
+		return nil, err
+	}
+	return axis, validate(axis, allowed)
+}
+
+// ParseStringEvent parses the Yaml string matrix definition of the build
+// event.
+func ParseStringEvent(data, event string) ([]Axis, error) {
+	return ParseEvent([]byte(data), event)
+}
+
 func calc(matrix Matrix) []Axis {
 	// calculate number of permutations and extract the list of tags
 	// (ie go_version, redis_version, etc)
@@ -96,12 +195,106 @@ func calc(matrix Matrix) []Axis {
 	return axisList
 }
 
+// validate returns an error naming the offending axis and value if an axis
+// has a value that is not allowed.
+func validate(axisList []Axis, allowed Matrix) error {
+	for _, axis := range axisList {
+		tags := make([]string, 0, len(axis))
+		for tag := range axis {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+
+		for _, tag := range tags {
+			values, ok := allowed[tag]
+			if !ok {
+				continue
+			}
+			if !contains(values, axis[tag]) {
+				return fmt.Errorf("Invalid matrix axis %s value %s, must be one of %s", tag, axis[tag], strings.Join(values, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 func parse(raw []byte) (Matrix, error) {
 	data := struct {
-		Matrix map[string][]string
+		Matrix map[string]yaml.Node
 	}{}
-	err := yaml.Unmarshal(raw, &data)
-	return data.Matrix, err
+	if err := yaml.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+
+	matrix := Matrix{}
+	for tag, node := range data.Matrix {
+		if reserved(tag, &node) {
+			continue
+		}
+		var values []string
+		if err := node.Decode(&values); err != nil {
+			return nil, err
+		}
+		matrix[tag] = values
+	}
+	return matrix, nil
+}
+
+// reserved returns true if the matrix key is a reserved key rather than an
+// axis. Matrices predating the reserved keys may have axes of the same name,
+// which are lists of values instead of mappings.
+func reserved(tag string, node *yaml.Node) bool {
+	return (tag == keyAllowed || tag == keyTopics) && node.Kind == yaml.MappingNode
+}
+
+// parseReserved decodes the value of the reserved matrix key into out, if
+// the matrix has the key.
+func parseReserved(raw []byte, key string, out interface{}) error {
+	data := struct {
+		Matrix map[string]yaml.Node
+	}{}
+	if err := yaml.Unmarshal(raw, &data); err != nil {
+		return err
+	}
+	node, ok := data.Matrix[key]
+	if !ok || !reserved(key, &node) {
+		return nil
+	}
+	return node.Decode(out)
+}
+
+func parseAllowed(raw []byte) (Matrix, error) {
+	var allowed Matrix
+	err := parseReserved(raw, keyAllowed, &allowed)
+	return allowed, err
+}
+
+func parseTopics(raw []byte) (map[string]string, error) {
+	var topics map[string]string
+	err := parseReserved(raw, keyTopics, &topics)
+	return topics, err
+}
+
+// topicValues returns the topics with the prefix, without the prefix and
+// sorted. An empty prefix matches all the topics.
+func topicValues(topics []string, prefix string) []string {
+	var values []string
+	for _, topic := range topics {
+		if strings.HasPrefix(topic, prefix) && len(topic) > len(prefix) {
+			values = append(values, topic[len(prefix):])
+		}
+	}
+	sort.Strings(values)
+	return values
 }
 
 func parseList(raw []byte) ([]Axis, error) {
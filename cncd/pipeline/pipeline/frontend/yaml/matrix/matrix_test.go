@@ -40,9 +40,129 @@ func TestMatrix(t *testing.T) {
 			g.Assert(axis[0]["python_version"]).Equal("3.4")
 			g.Assert(axis[1]["python_version"]).Equal("3.4")
 		})
+
+		g.It("Should accept axis values in the allowed set", func() {
+			axis, err := ParseString(fakeMatrixAllowed)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(axis)).Equal(4)
+		})
+
+		g.It("Should reject axis values outside of the allowed set", func() {
+			_, err := ParseString(fakeMatrixNotAllowed)
+			g.Assert(err != nil).IsTrue()
+			g.Assert(err.Error()).Equal("Invalid matrix axis platform value linux/amd46, must be one of linux/amd64, linux/arm64")
+		})
+
+		g.It("Should reject included axis values outside of the allowed set", func() {
+			_, err := ParseString(fakeMatrixIncludeNotAllowed)
+			g.Assert(err != nil).IsTrue()
+		})
+
+		g.It("Should use the event matrix", func() {
+			axis, err := ParseStringEvent(fakeMatrixEvent, "pull_request")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(axis)).Equal(1)
+			g.Assert(axis[0]["platform"]).Equal("linux/amd64")
+		})
+
+		g.It("Should fall back to the default matrix", func() {
+			axis, err := ParseStringEvent(fakeMatrixEvent, "push")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(axis)).Equal(4)
+		})
+
+		g.It("Should apply the allowed values to the event matrix", func() {
+			_, err := ParseStringEvent(fakeMatrixEventNotAllowed, "pull_request")
+			g.Assert(err != nil).IsTrue()
+		})
+
+		g.It("Should source axis values from the topics", func() {
+			topics := []string{"lang-rust", "docker", "lang-go"}
+			axis, err := ParseEventTopics([]byte(fakeMatrixTopics), "push", topics)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(axis)).Equal(4)
+			set := map[string]bool{}
+			for _, a := range axis {
+				set[a["language"]+" "+a["platform"]] = true
+			}
+			g.Assert(set).Equal(map[string]bool{
+				"go linux/amd64":   true,
+				"go linux/arm64":   true,
+				"rust linux/amd64": true,
+				"rust linux/arm64": true,
+			})
+		})
+
+		g.It("Should use the inline values without matching topics", func() {
+			axis, err := ParseEventTopics([]byte(fakeMatrixTopics), "push", []string{"docker"})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(axis)).Equal(2)
+			g.Assert(axis[0]["language"]).Equal("go")
+		})
+
+		g.It("Should drop axes without values without topics", func() {
+			axis, err := ParseEventTopics([]byte(fakeMatrixTopicsOnly), "push", nil)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(axis)).Equal(0)
+		})
+
+		g.It("Should ignore the topics without opting in", func() {
+			axis, err := ParseEventTopics([]byte(fakeMatrixEvent), "push", []string{"lang-go"})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(axis)).Equal(4)
+			g.Assert(axis[0]["language"]).Equal("")
+		})
+
+		g.It("Should keep axes named after the reserved keys", func() {
+			axis, err := ParseString(fakeMatrixReservedAxes)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(axis)).Equal(4)
+			set := map[string]bool{}
+			for _, a := range axis {
+				set[a["allowed"]+" "+a["topics"]] = true
+			}
+			g.Assert(set).Equal(map[string]bool{
+				"yes docs": true,
+				"yes api":  true,
+				"no docs":  true,
+				"no api":   true,
+			})
+		})
+
+		g.It("Should apply the allowed values to topics", func() {
+			_, err := ParseEventTopics([]byte(fakeMatrixTopicsAllowed), "push", []string{"lang-cobol"})
+			g.Assert(err != nil).IsTrue()
+		})
 	})
 }
 
+var fakeMatrixTopics = `
+matrix:
+  topics:
+    language: lang-
+  language:
+    - go
+  platform:
+    - linux/amd64
+    - linux/arm64
+`
+
+var fakeMatrixTopicsOnly = `
+matrix:
+  topics:
+    language: lang-
+`
+
+var fakeMatrixTopicsAllowed = `
+matrix:
+  topics:
+    language: lang-
+  allowed:
+    language:
+      - go
+      - rust
+`
+
 var fakeMatrix = `
 matrix:
   go_version:
@@ -68,3 +188,79 @@ matrix:
     - go_version: 1.6
       python_version: 3.4
 `
+
+var fakeMatrixAllowed = `
+matrix:
+  go_version:
+    - 1.15
+    - 1.16
+  platform:
+    - linux/amd64
+    - linux/arm64
+  allowed:
+    platform:
+      - linux/amd64
+      - linux/arm64
+`
+
+var fakeMatrixNotAllowed = `
+matrix:
+  go_version:
+    - 1.15
+  platform:
+    - linux/amd64
+    - linux/amd46
+  allowed:
+    platform:
+      - linux/amd64
+      - linux/arm64
+`
+
+var fakeMatrixIncludeNotAllowed = `
+matrix:
+  include:
+    - platform: linux/amd64
+    - platform: windows/amd64
+  allowed:
+    platform:
+      - linux/amd64
+      - linux/arm64
+`
+
+var fakeMatrixEvent = `
+matrix:
+  go_version:
+    - 1.15
+    - 1.16
+  platform:
+    - linux/amd64
+    - linux/arm64
+matrix_pull_request:
+  go_version:
+    - 1.16
+  platform:
+    - linux/amd64
+`
+
+var fakeMatrixEventNotAllowed = `
+matrix:
+  platform:
+    - linux/amd64
+  allowed:
+    platform:
+      - linux/amd64
+      - linux/arm64
+matrix_pull_request:
+  platform:
+    - linux/amd46
+`
+
+var fakeMatrixReservedAxes = `
+matrix:
+  allowed:
+    - yes
+    - no
+  topics:
+    - docs
+    - api
+`
@@ -0,0 +1,30 @@
+package yaml
+
+import (
+	"net/mail"
+	"net/url"
+
+	libcompose "github.com/docker/libcompose/yaml"
+)
+
+// Notify defines a notification of the build, sent by the server once the
+// build is done to the email addresses and the webhook, e.g. of a chat. The
+// when constraints, e.g. the status, select the builds that are notified.
+type Notify struct {
+	Email   libcompose.Stringorslice `yaml:"email,omitempty"`
+	Webhook string                   `yaml:"webhook,omitempty"`
+	When    Constraints              `yaml:"when,omitempty"`
+}
+
+// ValidNotifyEmail returns true if the email address can be notified.
+func ValidNotifyEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+// ValidNotifyWebhook returns true if the webhook is an absolute http or
+// https url.
+func ValidNotifyWebhook(webhook string) bool {
+	u, err := url.Parse(webhook)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,37 @@
+package yaml
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// OutputRef references an output declared by a previous pipeline step.
+type OutputRef struct {
+	Step string
+	Name string
+}
+
+var (
+	outputName   = regexp.MustCompile(`^\w+$`)
+	outputRefExp = regexp.MustCompile(`^\{\{\s*steps\.([\w-]+)\.outputs\.(\w+)\s*\}\}$`)
+)
+
+// ParseOutputRef parses a step output reference of the form
+// {{ steps.<step>.outputs.<name> }}. It returns false if the string is
+// not an output reference.
+func ParseOutputRef(s string) (OutputRef, bool) {
+	match := outputRefExp.FindStringSubmatch(s)
+	if match == nil {
+		return OutputRef{}, false
+	}
+	return OutputRef{Step: match[1], Name: match[2]}, true
+}
+
+// ValidOutputName returns true if the output name can be declared.
+func ValidOutputName(name string) bool {
+	return outputName.MatchString(name)
+}
+
+func (r OutputRef) String() string {
+	return fmt.Sprintf("steps.%s.outputs.%s", r.Step, r.Name)
+}
@@ -0,0 +1,35 @@
+package yaml
+
+import "testing"
+
+func TestParseOutputRef(t *testing.T) {
+	testdata := []struct {
+		from string
+		want OutputRef
+		ok   bool
+	}{
+		{
+			from: "{{ steps.build.outputs.version }}",
+			want: OutputRef{Step: "build", Name: "version"},
+			ok:   true,
+		},
+		{
+			from: "{{steps.build-image.outputs.tag}}",
+			want: OutputRef{Step: "build-image", Name: "tag"},
+			ok:   true,
+		},
+		{
+			from: "steps.build.outputs.version",
+		},
+		{
+			from: "{{ steps.build.outputs.version }} and more",
+		},
+	}
+
+	for _, test := range testdata {
+		got, ok := ParseOutputRef(test.from)
+		if ok != test.ok || got != test.want {
+			t.Errorf("Want output reference %v, got %v for %q", test.want, got, test.from)
+		}
+	}
+}
@@ -1,6 +1,14 @@
 package yaml
 
-import "gopkg.in/yaml.v3"
+import (
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// secretTarget matches the environment variable names a secret can be
+// exposed as.
+var secretTarget = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
 
 type (
 	// Secrets defines a collection of secrets.
@@ -8,27 +16,41 @@ type (
 		Secrets []*Secret
 	}
 
-	// Secret defines a container secret.
+	// Secret defines a container secret. The secret named source is exposed
+	// to the container as the target environment variable, which defaults to
+	// the source name. ExplicitTarget is true if the target was declared.
 	Secret struct {
-		Source string `yaml:"source"`
-		Target string `yaml:"target"`
+		Source         string `yaml:"source"`
+		Target         string `yaml:"target"`
+		ExplicitTarget bool   `yaml:"-"`
 	}
 )
 
-// UnmarshalYAML implements the Unmarshaller interface.
+// UnmarshalYAML implements the Unmarshaller interface. A secret is either
+// its name, exposed as is, or a mapping of its source to its target name.
 func (s *Secrets) UnmarshalYAML(value *yaml.Node) error {
-	y, _ := yaml.Marshal(value)
-
-	var strslice []string
-	err := yaml.Unmarshal(y, &strslice)
-	if err == nil {
-		for _, str := range strslice {
-			s.Secrets = append(s.Secrets, &Secret{
-				Source: str,
-				Target: str,
-			})
+	var items []yaml.Node
+	if err := value.Decode(&items); err != nil {
+		return err
+	}
+	for _, item := range items {
+		secret := new(Secret)
+		if item.Kind == yaml.ScalarNode {
+			secret.Source = item.Value
+		} else if err := item.Decode(secret); err != nil {
+			return err
+		}
+		secret.ExplicitTarget = secret.Target != ""
+		if !secret.ExplicitTarget {
+			secret.Target = secret.Source
 		}
-		return nil
+		s.Secrets = append(s.Secrets, secret)
 	}
-	return yaml.Unmarshal(y, &s.Secrets)
+	return nil
+}
+
+// ValidSecretTarget returns true if a secret can be exposed as the
+// environment variable name.
+func ValidSecretTarget(name string) bool {
+	return secretTarget.MatchString(name)
 }
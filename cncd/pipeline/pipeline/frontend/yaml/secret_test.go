@@ -30,8 +30,9 @@ func TestUnmarshalSecrets(t *testing.T) {
 			from: "[ { source: mysql_prod_username, target: mysql_username } ]",
 			want: []*Secret{
 				{
-					Source: "mysql_prod_username",
-					Target: "mysql_username",
+					Source:         "mysql_prod_username",
+					Target:         "mysql_username",
+					ExplicitTarget: true,
 				},
 			},
 		},
@@ -39,9 +40,34 @@ func TestUnmarshalSecrets(t *testing.T) {
 			from: "[ { source: mysql_prod_username, target: mysql_username }, { source: redis_username, target: redis_username } ]",
 			want: []*Secret{
 				{
-					Source: "mysql_prod_username",
+					Source:         "mysql_prod_username",
+					Target:         "mysql_username",
+					ExplicitTarget: true,
+				},
+				{
+					Source:         "redis_username",
+					Target:         "redis_username",
+					ExplicitTarget: true,
+				},
+			},
+		},
+		{
+			from: "[ { source: mysql_username } ]",
+			want: []*Secret{
+				{
+					Source: "mysql_username",
 					Target: "mysql_username",
 				},
+			},
+		},
+		{
+			from: "[ { source: mysql_prod_username, target: mysql_username }, redis_username ]",
+			want: []*Secret{
+				{
+					Source:         "mysql_prod_username",
+					Target:         "mysql_username",
+					ExplicitTarget: true,
+				},
 				{
 					Source: "redis_username",
 					Target: "redis_username",
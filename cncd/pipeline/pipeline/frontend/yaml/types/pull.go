@@ -0,0 +1,49 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Image pull policies.
+const (
+	PullAlways       = "always"
+	PullNever        = "never"
+	PullIfNotPresent = "if-not-present"
+)
+
+// PullPolicy is a custom Yaml type for the image pull policy of a step,
+// one of always, never or if-not-present. A boolean is accepted for
+// backward compatibility, true meaning always.
+type PullPolicy string
+
+// UnmarshalYAML implements custom Yaml unmarshaling.
+func (p *PullPolicy) UnmarshalYAML(value *yaml.Node) error {
+	var s string
+	if err := value.Decode(&s); err != nil {
+		return err
+	}
+
+	if v, err := strconv.ParseBool(s); err == nil {
+		if v {
+			*p = PullAlways
+		}
+		return nil
+	}
+	switch s {
+	case PullAlways, PullNever, PullIfNotPresent:
+		*p = PullPolicy(s)
+		return nil
+	}
+	return fmt.Errorf("Invalid pull policy %s, must be %s, %s or %s", s, PullAlways, PullNever, PullIfNotPresent)
+}
+
+// Policy returns the pull policy, defaulting to if-not-present.
+func (p PullPolicy) Policy() string {
+	if p == "" {
+		return PullIfNotPresent
+	}
+	return string(p)
+}
@@ -0,0 +1,36 @@
+package types
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestPullPolicy(t *testing.T) {
+	testTable := []struct {
+		in   string
+		want string
+	}{
+		{in: "", want: PullIfNotPresent},
+		{in: "always", want: PullAlways},
+		{in: "never", want: PullNever},
+		{in: "if-not-present", want: PullIfNotPresent},
+		{in: "true", want: PullAlways},
+		{in: "false", want: PullIfNotPresent},
+	}
+	for _, tt := range testTable {
+		var out PullPolicy
+		if err := yaml.Unmarshal([]byte(tt.in), &out); err != nil {
+			t.Errorf("%q: %s", tt.in, err)
+			continue
+		}
+		if got := out.Policy(); got != tt.want {
+			t.Errorf("%q: want pull policy %s, got %s", tt.in, tt.want, got)
+		}
+	}
+
+	var out PullPolicy
+	if err := yaml.Unmarshal([]byte("sometimes"), &out); err == nil {
+		t.Errorf("Want an error for an invalid pull policy")
+	}
+}
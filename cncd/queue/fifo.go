@@ -380,4 +380,13 @@ func (q *fifo) removeFromPending(taskID string) {
 			return
 		}
 	}
+	for e := q.waitingOnDeps.Front(); e != nil; e = next {
+		next = e.Next()
+		task := e.Value.(*Task)
+		if task.ID == taskID {
+			logrus.Debugf("queue: %s is removed from waiting on deps", taskID)
+			q.waitingOnDeps.Remove(e)
+			return
+		}
+	}
 }
@@ -421,6 +421,34 @@ func TestFifoCancel(t *testing.T) {
 	}
 }
 
+func TestFifoCancelWaitingOnDeps(t *testing.T) {
+	task1 := &Task{
+		ID: "1",
+	}
+
+	task2 := &Task{
+		ID:           "2",
+		Dependencies: []string{"1"},
+		DepStatus:    make(map[string]string),
+	}
+
+	q := New().(*fifo)
+	q.PushAtOnce(noContext, []*Task{task2, task1})
+
+	_, _ = q.Poll(noContext, func(*Task) bool { return true })
+	if info := q.Info(noContext); info.Stats.WaitingOnDeps != 1 {
+		t.Errorf("1 should wait on deps")
+		return
+	}
+
+	q.ErrorAtOnce(noContext, []string{task2.ID}, ErrCancel)
+
+	info := q.Info(noContext)
+	if info.Stats.WaitingOnDeps != 0 || info.Stats.Pending != 0 {
+		t.Errorf("The waiting pipeline should be cancelled")
+	}
+}
+
 func TestFifoPause(t *testing.T) {
 	task1 := &Task{
 		ID: "1",
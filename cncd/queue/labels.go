@@ -0,0 +1,150 @@
+package queue
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Label expression operators.
+const (
+	LabelOpIn    = "in"
+	LabelOpNotIn = "not in"
+	LabelOpNe    = "!="
+	LabelOpGt    = ">"
+	LabelOpGe    = ">="
+	LabelOpLt    = "<"
+	LabelOpLe    = "<="
+)
+
+// LabelExpr matches the value of an agent label against an expression,
+// e.g. memory > 4G or arch in (amd64, arm64).
+type LabelExpr struct {
+	Key    string   `json:"key"`
+	Op     string   `json:"op"`
+	Values []string `json:"values"`
+}
+
+var (
+	labelSetRegexp = regexp.MustCompile(`^(in|not\s+in)\s*\((.*)\)$`)
+	labelCmpRegexp = regexp.MustCompile(`^(>=|<=|!=|>|<)\s*(.+)$`)
+)
+
+// ParseLabelExpr parses the value of the label as an expression. It returns
+// nil if the value is a plain value, which is matched by equality.
+func ParseLabelExpr(key, value string) (*LabelExpr, error) {
+	value = strings.TrimSpace(value)
+
+	if m := labelSetRegexp.FindStringSubmatch(value); m != nil {
+		op := LabelOpIn
+		if m[1] != LabelOpIn {
+			op = LabelOpNotIn
+		}
+		var values []string
+		for _, v := range strings.Split(m[2], ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("Label %s: %s requires at least one value", key, op)
+		}
+		return &LabelExpr{Key: key, Op: op, Values: values}, nil
+	}
+
+	if m := labelCmpRegexp.FindStringSubmatch(value); m != nil {
+		v := strings.TrimSpace(m[2])
+		if m[1] != LabelOpNe {
+			if _, err := parseQuantity(v); err != nil {
+				return nil, fmt.Errorf("Label %s: %s requires a number, got %s", key, m[1], v)
+			}
+		}
+		return &LabelExpr{Key: key, Op: m[1], Values: []string{v}}, nil
+	}
+
+	return nil, nil
+}
+
+// Match returns true if the labels satisfy the expression. Comparisons
+// never match a missing or non-numeric label.
+func (e *LabelExpr) Match(labels map[string]string) bool {
+	label, ok := labels[e.Key]
+
+	switch e.Op {
+	case LabelOpIn:
+		return ok && e.contains(label)
+	case LabelOpNotIn:
+		return !ok || !e.contains(label)
+	case LabelOpNe:
+		return label != e.Values[0]
+	}
+
+	if !ok || len(e.Values) == 0 {
+		return false
+	}
+	have, err := parseQuantity(label)
+	if err != nil {
+		return false
+	}
+	want, err := parseQuantity(e.Values[0])
+	if err != nil {
+		return false
+	}
+	switch e.Op {
+	case LabelOpGt:
+		return have > want
+	case LabelOpGe:
+		return have >= want
+	case LabelOpLt:
+		return have < want
+	case LabelOpLe:
+		return have <= want
+	}
+	return false
+}
+
+// String returns the expression as written in the configuration.
+func (e *LabelExpr) String() string {
+	switch e.Op {
+	case LabelOpIn, LabelOpNotIn:
+		return fmt.Sprintf("%s %s (%s)", e.Key, e.Op, strings.Join(e.Values, ", "))
+	}
+	return fmt.Sprintf("%s %s %s", e.Key, e.Op, strings.Join(e.Values, ""))
+}
+
+func (e *LabelExpr) contains(label string) bool {
+	for _, v := range e.Values {
+		if v == label {
+			return true
+		}
+	}
+	return false
+}
+
+// parseQuantity parses a number with an optional binary unit suffix, e.g.
+// 512M, 4G or 4Gi.
+func parseQuantity(s string) (float64, error) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "i")
+	multiplier := 1.0
+	if n := len(s); n != 0 {
+		switch s[n-1] {
+		case 'K', 'k':
+			multiplier = 1 << 10
+		case 'M', 'm':
+			multiplier = 1 << 20
+		case 'G', 'g':
+			multiplier = 1 << 30
+		case 'T', 't':
+			multiplier = 1 << 40
+		}
+		if multiplier != 1 {
+			s = s[:n-1]
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return f * multiplier, nil
+}
@@ -0,0 +1,70 @@
+package queue
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLabelExpr(t *testing.T) {
+	testTable := []struct {
+		value string
+		want  *LabelExpr
+	}{
+		{value: "linux/amd64", want: nil},
+		{value: "> 4G", want: &LabelExpr{Key: "k", Op: LabelOpGt, Values: []string{"4G"}}},
+		{value: ">=8", want: &LabelExpr{Key: "k", Op: LabelOpGe, Values: []string{"8"}}},
+		{value: "< 1.5", want: &LabelExpr{Key: "k", Op: LabelOpLt, Values: []string{"1.5"}}},
+		{value: "<= 512Mi", want: &LabelExpr{Key: "k", Op: LabelOpLe, Values: []string{"512Mi"}}},
+		{value: "!= windows", want: &LabelExpr{Key: "k", Op: LabelOpNe, Values: []string{"windows"}}},
+		{value: "in (amd64, arm64)", want: &LabelExpr{Key: "k", Op: LabelOpIn, Values: []string{"amd64", "arm64"}}},
+		{value: "not  in (arm)", want: &LabelExpr{Key: "k", Op: LabelOpNotIn, Values: []string{"arm"}}},
+	}
+	for _, tt := range testTable {
+		got, err := ParseLabelExpr("k", tt.value)
+		if err != nil {
+			t.Errorf("%s: %s", tt.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: want %v, got %v", tt.value, tt.want, got)
+		}
+	}
+
+	for _, value := range []string{"> lots", "in ()", "<= 4X"} {
+		if _, err := ParseLabelExpr("k", value); err == nil {
+			t.Errorf("%s: want an error for an invalid expression", value)
+		}
+	}
+}
+
+func TestLabelExprMatch(t *testing.T) {
+	labels := map[string]string{"memory": "16G", "arch": "arm64", "cores": "4"}
+
+	testTable := []struct {
+		expr  string
+		key   string
+		match bool
+	}{
+		{key: "memory", expr: "> 4G", match: true},
+		{key: "memory", expr: "> 16G", match: false},
+		{key: "memory", expr: ">= 16384M", match: true},
+		{key: "cores", expr: "< 8", match: true},
+		{key: "cores", expr: "<= 2", match: false},
+		{key: "arch", expr: "in (amd64, arm64)", match: true},
+		{key: "arch", expr: "not in (amd64, arm64)", match: false},
+		{key: "arch", expr: "!= amd64", match: true},
+		{key: "arch", expr: "> 1", match: false},
+		{key: "gpu", expr: "> 0", match: false},
+		{key: "gpu", expr: "in (true)", match: false},
+		{key: "gpu", expr: "not in (true)", match: true},
+	}
+	for _, tt := range testTable {
+		e, err := ParseLabelExpr(tt.key, tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if match := e.Match(labels); match != tt.match {
+			t.Errorf("%s: want match %v, got %v", e, tt.match, match)
+		}
+	}
+}
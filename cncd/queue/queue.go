@@ -34,6 +34,17 @@ type Task struct {
 
 	// RunOn failure or success
 	RunOn []string
+
+	// ExcludeLabels represents the key-value pairs of agents the entry
+	// must not run on.
+	ExcludeLabels map[string]string `json:"exclude_labels,omitempty"`
+
+	// LabelExprs represents the expressions the labels of agents the entry
+	// runs on must match, in place of the equality of the labels.
+	LabelExprs []*LabelExpr `json:"label_exprs,omitempty"`
+
+	// FailFast cancels the other tasks of the build when this task fails.
+	FailFast bool `json:"fail_fast,omitempty"`
 }
 
 // ShouldRun tells if a task should be run or skipped, based on dependencies
@@ -110,11 +110,14 @@ type (
 
 	// Secret represents a secret variable, such as a password or token.
 	Secret struct {
-		ID     int64    `json:"id"`
-		Name   string   `json:"name"`
-		Value  string   `json:"value,omitempty"`
-		Images []string `json:"image"`
-		Events []string `json:"event"`
+		ID       int64             `json:"id"`
+		Name     string            `json:"name"`
+		Value    string            `json:"value,omitempty"`
+		Images   []string          `json:"image"`
+		Events   []string          `json:"event"`
+		Registry string            `json:"registry,omitempty"`
+		Template bool              `json:"template,omitempty"`
+		Matrix   map[string]string `json:"matrix,omitempty"`
 	}
 
 	// Activity represents an item in the user's feed or timeline.
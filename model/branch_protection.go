@@ -0,0 +1,32 @@
+package model
+
+import "path"
+
+// BranchProtection represents the protection settings of a branch that
+// affect the builds, e.g. to warn if the status context the builds report
+// is not the one required to merge.
+type BranchProtection struct {
+	Branch    string `json:"branch"`
+	Protected bool   `json:"protected"`
+
+	// RequiredContexts are the commit status contexts that must succeed
+	// before a pull request is merged. Contexts may be glob patterns.
+	RequiredContexts []string `json:"required_contexts"`
+
+	// RequiredApprovals is the number of approvals required to merge.
+	RequiredApprovals int `json:"required_approvals"`
+}
+
+// RequiresContext returns true if the status context is one of the
+// required contexts of the branch.
+func (p *BranchProtection) RequiresContext(context string) bool {
+	for _, pattern := range p.RequiredContexts {
+		if pattern == context {
+			return true
+		}
+		if match, _ := path.Match(pattern, context); match {
+			return true
+		}
+	}
+	return false
+}
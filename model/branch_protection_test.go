@@ -0,0 +1,25 @@
+package model
+
+import "testing"
+
+func TestBranchProtectionRequiresContext(t *testing.T) {
+	protection := &BranchProtection{
+		Branch:           "main",
+		Protected:        true,
+		RequiredContexts: []string{"ci/woodpecker", "lint/*"},
+	}
+	testdata := []struct {
+		context string
+		want    bool
+	}{
+		{"ci/woodpecker", true},
+		{"lint/golangci", true},
+		{"ci/drone", false},
+		{"continuous-integration/woodpecker", false},
+	}
+	for _, test := range testdata {
+		if got := protection.RequiresContext(test.context); got != test.want {
+			t.Errorf("Want context %s required %v, got %v", test.context, test.want, got)
+		}
+	}
+}
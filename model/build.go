@@ -30,10 +30,12 @@ type Build struct {
 	Finished     int64    `json:"finished_at"   meddler:"build_finished"`
 	Deploy       string   `json:"deploy_to"     meddler:"build_deploy"`
 	Commit       string   `json:"commit"        meddler:"build_commit"`
+	ConfigRef    string   `json:"config_ref"    meddler:"build_config_ref"`
 	Branch       string   `json:"branch"        meddler:"build_branch"`
 	Ref          string   `json:"ref"           meddler:"build_ref"`
 	Refspec      string   `json:"refspec"       meddler:"build_refspec"`
 	Remote       string   `json:"remote"        meddler:"build_remote"`
+	FromFork     bool     `json:"from_fork"     meddler:"build_from_fork"`
 	Title        string   `json:"title"         meddler:"build_title"`
 	Message      string   `json:"message"       meddler:"build_message"`
 	Timestamp    int64    `json:"timestamp"     meddler:"build_timestamp"`
@@ -0,0 +1,11 @@
+package model
+
+// Commit represents a commit of a repository.
+type Commit struct {
+	SHA     string `json:"sha"`
+	Message string `json:"message"`
+	Author  string `json:"author"`
+	Email   string `json:"author_email"`
+	Created int64  `json:"created_at"`
+	Link    string `json:"link_url"`
+}
@@ -0,0 +1,18 @@
+package model
+
+// CombinedStatus represents the statuses reported for a commit, e.g. by
+// another CI system, and their overall state.
+type CombinedStatus struct {
+	// State is the overall state, success only if all the statuses are
+	// successful. It is empty if no status is reported for the commit.
+	State    string          `json:"state"`
+	Statuses []*CommitStatus `json:"statuses"`
+}
+
+// CommitStatus represents the status reported for a commit by a context.
+type CommitStatus struct {
+	Context     string `json:"context"`
+	State       string `json:"state"`
+	Description string `json:"description"`
+	TargetURL   string `json:"target_url"`
+}
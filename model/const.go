@@ -19,6 +19,8 @@ const (
 	EventPull   = "pull_request"
 	EventTag    = "tag"
 	EventDeploy = "deployment"
+	EventManual = "manual"
+	EventWiki   = "wiki"
 )
 
 const (
@@ -0,0 +1,18 @@
+package model
+
+// HookDelivery represents a delivery of a repository webhook, e.g. to debug
+// why a push did not trigger a build.
+type HookDelivery struct {
+	ID    int64  `json:"id"`
+	GUID  string `json:"guid"`
+	Event string `json:"event"`
+
+	// Status is success or failure once the hook is delivered, pending
+	// before.
+	Status string `json:"status"`
+
+	// StatusCode is the http status the server answered the delivery with,
+	// zero if it did not answer.
+	StatusCode int   `json:"status_code"`
+	Delivered  int64 `json:"delivered_at"`
+}
@@ -0,0 +1,12 @@
+package model
+
+// OrgTeam represents a team within an organization and whether the user is
+// a member of the team.
+type OrgTeam struct {
+	ID          int64  `json:"id"`
+	Org         string `json:"org"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Permission  string `json:"permission"`
+	Member      bool   `json:"member"`
+}
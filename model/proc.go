@@ -45,6 +45,22 @@ type Proc struct {
 	Platform string            `json:"platform,omitempty"   meddler:"proc_platform"`
 	Environ  map[string]string `json:"environ,omitempty"    meddler:"proc_environ,json"`
 	Children []*Proc           `json:"children,omitempty"   meddler:"-"`
+
+	// Stage is the name of the stage of the step, shared by the steps of a
+	// group running in parallel. Only set on step procs.
+	Stage string `json:"stage,omitempty" meddler:"proc_stage"`
+
+	// EnvironSnapshot records the environment the pipeline was compiled
+	// with for auditing, sorted by key. Only set on pipeline procs.
+	EnvironSnapshot []EnvironVar `json:"environ_snapshot,omitempty" meddler:"proc_environ_snapshot,json"`
+}
+
+// EnvironVar is an environment variable of an environment snapshot. The
+// value of secrets is never recorded.
+type EnvironVar struct {
+	Key    string `json:"key"`
+	Value  string `json:"value,omitempty"`
+	Secret bool   `json:"secret,omitempty"`
 }
 
 // Running returns true if the process state is pending or running.
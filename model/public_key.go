@@ -0,0 +1,12 @@
+package model
+
+// PublicKey represents an SSH public key of a user.
+type PublicKey struct {
+	ID          int64  `json:"id"`
+	Title       string `json:"title"`
+	Key         string `json:"key"`
+	KeyType     string `json:"key_type"`
+	Fingerprint string `json:"fingerprint"`
+	ReadOnly    bool   `json:"read_only"`
+	Created     int64  `json:"created_at"`
+}
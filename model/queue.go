@@ -23,11 +23,14 @@ import (
 
 // Task defines scheduled pipeline Task.
 type Task struct {
-	ID           string            `meddler:"task_id"`
-	Data         []byte            `meddler:"task_data"`
-	Labels       map[string]string `meddler:"task_labels,json"`
-	Dependencies []string          `meddler:"task_dependencies,json"`
-	RunOn        []string          `meddler:"task_run_on,json"`
+	ID            string             `meddler:"task_id"`
+	Data          []byte             `meddler:"task_data"`
+	Labels        map[string]string  `meddler:"task_labels,json"`
+	Dependencies  []string           `meddler:"task_dependencies,json"`
+	RunOn         []string           `meddler:"task_run_on,json"`
+	ExcludeLabels map[string]string  `meddler:"task_exclude_labels,json"`
+	LabelExprs    []*queue.LabelExpr `meddler:"task_label_exprs,json"`
+	FailFast      bool               `meddler:"task_fail_fast"`
 }
 
 // TaskStore defines storage for scheduled Tasks.
@@ -44,12 +47,15 @@ func WithTaskStore(q queue.Queue, s TaskStore) queue.Queue {
 	toEnqueue := []*queue.Task{}
 	for _, task := range tasks {
 		toEnqueue = append(toEnqueue, &queue.Task{
-			ID:           task.ID,
-			Data:         task.Data,
-			Labels:       task.Labels,
-			Dependencies: task.Dependencies,
-			RunOn:        task.RunOn,
-			DepStatus:    make(map[string]string),
+			ID:            task.ID,
+			Data:          task.Data,
+			Labels:        task.Labels,
+			Dependencies:  task.Dependencies,
+			RunOn:         task.RunOn,
+			DepStatus:     make(map[string]string),
+			ExcludeLabels: task.ExcludeLabels,
+			LabelExprs:    task.LabelExprs,
+			FailFast:      task.FailFast,
 		})
 	}
 	q.PushAtOnce(context.Background(), toEnqueue)
@@ -64,11 +70,14 @@ type persistentQueue struct {
 // Push pushes a task to the tail of this queue.
 func (q *persistentQueue) Push(c context.Context, task *queue.Task) error {
 	q.store.TaskInsert(&Task{
-		ID:           task.ID,
-		Data:         task.Data,
-		Labels:       task.Labels,
-		Dependencies: task.Dependencies,
-		RunOn:        task.RunOn,
+		ID:            task.ID,
+		Data:          task.Data,
+		Labels:        task.Labels,
+		Dependencies:  task.Dependencies,
+		RunOn:         task.RunOn,
+		ExcludeLabels: task.ExcludeLabels,
+		LabelExprs:    task.LabelExprs,
+		FailFast:      task.FailFast,
 	})
 	err := q.Queue.Push(c, task)
 	if err != nil {
@@ -81,11 +90,14 @@ func (q *persistentQueue) Push(c context.Context, task *queue.Task) error {
 func (q *persistentQueue) PushAtOnce(c context.Context, tasks []*queue.Task) error {
 	for _, task := range tasks {
 		q.store.TaskInsert(&Task{
-			ID:           task.ID,
-			Data:         task.Data,
-			Labels:       task.Labels,
-			Dependencies: task.Dependencies,
-			RunOn:        task.RunOn,
+			ID:            task.ID,
+			Data:          task.Data,
+			Labels:        task.Labels,
+			Dependencies:  task.Dependencies,
+			RunOn:         task.RunOn,
+			ExcludeLabels: task.ExcludeLabels,
+			LabelExprs:    task.LabelExprs,
+			FailFast:      task.FailFast,
 		})
 	}
 	err := q.Queue.PushAtOnce(c, tasks)
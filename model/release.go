@@ -0,0 +1,12 @@
+package model
+
+// Release represents a release of a repository.
+type Release struct {
+	ID         int64  `json:"id"`
+	Tag        string `json:"tag_name"`
+	Name       string `json:"name"`
+	Body       string `json:"body"`
+	Draft      bool   `json:"draft"`
+	Prerelease bool   `json:"prerelease"`
+	Created    int64  `json:"created_at"`
+}
@@ -15,7 +15,9 @@
 package model
 
 import (
+	"errors"
 	"fmt"
+	"path/filepath"
 	"strings"
 )
 
@@ -30,32 +32,43 @@ type RepoLite struct {
 //
 // swagger:model repo
 type Repo struct {
-	ID          int64  `json:"id,omitempty"             meddler:"repo_id,pk"`
-	UserID      int64  `json:"-"                        meddler:"repo_user_id"`
-	Owner       string `json:"owner"                    meddler:"repo_owner"`
-	Name        string `json:"name"                     meddler:"repo_name"`
-	FullName    string `json:"full_name"                meddler:"repo_full_name"`
-	Avatar      string `json:"avatar_url,omitempty"     meddler:"repo_avatar"`
-	Link        string `json:"link_url,omitempty"       meddler:"repo_link"`
-	Kind        string `json:"scm,omitempty"            meddler:"repo_scm"`
-	Clone       string `json:"clone_url,omitempty"      meddler:"repo_clone"`
-	Branch      string `json:"default_branch,omitempty" meddler:"repo_branch"`
-	Timeout     int64  `json:"timeout,omitempty"        meddler:"repo_timeout"`
-	Visibility  string `json:"visibility"               meddler:"repo_visibility"`
-	IsPrivate   bool   `json:"private"                  meddler:"repo_private"`
-	IsTrusted   bool   `json:"trusted"                  meddler:"repo_trusted"`
-	IsStarred   bool   `json:"starred,omitempty"        meddler:"-"`
-	IsGated     bool   `json:"gated"                    meddler:"repo_gated"`
-	IsActive    bool   `json:"active"                   meddler:"repo_active"`
-	AllowPull   bool   `json:"allow_pr"                 meddler:"repo_allow_pr"`
-	AllowPush   bool   `json:"allow_push"               meddler:"repo_allow_push"`
-	AllowDeploy bool   `json:"allow_deploys"            meddler:"repo_allow_deploys"`
-	AllowTag    bool   `json:"allow_tags"               meddler:"repo_allow_tags"`
-	Counter     int    `json:"last_build"               meddler:"repo_counter"`
-	Config      string `json:"config_file"              meddler:"repo_config_path"`
-	Hash        string `json:"-"                        meddler:"repo_hash"`
-	Perm        *Perm  `json:"-"                        meddler:"-"`
-	Fallback    bool   `json:"fallback"                 meddler:"repo_fallback"`
+	ID              int64           `json:"id,omitempty"             meddler:"repo_id,pk"`
+	UserID          int64           `json:"-"                        meddler:"repo_user_id"`
+	Owner           string          `json:"owner"                    meddler:"repo_owner"`
+	Name            string          `json:"name"                     meddler:"repo_name"`
+	FullName        string          `json:"full_name"                meddler:"repo_full_name"`
+	Avatar          string          `json:"avatar_url,omitempty"     meddler:"repo_avatar"`
+	Link            string          `json:"link_url,omitempty"       meddler:"repo_link"`
+	Kind            string          `json:"scm,omitempty"            meddler:"repo_scm"`
+	Clone           string          `json:"clone_url,omitempty"      meddler:"repo_clone"`
+	Branch          string          `json:"default_branch,omitempty" meddler:"repo_branch"`
+	Timeout         int64           `json:"timeout,omitempty"        meddler:"repo_timeout"`
+	Visibility      string          `json:"visibility"               meddler:"repo_visibility"`
+	IsPrivate       bool            `json:"private"                  meddler:"repo_private"`
+	IsTrusted       bool            `json:"trusted"                  meddler:"repo_trusted"`
+	IsStarred       bool            `json:"starred,omitempty"        meddler:"-"`
+	IsArchived      bool            `json:"archived,omitempty"       meddler:"-"`
+	IsGated         bool            `json:"gated"                    meddler:"repo_gated"`
+	IsActive        bool            `json:"active"                   meddler:"repo_active"`
+	AllowPull       bool            `json:"allow_pr"                 meddler:"repo_allow_pr"`
+	AllowPush       bool            `json:"allow_push"               meddler:"repo_allow_push"`
+	AllowDeploy     bool            `json:"allow_deploys"            meddler:"repo_allow_deploys"`
+	AllowTag        bool            `json:"allow_tags"               meddler:"repo_allow_tags"`
+	Counter         int             `json:"last_build"               meddler:"repo_counter"`
+	Config          string          `json:"config_file"              meddler:"repo_config_path"`
+	Hash            string          `json:"-"                        meddler:"repo_hash"`
+	Perm            *Perm           `json:"-"                        meddler:"-"`
+	Fallback        bool            `json:"fallback"                 meddler:"repo_fallback"`
+	Description     string          `json:"description,omitempty"    meddler:"repo_description"`
+	Topics          []string        `json:"topics,omitempty"         meddler:"repo_topics,json"`
+	BranchFallback  bool            `json:"branch_fallback"          meddler:"repo_branch_fallback"`
+	IsGatedExternal bool            `json:"gated_external"           meddler:"repo_gated_external"`
+	StatusContext   string          `json:"status_context,omitempty" meddler:"repo_status_context"`
+	MatrixLimit     int             `json:"matrix_limit,omitempty"   meddler:"repo_matrix_limit"`
+	SkipLint        bool            `json:"skip_lint"                meddler:"repo_skip_lint"`
+	AllowedEvents   []string        `json:"allowed_events,omitempty" meddler:"repo_allowed_events,json"`
+	ConfigRepo      string          `json:"config_repo,omitempty"    meddler:"repo_config_repo"`
+	BranchEnviron   []BranchEnviron `json:"branch_environ,omitempty" meddler:"repo_branch_environ,json"`
 }
 
 func (r *Repo) ResetVisibility() {
@@ -65,6 +78,15 @@ func (r *Repo) ResetVisibility() {
 	}
 }
 
+// StatusContextOr returns the commit status context of the repository,
+// falling back to the given instance-wide context if none is set.
+func (r *Repo) StatusContextOr(fallback string) string {
+	if r.StatusContext != "" {
+		return r.StatusContext
+	}
+	return fallback
+}
+
 // ParseRepo parses the repository owner and name from a string.
 func ParseRepo(str string) (user, repo string, err error) {
 	var parts = strings.Split(str, "/")
@@ -84,6 +106,7 @@ func (r *Repo) Update(from *Repo) {
 	r.Kind = from.Kind
 	r.Clone = from.Clone
 	r.Branch = from.Branch
+	r.Description = from.Description
 	if from.IsPrivate != r.IsPrivate {
 		if from.IsPrivate {
 			r.Visibility = VisibilityPrivate
@@ -96,15 +119,52 @@ func (r *Repo) Update(from *Repo) {
 
 // RepoPatch represents a repository patch object.
 type RepoPatch struct {
-	Config       *string `json:"config_file,omitempty"`
-	IsTrusted    *bool   `json:"trusted,omitempty"`
-	IsGated      *bool   `json:"gated,omitempty"`
-	Timeout      *int64  `json:"timeout,omitempty"`
-	Visibility   *string `json:"visibility,omitempty"`
-	AllowPull    *bool   `json:"allow_pr,omitempty"`
-	AllowPush    *bool   `json:"allow_push,omitempty"`
-	AllowDeploy  *bool   `json:"allow_deploy,omitempty"`
-	AllowTag     *bool   `json:"allow_tag,omitempty"`
-	BuildCounter *int    `json:"build_counter,omitempty"`
-	Fallback     *bool   `json:"fallback,omitempty"`
+	Config          *string          `json:"config_file,omitempty"`
+	IsTrusted       *bool            `json:"trusted,omitempty"`
+	IsGated         *bool            `json:"gated,omitempty"`
+	Timeout         *int64           `json:"timeout,omitempty"`
+	Visibility      *string          `json:"visibility,omitempty"`
+	AllowPull       *bool            `json:"allow_pr,omitempty"`
+	AllowPush       *bool            `json:"allow_push,omitempty"`
+	AllowDeploy     *bool            `json:"allow_deploy,omitempty"`
+	AllowTag        *bool            `json:"allow_tag,omitempty"`
+	BuildCounter    *int             `json:"build_counter,omitempty"`
+	Fallback        *bool            `json:"fallback,omitempty"`
+	BranchFallback  *bool            `json:"branch_fallback,omitempty"`
+	IsGatedExternal *bool            `json:"gated_external,omitempty"`
+	StatusContext   *string          `json:"status_context,omitempty"`
+	MatrixLimit     *int             `json:"matrix_limit,omitempty"`
+	SkipLint        *bool            `json:"skip_lint,omitempty"`
+	AllowedEvents   *[]string        `json:"allowed_events,omitempty"`
+	ConfigRepo      *string          `json:"config_repo,omitempty"`
+	BranchEnviron   *[]BranchEnviron `json:"branch_environ,omitempty"`
+}
+
+// BranchEnviron defines environment variables for the builds of the
+// branches matching the pattern.
+type BranchEnviron struct {
+	Branch  string            `json:"branch"`
+	Environ map[string]string `json:"environ"`
+}
+
+// Match returns true if the branch matches the pattern.
+func (e *BranchEnviron) Match(branch string) bool {
+	match, _ := filepath.Match(e.Branch, branch)
+	return match
+}
+
+// Validate validates the branch pattern and the variable names.
+func (e *BranchEnviron) Validate() error {
+	if e.Branch == "" {
+		return errors.New("Invalid branch environment, branch pattern cannot be empty")
+	}
+	if _, err := filepath.Match(e.Branch, ""); err != nil {
+		return fmt.Errorf("Invalid branch pattern %s", e.Branch)
+	}
+	for name := range e.Environ {
+		if name == "" || strings.ContainsAny(name, "= ") {
+			return fmt.Errorf("Invalid environment variable name %q for branch %s", name, e.Branch)
+		}
+	}
+	return nil
 }
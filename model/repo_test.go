@@ -0,0 +1,30 @@
+package model
+
+import "testing"
+
+func TestRepoStatusContext(t *testing.T) {
+	repo := Repo{}
+	if got := repo.StatusContextOr("continuous-integration/woodpecker"); got != "continuous-integration/woodpecker" {
+		t.Errorf("Want the instance status context, got %s", got)
+	}
+	repo.StatusContext = "ci/team-a"
+	if got := repo.StatusContextOr("continuous-integration/woodpecker"); got != "ci/team-a" {
+		t.Errorf("Want the repository status context, got %s", got)
+	}
+}
+
+func TestBranchEnvironValidate(t *testing.T) {
+	valid := BranchEnviron{Branch: "release/*", Environ: map[string]string{"DEPLOY_ENV": "prod"}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Want a valid branch environment, got %s", err)
+	}
+	for _, environ := range []BranchEnviron{
+		{Environ: map[string]string{"DEPLOY_ENV": "prod"}},
+		{Branch: "[main", Environ: map[string]string{"DEPLOY_ENV": "prod"}},
+		{Branch: "main", Environ: map[string]string{"DEPLOY ENV": "prod"}},
+	} {
+		if err := environ.Validate(); err == nil {
+			t.Errorf("Want an error for branch environment %v", environ)
+		}
+	}
+}
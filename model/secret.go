@@ -17,11 +17,15 @@ package model
 import (
 	"errors"
 	"path/filepath"
+	"strings"
 )
 
 var (
 	errSecretNameInvalid  = errors.New("Invalid Secret Name")
 	errSecretValueInvalid = errors.New("Invalid Secret Value")
+
+	errSecretRegistryValueInvalid = errors.New("Invalid Registry Secret Value, must be username:password")
+	errSecretRegistryTemplate     = errors.New("Invalid Registry Secret, cannot be a template")
 )
 
 // SecretService defines a service for managing secrets.
@@ -46,14 +50,20 @@ type SecretStore interface {
 // Secret represents a secret variable, such as a password or token.
 // swagger:model registry
 type Secret struct {
-	ID         int64    `json:"id"              meddler:"secret_id,pk"`
-	RepoID     int64    `json:"-"               meddler:"secret_repo_id"`
-	Name       string   `json:"name"            meddler:"secret_name"`
-	Value      string   `json:"value,omitempty" meddler:"secret_value"`
-	Images     []string `json:"image"           meddler:"secret_images,json"`
-	Events     []string `json:"event"           meddler:"secret_events,json"`
-	SkipVerify bool     `json:"-"               meddler:"secret_skip_verify"`
-	Conceal    bool     `json:"-"               meddler:"secret_conceal"`
+	ID         int64    `json:"id"                 meddler:"secret_id,pk"`
+	RepoID     int64    `json:"-"                  meddler:"secret_repo_id"`
+	Name       string   `json:"name"               meddler:"secret_name"`
+	Value      string   `json:"value,omitempty"    meddler:"secret_value"`
+	Images     []string `json:"image"              meddler:"secret_images,json"`
+	Events     []string `json:"event"              meddler:"secret_events,json"`
+	SkipVerify bool     `json:"-"                  meddler:"secret_skip_verify"`
+	Conceal    bool     `json:"-"                  meddler:"secret_conceal"`
+	Registry   string   `json:"registry,omitempty" meddler:"secret_registry"`
+	Template   bool     `json:"template,omitempty" meddler:"secret_template"`
+
+	// Matrix limits the secret to the pipelines of the matrix axes with
+	// matching values, e.g. cloud: aws. Values are glob patterns.
+	Matrix map[string]string `json:"matrix,omitempty" meddler:"secret_matrix,json"`
 }
 
 // Match returns true if an image and event match the restricted list.
@@ -69,13 +79,48 @@ func (s *Secret) Match(event string) bool {
 	return false
 }
 
+// MatchAxis returns true if the matrix axis matches the matrix the secret is
+// limited to. Every key of the secret must be an axis of the matrix with a
+// matching value, a secret without a matrix matches every axis.
+func (s *Secret) MatchAxis(axis map[string]string) bool {
+	for key, pattern := range s.Matrix {
+		value, ok := axis[key]
+		if !ok {
+			return false
+		}
+		if match, _ := filepath.Match(pattern, value); !match {
+			return false
+		}
+	}
+	return true
+}
+
+// RegistryCredentials returns the username and password of a secret that
+// holds registry credentials, stored as username:password. It returns false
+// if the secret is not a registry credential.
+func (s *Secret) RegistryCredentials() (username, password string, ok bool) {
+	if s.Registry == "" {
+		return "", "", false
+	}
+	parts := strings.SplitN(s.Value, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
 // Validate validates the required fields and formats.
 func (s *Secret) Validate() error {
+	_, _, credentials := s.RegistryCredentials()
 	switch {
 	case len(s.Name) == 0:
 		return errSecretNameInvalid
 	case len(s.Value) == 0:
 		return errSecretValueInvalid
+	case len(s.Registry) != 0 && !credentials:
+		return errSecretRegistryValueInvalid
+	case len(s.Registry) != 0 && s.Template:
+		return errSecretRegistryTemplate
 	default:
 		return nil
 	}
@@ -84,10 +129,13 @@ func (s *Secret) Validate() error {
 // Copy makes a copy of the secret without the value.
 func (s *Secret) Copy() *Secret {
 	return &Secret{
-		ID:     s.ID,
-		RepoID: s.RepoID,
-		Name:   s.Name,
-		Images: s.Images,
-		Events: s.Events,
+		ID:       s.ID,
+		RepoID:   s.RepoID,
+		Name:     s.Name,
+		Images:   s.Images,
+		Events:   s.Events,
+		Registry: s.Registry,
+		Template: s.Template,
+		Matrix:   s.Matrix,
 	}
 }
@@ -39,6 +39,24 @@ func TestSecret(t *testing.T) {
 			secret := Secret{}
 			g.Assert(secret.Match("pull_request")).IsTrue()
 		})
+		g.It("should match when no matrix defined", func() {
+			secret := Secret{}
+			g.Assert(secret.MatchAxis(map[string]string{"cloud": "aws"})).IsTrue()
+			g.Assert(secret.MatchAxis(nil)).IsTrue()
+		})
+		g.It("should match matrix axis", func() {
+			secret := Secret{}
+			secret.Matrix = map[string]string{"cloud": "aws", "go": "1.1*"}
+			g.Assert(secret.MatchAxis(map[string]string{"cloud": "aws", "go": "1.16", "os": "linux"})).IsTrue()
+		})
+		g.It("should not match matrix axis", func() {
+			secret := Secret{}
+			secret.Matrix = map[string]string{"cloud": "aws", "go": "1.1*"}
+			g.Assert(secret.MatchAxis(map[string]string{"cloud": "gcp", "go": "1.16"})).IsFalse()
+			g.Assert(secret.MatchAxis(map[string]string{"cloud": "aws", "go": "1.9"})).IsFalse()
+			g.Assert(secret.MatchAxis(map[string]string{"cloud": "aws"})).IsFalse()
+			g.Assert(secret.MatchAxis(nil)).IsFalse()
+		})
 		g.It("should pass validation", func() {
 			secret := Secret{}
 			secret.Name = "secretname"
@@ -59,6 +77,35 @@ func TestSecret(t *testing.T) {
 				err := secret.Validate()
 				g.Assert(err != nil).IsTrue()
 			})
+			g.It("when a registry secret has no username", func() {
+				secret := Secret{}
+				secret.Name = "secretname"
+				secret.Value = "secretvalue"
+				secret.Registry = "docker.io"
+				err := secret.Validate()
+				g.Assert(err != nil).IsTrue()
+			})
+			g.It("when a registry secret is a template", func() {
+				secret := Secret{}
+				secret.Name = "secretname"
+				secret.Value = "${USERNAME}:${PASSWORD}"
+				secret.Registry = "docker.io"
+				secret.Template = true
+				err := secret.Validate()
+				g.Assert(err != nil).IsTrue()
+			})
+		})
+		g.It("should return registry credentials", func() {
+			secret := Secret{Registry: "docker.io", Value: "octocat:pa:ss"}
+			username, password, ok := secret.RegistryCredentials()
+			g.Assert(ok).IsTrue()
+			g.Assert(username).Equal("octocat")
+			g.Assert(password).Equal("pa:ss")
+		})
+		g.It("should not return registry credentials without registry", func() {
+			secret := Secret{Value: "octocat:pass"}
+			_, _, ok := secret.RegistryCredentials()
+			g.Assert(ok).IsFalse()
 		})
 	})
 }
@@ -0,0 +1,36 @@
+package environments
+
+import (
+	"github.com/woodpecker-ci/woodpecker/model"
+)
+
+type combined struct {
+	services []model.EnvironService
+}
+
+// Combined returns a service providing the environment variables of all
+// services. Variables of later services take precedence over variables of
+// the same name provided by earlier services.
+func Combined(services ...model.EnvironService) model.EnvironService {
+	return &combined{services}
+}
+
+func (c *combined) EnvironList(repo *model.Repo) ([]*model.Environ, error) {
+	var globals []*model.Environ
+	index := map[string]int{}
+	for _, service := range c.services {
+		list, err := service.EnvironList(repo)
+		if err != nil {
+			return nil, err
+		}
+		for _, env := range list {
+			if i, ok := index[env.Name]; ok {
+				globals[i] = env
+				continue
+			}
+			index[env.Name] = len(globals)
+			globals = append(globals, env)
+		}
+	}
+	return globals, nil
+}
@@ -0,0 +1,75 @@
+package environments
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/woodpecker-ci/woodpecker/model"
+)
+
+type file struct {
+	path string
+
+	sync.Mutex
+	modTime time.Time
+	globals []*model.Environ
+}
+
+// File returns a service providing the environment variables defined in
+// the file at path, one KEY=value pair per line. Empty lines and lines
+// starting with # are ignored. The file is read again when it changes, so
+// variables can be updated without restarting the server.
+func File(path string) model.EnvironService {
+	return &file{path: path}
+}
+
+func (f *file) EnvironList(repo *model.Repo) ([]*model.Environ, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		logrus.Warnf("cannot read environment file %s, using the last known variables. %s", f.path, err)
+		return f.globals, nil
+	}
+	if info.ModTime().Equal(f.modTime) {
+		return f.globals, nil
+	}
+
+	globals, err := parseEnvironFile(f.path)
+	if err != nil {
+		logrus.Warnf("cannot parse environment file %s, using the last known variables. %s", f.path, err)
+		return f.globals, nil
+	}
+	f.globals = globals
+	f.modTime = info.ModTime()
+	return f.globals, nil
+}
+
+func parseEnvironFile(path string) ([]*model.Environ, error) {
+	r, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var globals []*model.Environ
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kvpair := strings.SplitN(line, "=", 2)
+		if len(kvpair) != 2 || kvpair[0] == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=value", n)
+		}
+		globals = append(globals, &model.Environ{Name: kvpair[0], Value: kvpair[1]})
+	}
+	return globals, scanner.Err()
+}
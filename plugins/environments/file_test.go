@@ -0,0 +1,73 @@
+package environments
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/woodpecker-ci/woodpecker/model"
+)
+
+func TestFileReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "environ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "environ")
+	write := func(data string, mod time.Time) {
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	write("# comment\n\nFOO=bar\nURL=http://example.com/?a=b\n", now)
+
+	service := File(path)
+	globals, err := service.EnvironList(&model.Repo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(globals) != 2 || globals[0].Value != "bar" || globals[1].Value != "http://example.com/?a=b" {
+		t.Errorf("unexpected environment %v", globals)
+	}
+
+	write("FOO=baz\n", now.Add(time.Second))
+	globals, _ = service.EnvironList(&model.Repo{})
+	if len(globals) != 1 || globals[0].Value != "baz" {
+		t.Errorf("expected the environment to be reloaded, got %v", globals)
+	}
+
+	write("invalid\n", now.Add(2*time.Second))
+	globals, _ = service.EnvironList(&model.Repo{})
+	if len(globals) != 1 || globals[0].Value != "baz" {
+		t.Errorf("expected the last valid environment to be kept, got %v", globals)
+	}
+}
+
+type mockEnviron []*model.Environ
+
+func (m mockEnviron) EnvironList(*model.Repo) ([]*model.Environ, error) {
+	return m, nil
+}
+
+func TestCombined(t *testing.T) {
+	service := Combined(
+		mockEnviron{{Name: "FOO", Value: "file"}, {Name: "BAR", Value: "file"}},
+		mockEnviron{{Name: "FOO", Value: "flag"}},
+	)
+	globals, err := service.EnvironList(&model.Repo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(globals) != 2 || globals[0].Value != "flag" || globals[1].Value != "file" {
+		t.Errorf("unexpected environment %v", globals)
+	}
+}
@@ -0,0 +1,129 @@
+package gitea
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/woodpecker-ci/woodpecker/remote"
+)
+
+// helper function that sends an authenticated GET request to a Gitea API
+// endpoint not covered by the Gitea SDK and decodes the json response.
+func getAPI(baseURL string, skipVerify bool, token, path string, out interface{}) error {
+	res, err := get(baseURL, skipVerify, token, path)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// helper function that sends an authenticated GET request to a Gitea API
+// endpoint and returns the raw response body. An error is returned if the
+// body is larger than limit bytes, without reading it into memory.
+func getRaw(baseURL string, skipVerify bool, token, path string, limit int64) ([]byte, error) {
+	res, err := get(baseURL, skipVerify, token, path)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	return readLimit(res, limit, errTooLarge(limit))
+}
+
+// helper function that reads the response body, returning tooLarge if the
+// body is larger than limit bytes. A limit of zero disables the limit.
+func readLimit(res *http.Response, limit int64, tooLarge error) ([]byte, error) {
+	if limit <= 0 {
+		return ioutil.ReadAll(res.Body)
+	}
+	if res.ContentLength > limit {
+		return nil, tooLarge
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, tooLarge
+	}
+	return data, nil
+}
+
+func get(baseURL string, skipVerify bool, token, path string) (*http.Response, error) {
+	return send(baseURL, skipVerify, token, "GET", path, "", nil)
+}
+
+func send(baseURL string, skipVerify bool, token, method, path, contentType string, body io.Reader) (*http.Response, error) {
+	return sendURL(skipVerify, token, method, strings.TrimSuffix(baseURL, "/")+"/api/v1"+path, path, contentType, body)
+}
+
+// helper function that sends an authenticated request to the url. The path
+// is used in the error returned for an error status.
+func sendURL(skipVerify bool, token, method, url, path, contentType string, body io.Reader) (*http.Response, error) {
+	httpClient := &http.Client{}
+	if skipVerify {
+		httpClient.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "token "+token)
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		res.Body.Close()
+		return nil, &apiError{Path: path, Status: res.StatusCode}
+	}
+	return res, nil
+}
+
+// apiError is returned for Gitea API responses with an error status.
+type apiError struct {
+	Path   string
+	Status int
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("gitea api %s returned %d", e.Path, e.Status)
+}
+
+// helper function that returns true if the error is a Gitea API response
+// with the given status.
+func isStatus(err error, status int) bool {
+	apiErr, ok := err.(*apiError)
+	return ok && apiErr.Status == status
+}
+
+// helper function to return the error of a file missing at the commit.
+func errFileNotFound(f string) error {
+	return fmt.Errorf("%s: %w", f, remote.ErrFileNotFound)
+}
+
+func errTooLarge(limit int64) error {
+	return fmt.Errorf("config file exceeds the maximum size of %d bytes", limit)
+}
+
+// helper function to return the error of a config folder with more files
+// than the limit.
+func errTooManyFiles(dir string, count, limit int) error {
+	return fmt.Errorf("multi-pipeline %s has %d config files, exceeding the maximum of %d", dir, count, limit)
+}
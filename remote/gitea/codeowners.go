@@ -0,0 +1,24 @@
+package gitea
+
+import (
+	"net/http"
+
+	"code.gitea.io/sdk/gitea"
+	"github.com/woodpecker-ci/woodpecker/remote"
+)
+
+// helper function that returns the first CODEOWNERS file found in the
+// repository at the ref, or nil if there is none.
+func getCodeOwners(client *gitea.Client, owner, name, ref string) ([]byte, error) {
+	for _, path := range remote.CodeOwnersPaths {
+		data, resp, err := client.GetFile(owner, name, ref, path)
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+	return nil, nil
+}
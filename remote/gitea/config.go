@@ -0,0 +1,96 @@
+package gitea
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"code.gitea.io/sdk/gitea"
+	"github.com/woodpecker-ci/woodpecker/model"
+)
+
+// hasConfig returns true if the repository has the config file, or a file in
+// the config folder if the path has a trailing slash, at its default branch.
+func hasConfig(client *gitea.Client, trees *treeSupport, r *model.Repo, path string) (bool, error) {
+	ref := r.Branch
+	if ref == "" {
+		ref = "master"
+	}
+	if strings.HasSuffix(path, "/") {
+		entries, err := listDir(client, trees, r.Owner, r.Name, ref, path)
+		return len(configEntries(entries)) != 0, err
+	}
+	_, resp, err := client.GetFile(r.Owner, r.Name, ref, path)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// configCacheTTL is the time the presence of the config of a repository is
+// remembered, a config added or removed since is only noticed after it.
+const configCacheTTL = 10 * time.Minute
+
+// configCacheSize is the maximum number of repositories for which the
+// presence of the config is remembered.
+const configCacheSize = 4096
+
+// configCache remembers whether a repository has the config at its default
+// branch, so listing the repositories that can be built does not query
+// Gitea for each of them. A nil configCache disables caching.
+type configCache struct {
+	sync.Mutex
+
+	entries map[string]configEntry
+	order   []string
+}
+
+type configEntry struct {
+	exists  bool
+	checked time.Time
+}
+
+func newConfigCache() *configCache {
+	return &configCache{
+		entries: make(map[string]configEntry),
+	}
+}
+
+// Get returns whether the repository has the config, and false if it is not
+// known or was checked longer than configCacheTTL ago.
+func (c *configCache) Get(key string) (exists, ok bool) {
+	if c == nil {
+		return false, false
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Since(entry.checked) > configCacheTTL {
+		return false, false
+	}
+	return entry.exists, true
+}
+
+// Set records whether the repository has the config.
+func (c *configCache) Set(key string, exists bool) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	if _, ok := c.entries[key]; !ok {
+		if len(c.order) >= configCacheSize {
+			delete(c.entries, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = configEntry{exists: exists, checked: time.Now()}
+}
+
+func configCacheKey(r *model.Repo, path string) string {
+	return r.FullName + "@" + r.Branch + ":" + path
+}
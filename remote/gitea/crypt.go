@@ -0,0 +1,39 @@
+package gitea
+
+// Encryptor encrypts the tokens returned by Gitea before they are stored on
+// the user, e.g. with a KMS, and decrypts them before they are sent to
+// Gitea.
+type Encryptor interface {
+	Encrypt(plaintext string) (string, error)
+	Decrypt(ciphertext string) (string, error)
+}
+
+// noopEncryptor stores tokens as returned by Gitea.
+type noopEncryptor struct{}
+
+func (noopEncryptor) Encrypt(plaintext string) (string, error)  { return plaintext, nil }
+func (noopEncryptor) Decrypt(ciphertext string) (string, error) { return ciphertext, nil }
+
+// encryptor returns the encryptor of the options, defaulting to storing
+// tokens as returned by Gitea.
+func encryptor(opts Opts) Encryptor {
+	if opts.Encryptor == nil {
+		return noopEncryptor{}
+	}
+	return opts.Encryptor
+}
+
+// encryptTokens encrypts the token and refresh token of the user.
+func encryptTokens(crypt Encryptor, token, secret string) (string, string, error) {
+	token, err := crypt.Encrypt(token)
+	if err != nil {
+		return "", "", err
+	}
+	if secret != "" {
+		secret, err = crypt.Encrypt(secret)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	return token, secret, nil
+}
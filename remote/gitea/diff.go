@@ -0,0 +1,60 @@
+package gitea
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/woodpecker-ci/woodpecker/model"
+)
+
+// helper function to return the unified diff between the base and head
+// commits of the repository. The diff is served by the compare page, as
+// the Gitea API has no compare endpoint returning a diff.
+func getDiff(baseURL string, skipVerify bool, token string, limit int64, r *model.Repo, base, head string) ([]byte, error) {
+	path := fmt.Sprintf("/%s/%s/compare/%s...%s.diff", r.Owner, r.Name, url.PathEscape(base), url.PathEscape(head))
+	res, err := sendURL(skipVerify, token, "GET", strings.TrimSuffix(baseURL, "/")+path, path, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	return readLimit(res, limit, fmt.Errorf("diff of %s...%s exceeds the maximum size of %d bytes", base, head, limit))
+}
+
+// helper function to return the files changed by a unified diff, in diff
+// order. Renamed files are listed with their old and new path.
+func diffFiles(diff []byte) []string {
+	var files []string
+	seen := map[string]bool{}
+	add := func(file string) {
+		if file != "" && file != "/dev/null" && !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	for _, line := range strings.Split(string(diff), "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			// the header of files without content changes, e.g. binary
+			// files, is the only line naming them. The old and new path
+			// are the same unless the file is renamed.
+			paths := strings.TrimPrefix(line, "diff --git ")
+			half := (len(paths) - 1) / 2
+			if len(paths)%2 == 1 && paths[half] == ' ' &&
+				strings.HasPrefix(paths, "a/") && strings.HasPrefix(paths[half+1:], "b/") &&
+				paths[2:half] == paths[half+3:] {
+				add(paths[2:half])
+			}
+		case strings.HasPrefix(line, "--- a/"):
+			add(strings.TrimPrefix(line, "--- a/"))
+		case strings.HasPrefix(line, "+++ b/"):
+			add(strings.TrimPrefix(line, "+++ b/"))
+		case strings.HasPrefix(line, "rename from "):
+			add(strings.TrimPrefix(line, "rename from "))
+		case strings.HasPrefix(line, "rename to "):
+			add(strings.TrimPrefix(line, "rename to "))
+		}
+	}
+	return files
+}
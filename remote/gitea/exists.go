@@ -0,0 +1,31 @@
+package gitea
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/woodpecker-ci/woodpecker/remote"
+)
+
+// helper function that checks whether the repository exists with a HEAD
+// request, falling back to a GET request if Gitea does not allow HEAD
+// requests. Gitea hides private repositories the user cannot access, so
+// they are reported as gone, callers must confirm it with another account.
+func repoExists(baseURL string, skipVerify bool, token, owner, name string) (bool, error) {
+	path := fmt.Sprintf("/repos/%s/%s", owner, name)
+	res, err := send(baseURL, skipVerify, token, "HEAD", path, "", nil)
+	if isStatus(err, http.StatusMethodNotAllowed) {
+		res, err = get(baseURL, skipVerify, token, path)
+	}
+	switch {
+	case err == nil:
+		res.Body.Close()
+		return true, nil
+	case isStatus(err, http.StatusNotFound):
+		return false, nil
+	case isStatus(err, http.StatusUnauthorized), isStatus(err, http.StatusForbidden):
+		return false, remote.ErrRepoNoAccess
+	default:
+		return false, err
+	}
+}
@@ -15,7 +15,10 @@
 package fixtures
 
 import (
+	"fmt"
+	"io/ioutil"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -27,13 +30,34 @@ func Handler() http.Handler {
 
 	e := gin.New()
 	e.GET("/api/v1/repos/:owner/:name", getRepo)
+	e.HEAD("/api/v1/repos/:owner/:name", getRepo)
 	e.GET("/api/v1/repos/:owner/:name/raw/:commit/:file", getRepoFile)
+	e.GET("/api/v1/repos/:owner/:name/git/commits/:commit", getRepoCommit)
+	e.GET("/api/v1/repos/:owner/:name/git/trees/:sha", getRepoTree)
+	e.GET("/api/v1/repos/:owner/:name/commits", getRepoCommits)
+	e.GET("/api/v1/repos/:owner/:name/contents/*path", getRepoContents)
+	e.GET("/api/v1/repos/:owner/:name/topics", getRepoTopics)
+	e.GET("/api/v1/repos/:owner/:name/branches/:branch", getRepoBranch)
+	e.GET("/api/v1/repos/:owner/:name/tags/:tag", getRepoTag)
+	e.GET("/api/v1/repos/:owner/:name/branch_protections/:branch", getRepoBranchProtection)
+	e.GET("/api/v1/repos/:owner/:name/releases", getRepoReleases)
+	e.GET("/api/v1/repos/:owner/:name/releases/tags/:tag", getRepoReleaseByTag)
+	e.POST("/api/v1/repos/:owner/:name/releases", createRepoRelease)
+	e.POST("/api/v1/repos/:owner/:name/releases/:id/assets", createRepoReleaseAsset)
+	e.GET("/api/v1/repos/:owner/:name/collaborators/:login/permission", getRepoCollaboratorPerm)
 	e.POST("/api/v1/repos/:owner/:name/hooks", createRepoHook)
 	e.GET("/api/v1/repos/:owner/:name/hooks", listRepoHooks)
+	e.GET("/api/v1/repos/:owner/:name/hooks/:id", getRepoHook)
+	e.GET("/api/v1/repos/:owner/:name/hooks/:id/deliveries", getRepoHookDeliveries)
 	e.DELETE("/api/v1/repos/:owner/:name/hooks/:id", deleteRepoHook)
 	e.POST("/api/v1/repos/:owner/:name/statuses/:commit", createRepoCommitStatus)
+	e.GET("/api/v1/repos/:owner/:name/commits/:commit/status", getRepoCombinedStatus)
 	e.GET("/api/v1/user/repos", getUserRepos)
+	e.GET("/api/v1/user/teams", getUserTeams)
+	e.GET("/api/v1/user/keys", getUserKeys)
+	e.GET("/api/v1/orgs/:org/teams", getOrgTeams)
 	e.GET("/api/v1/version", getVersion)
+	e.POST("/login/oauth/access_token", postAccessToken)
 
 	return e
 }
@@ -46,6 +70,12 @@ func getRepo(c *gin.Context) {
 	switch c.Param("name") {
 	case "repo_not_found":
 		c.String(404, "")
+	case "repo_forbidden":
+		c.String(403, "")
+	case "repo_archived":
+		c.String(200, repoArchivedPayload)
+	case "repo_read_only":
+		c.String(200, repoReadOnlyPayload)
 	default:
 		c.String(200, repoPayload)
 	}
@@ -59,15 +89,181 @@ func createRepoCommitStatus(c *gin.Context) {
 }
 
 func getRepoFile(c *gin.Context) {
+	if c.Param("file") == "CODEOWNERS" {
+		if c.Param("commit") == "9ecad50" {
+			c.String(200, codeOwnersPayload)
+			return
+		}
+		c.String(404, "")
+		return
+	}
 	if c.Param("file") == "file_not_found" {
 		c.String(404, "")
 	}
+	if c.Param("file") == "file_too_large" {
+		c.String(200, strings.Repeat("#", 4096))
+		return
+	}
 	if c.Param("commit") == "v1.0.0" || c.Param("commit") == "9ecad50" {
 		c.String(200, repoFilePayload)
 	}
 	c.String(404, "")
 }
 
+func getRepoTopics(c *gin.Context) {
+	switch c.Param("name") {
+	case "repo_not_found":
+		c.String(404, "")
+	default:
+		c.String(200, repoTopicsPayload)
+	}
+}
+
+func postAccessToken(c *gin.Context) {
+	switch {
+	case c.PostForm("client_id") != "client":
+		c.String(400, accessTokenInvalidClientPayload)
+	case c.PostForm("client_secret") != "secret":
+		c.String(400, accessTokenInvalidSecretPayload)
+	case c.PostForm("grant_type") == "refresh_token" && c.PostForm("refresh_token") == "refresh_token":
+		c.Header("Content-Type", "application/json")
+		c.String(200, accessTokenRefreshedPayload)
+	default:
+		c.String(400, accessTokenInvalidCodePayload)
+	}
+}
+
+func getRepoCollaboratorPerm(c *gin.Context) {
+	switch c.Param("login") {
+	case "octocat":
+		c.String(200, collaboratorWritePayload)
+	default:
+		c.String(403, "")
+	}
+}
+
+func getRepoCommit(c *gin.Context) {
+	switch c.Param("commit") {
+	case "9ecad50":
+		c.String(200, repoCommitVerifiedPayload)
+	case "v1.0.0":
+		c.String(200, repoCommitUnverifiedPayload)
+	case "6dcb09b5b57875f334f61aebed695e2e4193db5e":
+		c.String(200, repoCommitPayload)
+	default:
+		c.String(404, "")
+	}
+}
+
+func getRepoBranch(c *gin.Context) {
+	switch c.Param("branch") {
+	case "main":
+		c.String(200, repoBranchPayload)
+	default:
+		c.String(404, "")
+	}
+}
+
+func getRepoBranchProtection(c *gin.Context) {
+	switch c.Param("branch") {
+	case "main":
+		c.String(200, repoBranchProtectionPayload)
+	default:
+		c.String(404, "")
+	}
+}
+
+func getRepoTag(c *gin.Context) {
+	switch c.Param("tag") {
+	case "v1.0.0":
+		c.String(200, repoTagPayload)
+	case "main":
+		c.String(200, repoTagMainPayload)
+	default:
+		c.String(404, "")
+	}
+}
+
+func getRepoReleases(c *gin.Context) {
+	switch c.Query("page") {
+	case "1":
+		c.String(200, repoReleasesPayload)
+	case "2":
+		c.String(200, repoReleasesPage2Payload)
+	default:
+		c.String(200, "[]")
+	}
+}
+
+func getRepoReleaseByTag(c *gin.Context) {
+	switch c.Param("tag") {
+	case "v1.0.0":
+		c.String(200, repoReleasePayload)
+	default:
+		c.String(404, "")
+	}
+}
+
+func createRepoRelease(c *gin.Context) {
+	in := struct {
+		Tag        string `json:"tag_name"`
+		Name       string `json:"name"`
+		Draft      bool   `json:"draft"`
+		Prerelease bool   `json:"prerelease"`
+	}{}
+	if err := c.BindJSON(&in); err != nil || in.Tag == "" {
+		c.String(422, "")
+		return
+	}
+	c.String(201, fmt.Sprintf(repoReleaseCreatedPayload, in.Tag, in.Name, in.Draft, in.Prerelease))
+}
+
+// release assets larger than 1 KiB are rejected.
+func createRepoReleaseAsset(c *gin.Context) {
+	file, header, err := c.Request.FormFile("attachment")
+	if err != nil || c.Param("id") != "1" {
+		c.String(400, "")
+		return
+	}
+	data, _ := ioutil.ReadAll(file)
+	if len(data) > 1024 {
+		c.String(413, "")
+		return
+	}
+	c.String(201, fmt.Sprintf(repoReleaseAssetPayload, header.Filename, len(data)))
+}
+
+func getRepoTree(c *gin.Context) {
+	c.String(200, repoTreePayload)
+}
+
+func getRepoCommits(c *gin.Context) {
+	if c.Param("name") == "empty_repo" {
+		c.String(409, `{"message": "Git Repository is empty."}`)
+		return
+	}
+	if c.Query("sha") != "main" {
+		c.String(404, "")
+		return
+	}
+	switch c.Query("page") {
+	case "1":
+		c.String(200, repoCommitsPayload)
+	case "2":
+		c.String(200, repoCommitsPage2Payload)
+	default:
+		c.String(200, "[]")
+	}
+}
+
+func getRepoContents(c *gin.Context) {
+	if c.Param("path") != "/.woodpecker" {
+		c.String(404, "")
+		return
+	}
+	c.String(200, repoContentsPayload)
+}
+
 func createRepoHook(c *gin.Context) {
 	in := struct {
 		Type string `json:"type"`
@@ -91,6 +287,74 @@ func deleteRepoHook(c *gin.Context) {
 	c.String(200, "{}")
 }
 
+func getRepoHook(c *gin.Context) {
+	switch c.Param("id") {
+	case "1", "2", "4":
+		c.String(200, repoHookPayload)
+	default:
+		c.String(404, "")
+	}
+}
+
+func getRepoHookDeliveries(c *gin.Context) {
+	switch c.Param("id") {
+	case "1":
+		c.String(200, repoHookDeliveriesPayload)
+	case "4":
+		// forks without the deliveries api
+		c.String(501, "")
+	default:
+		// missing hooks and versions without the deliveries api
+		c.String(404, "")
+	}
+}
+
+func getUserTeams(c *gin.Context) {
+	switch c.Query("page") {
+	case "1":
+		c.String(200, userTeamsPayload)
+	default:
+		c.String(200, "[]")
+	}
+}
+
+func getRepoCombinedStatus(c *gin.Context) {
+	switch c.Param("commit") {
+	case "green":
+		c.String(200, combinedStatusGreenPayload)
+	case "mixed":
+		c.String(200, combinedStatusMixedPayload)
+	default:
+		c.String(200, combinedStatusEmptyPayload)
+	}
+}
+
+func getUserKeys(c *gin.Context) {
+	switch c.Query("page") {
+	case "1":
+		c.String(200, userKeysPayload)
+	case "2":
+		c.String(200, userKeysPage2Payload)
+	default:
+		c.String(200, "[]")
+	}
+}
+
+func getOrgTeams(c *gin.Context) {
+	if c.Param("org") != "test_org" {
+		c.String(404, "")
+		return
+	}
+	switch c.Query("page") {
+	case "1":
+		c.String(200, orgTeamsPayload)
+	case "2":
+		c.String(200, orgTeamsPage2Payload)
+	default:
+		c.String(200, "[]")
+	}
+}
+
 func getUserRepos(c *gin.Context) {
 	switch c.Request.Header.Get("Authorization") {
 	case "token repos_not_found":
@@ -122,6 +386,52 @@ const listRepoHookPayloads = `
 ]
 `
 
+const repoHookPayload = `
+{
+  "id": 1,
+  "type": "gitea",
+  "config": {
+    "content_type": "json",
+    "url": "http:\/\/localhost\/hook?access_token=1234567890"
+  },
+  "active": true
+}
+`
+
+const repoHookDeliveriesPayload = `
+[
+  {
+    "id": 3,
+    "uuid": "5c0d6c1a-8b5e-4d45-9c11-3b7e3e0f9a41",
+    "event_type": "push",
+    "is_delivered": false,
+    "is_succeed": false
+  },
+  {
+    "id": 2,
+    "uuid": "0e0b8f25-31a1-4b4c-8a0e-2f2d3a9d5c17",
+    "event_type": "pull_request",
+    "is_delivered": true,
+    "is_succeed": false,
+    "delivered": "2021-01-01T10:05:00Z",
+    "response": {
+      "status": 502
+    }
+  },
+  {
+    "id": 1,
+    "uuid": "a3b1f2e4-7c4d-4c8e-b5a6-9d0e1f2a3b4c",
+    "event_type": "push",
+    "is_delivered": true,
+    "is_succeed": true,
+    "delivered": "2021-01-01T10:00:00Z",
+    "response": {
+      "status": 200
+    }
+  }
+]
+`
+
 const repoPayload = `
 {
   "owner": {
@@ -130,6 +440,7 @@ const repoPayload = `
     "avatar_url": "https:\/\/secure.gravatar.com\/avatar\/8c58a0be77ee441bb8f8595b7f1b4e87"
   },
   "full_name": "test_name\/repo_name",
+  "description": "a test repository",
   "private": true,
   "html_url": "http:\/\/localhost\/test_name\/repo_name",
   "clone_url": "http:\/\/localhost\/test_name\/repo_name.git",
@@ -141,8 +452,440 @@ const repoPayload = `
 }
 `
 
+const repoArchivedPayload = `
+{
+  "owner": {
+    "login": "test_name",
+    "email": "octocat@github.com",
+    "avatar_url": "https:\/\/secure.gravatar.com\/avatar\/8c58a0be77ee441bb8f8595b7f1b4e87"
+  },
+  "full_name": "test_name\/repo_archived",
+  "private": true,
+  "archived": true,
+  "html_url": "http:\/\/localhost\/test_name\/repo_archived",
+  "clone_url": "http:\/\/localhost\/test_name\/repo_archived.git",
+  "permissions": {
+    "admin": true,
+    "push": true,
+    "pull": true
+  }
+}
+`
+
+const repoReadOnlyPayload = `
+{
+  "owner": {
+    "login": "test_name",
+    "email": "octocat@github.com",
+    "avatar_url": "https:\/\/secure.gravatar.com\/avatar\/8c58a0be77ee441bb8f8595b7f1b4e87"
+  },
+  "full_name": "test_name\/repo_read_only",
+  "private": true,
+  "html_url": "http:\/\/localhost\/test_name\/repo_read_only",
+  "clone_url": "http:\/\/localhost\/test_name\/repo_read_only.git",
+  "permissions": {
+    "admin": false,
+    "push": false,
+    "pull": true
+  }
+}
+`
+
+const repoBranchPayload = `
+{
+  "name": "main",
+  "commit": {
+    "id": "9ecad50",
+    "message": "update readme",
+    "url": "http:\/\/localhost\/test_name\/repo_name\/commit\/9ecad50"
+  }
+}
+`
+
+const repoTagPayload = `
+{
+  "name": "v1.0.0",
+  "id": "1b2c3d4",
+  "commit": {
+    "sha": "e5f4a2b",
+    "url": "http:\/\/localhost\/api\/v1\/repos\/test_name\/repo_name\/git\/commits\/e5f4a2b"
+  }
+}
+`
+
+const repoTagMainPayload = `
+{
+  "name": "main",
+  "id": "5d6e7f8",
+  "commit": {
+    "sha": "0a1b2c3",
+    "url": "http:\/\/localhost\/api\/v1\/repos\/test_name\/repo_name\/git\/commits\/0a1b2c3"
+  }
+}
+`
+
+const repoReleasesPayload = `
+[
+  {
+    "id": 3,
+    "tag_name": "v1.1.0",
+    "name": "v1.1.0",
+    "body": "upcoming release",
+    "draft": true,
+    "prerelease": false,
+    "created_at": "2021-03-01T10:00:00Z"
+  },
+  {
+    "id": 2,
+    "tag_name": "v1.1.0-rc1",
+    "name": "v1.1.0 release candidate",
+    "body": "release candidate",
+    "draft": false,
+    "prerelease": true,
+    "created_at": "2021-02-01T10:00:00Z"
+  }
+]
+`
+
+const repoReleasesPage2Payload = `
+[
+  {
+    "id": 1,
+    "tag_name": "v1.0.0",
+    "name": "v1.0.0",
+    "body": "first release",
+    "draft": false,
+    "prerelease": false,
+    "created_at": "2021-01-01T10:00:00Z"
+  }
+]
+`
+
+const repoReleasePayload = `
+{
+  "id": 1,
+  "tag_name": "v1.0.0",
+  "name": "v1.0.0",
+  "body": "first release",
+  "draft": false,
+  "prerelease": false,
+  "created_at": "2021-01-01T10:00:00Z"
+}
+`
+
+const repoReleaseCreatedPayload = `
+{
+  "id": 4,
+  "tag_name": "%s",
+  "name": "%s",
+  "body": "",
+  "draft": %v,
+  "prerelease": %v,
+  "created_at": "2021-04-01T10:00:00Z"
+}
+`
+
+const repoReleaseAssetPayload = `
+{
+  "id": 1,
+  "name": "%s",
+  "size": %d
+}
+`
+
+const repoBranchProtectionPayload = `
+{
+  "branch_name": "main",
+  "enable_status_check": true,
+  "status_check_contexts": ["ci/woodpecker", "lint/*"],
+  "required_approvals": 2
+}
+`
+
+const repoTopicsPayload = `
+{
+  "topics": ["go", "ci"]
+}
+`
+
+const collaboratorWritePayload = `
+{
+  "permission": "write",
+  "role_name": "write"
+}
+`
+
 const repoFilePayload = `{ platform: linux/amd64 }`
 
+const codeOwnersPayload = `*.go @octocat
+/docs/ @org/docs
+`
+
+const repoTreePayload = `
+{
+  "sha": "9ecad50",
+  "truncated": false,
+  "tree": [
+    {
+      "path": ".woodpecker/release.yml",
+      "type": "blob",
+      "size": 4096
+    },
+    {
+      "path": ".woodpecker/build.yml",
+      "type": "blob",
+      "size": 24
+    },
+    {
+      "path": ".woodpecker/README.md",
+      "type": "blob",
+      "size": 512
+    }
+  ]
+}
+`
+
+const repoCommitsPayload = `
+[
+  {
+    "sha": "0a1b2c3d4e5f60718293a4b5c6d7e8f901234567",
+    "html_url": "http://localhost/test_name/repo_name/commit/0a1b2c3d4e5f60718293a4b5c6d7e8f901234567",
+    "commit": {
+      "message": "update readme",
+      "author": {
+        "name": "Test User",
+        "email": "test@example.com",
+        "date": "2021-01-02T10:00:00Z"
+      }
+    }
+  },
+  {
+    "sha": "9ecad50",
+    "html_url": "http://localhost/test_name/repo_name/commit/9ecad50",
+    "commit": {
+      "message": "initial commit",
+      "author": {
+        "name": "Test User",
+        "email": "test@example.com",
+        "date": "2021-01-01T10:00:00Z"
+      }
+    }
+  }
+]
+`
+
+const repoCommitsPage2Payload = `
+[
+  {
+    "sha": "6dcb09b5b57875f334f61aebed695e2e4193db5e",
+    "html_url": "http://localhost/test_name/repo_name/commit/6dcb09b5b57875f334f61aebed695e2e4193db5e",
+    "commit": {
+      "message": "import",
+      "author": {
+        "name": "Other User",
+        "email": "other@example.com",
+        "date": "2020-12-31T10:00:00Z"
+      }
+    }
+  }
+]
+`
+
+const userTeamsPayload = `
+[
+  {
+    "id": 2,
+    "name": "Developers",
+    "permission": "write"
+  }
+]
+`
+
+const combinedStatusGreenPayload = `
+{
+  "state": "success",
+  "sha": "green",
+  "total_count": 2,
+  "statuses": [
+    {
+      "id": 1,
+      "status": "success",
+      "target_url": "https://jenkins.example.com/job/build/1",
+      "description": "Build passed",
+      "context": "jenkins/build"
+    },
+    {
+      "id": 2,
+      "status": "success",
+      "target_url": "https://sonar.example.com/dashboard",
+      "description": "Quality gate passed",
+      "context": "sonar/quality"
+    }
+  ]
+}
+`
+
+const combinedStatusMixedPayload = `
+{
+  "state": "failure",
+  "sha": "mixed",
+  "total_count": 3,
+  "statuses": [
+    {
+      "id": 1,
+      "status": "success",
+      "target_url": "https://jenkins.example.com/job/build/2",
+      "description": "Build passed",
+      "context": "jenkins/build"
+    },
+    {
+      "id": 2,
+      "status": "warning",
+      "target_url": "https://sonar.example.com/dashboard",
+      "description": "Quality gate failed",
+      "context": "sonar/quality"
+    },
+    {
+      "id": 3,
+      "status": "pending",
+      "target_url": "",
+      "description": "Waiting for approval",
+      "context": "review"
+    }
+  ]
+}
+`
+
+const combinedStatusEmptyPayload = `
+{
+  "state": "pending",
+  "sha": "none",
+  "total_count": 0,
+  "statuses": []
+}
+`
+
+const userKeysPayload = `
+[
+  {
+    "id": 1,
+    "key": "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIJ3+5mJ3vJq3v6rHf9WmW2c6xv1oVKCLwQ2rXcDpHoPF",
+    "title": "laptop",
+    "fingerprint": "SHA256:9AYEMZdzj3tmdp8NvlXhtWXa5zEg+Y8JABtS4GTqvXk",
+    "created_at": "2021-01-01T10:00:00Z",
+    "read_only": false,
+    "key_type": "user"
+  },
+  {
+    "id": 2,
+    "key": "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQC7",
+    "title": "deploy",
+    "fingerprint": "SHA256:Y5g0bsCunXsQ1pN5Fxh1D3m1dH6AOEvKHMnk2Q7eEbM",
+    "created_at": "2021-01-02T10:00:00Z",
+    "read_only": true,
+    "key_type": "user"
+  }
+]
+`
+
+const userKeysPage2Payload = `
+[
+  {
+    "id": 3,
+    "key": "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIFnBxDn0bGmW9FvtQ6XlD2Ec3qWXZz5M3sBq6v7p2mXh",
+    "title": "desktop",
+    "fingerprint": "SHA256:pG7Yy0x3lYz2L0G2o2q4gq7mJ3mYc4jKc9iN1yUj7wE",
+    "created_at": "2021-01-03T10:00:00Z",
+    "read_only": false,
+    "key_type": "user"
+  }
+]
+`
+
+const orgTeamsPayload = `
+[
+  {
+    "id": 1,
+    "name": "Owners",
+    "description": "Owners of the organization",
+    "permission": "owner"
+  },
+  {
+    "id": 2,
+    "name": "Developers",
+    "permission": "write"
+  }
+]
+`
+
+const orgTeamsPage2Payload = `
+[
+  {
+    "id": 3,
+    "name": "Readers",
+    "permission": "read"
+  }
+]
+`
+
+const repoContentsPayload = `
+[
+  {
+    "name": "release.yml",
+    "path": ".woodpecker/release.yml",
+    "type": "file",
+    "size": 4096
+  },
+  {
+    "name": "build.yml",
+    "path": ".woodpecker/build.yml",
+    "type": "file",
+    "size": 24
+  },
+  {
+    "name": "scripts",
+    "path": ".woodpecker/scripts",
+    "type": "dir",
+    "size": 0
+  }
+]
+`
+
+const repoCommitVerifiedPayload = `
+{
+  "sha": "9ecad50",
+  "commit": {
+    "message": "signed commit",
+    "verification": {
+      "verified": true,
+      "reason": ""
+    }
+  }
+}
+`
+
+const repoCommitPayload = `
+{
+  "sha": "6dcb09b5b57875f334f61aebed695e2e4193db5e",
+  "commit": {
+    "message": "initial commit"
+  }
+}
+`
+
+const repoCommitUnverifiedPayload = `
+{
+  "sha": "v1.0.0",
+  "commit": {
+    "message": "unsigned commit",
+    "verification": {
+      "verified": false,
+      "reason": "gpg.error.not_signed_commit"
+    }
+  }
+}
+`
+
 const userRepoPayload = `
 [
   {
@@ -160,6 +903,53 @@ const userRepoPayload = `
       "push": true,
       "pull": true
     }
+  },
+  {
+    "owner": {
+      "login": "test_name",
+      "email": "octocat@github.com",
+      "avatar_url": "https:\/\/secure.gravatar.com\/avatar\/8c58a0be77ee441bb8f8595b7f1b4e87"
+    },
+    "full_name": "test_name\/repo_archived",
+    "private": true,
+    "archived": true,
+    "html_url": "http:\/\/localhost\/test_name\/repo_archived",
+    "clone_url": "http:\/\/localhost\/test_name\/repo_archived.git",
+    "permissions": {
+      "admin": true,
+      "push": true,
+      "pull": true
+    }
   }
 ]
 `
+
+const accessTokenRefreshedPayload = `
+{
+  "access_token": "refreshed_token",
+  "refresh_token": "refreshed_refresh_token",
+  "token_type": "bearer",
+  "expires_in": 3600
+}
+`
+
+const accessTokenInvalidClientPayload = `
+{
+  "error": "invalid_client",
+  "error_description": "cannot load client with client id: 'unknown'"
+}
+`
+
+const accessTokenInvalidSecretPayload = `
+{
+  "error": "unauthorized_client",
+  "error_description": "invalid client secret"
+}
+`
+
+const accessTokenInvalidCodePayload = `
+{
+  "error": "unauthorized_client",
+  "error_description": "client is not authorized"
+}
+`
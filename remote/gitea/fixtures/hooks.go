@@ -105,6 +105,42 @@ const HookPushTag = `{
   }
 }`
 
+// HookWiki is a sample wiki webhook payload
+const HookWiki = `{
+  "action": "edited",
+  "repository": {
+    "id": 1,
+    "owner": {
+      "id": 1,
+      "username": "gordon",
+      "full_name": "Gordon the Gopher",
+      "email": "gordon@golang.org",
+      "avatar_url": "https://secure.gravatar.com/avatar/8c58a0be77ee441bb8f8595b7f1b4e87"
+    },
+    "name": "hello-world",
+    "full_name": "gordon/hello-world",
+    "description": "",
+    "private": true,
+    "fork": false,
+    "html_url": "http://gitea.golang.org/gordon/hello-world",
+    "ssh_url": "git@gitea.golang.org:gordon/hello-world.git",
+    "clone_url": "http://gitea.golang.org/gordon/hello-world.git",
+    "default_branch": "main",
+    "created_at": "2015-10-22T19:32:44Z",
+    "updated_at": "2016-11-24T13:37:16Z"
+  },
+  "sender": {
+    "id": 1,
+    "login": "gordon",
+    "username": "gordon",
+    "full_name": "Gordon the Gopher",
+    "email": "gordon@golang.org",
+    "avatar_url": "https://secure.gravatar.com/avatar/8c58a0be77ee441bb8f8595b7f1b4e87"
+  },
+  "page": "Getting Started",
+  "comment": "Document the installation"
+}`
+
 // HookPullRequest is a sample pull_request webhook payload
 const HookPullRequest = `{
   "action": "opened",
@@ -20,11 +20,11 @@ package gitea
 import (
 	"crypto/tls"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
-	"path"
-	"path/filepath"
+	"strings"
 
 	"code.gitea.io/sdk/gitea"
 	"github.com/woodpecker-ci/woodpecker/model"
@@ -33,14 +33,24 @@ import (
 
 // Opts defines configuration options.
 type Opts struct {
-	URL         string // Gitea server url.
-	Context     string // Context to display in status check
-	Client      string // OAuth2 Client ID
-	Secret      string // OAuth2 Client Secret
-	Username    string // Optional machine account username.
-	Password    string // Optional machine account password.
-	PrivateMode bool   // Gitea is running in private mode.
-	SkipVerify  bool   // Skip ssl verification.
+	URL             string // Gitea server url.
+	Context         string // Context to display in status check
+	Client          string // OAuth2 Client ID
+	Secret          string // OAuth2 Client Secret
+	Username        string // Optional machine account username.
+	Password        string // Optional machine account password.
+	PrivateMode     bool   // Gitea is running in private mode.
+	SkipVerify      bool   // Skip ssl verification.
+	StatusDedup     bool   // Skip posting a status equal to the last posted one.
+	SkippedSuccess  bool   // Post a success status for builds with all pipelines skipped.
+	IncludeArchived bool   // List archived repositories.
+	MaxConfigSize   int64  // Maximum pipeline config file size in bytes.
+	MaxConfigFiles  int    // Maximum number of pipeline config files in a folder.
+	MaxAssetSize    int64  // Maximum release asset size in bytes.
+	MaxDiffSize     int64  // Maximum commit diff size in bytes.
+	SkipSelfTest    bool   // Skip checking the OAuth2 configuration at startup.
+
+	Encryptor Encryptor // Optional encryption of the stored tokens.
 }
 
 type client struct {
@@ -51,6 +61,16 @@ type client struct {
 	Password    string
 	PrivateMode bool
 	SkipVerify  bool
+	Archived    bool
+	Skipped     bool
+	MaxConfig   int64
+	MaxFiles    int
+	MaxAsset    int64
+	MaxDiff     int64
+	statuses    *statusCache
+	configs     *configCache
+	trees       treeSupport
+	crypt       Encryptor
 }
 
 const (
@@ -61,8 +81,15 @@ const (
 	DescCanceled = "the build canceled"
 	DescBlocked  = "the build is pending approval"
 	DescDeclined = "the build was rejected"
+	DescSkipped  = "the build was skipped"
 )
 
+// releasePageSize is the number of releases fetched per page.
+const releasePageSize = 50
+
+// commitPageSize is the number of commits fetched per page.
+const commitPageSize = 50
+
 // getStatus is a helper function that converts a Drone
 // status to a Gitea status.
 func getStatus(status string) gitea.StatusState {
@@ -110,6 +137,9 @@ func getDesc(status string) string {
 // New returns a Remote implementation that integrates with Gitea, an open
 // source Git service written in Go. See https://gitea.io/
 func New(opts Opts) (remote.Remote, error) {
+	// Gitea may be served under a base path, e.g. https://example.com/gitea,
+	// which all the URLs are relative to.
+	opts.URL = strings.TrimRight(opts.URL, "/")
 	url, err := url.Parse(opts.URL)
 	if err != nil {
 		return nil, err
@@ -118,7 +148,7 @@ func New(opts Opts) (remote.Remote, error) {
 	if err == nil {
 		url.Host = host
 	}
-	return &client{
+	c := &client{
 		URL:         opts.URL,
 		Context:     opts.Context,
 		Machine:     url.Host,
@@ -126,7 +156,19 @@ func New(opts Opts) (remote.Remote, error) {
 		Password:    opts.Password,
 		PrivateMode: opts.PrivateMode,
 		SkipVerify:  opts.SkipVerify,
-	}, nil
+		Archived:    opts.IncludeArchived,
+		Skipped:     opts.SkippedSuccess,
+		MaxConfig:   opts.MaxConfigSize,
+		MaxFiles:    opts.MaxConfigFiles,
+		MaxAsset:    opts.MaxAssetSize,
+		MaxDiff:     opts.MaxDiffSize,
+		configs:     newConfigCache(),
+		crypt:       encryptor(opts),
+	}
+	if opts.StatusDedup {
+		c.statuses = newStatusCache()
+	}
+	return c, nil
 }
 
 // Login authenticates an account with Gitea using basic authentication. The
@@ -163,7 +205,7 @@ func (c *client) Login(res http.ResponseWriter, req *http.Request) (*model.User,
 	}
 	accessToken := token.Token
 
-	client, err = c.newClientToken(accessToken)
+	client, err = c.newClient(accessToken)
 	if err != nil {
 		return nil, err
 	}
@@ -171,6 +213,10 @@ func (c *client) Login(res http.ResponseWriter, req *http.Request) (*model.User,
 	if err != nil {
 		return nil, err
 	}
+	accessToken, err = c.crypt.Encrypt(accessToken)
+	if err != nil {
+		return nil, err
+	}
 
 	return &model.User{
 		Token:  accessToken,
@@ -256,6 +302,9 @@ func (c *client) Repos(u *model.User) ([]*model.Repo, error) {
 		}
 
 		for _, repo := range all {
+			if repo.Archived && !c.Archived {
+				continue
+			}
 			repos = append(repos, toRepo(repo, c.PrivateMode))
 		}
 
@@ -289,12 +338,27 @@ func (c *client) Perm(u *model.User, owner, name string) (*model.Perm, error) {
 
 // File fetches the file from the Gitea repository and returns its contents.
 func (c *client) File(u *model.User, r *model.Repo, b *model.Build, f string) ([]byte, error) {
+	if c.MaxConfig > 0 {
+		token, err := c.crypt.Decrypt(u.Token)
+		if err != nil {
+			return nil, err
+		}
+		cfg, err := getRaw(c.URL, c.SkipVerify, token, fmt.Sprintf("/repos/%s/%s/raw/%s/%s", r.Owner, r.Name, b.Commit, f), c.MaxConfig)
+		if isStatus(err, http.StatusNotFound) {
+			return nil, errFileNotFound(f)
+		}
+		return cfg, err
+	}
+
 	client, err := c.newClientToken(u.Token)
 	if err != nil {
 		return nil, err
 	}
 
-	cfg, _, err := client.GetFile(r.Owner, r.Name, b.Commit, f)
+	cfg, resp, err := client.GetFile(r.Owner, r.Name, b.Commit, f)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, errFileNotFound(f)
+	}
 	return cfg, err
 }
 
@@ -306,27 +370,28 @@ func (c *client) Dir(u *model.User, r *model.Repo, b *model.Build, f string) ([]
 		return nil, err
 	}
 
-	// List files in repository. Path from root
-	tree, _, err := client.GetTrees(r.Owner, r.Name, b.Commit, true)
+	entries, err := listDir(client, &c.trees, r.Owner, r.Name, b.Commit, f)
 	if err != nil {
 		return nil, err
 	}
+	entries = configEntries(entries)
+	if c.MaxFiles > 0 && len(entries) > c.MaxFiles {
+		return nil, errTooManyFiles(f, len(entries), c.MaxFiles)
+	}
 
-	f = path.Clean(f) // We clean path and remove trailing slash
-	f += "/" + "*"    // construct pattern for match i.e. file in subdir
-	for _, e := range tree.Entries {
-		// Filter path matching pattern and type file (blob)
-		if m, _ := filepath.Match(f, e.Path); m && e.Type == "blob" {
-			data, err := c.File(u, r, b, e.Path)
-			if err != nil {
-				return nil, fmt.Errorf("multi-pipeline cannot get %s: %s", e.Path, err)
-			}
-
-			configs = append(configs, &remote.FileMeta{
-				Name: e.Path,
-				Data: data,
-			})
+	for _, e := range entries {
+		if c.MaxConfig > 0 && e.Size > c.MaxConfig {
+			return nil, fmt.Errorf("multi-pipeline cannot get %s: %s", e.Path, errTooLarge(c.MaxConfig))
+		}
+		data, err := c.File(u, r, b, e.Path)
+		if err != nil {
+			return nil, fmt.Errorf("multi-pipeline cannot get %s: %s", e.Path, err)
 		}
+
+		configs = append(configs, &remote.FileMeta{
+			Name: e.Path,
+			Data: data,
+		})
 	}
 
 	return configs, nil
@@ -339,22 +404,19 @@ func (c *client) Status(u *model.User, r *model.Repo, b *model.Build, link strin
 		return err
 	}
 
-	status := getStatus(b.Status)
-	desc := getDesc(b.Status)
-
-	_, _, err = client.CreateStatus(
-		r.Owner,
-		r.Name,
-		b.Commit,
-		gitea.CreateStatusOption{
-			State:       status,
-			TargetURL:   link,
-			Description: desc,
-			Context:     c.Context,
-		},
-	)
+	return postStatus(client, c.statuses, r, b.Commit, c.commitStatus(r, b, link))
+}
 
-	return err
+// commitStatus returns the commit status of the build. Gitea reports a
+// single status per build, with the context of the repository.
+func (c *client) commitStatus(r *model.Repo, b *model.Build, link string) gitea.CreateStatusOption {
+	state, desc := buildStatus(b, c.Skipped)
+	return gitea.CreateStatusOption{
+		State:       state,
+		TargetURL:   link,
+		Description: desc,
+		Context:     r.StatusContextOr(c.Context),
+	}
 }
 
 // Netrc returns a netrc file capable of authenticating Gitea requests and
@@ -368,9 +430,13 @@ func (c *client) Netrc(u *model.User, r *model.Repo) (*model.Netrc, error) {
 			Machine:  c.Machine,
 		}, nil
 	}
+	token, err := c.crypt.Decrypt(u.Token)
+	if err != nil {
+		return nil, err
+	}
 	return &model.Netrc{
 		Login:    u.Login,
-		Password: u.Token,
+		Password: token,
 		Machine:  c.Machine,
 	}, nil
 }
@@ -386,7 +452,7 @@ func (c *client) Activate(u *model.User, r *model.Repo, link string) error {
 	hook := gitea.CreateHookOption{
 		Type:   "gitea",
 		Config: config,
-		Events: []string{"push", "create", "pull_request"},
+		Events: []string{"push", "create", "pull_request", "wiki"},
 		Active: true,
 	}
 
@@ -394,6 +460,16 @@ func (c *client) Activate(u *model.User, r *model.Repo, link string) error {
 	if err != nil {
 		return err
 	}
+
+	// archived repositories are read-only and cannot be built.
+	from, _, err := client.GetRepo(r.Owner, r.Name)
+	if err != nil {
+		return err
+	}
+	if from.Archived {
+		return fmt.Errorf("Repository %s is archived and cannot be activated", r.FullName)
+	}
+
 	_, _, err = client.CreateRepoHook(r.Owner, r.Name, hook)
 	return err
 }
@@ -426,8 +502,304 @@ func (c *client) Hook(r *http.Request) (*model.Repo, *model.Build, error) {
 	return parseHook(r)
 }
 
-// helper function to return the Gitea client with Token
+// CommitVerified returns true if the build commit carries a signature that
+// Gitea was able to verify.
+func (c *client) CommitVerified(u *model.User, r *model.Repo, b *model.Build) (bool, error) {
+	token, err := c.crypt.Decrypt(u.Token)
+	if err != nil {
+		return false, err
+	}
+	commit := new(commitVerification)
+	err = getAPI(c.URL, c.SkipVerify, token, fmt.Sprintf("/repos/%s/%s/git/commits/%s", r.Owner, r.Name, b.Commit), commit)
+	if err != nil {
+		return false, err
+	}
+	return commit.Commit.Verification.Verified, nil
+}
+
+// Topics returns the topics of the Gitea repository.
+func (c *client) Topics(u *model.User, r *model.Repo) ([]string, error) {
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	topics, _, err := client.ListRepoTopics(r.Owner, r.Name, gitea.ListRepoTopicsOptions{})
+	return topics, err
+}
+
+// Dispatch resolves a manually triggered build of the branch ref. The user
+// must have push access to the Gitea repository.
+func (c *client) Dispatch(u *model.User, r *model.Repo, ref string, inputs map[string]string) (*remote.Dispatch, error) {
+	perm, err := c.Perm(u, r.Owner, r.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !perm.Push {
+		return nil, fmt.Errorf("User %s is not allowed to trigger builds of %s", u.Login, r.FullName)
+	}
+
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return nil, err
+	}
+	branch, _, err := client.GetRepoBranch(r.Owner, r.Name, strings.TrimPrefix(ref, "refs/heads/"))
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]string{}
+	for k, v := range inputs {
+		params[k] = v
+	}
+	return &remote.Dispatch{
+		Build:  buildFromBranch(branch, u),
+		Params: params,
+	}, nil
+}
+
+// SenderPerm returns the permissions the named user holds on the Gitea
+// repository. Users that are not collaborators have no permissions.
+func (c *client) SenderPerm(u *model.User, r *model.Repo, login string) (*model.Perm, error) {
+	token, err := c.crypt.Decrypt(u.Token)
+	if err != nil {
+		return nil, err
+	}
+	perm := new(collaboratorPermission)
+	err = getAPI(c.URL, c.SkipVerify, token, fmt.Sprintf("/repos/%s/%s/collaborators/%s/permission", r.Owner, r.Name, login), perm)
+	if err != nil {
+		return nil, err
+	}
+	return toCollaboratorPerm(perm.Permission), nil
+}
+
+// ResolveRef resolves a branch, tag or commit of the Gitea repository to a
+// commit sha. Branches take precedence over tags of the same name.
+func (c *client) ResolveRef(u *model.User, r *model.Repo, ref string) (string, error) {
+	token, err := c.crypt.Decrypt(u.Token)
+	if err != nil {
+		return "", err
+	}
+	return resolveRef(c.URL, c.SkipVerify, token, r, ref)
+}
+
+// ListReleases returns a page of the releases of the Gitea repository,
+// newest first. Pages start at 1.
+func (c *client) ListReleases(u *model.User, r *model.Repo, page int) ([]*model.Release, error) {
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return nil, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	from, _, err := client.ListReleases(r.Owner, r.Name, gitea.ListReleasesOptions{
+		ListOptions: gitea.ListOptions{Page: page, PageSize: releasePageSize},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	releases := make([]*model.Release, 0, len(from))
+	for _, rel := range from {
+		releases = append(releases, toRelease(rel))
+	}
+	return releases, nil
+}
+
+// CreateRelease creates a release of the Gitea repository, or returns the
+// existing release if the tag already has one.
+func (c *client) CreateRelease(u *model.User, r *model.Repo, in *remote.ReleaseInput) (*model.Release, error) {
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return nil, err
+	}
+	return createRelease(client, r, in)
+}
+
+// UploadReleaseAsset attaches the named asset to a release of the Gitea
+// repository.
+func (c *client) UploadReleaseAsset(u *model.User, r *model.Repo, release *model.Release, name string, data io.Reader) error {
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return err
+	}
+	return uploadReleaseAsset(client, c.MaxAsset, r, release, name, data)
+}
+
+// CommitDiff returns the unified diff between the base and head commits.
+func (c *client) CommitDiff(u *model.User, r *model.Repo, base, head string) ([]byte, error) {
+	token, err := c.crypt.Decrypt(u.Token)
+	if err != nil {
+		return nil, err
+	}
+	return getDiff(c.URL, c.SkipVerify, token, c.MaxDiff, r, base, head)
+}
+
+// ChangedFiles returns the files changed by the pull request of the build,
+// read from the diff of its head commit with the target branch, as Gitea
+// pull request hooks do not list them.
+func (c *client) ChangedFiles(u *model.User, r *model.Repo, b *model.Build) ([]string, error) {
+	diff, err := c.CommitDiff(u, r, b.Branch, b.Commit)
+	if err != nil {
+		return nil, err
+	}
+	return diffFiles(diff), nil
+}
+
+// OrgTeams returns the teams of the organization, flagging the teams the
+// user is a member of.
+func (c *client) OrgTeams(u *model.User, org string) ([]*model.OrgTeam, error) {
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return nil, err
+	}
+	return listOrgTeams(client, org)
+}
+
+// ListCommits returns a page of the commits of the branch or commit,
+// newest first. An empty repository has no commits.
+func (c *client) ListCommits(u *model.User, r *model.Repo, ref string, page int) ([]*model.Commit, error) {
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return nil, err
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	from, resp, err := client.ListRepoCommits(r.Owner, r.Name, gitea.ListCommitOptions{
+		ListOptions: gitea.ListOptions{Page: page, PageSize: commitPageSize},
+		SHA:         ref,
+	})
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusConflict {
+			return []*model.Commit{}, nil
+		}
+		return nil, err
+	}
+
+	commits := make([]*model.Commit, 0, len(from))
+	for _, commit := range from {
+		commits = append(commits, toCommit(commit))
+	}
+	return commits, nil
+}
+
+// RepoExists returns true if the Gitea repository exists and is accessible.
+func (c *client) RepoExists(u *model.User, owner, name string) (bool, error) {
+	token, err := c.crypt.Decrypt(u.Token)
+	if err != nil {
+		return false, err
+	}
+	return repoExists(c.URL, c.SkipVerify, token, owner, name)
+}
+
+// CodeOwners returns the CODEOWNERS file of the Gitea repository at the ref.
+func (c *client) CodeOwners(u *model.User, r *model.Repo, ref string) ([]byte, error) {
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return nil, err
+	}
+	return getCodeOwners(client, r.Owner, r.Name, ref)
+}
+
+// ListPublicKeys returns the SSH public keys of the user.
+func (c *client) ListPublicKeys(u *model.User) ([]*model.PublicKey, error) {
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return nil, err
+	}
+	return listPublicKeys(client)
+}
+
+// GetCombinedStatus returns the statuses reported for the commit and their
+// overall state.
+func (c *client) GetCombinedStatus(u *model.User, r *model.Repo, sha string) (*model.CombinedStatus, error) {
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return nil, err
+	}
+	status, _, err := client.GetCombinedStatus(r.Owner, r.Name, sha)
+	if err != nil {
+		return nil, err
+	}
+	return toCombinedStatus(status), nil
+}
+
+// ListHookDeliveries returns the recent deliveries of the repository hook,
+// newest first.
+func (c *client) ListHookDeliveries(u *model.User, r *model.Repo, hookID int64) ([]*model.HookDelivery, error) {
+	token, err := c.crypt.Decrypt(u.Token)
+	if err != nil {
+		return nil, err
+	}
+	client, err := c.newClient(token)
+	if err != nil {
+		return nil, err
+	}
+	return listHookDeliveries(client, c.URL, c.SkipVerify, token, r, hookID)
+}
+
+// HasConfig returns true if the repository has the config file or folder at
+// its default branch. The result is cached per repository and path.
+func (c *client) HasConfig(u *model.User, r *model.Repo, path string) (bool, error) {
+	key := configCacheKey(r, path)
+	if exists, ok := c.configs.Get(key); ok {
+		return exists, nil
+	}
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return false, err
+	}
+	exists, err := hasConfig(client, &c.trees, r, path)
+	if err != nil {
+		return false, err
+	}
+	c.configs.Set(key, exists)
+	return exists, nil
+}
+
+// StatusBatch sends the commit status of the build and one status per
+// pipeline. The statuses are coalesced by context, so each context is posted
+// once, and contexts whose status did not change are skipped if dedup is
+// enabled.
+func (c *client) StatusBatch(u *model.User, r *model.Repo, b *model.Build, link string, procs []*model.Proc) error {
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return err
+	}
+
+	statuses := batchStatuses(b, link, r.StatusContextOr(c.Context), c.Skipped, procs)
+	for _, status := range statuses {
+		if err := postStatus(client, c.statuses, r, b.Commit, status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BranchProtection returns the protection settings of the branch.
+func (c *client) BranchProtection(u *model.User, r *model.Repo, branch string) (*model.BranchProtection, error) {
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return nil, err
+	}
+	return getBranchProtection(client, r.Owner, r.Name, branch)
+}
+
+// helper function to return the Gitea client with the stored, possibly
+// encrypted, Token
 func (c *client) newClientToken(token string) (*gitea.Client, error) {
+	token, err := c.crypt.Decrypt(token)
+	if err != nil {
+		return nil, err
+	}
+	return c.newClient(token)
+}
+
+// helper function to return the Gitea client with a plaintext Token
+func (c *client) newClient(token string) (*gitea.Client, error) {
 	httpClient := &http.Client{}
 	if c.SkipVerify {
 		httpClient.Transport = &http.Transport{
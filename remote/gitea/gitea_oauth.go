@@ -19,14 +19,17 @@ package gitea
 
 import (
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
-	"path"
-	"path/filepath"
+	"strings"
+	"time"
 
 	"code.gitea.io/sdk/gitea"
+	"github.com/sirupsen/logrus"
 	"github.com/woodpecker-ci/woodpecker/model"
 	"github.com/woodpecker-ci/woodpecker/remote"
 	"github.com/woodpecker-ci/woodpecker/server"
@@ -49,11 +52,24 @@ type oauthclient struct {
 	Password    string
 	PrivateMode bool
 	SkipVerify  bool
+	Archived    bool
+	Skipped     bool
+	MaxConfig   int64
+	MaxFiles    int
+	MaxAsset    int64
+	MaxDiff     int64
+	statuses    *statusCache
+	configs     *configCache
+	trees       treeSupport
+	crypt       Encryptor
 }
 
 // New returns a Remote implementation that integrates with Gitea, an open
 // source Git service written in Go. See https://gitea.io/
 func NewOauth(opts Opts) (remote.Remote, error) {
+	// Gitea may be served under a base path, e.g. https://example.com/gitea,
+	// which all the URLs are relative to.
+	opts.URL = strings.TrimRight(opts.URL, "/")
 	url, err := url.Parse(opts.URL)
 	if err != nil {
 		return nil, err
@@ -62,7 +78,7 @@ func NewOauth(opts Opts) (remote.Remote, error) {
 	if err == nil {
 		url.Host = host
 	}
-	return &oauthclient{
+	c := &oauthclient{
 		URL:         opts.URL,
 		Context:     opts.Context,
 		Machine:     url.Host,
@@ -72,7 +88,72 @@ func NewOauth(opts Opts) (remote.Remote, error) {
 		Password:    opts.Password,
 		PrivateMode: opts.PrivateMode,
 		SkipVerify:  opts.SkipVerify,
-	}, nil
+		Archived:    opts.IncludeArchived,
+		Skipped:     opts.SkippedSuccess,
+		MaxConfig:   opts.MaxConfigSize,
+		MaxFiles:    opts.MaxConfigFiles,
+		MaxAsset:    opts.MaxAssetSize,
+		MaxDiff:     opts.MaxDiffSize,
+		configs:     newConfigCache(),
+		crypt:       encryptor(opts),
+	}
+	if opts.StatusDedup {
+		c.statuses = newStatusCache()
+	}
+	if !opts.SkipSelfTest {
+		if err := c.selfTest(); err != nil {
+			logrus.Warnf("gitea oauth self-test failed, users will not be able to log in. %s", err)
+		}
+	}
+	return c, nil
+}
+
+// selfTestTimeout is the time the self-test waits for Gitea, so an
+// unreachable instance does not block the server startup.
+const selfTestTimeout = 10 * time.Second
+
+// selfTest checks that the Gitea OAuth2 endpoints are reachable and that the
+// client credentials are accepted. The token endpoint is probed with an
+// invalid authorization code, which Gitea only rejects as such once the
+// client credentials are verified.
+func (c *oauthclient) selfTest() error {
+	httpClient := &http.Client{Timeout: selfTestTimeout}
+	if c.SkipVerify {
+		httpClient.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+
+	tokenURL := fmt.Sprintf(accessTokenURL, c.URL)
+	res, err := httpClient.PostForm(tokenURL, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {"self-test"},
+		"client_id":     {c.Client},
+		"client_secret": {c.Secret},
+	})
+	if err != nil {
+		return fmt.Errorf("cannot reach %s. %s", tokenURL, err)
+	}
+	defer res.Body.Close()
+
+	out := struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return fmt.Errorf("unexpected response from %s, status %d", tokenURL, res.StatusCode)
+	}
+
+	switch {
+	case out.Error == "invalid_grant":
+		return nil
+	case out.Error == "unauthorized_client" && strings.Contains(out.Description, "not authorized"):
+		return nil
+	case out.Error == "invalid_client" || out.Error == "unauthorized_client":
+		return fmt.Errorf("client %s was rejected. %s", c.Client, out.Description)
+	default:
+		return fmt.Errorf("unexpected response from %s, status %d. %s %s", tokenURL, res.StatusCode, out.Error, out.Description)
+	}
 }
 
 // Login authenticates an account with Gitea using basic authentication. The
@@ -109,7 +190,7 @@ func (c *oauthclient) Login(w http.ResponseWriter, req *http.Request) (*model.Us
 		return nil, err
 	}
 
-	client, err := c.newClientToken(token.AccessToken)
+	client, err := c.newClient(token.AccessToken)
 	if err != nil {
 		return nil, err
 	}
@@ -117,10 +198,14 @@ func (c *oauthclient) Login(w http.ResponseWriter, req *http.Request) (*model.Us
 	if err != nil {
 		return nil, err
 	}
+	accessToken, refreshToken, err := encryptTokens(c.crypt, token.AccessToken, token.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
 
 	return &model.User{
-		Token:  token.AccessToken,
-		Secret: token.RefreshToken,
+		Token:  accessToken,
+		Secret: refreshToken,
 		Expiry: token.Expiry.UTC().Unix(),
 		Login:  account.UserName,
 		Email:  account.Email,
@@ -129,9 +214,10 @@ func (c *oauthclient) Login(w http.ResponseWriter, req *http.Request) (*model.Us
 }
 
 // Auth uses the Gitea oauth2 access token and refresh token to authenticate
-// a session and return the Gitea account login.
+// a session and return the Gitea account login. The tokens are presented by
+// the caller as issued by Gitea and are not decrypted.
 func (c *oauthclient) Auth(token, secret string) (string, error) {
-	client, err := c.newClientToken(token)
+	client, err := c.newClient(token)
 	if err != nil {
 		return "", err
 	}
@@ -153,16 +239,24 @@ func (c *oauthclient) Refresh(user *model.User) (bool, error) {
 			TokenURL: fmt.Sprintf(accessTokenURL, c.URL),
 		},
 	}
+	secret, err := c.crypt.Decrypt(user.Secret)
+	if err != nil {
+		return false, err
+	}
 	source := config.TokenSource(
-		oauth2.NoContext, &oauth2.Token{RefreshToken: user.Secret})
+		oauth2.NoContext, &oauth2.Token{RefreshToken: secret})
 
 	token, err := source.Token()
 	if err != nil || len(token.AccessToken) == 0 {
 		return false, err
 	}
+	accessToken, refreshToken, err := encryptTokens(c.crypt, token.AccessToken, token.RefreshToken)
+	if err != nil {
+		return false, err
+	}
 
-	user.Token = token.AccessToken
-	user.Secret = token.RefreshToken
+	user.Token = accessToken
+	user.Secret = refreshToken
 	user.Expiry = token.Expiry.UTC().Unix()
 	return true, nil
 }
@@ -238,6 +332,9 @@ func (c *oauthclient) Repos(u *model.User) ([]*model.Repo, error) {
 		}
 
 		for _, repo := range all {
+			if repo.Archived && !c.Archived {
+				continue
+			}
 			repos = append(repos, toRepo(repo, c.PrivateMode))
 		}
 
@@ -271,12 +368,27 @@ func (c *oauthclient) Perm(u *model.User, owner, name string) (*model.Perm, erro
 
 // File fetches the file from the Gitea repository and returns its contents.
 func (c *oauthclient) File(u *model.User, r *model.Repo, b *model.Build, f string) ([]byte, error) {
+	if c.MaxConfig > 0 {
+		token, err := c.crypt.Decrypt(u.Token)
+		if err != nil {
+			return nil, err
+		}
+		cfg, err := getRaw(c.URL, c.SkipVerify, token, fmt.Sprintf("/repos/%s/%s/raw/%s/%s", r.Owner, r.Name, b.Commit, f), c.MaxConfig)
+		if isStatus(err, http.StatusNotFound) {
+			return nil, errFileNotFound(f)
+		}
+		return cfg, err
+	}
+
 	client, err := c.newClientToken(u.Token)
 	if err != nil {
 		return nil, err
 	}
 
-	cfg, _, err := client.GetFile(r.Owner, r.Name, b.Commit, f)
+	cfg, resp, err := client.GetFile(r.Owner, r.Name, b.Commit, f)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, errFileNotFound(f)
+	}
 	return cfg, err
 }
 
@@ -288,27 +400,28 @@ func (c *oauthclient) Dir(u *model.User, r *model.Repo, b *model.Build, f string
 		return nil, err
 	}
 
-	// List files in repository. Path from root
-	tree, _, err := client.GetTrees(r.Owner, r.Name, b.Commit, true)
+	entries, err := listDir(client, &c.trees, r.Owner, r.Name, b.Commit, f)
 	if err != nil {
 		return nil, err
 	}
+	entries = configEntries(entries)
+	if c.MaxFiles > 0 && len(entries) > c.MaxFiles {
+		return nil, errTooManyFiles(f, len(entries), c.MaxFiles)
+	}
 
-	f = path.Clean(f) // We clean path and remove trailing slash
-	f += "/" + "*"    // construct pattern for match i.e. file in subdir
-	for _, e := range tree.Entries {
-		// Filter path matching pattern and type file (blob)
-		if m, _ := filepath.Match(f, e.Path); m && e.Type == "blob" {
-			data, err := c.File(u, r, b, e.Path)
-			if err != nil {
-				return nil, fmt.Errorf("multi-pipeline cannot get %s: %s", e.Path, err)
-			}
-
-			configs = append(configs, &remote.FileMeta{
-				Name: e.Path,
-				Data: data,
-			})
+	for _, e := range entries {
+		if c.MaxConfig > 0 && e.Size > c.MaxConfig {
+			return nil, fmt.Errorf("multi-pipeline cannot get %s: %s", e.Path, errTooLarge(c.MaxConfig))
+		}
+		data, err := c.File(u, r, b, e.Path)
+		if err != nil {
+			return nil, fmt.Errorf("multi-pipeline cannot get %s: %s", e.Path, err)
 		}
+
+		configs = append(configs, &remote.FileMeta{
+			Name: e.Path,
+			Data: data,
+		})
 	}
 
 	return configs, nil
@@ -321,22 +434,19 @@ func (c *oauthclient) Status(u *model.User, r *model.Repo, b *model.Build, link
 		return err
 	}
 
-	status := getStatus(b.Status)
-	desc := getDesc(b.Status)
-
-	_, _, err = client.CreateStatus(
-		r.Owner,
-		r.Name,
-		b.Commit,
-		gitea.CreateStatusOption{
-			State:       status,
-			TargetURL:   link,
-			Description: desc,
-			Context:     c.Context,
-		},
-	)
+	return postStatus(client, c.statuses, r, b.Commit, c.commitStatus(r, b, link))
+}
 
-	return err
+// commitStatus returns the commit status of the build. Gitea reports a
+// single status per build, with the context of the repository.
+func (c *oauthclient) commitStatus(r *model.Repo, b *model.Build, link string) gitea.CreateStatusOption {
+	state, desc := buildStatus(b, c.Skipped)
+	return gitea.CreateStatusOption{
+		State:       state,
+		TargetURL:   link,
+		Description: desc,
+		Context:     r.StatusContextOr(c.Context),
+	}
 }
 
 // Netrc returns a netrc file capable of authenticating Gitea requests and
@@ -350,9 +460,13 @@ func (c *oauthclient) Netrc(u *model.User, r *model.Repo) (*model.Netrc, error)
 			Machine:  c.Machine,
 		}, nil
 	}
+	token, err := c.crypt.Decrypt(u.Token)
+	if err != nil {
+		return nil, err
+	}
 	return &model.Netrc{
 		Login:    u.Login,
-		Password: u.Token,
+		Password: token,
 		Machine:  c.Machine,
 	}, nil
 }
@@ -368,7 +482,7 @@ func (c *oauthclient) Activate(u *model.User, r *model.Repo, link string) error
 	hook := gitea.CreateHookOption{
 		Type:   "gitea",
 		Config: config,
-		Events: []string{"push", "create", "pull_request"},
+		Events: []string{"push", "create", "pull_request", "wiki"},
 		Active: true,
 	}
 
@@ -376,6 +490,16 @@ func (c *oauthclient) Activate(u *model.User, r *model.Repo, link string) error
 	if err != nil {
 		return err
 	}
+
+	// archived repositories are read-only and cannot be built.
+	from, _, err := client.GetRepo(r.Owner, r.Name)
+	if err != nil {
+		return err
+	}
+	if from.Archived {
+		return fmt.Errorf("Repository %s is archived and cannot be activated", r.FullName)
+	}
+
 	_, _, err = client.CreateRepoHook(r.Owner, r.Name, hook)
 	return err
 }
@@ -408,8 +532,304 @@ func (c *oauthclient) Hook(r *http.Request) (*model.Repo, *model.Build, error) {
 	return parseHook(r)
 }
 
-// helper function to return the Gitea client with Token
+// CommitVerified returns true if the build commit carries a signature that
+// Gitea was able to verify.
+func (c *oauthclient) CommitVerified(u *model.User, r *model.Repo, b *model.Build) (bool, error) {
+	token, err := c.crypt.Decrypt(u.Token)
+	if err != nil {
+		return false, err
+	}
+	commit := new(commitVerification)
+	err = getAPI(c.URL, c.SkipVerify, token, fmt.Sprintf("/repos/%s/%s/git/commits/%s", r.Owner, r.Name, b.Commit), commit)
+	if err != nil {
+		return false, err
+	}
+	return commit.Commit.Verification.Verified, nil
+}
+
+// Topics returns the topics of the Gitea repository.
+func (c *oauthclient) Topics(u *model.User, r *model.Repo) ([]string, error) {
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	topics, _, err := client.ListRepoTopics(r.Owner, r.Name, gitea.ListRepoTopicsOptions{})
+	return topics, err
+}
+
+// Dispatch resolves a manually triggered build of the branch ref. The user
+// must have push access to the Gitea repository.
+func (c *oauthclient) Dispatch(u *model.User, r *model.Repo, ref string, inputs map[string]string) (*remote.Dispatch, error) {
+	perm, err := c.Perm(u, r.Owner, r.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !perm.Push {
+		return nil, fmt.Errorf("User %s is not allowed to trigger builds of %s", u.Login, r.FullName)
+	}
+
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return nil, err
+	}
+	branch, _, err := client.GetRepoBranch(r.Owner, r.Name, strings.TrimPrefix(ref, "refs/heads/"))
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]string{}
+	for k, v := range inputs {
+		params[k] = v
+	}
+	return &remote.Dispatch{
+		Build:  buildFromBranch(branch, u),
+		Params: params,
+	}, nil
+}
+
+// SenderPerm returns the permissions the named user holds on the Gitea
+// repository. Users that are not collaborators have no permissions.
+func (c *oauthclient) SenderPerm(u *model.User, r *model.Repo, login string) (*model.Perm, error) {
+	token, err := c.crypt.Decrypt(u.Token)
+	if err != nil {
+		return nil, err
+	}
+	perm := new(collaboratorPermission)
+	err = getAPI(c.URL, c.SkipVerify, token, fmt.Sprintf("/repos/%s/%s/collaborators/%s/permission", r.Owner, r.Name, login), perm)
+	if err != nil {
+		return nil, err
+	}
+	return toCollaboratorPerm(perm.Permission), nil
+}
+
+// ResolveRef resolves a branch, tag or commit of the Gitea repository to a
+// commit sha. Branches take precedence over tags of the same name.
+func (c *oauthclient) ResolveRef(u *model.User, r *model.Repo, ref string) (string, error) {
+	token, err := c.crypt.Decrypt(u.Token)
+	if err != nil {
+		return "", err
+	}
+	return resolveRef(c.URL, c.SkipVerify, token, r, ref)
+}
+
+// ListReleases returns a page of the releases of the Gitea repository,
+// newest first. Pages start at 1.
+func (c *oauthclient) ListReleases(u *model.User, r *model.Repo, page int) ([]*model.Release, error) {
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return nil, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	from, _, err := client.ListReleases(r.Owner, r.Name, gitea.ListReleasesOptions{
+		ListOptions: gitea.ListOptions{Page: page, PageSize: releasePageSize},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	releases := make([]*model.Release, 0, len(from))
+	for _, rel := range from {
+		releases = append(releases, toRelease(rel))
+	}
+	return releases, nil
+}
+
+// CreateRelease creates a release of the Gitea repository, or returns the
+// existing release if the tag already has one.
+func (c *oauthclient) CreateRelease(u *model.User, r *model.Repo, in *remote.ReleaseInput) (*model.Release, error) {
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return nil, err
+	}
+	return createRelease(client, r, in)
+}
+
+// UploadReleaseAsset attaches the named asset to a release of the Gitea
+// repository.
+func (c *oauthclient) UploadReleaseAsset(u *model.User, r *model.Repo, release *model.Release, name string, data io.Reader) error {
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return err
+	}
+	return uploadReleaseAsset(client, c.MaxAsset, r, release, name, data)
+}
+
+// CommitDiff returns the unified diff between the base and head commits.
+func (c *oauthclient) CommitDiff(u *model.User, r *model.Repo, base, head string) ([]byte, error) {
+	token, err := c.crypt.Decrypt(u.Token)
+	if err != nil {
+		return nil, err
+	}
+	return getDiff(c.URL, c.SkipVerify, token, c.MaxDiff, r, base, head)
+}
+
+// ChangedFiles returns the files changed by the pull request of the build,
+// read from the diff of its head commit with the target branch, as Gitea
+// pull request hooks do not list them.
+func (c *oauthclient) ChangedFiles(u *model.User, r *model.Repo, b *model.Build) ([]string, error) {
+	diff, err := c.CommitDiff(u, r, b.Branch, b.Commit)
+	if err != nil {
+		return nil, err
+	}
+	return diffFiles(diff), nil
+}
+
+// OrgTeams returns the teams of the organization, flagging the teams the
+// user is a member of.
+func (c *oauthclient) OrgTeams(u *model.User, org string) ([]*model.OrgTeam, error) {
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return nil, err
+	}
+	return listOrgTeams(client, org)
+}
+
+// ListCommits returns a page of the commits of the branch or commit,
+// newest first. An empty repository has no commits.
+func (c *oauthclient) ListCommits(u *model.User, r *model.Repo, ref string, page int) ([]*model.Commit, error) {
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return nil, err
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	from, resp, err := client.ListRepoCommits(r.Owner, r.Name, gitea.ListCommitOptions{
+		ListOptions: gitea.ListOptions{Page: page, PageSize: commitPageSize},
+		SHA:         ref,
+	})
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusConflict {
+			return []*model.Commit{}, nil
+		}
+		return nil, err
+	}
+
+	commits := make([]*model.Commit, 0, len(from))
+	for _, commit := range from {
+		commits = append(commits, toCommit(commit))
+	}
+	return commits, nil
+}
+
+// RepoExists returns true if the Gitea repository exists and is accessible.
+func (c *oauthclient) RepoExists(u *model.User, owner, name string) (bool, error) {
+	token, err := c.crypt.Decrypt(u.Token)
+	if err != nil {
+		return false, err
+	}
+	return repoExists(c.URL, c.SkipVerify, token, owner, name)
+}
+
+// CodeOwners returns the CODEOWNERS file of the Gitea repository at the ref.
+func (c *oauthclient) CodeOwners(u *model.User, r *model.Repo, ref string) ([]byte, error) {
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return nil, err
+	}
+	return getCodeOwners(client, r.Owner, r.Name, ref)
+}
+
+// ListPublicKeys returns the SSH public keys of the user.
+func (c *oauthclient) ListPublicKeys(u *model.User) ([]*model.PublicKey, error) {
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return nil, err
+	}
+	return listPublicKeys(client)
+}
+
+// GetCombinedStatus returns the statuses reported for the commit and their
+// overall state.
+func (c *oauthclient) GetCombinedStatus(u *model.User, r *model.Repo, sha string) (*model.CombinedStatus, error) {
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return nil, err
+	}
+	status, _, err := client.GetCombinedStatus(r.Owner, r.Name, sha)
+	if err != nil {
+		return nil, err
+	}
+	return toCombinedStatus(status), nil
+}
+
+// ListHookDeliveries returns the recent deliveries of the repository hook,
+// newest first.
+func (c *oauthclient) ListHookDeliveries(u *model.User, r *model.Repo, hookID int64) ([]*model.HookDelivery, error) {
+	token, err := c.crypt.Decrypt(u.Token)
+	if err != nil {
+		return nil, err
+	}
+	client, err := c.newClient(token)
+	if err != nil {
+		return nil, err
+	}
+	return listHookDeliveries(client, c.URL, c.SkipVerify, token, r, hookID)
+}
+
+// HasConfig returns true if the repository has the config file or folder at
+// its default branch. The result is cached per repository and path.
+func (c *oauthclient) HasConfig(u *model.User, r *model.Repo, path string) (bool, error) {
+	key := configCacheKey(r, path)
+	if exists, ok := c.configs.Get(key); ok {
+		return exists, nil
+	}
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return false, err
+	}
+	exists, err := hasConfig(client, &c.trees, r, path)
+	if err != nil {
+		return false, err
+	}
+	c.configs.Set(key, exists)
+	return exists, nil
+}
+
+// StatusBatch sends the commit status of the build and one status per
+// pipeline. The statuses are coalesced by context, so each context is posted
+// once, and contexts whose status did not change are skipped if dedup is
+// enabled.
+func (c *oauthclient) StatusBatch(u *model.User, r *model.Repo, b *model.Build, link string, procs []*model.Proc) error {
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return err
+	}
+
+	statuses := batchStatuses(b, link, r.StatusContextOr(c.Context), c.Skipped, procs)
+	for _, status := range statuses {
+		if err := postStatus(client, c.statuses, r, b.Commit, status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BranchProtection returns the protection settings of the branch.
+func (c *oauthclient) BranchProtection(u *model.User, r *model.Repo, branch string) (*model.BranchProtection, error) {
+	client, err := c.newClientToken(u.Token)
+	if err != nil {
+		return nil, err
+	}
+	return getBranchProtection(client, r.Owner, r.Name, branch)
+}
+
+// helper function to return the Gitea client with the stored, possibly
+// encrypted, Token
 func (c *oauthclient) newClientToken(token string) (*gitea.Client, error) {
+	token, err := c.crypt.Decrypt(token)
+	if err != nil {
+		return nil, err
+	}
+	return c.newClient(token)
+}
+
+// helper function to return the Gitea client with a plaintext Token
+func (c *oauthclient) newClient(token string) (*gitea.Client, error) {
 	httpClient := &http.Client{}
 	if c.SkipVerify {
 		httpClient.Transport = &http.Transport{
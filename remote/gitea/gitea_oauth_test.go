@@ -0,0 +1,198 @@
+package gitea
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/franela/goblin"
+	"github.com/gin-gonic/gin"
+	"github.com/woodpecker-ci/woodpecker/model"
+	"github.com/woodpecker-ci/woodpecker/remote/gitea/fixtures"
+)
+
+func Test_giteaOauthSelfTest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	s := httptest.NewServer(fixtures.Handler())
+	defer s.Close()
+
+	newClient := func(url, client, secret string) *oauthclient {
+		c, _ := NewOauth(Opts{
+			URL:          url,
+			Client:       client,
+			Secret:       secret,
+			SkipSelfTest: true,
+		})
+		return c.(*oauthclient)
+	}
+
+	g := goblin.Goblin(t)
+	g.Describe("Gitea OAuth self-test", func() {
+		g.It("Should pass for a reachable server and valid credentials", func() {
+			err := newClient(s.URL, "client", "secret").selfTest()
+			g.Assert(err == nil).IsTrue()
+		})
+		g.It("Should fail for an unknown client", func() {
+			err := newClient(s.URL, "unknown", "secret").selfTest()
+			g.Assert(err != nil).IsTrue()
+		})
+		g.It("Should fail for an invalid client secret", func() {
+			err := newClient(s.URL, "client", "invalid").selfTest()
+			g.Assert(err != nil).IsTrue()
+		})
+		g.It("Should fail for an unreachable server", func() {
+			unreachable := httptest.NewServer(fixtures.Handler())
+			unreachable.Close()
+
+			err := newClient(unreachable.URL, "client", "secret").selfTest()
+			g.Assert(err != nil).IsTrue()
+		})
+	})
+}
+
+// prefixEncryptor marks tokens as encrypted with a prefix.
+type prefixEncryptor struct{}
+
+func (prefixEncryptor) Encrypt(plaintext string) (string, error) {
+	return "enc:" + plaintext, nil
+}
+
+func (prefixEncryptor) Decrypt(ciphertext string) (string, error) {
+	if !strings.HasPrefix(ciphertext, "enc:") {
+		return "", errors.New("token is not encrypted")
+	}
+	return strings.TrimPrefix(ciphertext, "enc:"), nil
+}
+
+func Test_giteaOauthEncryptor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var auth []string
+	handler := fixtures.Handler()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "token ") {
+			auth = append(auth, header)
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+
+	r, _ := NewOauth(Opts{
+		URL:          s.URL,
+		Client:       "client",
+		Secret:       "secret",
+		SkipSelfTest: true,
+		Encryptor:    prefixEncryptor{},
+	})
+	c := r.(*oauthclient)
+
+	g := goblin.Goblin(t)
+	g.Describe("Gitea OAuth token encryption", func() {
+		g.BeforeEach(func() {
+			auth = nil
+		})
+		g.It("Should store refreshed tokens encrypted", func() {
+			user := &model.User{Token: "enc:token", Secret: "enc:refresh_token"}
+			ok, err := c.Refresh(user)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(ok).IsTrue()
+			g.Assert(user.Token).Equal("enc:refreshed_token")
+			g.Assert(user.Secret).Equal("enc:refreshed_refresh_token")
+		})
+		g.It("Should decrypt the token for API calls", func() {
+			user := &model.User{Token: "enc:token"}
+			repo, err := c.Repo(user, "test_name", "repo_name")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(repo.FullName).Equal("test_name/repo_name")
+			g.Assert(len(auth) != 0).IsTrue()
+			for _, header := range auth {
+				g.Assert(header).Equal("token token")
+			}
+		})
+		g.It("Should decrypt the token for raw API calls", func() {
+			user := &model.User{Token: "enc:token"}
+			_, err := c.SenderPerm(user, &model.Repo{Owner: "test_name", Name: "repo_name"}, "octocat")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(len(auth) != 0).IsTrue()
+			for _, header := range auth {
+				g.Assert(header).Equal("token token")
+			}
+		})
+		g.It("Should use the plaintext token for the netrc", func() {
+			netrc, err := c.Netrc(&model.User{Login: "octocat", Token: "enc:token"}, &model.Repo{})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(netrc.Password).Equal("token")
+		})
+		g.It("Should fail if the token cannot be decrypted", func() {
+			_, err := c.Repo(&model.User{Token: "token"}, "test_name", "repo_name")
+			g.Assert(err != nil).IsTrue()
+			g.Assert(len(auth)).Equal(0)
+		})
+	})
+}
+
+func Test_giteaOauthBasePath(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var paths []string
+	handler := http.StripPrefix("/gitea", fixtures.Handler())
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		handler.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+
+	r, _ := NewOauth(Opts{
+		URL:          s.URL + "/gitea/",
+		Client:       "client",
+		Secret:       "secret",
+		SkipSelfTest: true,
+	})
+	c := r.(*oauthclient)
+
+	g := goblin.Goblin(t)
+	g.Describe("Gitea OAuth served under a base path", func() {
+		g.BeforeEach(func() {
+			paths = nil
+		})
+		g.It("Should strip the trailing slash of the url", func() {
+			g.Assert(c.URL).Equal(s.URL + "/gitea")
+		})
+		g.It("Should redirect to the prefixed login url", func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/authorize", nil)
+			user, err := c.Login(w, req)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(user == nil).IsTrue()
+			location := w.Header().Get("Location")
+			g.Assert(strings.HasPrefix(location, s.URL+"/gitea/login/oauth/authorize?")).IsTrue()
+		})
+		g.It("Should refresh the token with the prefixed token url", func() {
+			user := &model.User{Token: "token", Secret: "refresh_token"}
+			ok, err := c.Refresh(user)
+			g.Assert(err == nil).IsTrue()
+			g.Assert(ok).IsTrue()
+			g.Assert(len(paths) != 0).IsTrue()
+			for _, path := range paths {
+				g.Assert(path).Equal("/gitea/login/oauth/access_token")
+			}
+		})
+		g.It("Should call the prefixed api", func() {
+			repo, err := c.Repo(&model.User{Token: "token"}, "test_name", "repo_name")
+			g.Assert(err == nil).IsTrue()
+			g.Assert(repo.FullName).Equal("test_name/repo_name")
+			g.Assert(len(paths) != 0).IsTrue()
+			for _, path := range paths {
+				g.Assert(strings.HasPrefix(path, "/gitea/api/v1/")).IsTrue()
+			}
+		})
+		g.It("Should use the host for the netrc machine", func() {
+			netrc, err := c.Netrc(&model.User{Login: "octocat", Token: "token"}, &model.Repo{})
+			g.Assert(err == nil).IsTrue()
+			g.Assert(netrc.Machine).Equal("127.0.0.1")
+		})
+	})
+}
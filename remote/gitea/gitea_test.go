@@ -15,12 +15,18 @@
 package gitea
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/franela/goblin"
 	"github.com/gin-gonic/gin"
 	"github.com/woodpecker-ci/woodpecker/model"
+	"github.com/woodpecker-ci/woodpecker/remote"
 	"github.com/woodpecker-ci/woodpecker/remote/gitea/fixtures"
 )
 
@@ -97,6 +103,7 @@ func Test_gitea(t *testing.T) {
 				g.Assert(repo.IsPrivate).IsTrue()
 				g.Assert(repo.Clone).Equal("http://localhost/test_name/repo_name.git")
 				g.Assert(repo.Link).Equal("http://localhost/test_name/repo_name")
+				g.Assert(repo.Description).Equal("a test repository")
 			})
 			g.It("Should handle a not found error", func() {
 				_, err := c.Repo(fakeUser, fakeRepoNotFound.Owner, fakeRepoNotFound.Name)
@@ -104,6 +111,84 @@ func Test_gitea(t *testing.T) {
 			})
 		})
 
+		g.Describe("Checking a repository exists", func() {
+			g.It("Should return true for an existing repository", func() {
+				exists, err := c.(remote.RepoChecker).RepoExists(fakeUser, fakeRepo.Owner, fakeRepo.Name)
+				g.Assert(err == nil).IsTrue()
+				g.Assert(exists).IsTrue()
+			})
+			g.It("Should return false for a missing repository", func() {
+				exists, err := c.(remote.RepoChecker).RepoExists(fakeUser, fakeRepoNotFound.Owner, fakeRepoNotFound.Name)
+				g.Assert(err == nil).IsTrue()
+				g.Assert(exists).IsFalse()
+			})
+			g.It("Should return an error for a forbidden repository", func() {
+				exists, err := c.(remote.RepoChecker).RepoExists(fakeUser, "test_name", "repo_forbidden")
+				g.Assert(err == remote.ErrRepoNoAccess).IsTrue()
+				g.Assert(exists).IsFalse()
+			})
+			g.It("Should fall back to a GET request", func() {
+				var methods []string
+				d := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					methods = append(methods, r.Method)
+					if r.Method == "HEAD" {
+						w.WriteHeader(http.StatusMethodNotAllowed)
+						return
+					}
+					fixtures.Handler().ServeHTTP(w, r)
+				}))
+				defer d.Close()
+				c, _ := New(Opts{URL: d.URL})
+
+				exists, err := c.(remote.RepoChecker).RepoExists(fakeUser, fakeRepo.Owner, fakeRepo.Name)
+				g.Assert(err == nil).IsTrue()
+				g.Assert(exists).IsTrue()
+				g.Assert(methods).Equal([]string{"HEAD", "GET"})
+			})
+		})
+
+		g.Describe("Requesting the CODEOWNERS file", func() {
+			g.It("Should return the first CODEOWNERS file found", func() {
+				data, err := c.(remote.CodeOwnersFetcher).CodeOwners(fakeUser, fakeRepo, "9ecad50")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(string(data)).Equal("*.go @octocat\n/docs/ @org/docs\n")
+			})
+			g.It("Should return nothing without a CODEOWNERS file", func() {
+				data, err := c.(remote.CodeOwnersFetcher).CodeOwners(fakeUser, fakeRepo, "v1.0.0")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(data == nil).IsTrue()
+			})
+		})
+
+		g.Describe("Requesting the branch protection", func() {
+			g.It("Should return the required contexts and approvals", func() {
+				protection, err := c.(remote.BranchProtectionGetter).BranchProtection(fakeUser, fakeRepo, "main")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(protection.Protected).IsTrue()
+				g.Assert(protection.RequiredContexts).Equal([]string{"ci/woodpecker", "lint/*"})
+				g.Assert(protection.RequiredApprovals).Equal(2)
+			})
+			g.It("Should return an unprotected branch", func() {
+				protection, err := c.(remote.BranchProtectionGetter).BranchProtection(fakeUser, fakeRepo, "feature")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(protection.Branch).Equal("feature")
+				g.Assert(protection.Protected).IsFalse()
+				g.Assert(len(protection.RequiredContexts)).Equal(0)
+			})
+		})
+
+		g.Describe("Requesting repository topics", func() {
+			g.It("Should return the topics", func() {
+				topics, err := c.(remote.TopicLister).Topics(fakeUser, fakeRepo)
+				g.Assert(err == nil).IsTrue()
+				g.Assert(topics).Equal([]string{"go", "ci"})
+			})
+			g.It("Should handle a not found error", func() {
+				_, err := c.(remote.TopicLister).Topics(fakeUser, fakeRepoNotFound)
+				g.Assert(err != nil).IsTrue()
+			})
+		})
+
 		g.Describe("Requesting repository permissions", func() {
 			g.It("Should return the permission details", func() {
 				perm, err := c.Perm(fakeUser, fakeRepo.Owner, fakeRepo.Name)
@@ -118,6 +203,20 @@ func Test_gitea(t *testing.T) {
 			})
 		})
 
+		g.Describe("Requesting sender permissions", func() {
+			g.It("Should return the collaborator permissions", func() {
+				perm, err := c.(remote.SenderPermer).SenderPerm(fakeUser, fakeRepo, "octocat")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(perm.Admin).IsFalse()
+				g.Assert(perm.Push).IsTrue()
+				g.Assert(perm.Pull).IsTrue()
+			})
+			g.It("Should return an error for non-collaborators", func() {
+				_, err := c.(remote.SenderPermer).SenderPerm(fakeUser, fakeRepo, "stranger")
+				g.Assert(err != nil).IsTrue()
+			})
+		})
+
 		g.Describe("Requesting a repository list", func() {
 			g.It("Should return the repository list", func() {
 				repos, err := c.Repos(fakeUser)
@@ -126,6 +225,24 @@ func Test_gitea(t *testing.T) {
 				g.Assert(repos[0].Name).Equal(fakeRepo.Name)
 				g.Assert(repos[0].FullName).Equal(fakeRepo.Owner + "/" + fakeRepo.Name)
 			})
+			g.It("Should exclude archived repositories", func() {
+				repos, err := c.Repos(fakeUser)
+				g.Assert(err == nil).IsTrue()
+				g.Assert(len(repos)).Equal(1)
+				g.Assert(repos[0].IsArchived).IsFalse()
+			})
+			g.It("Should include archived repositories when enabled", func() {
+				c, _ := New(Opts{
+					URL:             s.URL,
+					SkipVerify:      true,
+					IncludeArchived: true,
+				})
+				repos, err := c.Repos(fakeUser)
+				g.Assert(err == nil).IsTrue()
+				g.Assert(len(repos)).Equal(2)
+				g.Assert(repos[1].FullName).Equal(fakeRepoArchived.FullName)
+				g.Assert(repos[1].IsArchived).IsTrue()
+			})
 			g.It("Should handle a not found error", func() {
 				_, err := c.Repos(fakeUserNoRepos)
 				g.Assert(err != nil).IsTrue()
@@ -137,6 +254,12 @@ func Test_gitea(t *testing.T) {
 			g.Assert(err == nil).IsTrue()
 		})
 
+		g.It("Should not register hooks for archived repositories", func() {
+			err := c.Activate(fakeUser, fakeRepoArchived, "http://localhost")
+			g.Assert(err != nil).IsTrue()
+			g.Assert(err.Error()).Equal("Repository test_name/repo_archived is archived and cannot be activated")
+		})
+
 		g.It("Should remove repository hooks", func() {
 			err := c.Deactivate(fakeUser, fakeRepo, "http://localhost")
 			g.Assert(err == nil).IsTrue()
@@ -147,12 +270,636 @@ func Test_gitea(t *testing.T) {
 			g.Assert(err == nil).IsTrue()
 			g.Assert(string(raw)).Equal("{ platform: linux/amd64 }")
 		})
+		g.It("Should report a missing repository file as not found", func() {
+			_, err := c.File(fakeUser, fakeRepo, fakeBuild, "file_not_found")
+			g.Assert(errors.Is(err, remote.ErrFileNotFound)).IsTrue()
+		})
+
+		g.Describe("Requesting files with a maximum config size", func() {
+			c, _ := New(Opts{
+				URL:           s.URL,
+				SkipVerify:    true,
+				MaxConfigSize: 1024,
+			})
+
+			g.It("Should return a file within the limit", func() {
+				raw, err := c.File(fakeUser, fakeRepo, fakeBuild, ".drone.yml")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(string(raw)).Equal("{ platform: linux/amd64 }")
+			})
+			g.It("Should report a missing file as not found", func() {
+				_, err := c.File(fakeUser, fakeRepo, fakeBuild, "file_not_found")
+				g.Assert(errors.Is(err, remote.ErrFileNotFound)).IsTrue()
+			})
+			g.It("Should reject a file exceeding the limit", func() {
+				_, err := c.File(fakeUser, fakeRepo, fakeBuild, "file_too_large")
+				g.Assert(err != nil).IsTrue()
+				g.Assert(err.Error()).Equal("config file exceeds the maximum size of 1024 bytes")
+			})
+			g.It("Should reject a folder file exceeding the limit", func() {
+				_, err := c.Dir(fakeUser, fakeRepo, fakeBuild, ".woodpecker")
+				g.Assert(err != nil).IsTrue()
+				g.Assert(err.Error()).Equal("multi-pipeline cannot get .woodpecker/release.yml: config file exceeds the maximum size of 1024 bytes")
+			})
+		})
+
+		g.Describe("Requesting a folder with a maximum number of config files", func() {
+			raws := 0
+			d := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.Contains(r.URL.Path, "/raw/") {
+					raws++
+					w.Write([]byte("{ platform: linux/amd64 }"))
+					return
+				}
+				fixtures.Handler().ServeHTTP(w, r)
+			}))
+
+			g.After(func() {
+				d.Close()
+			})
+
+			g.It("Should return the files at the limit, ignoring the files that are not configs", func() {
+				c, _ := New(Opts{URL: d.URL, MaxConfigFiles: 2})
+				configs, err := c.Dir(fakeUser, fakeRepo, fakeBuild, ".woodpecker")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(len(configs)).Equal(2)
+			})
+			g.It("Should reject a folder exceeding the limit before fetching the files", func() {
+				raws = 0
+				c, _ := New(Opts{URL: d.URL, MaxConfigFiles: 1})
+				_, err := c.Dir(fakeUser, fakeRepo, fakeBuild, ".woodpecker")
+				g.Assert(err != nil).IsTrue()
+				g.Assert(err.Error()).Equal("multi-pipeline .woodpecker has 2 config files, exceeding the maximum of 1")
+				g.Assert(raws).Equal(0)
+			})
+		})
 
 		g.It("Should return nil from send build status", func() {
 			err := c.Status(fakeUser, fakeRepo, fakeBuild, "http://gitea.io", nil)
 			g.Assert(err == nil).IsTrue()
 		})
 
+		g.Describe("Dispatching a manual build", func() {
+			g.It("Should return a manual build of the branch", func() {
+				dispatch, err := c.(remote.Dispatcher).Dispatch(fakeUser, fakeRepo, "refs/heads/main", map[string]string{"DEPLOY": "staging"})
+				g.Assert(err == nil).IsTrue()
+				g.Assert(dispatch.Build.Event).Equal(model.EventManual)
+				g.Assert(dispatch.Build.Commit).Equal("9ecad50")
+				g.Assert(dispatch.Build.Ref).Equal("refs/heads/main")
+				g.Assert(dispatch.Build.Branch).Equal("main")
+				g.Assert(dispatch.Build.Message).Equal("update readme")
+				g.Assert(dispatch.Build.Link).Equal("http://localhost/test_name/repo_name/commit/9ecad50")
+				g.Assert(dispatch.Build.Sender).Equal(fakeUser.Login)
+				g.Assert(dispatch.Params).Equal(map[string]string{"DEPLOY": "staging"})
+			})
+			g.It("Should reject users without push access", func() {
+				_, err := c.(remote.Dispatcher).Dispatch(fakeUser, fakeRepoReadOnly, "main", nil)
+				g.Assert(err != nil).IsTrue()
+				g.Assert(err.Error()).Equal("User someuser is not allowed to trigger builds of test_name/repo_read_only")
+			})
+			g.It("Should handle a not found error", func() {
+				_, err := c.(remote.Dispatcher).Dispatch(fakeUser, fakeRepo, "branch_not_found", nil)
+				g.Assert(err != nil).IsTrue()
+			})
+		})
+
+		g.Describe("Requesting the commits of a branch", func() {
+			g.It("Should return the first page", func() {
+				commits, err := c.(remote.CommitLister).ListCommits(fakeUser, fakeRepo, "main", 1)
+				g.Assert(err == nil).IsTrue()
+				g.Assert(len(commits)).Equal(2)
+				g.Assert(commits[0].SHA).Equal("0a1b2c3d4e5f60718293a4b5c6d7e8f901234567")
+				g.Assert(commits[0].Message).Equal("update readme")
+				g.Assert(commits[0].Author).Equal("Test User")
+				g.Assert(commits[0].Email).Equal("test@example.com")
+				g.Assert(commits[0].Created).Equal(int64(1609581600))
+				g.Assert(commits[1].SHA).Equal("9ecad50")
+			})
+			g.It("Should return the next page", func() {
+				commits, err := c.(remote.CommitLister).ListCommits(fakeUser, fakeRepo, "main", 2)
+				g.Assert(err == nil).IsTrue()
+				g.Assert(len(commits)).Equal(1)
+				g.Assert(commits[0].Author).Equal("Other User")
+				g.Assert(commits[0].Link).Equal("http://localhost/test_name/repo_name/commit/6dcb09b5b57875f334f61aebed695e2e4193db5e")
+			})
+			g.It("Should return an empty page after the last commit", func() {
+				commits, err := c.(remote.CommitLister).ListCommits(fakeUser, fakeRepo, "main", 3)
+				g.Assert(err == nil).IsTrue()
+				g.Assert(len(commits)).Equal(0)
+			})
+			g.It("Should return no commits for an empty repository", func() {
+				repo := &model.Repo{Owner: "test_name", Name: "empty_repo", FullName: "test_name/empty_repo"}
+				commits, err := c.(remote.CommitLister).ListCommits(fakeUser, repo, "main", 1)
+				g.Assert(err == nil).IsTrue()
+				g.Assert(len(commits)).Equal(0)
+			})
+			g.It("Should handle a not found error", func() {
+				_, err := c.(remote.CommitLister).ListCommits(fakeUser, fakeRepo, "unknown", 1)
+				g.Assert(err != nil).IsTrue()
+			})
+		})
+
+		g.Describe("Requesting the organization teams", func() {
+			g.It("Should return the teams of all pages", func() {
+				teams, err := c.(remote.OrgTeamLister).OrgTeams(fakeUser, "test_org")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(len(teams)).Equal(3)
+				g.Assert(teams[0].Name).Equal("Owners")
+				g.Assert(teams[0].Org).Equal("test_org")
+				g.Assert(teams[0].Description).Equal("Owners of the organization")
+				g.Assert(teams[0].Permission).Equal("owner")
+				g.Assert(teams[2].Name).Equal("Readers")
+			})
+			g.It("Should flag the teams the user is a member of", func() {
+				teams, err := c.(remote.OrgTeamLister).OrgTeams(fakeUser, "test_org")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(teams[0].Member).IsFalse()
+				g.Assert(teams[1].Member).IsTrue()
+				g.Assert(teams[2].Member).IsFalse()
+			})
+			g.It("Should handle a not found error", func() {
+				_, err := c.(remote.OrgTeamLister).OrgTeams(fakeUser, "unknown_org")
+				g.Assert(err != nil).IsTrue()
+			})
+		})
+
+		g.Describe("Requesting the public keys", func() {
+			g.It("Should return the keys of all pages", func() {
+				keys, err := c.(remote.PublicKeyLister).ListPublicKeys(fakeUser)
+				g.Assert(err == nil).IsTrue()
+				g.Assert(len(keys)).Equal(3)
+				g.Assert(keys[0].ID).Equal(int64(1))
+				g.Assert(keys[0].Title).Equal("laptop")
+				g.Assert(keys[0].Fingerprint).Equal("SHA256:9AYEMZdzj3tmdp8NvlXhtWXa5zEg+Y8JABtS4GTqvXk")
+				g.Assert(keys[0].ReadOnly).IsFalse()
+				g.Assert(keys[0].Created).Equal(int64(1609495200))
+				g.Assert(keys[1].Title).Equal("deploy")
+				g.Assert(keys[1].ReadOnly).IsTrue()
+				g.Assert(keys[2].Title).Equal("desktop")
+				g.Assert(keys[2].Fingerprint).Equal("SHA256:pG7Yy0x3lYz2L0G2o2q4gq7mJ3mYc4jKc9iN1yUj7wE")
+			})
+		})
+
+		g.Describe("Requesting the combined status of a commit", func() {
+			g.It("Should return a successful state if all statuses are", func() {
+				status, err := c.(remote.CombinedStatusGetter).GetCombinedStatus(fakeUser, fakeRepo, "green")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(status.State).Equal(model.StatusSuccess)
+				g.Assert(len(status.Statuses)).Equal(2)
+				g.Assert(status.Statuses[0].Context).Equal("jenkins/build")
+				g.Assert(status.Statuses[0].State).Equal(model.StatusSuccess)
+				g.Assert(status.Statuses[0].Description).Equal("Build passed")
+				g.Assert(status.Statuses[0].TargetURL).Equal("https://jenkins.example.com/job/build/1")
+				g.Assert(status.Statuses[1].Context).Equal("sonar/quality")
+			})
+			g.It("Should return a failed state for mixed statuses", func() {
+				status, err := c.(remote.CombinedStatusGetter).GetCombinedStatus(fakeUser, fakeRepo, "mixed")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(status.State).Equal(model.StatusFailure)
+				g.Assert(len(status.Statuses)).Equal(3)
+				g.Assert(status.Statuses[0].State).Equal(model.StatusSuccess)
+				g.Assert(status.Statuses[1].State).Equal(model.StatusFailure)
+				g.Assert(status.Statuses[2].Context).Equal("review")
+				g.Assert(status.Statuses[2].State).Equal(model.StatusPending)
+			})
+			g.It("Should return an empty state without statuses", func() {
+				status, err := c.(remote.CombinedStatusGetter).GetCombinedStatus(fakeUser, fakeRepo, "none")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(status.State).Equal("")
+				g.Assert(len(status.Statuses)).Equal(0)
+			})
+		})
+
+		g.Describe("Requesting the hook deliveries", func() {
+			g.It("Should return the deliveries", func() {
+				deliveries, err := c.(remote.HookDeliveryLister).ListHookDeliveries(fakeUser, fakeRepo, 1)
+				g.Assert(err == nil).IsTrue()
+				g.Assert(len(deliveries)).Equal(3)
+				g.Assert(deliveries[0].Status).Equal(model.StatusPending)
+				g.Assert(deliveries[0].Event).Equal("push")
+				g.Assert(deliveries[0].StatusCode).Equal(0)
+				g.Assert(deliveries[0].Delivered).Equal(int64(0))
+				g.Assert(deliveries[1].Status).Equal(model.StatusFailure)
+				g.Assert(deliveries[1].StatusCode).Equal(502)
+				g.Assert(deliveries[2].ID).Equal(int64(1))
+				g.Assert(deliveries[2].GUID).Equal("a3b1f2e4-7c4d-4c8e-b5a6-9d0e1f2a3b4c")
+				g.Assert(deliveries[2].Status).Equal(model.StatusSuccess)
+				g.Assert(deliveries[2].StatusCode).Equal(200)
+				g.Assert(deliveries[2].Delivered).Equal(int64(1609495200))
+			})
+			g.It("Should report versions without the deliveries api as unsupported", func() {
+				_, err := c.(remote.HookDeliveryLister).ListHookDeliveries(fakeUser, fakeRepo, 2)
+				g.Assert(err == remote.ErrHookDeliveriesUnsupported).IsTrue()
+				_, err = c.(remote.HookDeliveryLister).ListHookDeliveries(fakeUser, fakeRepo, 4)
+				g.Assert(err == remote.ErrHookDeliveriesUnsupported).IsTrue()
+			})
+			g.It("Should return an error for a missing hook", func() {
+				_, err := c.(remote.HookDeliveryLister).ListHookDeliveries(fakeUser, fakeRepo, 3)
+				g.Assert(err != nil).IsTrue()
+				g.Assert(err != remote.ErrHookDeliveriesUnsupported).IsTrue()
+			})
+		})
+
+		g.Describe("Checking the repository config", func() {
+			withBranch := func(branch string) *model.Repo {
+				repo := *fakeRepo
+				repo.Branch = branch
+				return &repo
+			}
+			g.It("Should find the config file at the default branch", func() {
+				exists, err := c.(remote.ConfigChecker).HasConfig(fakeUser, withBranch("v1.0.0"), ".drone.yml")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(exists).IsTrue()
+			})
+			g.It("Should report a missing config file", func() {
+				exists, err := c.(remote.ConfigChecker).HasConfig(fakeUser, withBranch("main"), ".drone.yml")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(exists).IsFalse()
+			})
+			g.It("Should find the config folder", func() {
+				exists, err := c.(remote.ConfigChecker).HasConfig(fakeUser, withBranch("main"), ".woodpecker/")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(exists).IsTrue()
+			})
+			g.It("Should report a missing config folder", func() {
+				exists, err := c.(remote.ConfigChecker).HasConfig(fakeUser, withBranch("main"), ".ci/")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(exists).IsFalse()
+			})
+			g.It("Should cache the result per repository", func() {
+				repo := withBranch("cached")
+				c.(*client).configs.Set(configCacheKey(repo, ".drone.yml"), true)
+				exists, err := c.(remote.ConfigChecker).HasConfig(fakeUser, repo, ".drone.yml")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(exists).IsTrue()
+
+				exists, err = c.(remote.ConfigChecker).HasConfig(fakeUser, withBranch("uncached"), ".drone.yml")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(exists).IsFalse()
+			})
+		})
+
+		g.Describe("Requesting the releases", func() {
+			g.It("Should return the first page", func() {
+				releases, err := c.(remote.ReleaseLister).ListReleases(fakeUser, fakeRepo, 1)
+				g.Assert(err == nil).IsTrue()
+				g.Assert(len(releases)).Equal(2)
+				g.Assert(releases[0].Tag).Equal("v1.1.0")
+				g.Assert(releases[0].Draft).IsTrue()
+				g.Assert(releases[0].Prerelease).IsFalse()
+				g.Assert(releases[1].Tag).Equal("v1.1.0-rc1")
+				g.Assert(releases[1].Name).Equal("v1.1.0 release candidate")
+				g.Assert(releases[1].Draft).IsFalse()
+				g.Assert(releases[1].Prerelease).IsTrue()
+			})
+			g.It("Should return the next page", func() {
+				releases, err := c.(remote.ReleaseLister).ListReleases(fakeUser, fakeRepo, 2)
+				g.Assert(err == nil).IsTrue()
+				g.Assert(len(releases)).Equal(1)
+				g.Assert(releases[0].Tag).Equal("v1.0.0")
+				g.Assert(releases[0].Body).Equal("first release")
+				g.Assert(releases[0].Created).Equal(int64(1609495200))
+			})
+			g.It("Should return an empty page after the last release", func() {
+				releases, err := c.(remote.ReleaseLister).ListReleases(fakeUser, fakeRepo, 3)
+				g.Assert(err == nil).IsTrue()
+				g.Assert(len(releases)).Equal(0)
+			})
+		})
+
+		g.Describe("Publishing a release", func() {
+			g.It("Should create a release", func() {
+				release, err := c.(remote.ReleasePublisher).CreateRelease(fakeUser, fakeRepo, &remote.ReleaseInput{
+					Tag:        "v2.0.0-rc1",
+					Name:       "v2.0.0 release candidate",
+					Draft:      true,
+					Prerelease: true,
+				})
+				g.Assert(err == nil).IsTrue()
+				g.Assert(release.ID).Equal(int64(4))
+				g.Assert(release.Tag).Equal("v2.0.0-rc1")
+				g.Assert(release.Name).Equal("v2.0.0 release candidate")
+				g.Assert(release.Draft).IsTrue()
+				g.Assert(release.Prerelease).IsTrue()
+			})
+			g.It("Should title a release after its tag by default", func() {
+				release, err := c.(remote.ReleasePublisher).CreateRelease(fakeUser, fakeRepo, &remote.ReleaseInput{
+					Tag: "v2.0.0",
+				})
+				g.Assert(err == nil).IsTrue()
+				g.Assert(release.Name).Equal("v2.0.0")
+			})
+			g.It("Should return the existing release of a tag", func() {
+				release, err := c.(remote.ReleasePublisher).CreateRelease(fakeUser, fakeRepo, &remote.ReleaseInput{
+					Tag:  "v1.0.0",
+					Name: "another name",
+				})
+				g.Assert(err == nil).IsTrue()
+				g.Assert(release.ID).Equal(int64(1))
+				g.Assert(release.Name).Equal("v1.0.0")
+			})
+			g.It("Should upload a release asset", func() {
+				err := c.(remote.ReleasePublisher).UploadReleaseAsset(fakeUser, fakeRepo, &model.Release{ID: 1}, "app.tar.gz", strings.NewReader("content"))
+				g.Assert(err == nil).IsTrue()
+			})
+			g.It("Should handle an asset rejected by the server", func() {
+				err := c.(remote.ReleasePublisher).UploadReleaseAsset(fakeUser, fakeRepo, &model.Release{ID: 1}, "app.tar.gz", strings.NewReader(strings.Repeat("#", 2048)))
+				g.Assert(err != nil).IsTrue()
+				g.Assert(err.Error()).Equal("release asset app.tar.gz exceeds the maximum size allowed by the gitea server")
+			})
+			g.It("Should reject an asset exceeding the configured size", func() {
+				limited, _ := New(Opts{URL: s.URL, MaxAssetSize: 16})
+				err := limited.(remote.ReleasePublisher).UploadReleaseAsset(fakeUser, fakeRepo, &model.Release{ID: 1}, "app.tar.gz", strings.NewReader(strings.Repeat("#", 32)))
+				g.Assert(err != nil).IsTrue()
+				g.Assert(err.Error()).Equal("release asset app.tar.gz exceeds the maximum size of 16 bytes")
+			})
+		})
+
+		g.Describe("Requesting a ref resolution", func() {
+			g.It("Should resolve a branch", func() {
+				sha, err := c.(remote.RefResolver).ResolveRef(fakeUser, fakeRepo, "refs/heads/main")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(sha).Equal("9ecad50")
+			})
+			g.It("Should resolve a tag", func() {
+				sha, err := c.(remote.RefResolver).ResolveRef(fakeUser, fakeRepo, "refs/tags/v1.0.0")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(sha).Equal("e5f4a2b")
+			})
+			g.It("Should resolve a full sha", func() {
+				sha, err := c.(remote.RefResolver).ResolveRef(fakeUser, fakeRepo, "6dcb09b5b57875f334f61aebed695e2e4193db5e")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(sha).Equal("6dcb09b5b57875f334f61aebed695e2e4193db5e")
+			})
+			g.It("Should resolve an unqualified tag name", func() {
+				sha, err := c.(remote.RefResolver).ResolveRef(fakeUser, fakeRepo, "v1.0.0")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(sha).Equal("e5f4a2b")
+			})
+			g.It("Should prefer a branch over a tag of the same name", func() {
+				sha, err := c.(remote.RefResolver).ResolveRef(fakeUser, fakeRepo, "main")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(sha).Equal("9ecad50")
+			})
+			g.It("Should handle an unknown ref", func() {
+				_, err := c.(remote.RefResolver).ResolveRef(fakeUser, fakeRepo, "unknown")
+				g.Assert(err != nil).IsTrue()
+			})
+		})
+
+		g.Describe("Requesting commit verification", func() {
+			g.It("Should return true for a verified commit", func() {
+				verified, err := c.(remote.CommitVerifier).CommitVerified(fakeUser, fakeRepo, fakeBuild)
+				g.Assert(err == nil).IsTrue()
+				g.Assert(verified).IsTrue()
+			})
+			g.It("Should return false for an unverified commit", func() {
+				verified, err := c.(remote.CommitVerifier).CommitVerified(fakeUser, fakeRepo, &model.Build{Commit: "v1.0.0"})
+				g.Assert(err == nil).IsTrue()
+				g.Assert(verified).IsFalse()
+			})
+			g.It("Should handle a not found error", func() {
+				_, err := c.(remote.CommitVerifier).CommitVerified(fakeUser, fakeRepo, &model.Build{Commit: "unknown"})
+				g.Assert(err != nil).IsTrue()
+			})
+		})
+
+		g.Describe("Listing a config folder without the tree api", func() {
+			var trees int
+			d := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case strings.Contains(r.URL.Path, "/git/trees/"):
+					trees++
+					w.WriteHeader(http.StatusNotImplemented)
+				case strings.Contains(r.URL.Path, "/raw/"):
+					w.Write([]byte("{ platform: linux/amd64 }"))
+				default:
+					fixtures.Handler().ServeHTTP(w, r)
+				}
+			}))
+			c, _ := New(Opts{URL: d.URL})
+
+			g.After(func() {
+				d.Close()
+			})
+
+			g.It("Should fall back to listing the folder contents", func() {
+				configs, err := c.Dir(fakeUser, fakeRepo, fakeBuild, ".woodpecker/")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(len(configs)).Equal(2)
+				g.Assert(configs[0].Name).Equal(".woodpecker/release.yml")
+				g.Assert(configs[1].Name).Equal(".woodpecker/build.yml")
+				g.Assert(string(configs[1].Data)).Equal("{ platform: linux/amd64 }")
+			})
+			g.It("Should remember the tree api is unsupported", func() {
+				_, err := c.Dir(fakeUser, fakeRepo, fakeBuild, ".woodpecker")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(trees).Equal(1)
+			})
+			g.It("Should return no configs for a missing folder", func() {
+				configs, err := c.Dir(fakeUser, fakeRepo, fakeBuild, ".drone")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(len(configs)).Equal(0)
+			})
+		})
+
+		g.Describe("Fetching a commit diff", func() {
+			d := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/test_name/repo_name/compare/main...feature.diff":
+					w.Write([]byte("diff --git a/README.md b/README.md\n"))
+				case "/test_name/repo_name/compare/main...large.diff":
+					w.Write([]byte(strings.Repeat("+", 4096)))
+				default:
+					fixtures.Handler().ServeHTTP(w, r)
+				}
+			}))
+			c, _ := New(Opts{
+				URL:         d.URL,
+				MaxDiffSize: 1024,
+			})
+
+			g.After(func() {
+				d.Close()
+			})
+
+			g.It("Should return the diff", func() {
+				diff, err := c.(remote.DiffFetcher).CommitDiff(fakeUser, fakeRepo, "main", "feature")
+				g.Assert(err == nil).IsTrue()
+				g.Assert(string(diff)).Equal("diff --git a/README.md b/README.md\n")
+			})
+			g.It("Should reject a diff exceeding the limit", func() {
+				_, err := c.(remote.DiffFetcher).CommitDiff(fakeUser, fakeRepo, "main", "large")
+				g.Assert(err != nil).IsTrue()
+				g.Assert(err.Error()).Equal("diff of main...large exceeds the maximum size of 1024 bytes")
+			})
+			g.It("Should handle a not found error", func() {
+				_, err := c.(remote.DiffFetcher).CommitDiff(fakeUser, fakeRepo, "main", "unknown")
+				g.Assert(err != nil).IsTrue()
+			})
+			g.It("Should return the files changed by a pull request", func() {
+				build := &model.Build{Event: model.EventPull, Branch: "main", Commit: "feature"}
+				files, err := c.(remote.ChangedFilesLister).ChangedFiles(fakeUser, fakeRepo, build)
+				g.Assert(err == nil).IsTrue()
+				g.Assert(files).Equal([]string{"README.md"})
+			})
+		})
+
+		g.Describe("Sending a build status with dedup enabled", func() {
+			var posts int
+			var contexts []string
+			d := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == "POST" && strings.Contains(r.URL.Path, "/statuses/") {
+					in := struct {
+						Context string `json:"context"`
+					}{}
+					json.NewDecoder(r.Body).Decode(&in)
+					contexts = append(contexts, in.Context)
+					posts++
+				}
+				fixtures.Handler().ServeHTTP(w, r)
+			}))
+			c, _ := New(Opts{
+				URL:         d.URL,
+				StatusDedup: true,
+			})
+
+			g.After(func() {
+				d.Close()
+			})
+
+			g.It("Should skip posting a duplicate status", func() {
+				posts = 0
+				build := &model.Build{Commit: "9ecad50", Status: model.StatusPending}
+				g.Assert(c.Status(fakeUser, fakeRepo, build, "http://gitea.io", nil) == nil).IsTrue()
+				g.Assert(c.Status(fakeUser, fakeRepo, build, "http://gitea.io", nil) == nil).IsTrue()
+				g.Assert(posts).Equal(1)
+			})
+			g.It("Should post a changed status", func() {
+				posts = 0
+				build := &model.Build{Commit: "v1.0.0", Status: model.StatusPending}
+				g.Assert(c.Status(fakeUser, fakeRepo, build, "http://gitea.io", nil) == nil).IsTrue()
+				build.Status = model.StatusSuccess
+				g.Assert(c.Status(fakeUser, fakeRepo, build, "http://gitea.io", nil) == nil).IsTrue()
+				build.Status = model.StatusPending
+				g.Assert(c.Status(fakeUser, fakeRepo, build, "http://gitea.io", nil) == nil).IsTrue()
+				g.Assert(posts).Equal(3)
+			})
+			g.It("Should post the statuses of a batch once per context", func() {
+				posts, contexts = 0, nil
+				build := &model.Build{Commit: "v1.0.0", Status: model.StatusRunning}
+				var procs []*model.Proc
+				for i := 0; i < 10; i++ {
+					procs = append(procs, &model.Proc{PID: i + 1, Name: fmt.Sprintf("pipeline-%d", i%5), State: model.StatusRunning})
+				}
+				g.Assert(c.(remote.StatusBatcher).StatusBatch(fakeUser, fakeRepo, build, "http://gitea.io", procs) == nil).IsTrue()
+				g.Assert(posts).Equal(6)
+				g.Assert(contexts[0]).Equal(fakeRepo.StatusContextOr(""))
+				g.Assert(contexts[1]).Equal(fakeRepo.StatusContextOr("") + "/pipeline-0")
+				g.Assert(contexts[5]).Equal(fakeRepo.StatusContextOr("") + "/pipeline-4")
+				g.Assert(c.(remote.StatusBatcher).StatusBatch(fakeUser, fakeRepo, build, "http://gitea.io", procs) == nil).IsTrue()
+				g.Assert(posts).Equal(6)
+
+				procs[0].State = model.StatusSuccess
+				g.Assert(c.(remote.StatusBatcher).StatusBatch(fakeUser, fakeRepo, build, "http://gitea.io", procs) == nil).IsTrue()
+				g.Assert(posts).Equal(6)
+				procs[5].State = model.StatusSuccess
+				g.Assert(c.(remote.StatusBatcher).StatusBatch(fakeUser, fakeRepo, build, "http://gitea.io", procs) == nil).IsTrue()
+				g.Assert(posts).Equal(7)
+				g.Assert(contexts[6]).Equal(fakeRepo.StatusContextOr("") + "/pipeline-0")
+			})
+		})
+
+		g.Describe("Sending the status of a skipped build", func() {
+			var state, desc string
+			d := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == "POST" && strings.Contains(r.URL.Path, "/statuses/") {
+					in := struct {
+						State       string `json:"state"`
+						Description string `json:"description"`
+					}{}
+					json.NewDecoder(r.Body).Decode(&in)
+					state, desc = in.State, in.Description
+				}
+				fixtures.Handler().ServeHTTP(w, r)
+			}))
+			skipped, _ := New(Opts{URL: d.URL, SkippedSuccess: true})
+			pending, _ := New(Opts{URL: d.URL})
+
+			g.After(func() {
+				d.Close()
+			})
+
+			build := func(states ...string) *model.Build {
+				b := &model.Build{Commit: "9ecad50", Status: model.StatusPending}
+				for i, s := range states {
+					b.Procs = append(b.Procs,
+						&model.Proc{PID: i*2 + 1, State: s},
+						&model.Proc{PID: i*2 + 2, PPID: i*2 + 1, State: s},
+					)
+				}
+				return b
+			}
+
+			g.It("Should post success if all pipelines are skipped", func() {
+				b := build(model.StatusSkipped, model.StatusSkipped)
+				g.Assert(skipped.Status(fakeUser, fakeRepo, b, "http://gitea.io", nil) == nil).IsTrue()
+				g.Assert(state).Equal("success")
+				g.Assert(desc).Equal(DescSkipped)
+			})
+			g.It("Should post the build status if a pipeline runs", func() {
+				b := build(model.StatusSkipped, model.StatusPending)
+				g.Assert(skipped.Status(fakeUser, fakeRepo, b, "http://gitea.io", nil) == nil).IsTrue()
+				g.Assert(state).Equal("pending")
+				g.Assert(desc).Equal(DescPending)
+			})
+			g.It("Should post the build status for a build without pipelines", func() {
+				g.Assert(skipped.Status(fakeUser, fakeRepo, build(), "http://gitea.io", nil) == nil).IsTrue()
+				g.Assert(state).Equal("pending")
+			})
+			g.It("Should post the build status unless enabled", func() {
+				b := build(model.StatusSkipped, model.StatusSkipped)
+				g.Assert(pending.Status(fakeUser, fakeRepo, b, "http://gitea.io", nil) == nil).IsTrue()
+				g.Assert(state).Equal("pending")
+				g.Assert(desc).Equal(DescPending)
+			})
+		})
+
+		g.Describe("Sending a build status with a status context", func() {
+			var context string
+			d := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == "POST" && strings.Contains(r.URL.Path, "/statuses/") {
+					in := struct {
+						Context string `json:"context"`
+					}{}
+					json.NewDecoder(r.Body).Decode(&in)
+					context = in.Context
+				}
+				fixtures.Handler().ServeHTTP(w, r)
+			}))
+			c, _ := New(Opts{
+				URL:     d.URL,
+				Context: "continuous-integration/woodpecker",
+			})
+
+			g.After(func() {
+				d.Close()
+			})
+
+			g.It("Should use the instance context by default", func() {
+				build := &model.Build{Commit: "9ecad50", Status: model.StatusPending}
+				g.Assert(c.Status(fakeUser, fakeRepo, build, "http://gitea.io", nil) == nil).IsTrue()
+				g.Assert(context).Equal("continuous-integration/woodpecker")
+			})
+			g.It("Should use the repository context when set", func() {
+				repo := *fakeRepo
+				repo.StatusContext = "ci/team-a"
+				build := &model.Build{Commit: "9ecad50", Status: model.StatusPending}
+				g.Assert(c.Status(fakeUser, &repo, build, "http://gitea.io", nil) == nil).IsTrue()
+				g.Assert(context).Equal("ci/team-a")
+			})
+		})
+
 		g.Describe("Given an authentication request", func() {
 			g.It("Should redirect to login form")
 			g.It("Should create an access token")
@@ -191,6 +938,18 @@ var (
 		FullName: "test_name/repo_not_found",
 	}
 
+	fakeRepoArchived = &model.Repo{
+		Owner:    "test_name",
+		Name:     "repo_archived",
+		FullName: "test_name/repo_archived",
+	}
+
+	fakeRepoReadOnly = &model.Repo{
+		Owner:    "test_name",
+		Name:     "repo_read_only",
+		FullName: "test_name/repo_read_only",
+	}
+
 	fakeBuild = &model.Build{
 		Commit: "9ecad50",
 	}
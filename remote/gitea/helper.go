@@ -30,7 +30,7 @@ import (
 func toRepo(from *gitea.Repository, privateMode bool) *model.Repo {
 	name := strings.Split(from.FullName, "/")[1]
 	avatar := expandAvatar(
-		from.HTMLURL,
+		baseURL(from.HTMLURL),
 		from.Owner.AvatarURL,
 	)
 	private := from.Private
@@ -38,15 +38,17 @@ func toRepo(from *gitea.Repository, privateMode bool) *model.Repo {
 		private = true
 	}
 	return &model.Repo{
-		Kind:      model.RepoGit,
-		Name:      name,
-		Owner:     from.Owner.UserName,
-		FullName:  from.FullName,
-		Avatar:    avatar,
-		Link:      from.HTMLURL,
-		IsPrivate: private,
-		Clone:     from.CloneURL,
-		Branch:    "master",
+		Kind:        model.RepoGit,
+		Name:        name,
+		Owner:       from.Owner.UserName,
+		FullName:    from.FullName,
+		Avatar:      avatar,
+		Link:        from.HTMLURL,
+		IsPrivate:   private,
+		Clone:       from.CloneURL,
+		Branch:      "master",
+		Description: from.Description,
+		IsArchived:  from.Archived,
 	}
 }
 
@@ -59,6 +61,21 @@ func toPerm(from *gitea.Permission) *model.Perm {
 	}
 }
 
+// helper function that converts a Gitea collaborator permission level to a
+// Drone permission.
+func toCollaboratorPerm(level string) *model.Perm {
+	switch level {
+	case "owner", "admin":
+		return &model.Perm{Pull: true, Push: true, Admin: true}
+	case "write":
+		return &model.Perm{Pull: true, Push: true}
+	case "read":
+		return &model.Perm{Pull: true}
+	default:
+		return &model.Perm{}
+	}
+}
+
 // helper function that converts a Gitea team to a Drone team.
 func toTeam(from *gitea.Organization, link string) *model.Team {
 	return &model.Team{
@@ -67,10 +84,71 @@ func toTeam(from *gitea.Organization, link string) *model.Team {
 	}
 }
 
+// helper function that converts a Gitea team to a Woodpecker organization
+// team.
+func toOrgTeam(from *gitea.Team, org string, member bool) *model.OrgTeam {
+	return &model.OrgTeam{
+		ID:          from.ID,
+		Org:         org,
+		Name:        from.Name,
+		Description: from.Description,
+		Permission:  string(from.Permission),
+		Member:      member,
+	}
+}
+
+// helper function that converts a Gitea public key to a Woodpecker public
+// key.
+func toPublicKey(from *gitea.PublicKey) *model.PublicKey {
+	return &model.PublicKey{
+		ID:          from.ID,
+		Title:       from.Title,
+		Key:         from.Key,
+		KeyType:     from.KeyType,
+		Fingerprint: from.Fingerprint,
+		ReadOnly:    from.ReadOnly,
+		Created:     from.Created.UTC().Unix(),
+	}
+}
+
+// helper function that converts a Gitea commit to a Woodpecker commit.
+func toCommit(from *gitea.Commit) *model.Commit {
+	commit := &model.Commit{
+		Link: from.HTMLURL,
+	}
+	if from.CommitMeta != nil {
+		commit.SHA = from.SHA
+	}
+	if from.RepoCommit != nil {
+		commit.Message = from.RepoCommit.Message
+		if author := from.RepoCommit.Author; author != nil {
+			commit.Author = author.Name
+			commit.Email = author.Email
+			if created, err := time.Parse(time.RFC3339, author.Date); err == nil {
+				commit.Created = created.Unix()
+			}
+		}
+	}
+	return commit
+}
+
+// helper function that converts a Gitea release to a Woodpecker release.
+func toRelease(from *gitea.Release) *model.Release {
+	return &model.Release{
+		ID:         from.ID,
+		Tag:        from.TagName,
+		Name:       from.Title,
+		Body:       from.Note,
+		Draft:      from.IsDraft,
+		Prerelease: from.IsPrerelease,
+		Created:    from.CreatedAt.Unix(),
+	}
+}
+
 // helper function that extracts the Build data from a Gitea push hook
 func buildFromPush(hook *pushHook) *model.Build {
 	avatar := expandAvatar(
-		hook.Repo.URL,
+		baseURL(hook.Repo.URL),
 		fixMalformedAvatar(hook.Sender.Avatar),
 	)
 	author := hook.Sender.Login
@@ -117,10 +195,62 @@ func getChangedFilesFromPushHook(hook *pushHook) []string {
 	return files
 }
 
+// helper function that converts a Gitea branch to a manually triggered build.
+func buildFromBranch(from *gitea.Branch, sender *model.User) *model.Build {
+	return &model.Build{
+		Event:     model.EventManual,
+		Commit:    from.Commit.ID,
+		Ref:       "refs/heads/" + from.Name,
+		Link:      from.Commit.URL,
+		Branch:    from.Name,
+		Message:   from.Commit.Message,
+		Avatar:    sender.Avatar,
+		Author:    sender.Login,
+		Email:     sender.Email,
+		Timestamp: time.Now().UTC().Unix(),
+		Sender:    sender.Login,
+	}
+}
+
+// helper function that extracts the Build data from a Gitea wiki hook. The
+// hook carries no commit, the server builds the head of the branch.
+func buildFromWiki(hook *wikiHook) *model.Build {
+	avatar := expandAvatar(
+		baseURL(hook.Repo.URL),
+		fixMalformedAvatar(hook.Sender.Avatar),
+	)
+	author := hook.Sender.Login
+	if author == "" {
+		author = hook.Sender.Username
+	}
+	sender := hook.Sender.Username
+	if sender == "" {
+		sender = hook.Sender.Login
+	}
+	message := hook.Comment
+	if message == "" {
+		message = fmt.Sprintf("%s wiki page %s", hook.Action, hook.Page)
+	}
+
+	return &model.Build{
+		Event:     model.EventWiki,
+		Ref:       "refs/heads/" + hook.Repo.DefaultBranch,
+		Link:      fmt.Sprintf("%s/wiki/%s", hook.Repo.URL, url.PathEscape(hook.Page)),
+		Branch:    hook.Repo.DefaultBranch,
+		Title:     hook.Page,
+		Message:   message,
+		Avatar:    avatar,
+		Author:    author,
+		Email:     hook.Sender.Email,
+		Sender:    sender,
+		Timestamp: time.Now().UTC().Unix(),
+	}
+}
+
 // helper function that extracts the Build data from a Gitea tag hook
 func buildFromTag(hook *pushHook) *model.Build {
 	avatar := expandAvatar(
-		hook.Repo.URL,
+		baseURL(hook.Repo.URL),
 		fixMalformedAvatar(hook.Sender.Avatar),
 	)
 	author := hook.Sender.Login
@@ -149,7 +279,7 @@ func buildFromTag(hook *pushHook) *model.Build {
 // helper function that extracts the Build data from a Gitea pull_request hook
 func buildFromPullRequest(hook *pullRequestHook) *model.Build {
 	avatar := expandAvatar(
-		hook.Repo.URL,
+		baseURL(hook.Repo.URL),
 		fixMalformedAvatar(hook.PullRequest.User.Avatar),
 	)
 	sender := hook.Sender.Username
@@ -171,6 +301,7 @@ func buildFromPullRequest(hook *pullRequestHook) *model.Build {
 			hook.PullRequest.Head.Ref,
 			hook.PullRequest.Base.Ref,
 		),
+		FromFork: hook.PullRequest.Head.Repo.ID != hook.PullRequest.Base.Repo.ID,
 	}
 	return build
 }
@@ -185,6 +316,16 @@ func repoFromPush(hook *pushHook) *model.Repo {
 	}
 }
 
+// helper function that extracts the Repository data from a Gitea wiki hook
+func repoFromWiki(hook *wikiHook) *model.Repo {
+	return &model.Repo{
+		Name:     hook.Repo.Name,
+		Owner:    hook.Repo.Owner.Username,
+		FullName: hook.Repo.FullName,
+		Link:     hook.Repo.URL,
+	}
+}
+
 // helper function that extracts the Repository data from a Gitea pull_request hook
 func repoFromPullRequest(hook *pullRequestHook) *model.Repo {
 	return &model.Repo{
@@ -195,6 +336,13 @@ func repoFromPullRequest(hook *pullRequestHook) *model.Repo {
 	}
 }
 
+// helper function that parses a wiki hook from a read closer.
+func parseWiki(r io.Reader) (*wikiHook, error) {
+	wiki := new(wikiHook)
+	err := json.NewDecoder(r).Decode(wiki)
+	return wiki, err
+}
+
 // helper function that parses a push hook from a read closer.
 func parsePush(r io.Reader) (*pushHook, error) {
 	push := new(pushHook)
@@ -223,8 +371,10 @@ func fixMalformedAvatar(url string) string {
 }
 
 // expandAvatar is a helper function that converts a relative avatar URL to the
-// absolute url.
-func expandAvatar(repo, rawurl string) string {
+// absolute url. Root relative avatar URLs are resolved against the base URL
+// of the Gitea instance, including its base path if Gitea is not served from
+// the root of the host.
+func expandAvatar(base, rawurl string) string {
 	aurl, err := url.Parse(rawurl)
 	if err != nil {
 		return rawurl
@@ -235,11 +385,28 @@ func expandAvatar(repo, rawurl string) string {
 	}
 
 	// Resolve to base
-	burl, err := url.Parse(repo)
+	burl, err := url.Parse(base)
 	if err != nil {
 		return rawurl
 	}
+	prefix := strings.TrimSuffix(burl.Path, "/")
+	if aurl.Host == "" && strings.HasPrefix(aurl.Path, "/") && prefix != "" &&
+		aurl.Path != prefix && !strings.HasPrefix(aurl.Path, prefix+"/") {
+		aurl.Path = prefix + aurl.Path
+	}
 	aurl = burl.ResolveReference(aurl)
 
 	return aurl.String()
 }
+
+// baseURL returns the base URL of the Gitea instance from the link of one of
+// its repositories, i.e. the link without the trailing owner and name.
+func baseURL(link string) string {
+	link = strings.TrimSuffix(link, "/")
+	for i := 0; i < 2; i++ {
+		if index := strings.LastIndex(link, "/"); index != -1 {
+			link = link[:index]
+		}
+	}
+	return link
+}
@@ -16,6 +16,7 @@ package gitea
 
 import (
 	"bytes"
+	"fmt"
 	"testing"
 
 	"code.gitea.io/sdk/gitea"
@@ -145,7 +146,16 @@ func Test_parse(t *testing.T) {
 			g.Assert(build.Message).Equal(hook.PullRequest.Title)
 			g.Assert(build.Avatar).Equal("http://1.gravatar.com/avatar/8c58a0be77ee441bb8f8595b7f1b4e87")
 			g.Assert(build.Author).Equal(hook.PullRequest.User.Username)
+			g.Assert(build.FromFork).IsFalse()
+		})
 
+		g.It("Should return a Build struct from a pull_request hook of a fork", func() {
+			buf := bytes.NewBufferString(fixtures.HookPullRequest)
+			hook, _ := parsePullRequest(buf)
+			hook.PullRequest.Base.Repo.ID = 35129377
+			hook.PullRequest.Head.Repo.ID = 35129378
+			build := buildFromPullRequest(hook)
+			g.Assert(build.FromFork).IsTrue()
 		})
 
 		g.It("Should return a Repo struct from a pull_request hook", func() {
@@ -184,6 +194,89 @@ func Test_parse(t *testing.T) {
 			}
 		})
 
+		g.It("Should return the files changed by a diff", func() {
+			diff := `diff --git a/.drone.yml b/.drone.yml
+index 3b18e51..a4b2c3d 100644
+--- a/.drone.yml
++++ b/.drone.yml
+@@ -1 +1 @@
+-pipeline: {}
++pipeline: { build: { image: golang } }
+diff --git a/docs/old.md b/docs/new.md
+similarity index 100%
+rename from docs/old.md
+rename to docs/new.md
+diff --git a/logo.png b/logo.png
+new file mode 100644
+Binary files /dev/null and b/logo.png differ
+diff --git a/main.go b/main.go
+deleted file mode 100644
+--- a/main.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package main
+`
+			g.Assert(diffFiles([]byte(diff))).Equal([]string{".drone.yml", "docs/old.md", "docs/new.md", "logo.png", "main.go"})
+			g.Assert(len(diffFiles(nil))).Equal(0)
+		})
+
+		g.It("Should coalesce the statuses of a context", func() {
+			var statuses []gitea.CreateStatusOption
+			for i := 0; i < 10; i++ {
+				context := fmt.Sprintf("ci/pipeline-%d", i)
+				statuses = append(statuses,
+					gitea.CreateStatusOption{Context: context, State: gitea.StatusPending},
+					gitea.CreateStatusOption{Context: context, State: gitea.StatusSuccess},
+				)
+			}
+			coalesced := coalesceStatuses(statuses)
+			g.Assert(len(coalesced)).Equal(10)
+			g.Assert(coalesced[0].Context).Equal("ci/pipeline-0")
+			g.Assert(coalesced[9].Context).Equal("ci/pipeline-9")
+			g.Assert(coalesced[9].State).Equal(gitea.StatusSuccess)
+		})
+
+		g.It("Should report the build and each pipeline with its own context", func() {
+			build := &model.Build{Status: model.StatusFailure}
+			procs := []*model.Proc{
+				{Name: "build", State: model.StatusSuccess},
+				{Name: "test", State: model.StatusFailure},
+				{Name: "deploy", State: model.StatusSkipped},
+			}
+			statuses := batchStatuses(build, "http://gitea.io", "ci/woodpecker", true, procs)
+			g.Assert(len(statuses)).Equal(4)
+			g.Assert(statuses[0].Context).Equal("ci/woodpecker")
+			g.Assert(statuses[0].State).Equal(gitea.StatusFailure)
+			g.Assert(statuses[1].Context).Equal("ci/woodpecker/build")
+			g.Assert(statuses[1].State).Equal(gitea.StatusSuccess)
+			g.Assert(statuses[2].Context).Equal("ci/woodpecker/test")
+			g.Assert(statuses[2].State).Equal(gitea.StatusFailure)
+			g.Assert(statuses[3].State).Equal(gitea.StatusSuccess)
+			g.Assert(statuses[3].Description).Equal(DescSkipped)
+
+			statuses = batchStatuses(build, "http://gitea.io", "ci/woodpecker", false, []*model.Proc{nil})
+			g.Assert(len(statuses)).Equal(1)
+			g.Assert(statuses[0].Context).Equal("ci/woodpecker")
+		})
+
+		g.It("Should only require the status contexts of enabled status checks", func() {
+			from := &gitea.BranchProtection{
+				BranchName:          "main",
+				EnableStatusCheck:   false,
+				StatusCheckContexts: []string{"ci/woodpecker"},
+				RequiredApprovals:   1,
+			}
+			protection := toBranchProtection("main", from)
+			g.Assert(protection.Protected).IsTrue()
+			g.Assert(protection.RequiredApprovals).Equal(1)
+			g.Assert(protection.RequiresContext("ci/woodpecker")).IsFalse()
+
+			from.EnableStatusCheck = true
+			protection = toBranchProtection("main", from)
+			g.Assert(protection.RequiresContext("ci/woodpecker")).IsTrue()
+			g.Assert(protection.RequiresContext("ci/drone")).IsFalse()
+		})
+
 		g.It("Should return a Team struct from a Gitea Org", func() {
 			from := &gitea.Organization{
 				UserName:  "drone",
@@ -205,6 +298,7 @@ func Test_parse(t *testing.T) {
 				CloneURL: "http://gitea.golang.org/gophers/hello-world.git",
 				HTMLURL:  "http://gitea.golang.org/gophers/hello-world",
 				Private:  true,
+				Archived: true,
 			}
 			repo := toRepo(&from, false)
 			g.Assert(repo.FullName).Equal(from.FullName)
@@ -215,6 +309,7 @@ func Test_parse(t *testing.T) {
 			g.Assert(repo.Clone).Equal(from.CloneURL)
 			g.Assert(repo.Avatar).Equal(from.Owner.AvatarURL)
 			g.Assert(repo.IsPrivate).Equal(from.Private)
+			g.Assert(repo.IsArchived).Equal(from.Archived)
 		})
 
 		g.It("Should correct a malformed avatar url", func() {
@@ -268,7 +363,34 @@ func Test_parse(t *testing.T) {
 
 			var repo = "http://gitea.io/foo/bar"
 			for _, url := range urls {
-				got := expandAvatar(repo, url.Before)
+				got := expandAvatar(baseURL(repo), url.Before)
+				g.Assert(got).Equal(url.After)
+			}
+		})
+
+		g.It("Should expand the avatar url of a Gitea served under a base path", func() {
+			var urls = []struct {
+				Before string
+				After  string
+			}{
+				{
+					"/avatars/1",
+					"https://example.com/gitea/avatars/1",
+				},
+				{
+					"/gitea/avatars/2",
+					"https://example.com/gitea/avatars/2",
+				},
+				{
+					"https://example.com/gitea/avatars/3",
+					"https://example.com/gitea/avatars/3",
+				},
+			}
+
+			var repo = "https://example.com/gitea/foo/bar"
+			g.Assert(baseURL(repo)).Equal("https://example.com/gitea")
+			for _, url := range urls {
+				got := expandAvatar(baseURL(repo), url.Before)
 				g.Assert(got).Equal(url.After)
 			}
 		})
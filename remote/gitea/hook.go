@@ -0,0 +1,72 @@
+package gitea
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"code.gitea.io/sdk/gitea"
+	"github.com/woodpecker-ci/woodpecker/model"
+	"github.com/woodpecker-ci/woodpecker/remote"
+)
+
+// hookDelivery is a delivery of a repository webhook, as recorded by Gitea.
+type hookDelivery struct {
+	ID          int64     `json:"id"`
+	UUID        string    `json:"uuid"`
+	EventType   string    `json:"event_type"`
+	IsDelivered bool      `json:"is_delivered"`
+	IsSucceed   bool      `json:"is_succeed"`
+	Delivered   time.Time `json:"delivered"`
+	Response    *struct {
+		Status int `json:"status"`
+	} `json:"response"`
+}
+
+// listHookDeliveries returns the recent deliveries of the repository hook.
+// Gitea versions without the deliveries api answer with 404, like for a
+// missing hook, or with 501, so a 404 is only reported as unsupported if
+// the hook exists.
+func listHookDeliveries(client *gitea.Client, baseURL string, skipVerify bool, token string, r *model.Repo, hookID int64) ([]*model.HookDelivery, error) {
+	var from []*hookDelivery
+	err := getAPI(baseURL, skipVerify, token, fmt.Sprintf("/repos/%s/%s/hooks/%d/deliveries", r.Owner, r.Name, hookID), &from)
+	switch {
+	case isStatus(err, http.StatusNotImplemented):
+		return nil, remote.ErrHookDeliveriesUnsupported
+	case isStatus(err, http.StatusNotFound):
+		if _, _, herr := client.GetRepoHook(r.Owner, r.Name, hookID); herr == nil {
+			return nil, remote.ErrHookDeliveriesUnsupported
+		}
+		return nil, err
+	case err != nil:
+		return nil, err
+	}
+
+	deliveries := make([]*model.HookDelivery, 0, len(from))
+	for _, d := range from {
+		deliveries = append(deliveries, toHookDelivery(d))
+	}
+	return deliveries, nil
+}
+
+// toHookDelivery converts a Gitea hook delivery.
+func toHookDelivery(from *hookDelivery) *model.HookDelivery {
+	delivery := &model.HookDelivery{
+		ID:     from.ID,
+		GUID:   from.UUID,
+		Event:  from.EventType,
+		Status: model.StatusPending,
+	}
+	if !from.IsDelivered {
+		return delivery
+	}
+	delivery.Status = model.StatusFailure
+	if from.IsSucceed {
+		delivery.Status = model.StatusSuccess
+	}
+	if from.Response != nil {
+		delivery.StatusCode = from.Response.Status
+	}
+	delivery.Delivered = from.Delivered.Unix()
+	return delivery
+}
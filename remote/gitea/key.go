@@ -0,0 +1,30 @@
+package gitea
+
+import (
+	"code.gitea.io/sdk/gitea"
+	"github.com/woodpecker-ci/woodpecker/model"
+)
+
+// keyPageSize is the number of public keys requested per page.
+const keyPageSize = 50
+
+// helper function that returns the public keys of the user of the client.
+func listPublicKeys(client *gitea.Client) ([]*model.PublicKey, error) {
+	keys := []*model.PublicKey{}
+	for page := 1; ; page++ {
+		from, _, err := client.ListMyPublicKeys(gitea.ListPublicKeysOptions{
+			ListOptions: gitea.ListOptions{Page: page, PageSize: keyPageSize},
+		})
+		if err != nil {
+			return nil, err
+		}
+		// an empty page is returned after the last page
+		if len(from) == 0 {
+			break
+		}
+		for _, key := range from {
+			keys = append(keys, toPublicKey(key))
+		}
+	}
+	return keys, nil
+}
@@ -27,6 +27,7 @@ const (
 	hookPush        = "push"
 	hookCreated     = "create"
 	hookPullRequest = "pull_request"
+	hookWiki        = "wiki"
 
 	actionOpen = "opened"
 	actionSync = "synchronized"
@@ -47,6 +48,8 @@ func parseHook(r *http.Request) (*model.Repo, *model.Build, error) {
 		return parseCreatedHook(r.Body)
 	case hookPullRequest:
 		return parsePullRequestHook(r.Body)
+	case hookWiki:
+		return parseWikiHook(r.Body)
 	}
 	return nil, nil, nil
 }
@@ -115,3 +118,17 @@ func parsePullRequestHook(payload io.Reader) (*model.Repo, *model.Build, error)
 	build = buildFromPullRequest(pr)
 	return repo, build, err
 }
+
+// parseWikiHook parses a wiki hook and returns the Repo and Build details.
+// The wiki is a repository of its own, the build runs the head of the
+// default branch of the repository.
+func parseWikiHook(payload io.Reader) (*model.Repo, *model.Build, error) {
+	wiki, err := parseWiki(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	if wiki.Page == "" {
+		return nil, nil, nil
+	}
+	return repoFromWiki(wiki), buildFromWiki(wiki), nil
+}
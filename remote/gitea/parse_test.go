@@ -51,5 +51,24 @@ func Test_parser(t *testing.T) {
 				g.Assert(b.ChangedFiles).Equal([]string{"CHANGELOG.md", "app/controller/application.rb"})
 			})
 		})
+		g.Describe("given a wiki hook", func() {
+			g.It("should extract repository and build details", func() {
+				buf := bytes.NewBufferString(fixtures.HookWiki)
+				req, _ := http.NewRequest("POST", "/hook", buf)
+				req.Header = http.Header{}
+				req.Header.Set(hookEvent, hookWiki)
+				r, b, err := parseHook(req)
+				g.Assert(err == nil).IsTrue()
+				g.Assert(r.FullName).Equal("gordon/hello-world")
+				g.Assert(b.Event).Equal(model.EventWiki)
+				g.Assert(b.Ref).Equal("refs/heads/main")
+				g.Assert(b.Branch).Equal("main")
+				g.Assert(b.Commit).Equal("")
+				g.Assert(b.Title).Equal("Getting Started")
+				g.Assert(b.Message).Equal("Document the installation")
+				g.Assert(b.Link).Equal("http://gitea.golang.org/gordon/hello-world/wiki/Getting%20Started")
+				g.Assert(b.Sender).Equal("gordon")
+			})
+		})
 	})
 }
@@ -0,0 +1,36 @@
+package gitea
+
+import (
+	"net/http"
+
+	"code.gitea.io/sdk/gitea"
+	"github.com/woodpecker-ci/woodpecker/model"
+)
+
+// helper function that returns the protection settings of the branch.
+// Gitea answers with 404 for a branch without protection.
+func getBranchProtection(client *gitea.Client, owner, name, branch string) (*model.BranchProtection, error) {
+	protection, resp, err := client.GetBranchProtection(owner, name, branch)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return &model.BranchProtection{Branch: branch, RequiredContexts: []string{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toBranchProtection(branch, protection), nil
+}
+
+// toBranchProtection converts Gitea branch protection settings. The status
+// contexts are only required if status checks are enabled.
+func toBranchProtection(branch string, from *gitea.BranchProtection) *model.BranchProtection {
+	protection := &model.BranchProtection{
+		Branch:            branch,
+		Protected:         true,
+		RequiredContexts:  []string{},
+		RequiredApprovals: int(from.RequiredApprovals),
+	}
+	if from.EnableStatusCheck {
+		protection.RequiredContexts = append(protection.RequiredContexts, from.StatusCheckContexts...)
+	}
+	return protection
+}
@@ -0,0 +1,66 @@
+package gitea
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/woodpecker-ci/woodpecker/model"
+)
+
+// full length sha1 or sha256 commit hash.
+var shaRe = regexp.MustCompile(`^[0-9a-f]{40}([0-9a-f]{24})?$`)
+
+type branchRef struct {
+	Commit struct {
+		ID string `json:"id"`
+	} `json:"commit"`
+}
+
+type tagRef struct {
+	Commit struct {
+		SHA string `json:"sha"`
+	} `json:"commit"`
+}
+
+type commitRef struct {
+	SHA string `json:"sha"`
+}
+
+// resolveRef resolves a ref of the repository to a commit sha. Fully
+// qualified refs resolve to the named branch or tag and a full length sha
+// resolves to the commit. Other names are ambiguous and are resolved as a
+// branch first, then as a tag and last as an abbreviated commit sha.
+func resolveRef(baseURL string, skipVerify bool, token string, r *model.Repo, ref string) (string, error) {
+	resolveBranch := func(name string) (string, error) {
+		out := new(branchRef)
+		err := getAPI(baseURL, skipVerify, token, fmt.Sprintf("/repos/%s/%s/branches/%s", r.Owner, r.Name, url.PathEscape(name)), out)
+		return out.Commit.ID, err
+	}
+	resolveTag := func(name string) (string, error) {
+		out := new(tagRef)
+		err := getAPI(baseURL, skipVerify, token, fmt.Sprintf("/repos/%s/%s/tags/%s", r.Owner, r.Name, url.PathEscape(name)), out)
+		return out.Commit.SHA, err
+	}
+	resolveCommit := func(sha string) (string, error) {
+		out := new(commitRef)
+		err := getAPI(baseURL, skipVerify, token, fmt.Sprintf("/repos/%s/%s/git/commits/%s", r.Owner, r.Name, url.PathEscape(sha)), out)
+		return out.SHA, err
+	}
+
+	switch {
+	case strings.HasPrefix(ref, "refs/heads/"):
+		return resolveBranch(strings.TrimPrefix(ref, "refs/heads/"))
+	case strings.HasPrefix(ref, "refs/tags/"):
+		return resolveTag(strings.TrimPrefix(ref, "refs/tags/"))
+	case shaRe.MatchString(ref):
+		return resolveCommit(ref)
+	}
+	for _, resolve := range []func(string) (string, error){resolveBranch, resolveTag, resolveCommit} {
+		if sha, err := resolve(ref); err == nil && sha != "" {
+			return sha, nil
+		}
+	}
+	return "", fmt.Errorf("Cannot resolve %s in %s", ref, r.FullName)
+}
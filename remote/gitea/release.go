@@ -0,0 +1,88 @@
+package gitea
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"code.gitea.io/sdk/gitea"
+	"github.com/woodpecker-ci/woodpecker/model"
+	"github.com/woodpecker-ci/woodpecker/remote"
+)
+
+// defaultAssetSize is the maximum size of a release asset if the limit is
+// not set, the asset is held in memory while uploading.
+const defaultAssetSize = 100 << 20
+
+// createRelease returns the release of the tag, creating it if it does not
+// exist yet. Gitea requires a release title, which defaults to the tag.
+func createRelease(client *gitea.Client, r *model.Repo, in *remote.ReleaseInput) (*model.Release, error) {
+	existing, resp, err := client.GetReleaseByTag(r.Owner, r.Name, in.Tag)
+	if err == nil {
+		return toRelease(existing), nil
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return nil, err
+	}
+
+	title := in.Name
+	if title == "" {
+		title = in.Tag
+	}
+	created, _, err := client.CreateRelease(r.Owner, r.Name, gitea.CreateReleaseOption{
+		TagName:      in.Tag,
+		Target:       in.Target,
+		Title:        title,
+		Note:         in.Body,
+		IsDraft:      in.Draft,
+		IsPrerelease: in.Prerelease,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toRelease(created), nil
+}
+
+// uploadReleaseAsset attaches the named asset to the release. Assets larger
+// than limit bytes, or the default size if the limit is not set, are rejected
+// before uploading.
+func uploadReleaseAsset(client *gitea.Client, limit int64, r *model.Repo, rel *model.Release, name string, data io.Reader) error {
+	if limit <= 0 {
+		limit = defaultAssetSize
+	}
+	errLimit := errAssetTooLarge(name, limit)
+	_, resp, err := client.CreateReleaseAttachment(r.Owner, r.Name, rel.ID, &assetReader{
+		r:   io.LimitReader(data, limit+1),
+		n:   limit,
+		err: errLimit,
+	}, name)
+	if errors.Is(err, errLimit) {
+		return err
+	}
+	if resp != nil && resp.StatusCode == http.StatusRequestEntityTooLarge {
+		return fmt.Errorf("release asset %s exceeds the maximum size allowed by the gitea server", name)
+	}
+	return err
+}
+
+// assetReader reads an asset of at most n bytes, failing with err once more
+// is read.
+type assetReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (a *assetReader) Read(p []byte) (int, error) {
+	n, err := a.r.Read(p)
+	a.n -= int64(n)
+	if a.n < 0 {
+		return n, a.err
+	}
+	return n, err
+}
+
+func errAssetTooLarge(name string, limit int64) error {
+	return fmt.Errorf("release asset %s exceeds the maximum size of %d bytes", name, limit)
+}
@@ -0,0 +1,185 @@
+package gitea
+
+import (
+	"sync"
+
+	"code.gitea.io/sdk/gitea"
+	"github.com/woodpecker-ci/woodpecker/model"
+)
+
+// buildStatus returns the commit status and description of the build. With
+// skippedSuccess a build whose pipelines are all skipped is reported as
+// successful, as it never completes and would otherwise block protected
+// branches requiring the status.
+func buildStatus(b *model.Build, skippedSuccess bool) (gitea.StatusState, string) {
+	if skippedSuccess && allSkipped(b) {
+		return gitea.StatusSuccess, DescSkipped
+	}
+	return getStatus(b.Status), getDesc(b.Status)
+}
+
+// allSkipped returns true if the build has pipelines and all of them are
+// skipped.
+func allSkipped(b *model.Build) bool {
+	var pipelines int
+	for _, proc := range b.Procs {
+		if proc.PPID != 0 {
+			continue
+		}
+		if proc.State != model.StatusSkipped {
+			return false
+		}
+		pipelines++
+	}
+	return pipelines != 0
+}
+
+// toCombinedStatus converts the combined status of a commit. The overall
+// state is left empty for commits without statuses, for which Gitea reports
+// pending.
+func toCombinedStatus(from *gitea.CombinedStatus) *model.CombinedStatus {
+	status := &model.CombinedStatus{Statuses: []*model.CommitStatus{}}
+	for _, s := range from.Statuses {
+		status.Statuses = append(status.Statuses, &model.CommitStatus{
+			Context:     s.Context,
+			State:       toCommitState(s.State),
+			Description: s.Description,
+			TargetURL:   s.TargetURL,
+		})
+	}
+	if len(status.Statuses) != 0 {
+		status.State = toCommitState(from.State)
+	}
+	return status
+}
+
+// toCommitState converts a Gitea commit status state. Warnings are not
+// successful, so they fail the commit like failures.
+func toCommitState(state gitea.StatusState) string {
+	switch state {
+	case gitea.StatusSuccess:
+		return model.StatusSuccess
+	case gitea.StatusFailure, gitea.StatusWarning:
+		return model.StatusFailure
+	case gitea.StatusError:
+		return model.StatusError
+	default:
+		return model.StatusPending
+	}
+}
+
+// batchStatuses returns the commit statuses of the build and its pipelines.
+// The build is reported with the base context, and each pipeline with the
+// base context suffixed by the pipeline name, so protected branches requiring
+// the base context keep working. Nil procs only report the build.
+func batchStatuses(b *model.Build, link, context string, skippedSuccess bool, procs []*model.Proc) []gitea.CreateStatusOption {
+	state, desc := buildStatus(b, skippedSuccess)
+	statuses := []gitea.CreateStatusOption{{
+		State:       state,
+		TargetURL:   link,
+		Description: desc,
+		Context:     context,
+	}}
+	for _, proc := range procs {
+		if proc == nil {
+			continue
+		}
+		state, desc := getStatus(proc.State), getDesc(proc.State)
+		if skippedSuccess && proc.State == model.StatusSkipped {
+			state, desc = gitea.StatusSuccess, DescSkipped
+		}
+		statuses = append(statuses, gitea.CreateStatusOption{
+			State:       state,
+			TargetURL:   link,
+			Description: desc,
+			Context:     context + "/" + proc.Name,
+		})
+	}
+	return coalesceStatuses(statuses)
+}
+
+// coalesceStatuses returns the last status of each context, in the order the
+// contexts are first updated, so each context is posted once.
+func coalesceStatuses(statuses []gitea.CreateStatusOption) []gitea.CreateStatusOption {
+	index := map[string]int{}
+	var coalesced []gitea.CreateStatusOption
+	for _, status := range statuses {
+		if i, ok := index[status.Context]; ok {
+			coalesced[i] = status
+			continue
+		}
+		index[status.Context] = len(coalesced)
+		coalesced = append(coalesced, status)
+	}
+	return coalesced
+}
+
+// postStatus posts the commit status, unless the cache knows it did not
+// change since the last post.
+func postStatus(client *gitea.Client, cache *statusCache, r *model.Repo, commit string, status gitea.CreateStatusOption) error {
+	if cache.Seen(r.FullName, commit, status) {
+		return nil
+	}
+	if _, _, err := client.CreateStatus(r.Owner, r.Name, commit, status); err != nil {
+		return err
+	}
+	cache.Set(r.FullName, commit, status)
+	return nil
+}
+
+// statusCacheSize is the maximum number of commit/context pairs for which
+// the last posted status is remembered.
+const statusCacheSize = 1024
+
+// statusCache remembers the last commit status posted for a given
+// repository, commit and context, so that identical statuses are not
+// posted to Gitea twice in a row. A nil statusCache disables dedup.
+type statusCache struct {
+	sync.Mutex
+
+	entries map[string]gitea.CreateStatusOption
+	order   []string
+}
+
+func newStatusCache() *statusCache {
+	return &statusCache{
+		entries: make(map[string]gitea.CreateStatusOption),
+	}
+}
+
+// Seen returns true if the status equals the last status posted for
+// the repository, commit and status context.
+func (c *statusCache) Seen(repo, commit string, status gitea.CreateStatusOption) bool {
+	if c == nil {
+		return false
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	last, ok := c.entries[statusCacheKey(repo, commit, status.Context)]
+	return ok && last == status
+}
+
+// Set records the status as the last status posted for the repository,
+// commit and status context.
+func (c *statusCache) Set(repo, commit string, status gitea.CreateStatusOption) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	key := statusCacheKey(repo, commit, status.Context)
+	if _, ok := c.entries[key]; !ok {
+		if len(c.order) >= statusCacheSize {
+			delete(c.entries, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = status
+}
+
+func statusCacheKey(repo, commit, context string) string {
+	return repo + "@" + commit + "#" + context
+}
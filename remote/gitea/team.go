@@ -0,0 +1,47 @@
+package gitea
+
+import (
+	"code.gitea.io/sdk/gitea"
+	"github.com/woodpecker-ci/woodpecker/model"
+)
+
+// teamPageSize is the number of teams requested per page.
+const teamPageSize = 50
+
+// helper function that returns the teams of the organization, flagging the
+// teams the user of the client is a member of.
+func listOrgTeams(client *gitea.Client, org string) ([]*model.OrgTeam, error) {
+	member := map[int64]bool{}
+	for page := 1; ; page++ {
+		mine, _, err := client.ListMyTeams(&gitea.ListTeamsOptions{
+			ListOptions: gitea.ListOptions{Page: page, PageSize: teamPageSize},
+		})
+		if err != nil {
+			return nil, err
+		}
+		// an empty page is returned after the last page
+		if len(mine) == 0 {
+			break
+		}
+		for _, team := range mine {
+			member[team.ID] = true
+		}
+	}
+
+	var teams []*model.OrgTeam
+	for page := 1; ; page++ {
+		all, _, err := client.ListOrgTeams(org, gitea.ListTeamsOptions{
+			ListOptions: gitea.ListOptions{Page: page, PageSize: teamPageSize},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(all) == 0 {
+			break
+		}
+		for _, team := range all {
+			teams = append(teams, toOrgTeam(team, org, member[team.ID]))
+		}
+	}
+	return teams, nil
+}
@@ -0,0 +1,80 @@
+package gitea
+
+import (
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"code.gitea.io/sdk/gitea"
+)
+
+// treeEntry is a file found in a repository folder.
+type treeEntry struct {
+	Path string
+	Size int64
+}
+
+// treeSupport remembers whether the Gitea instance supports listing
+// repository trees recursively. Older and forked Gitea versions answer
+// with 404 or 501, in which case folders are listed using the contents
+// api instead.
+type treeSupport struct {
+	unsupported int32
+}
+
+// listDir returns the files in the repository folder dir at the given ref.
+func listDir(client *gitea.Client, trees *treeSupport, owner, name, ref, dir string) ([]treeEntry, error) {
+	dir = path.Clean(dir) // We clean path and remove trailing slash
+
+	if atomic.LoadInt32(&trees.unsupported) == 0 {
+		// List files in repository. Path from root
+		tree, resp, err := client.GetTrees(owner, name, ref, true)
+		if err == nil {
+			var entries []treeEntry
+			pattern := dir + "/" + "*" // construct pattern for match i.e. file in subdir
+			for _, e := range tree.Entries {
+				// Filter path matching pattern and type file (blob)
+				if m, _ := filepath.Match(pattern, e.Path); m && e.Type == "blob" {
+					entries = append(entries, treeEntry{Path: e.Path, Size: e.Size})
+				}
+			}
+			return entries, nil
+		}
+		if resp == nil || (resp.StatusCode != http.StatusNotFound && resp.StatusCode != http.StatusNotImplemented) {
+			return nil, err
+		}
+	}
+
+	contents, resp, err := client.ListContents(owner, name, ref, dir)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	// only remember the missing tree api once the contents api worked, so
+	// that a missing ref does not disable the tree api.
+	atomic.StoreInt32(&trees.unsupported, 1)
+
+	var entries []treeEntry
+	for _, e := range contents {
+		if e.Type == "file" {
+			entries = append(entries, treeEntry{Path: e.Path, Size: e.Size})
+		}
+	}
+	return entries, nil
+}
+
+// configEntries returns the yaml files of the folder entries, the only ones
+// read as pipeline configs.
+func configEntries(entries []treeEntry) []treeEntry {
+	var configs []treeEntry
+	for _, e := range entries {
+		if strings.HasSuffix(e.Path, ".yml") || strings.HasSuffix(e.Path, ".yaml") {
+			configs = append(configs, e)
+		}
+	}
+	return configs
+}
@@ -141,3 +141,44 @@ type pullRequestHook struct {
 		Avatar   string `json:"avatar_url"`
 	} `json:"sender"`
 }
+
+type wikiHook struct {
+	Action  string `json:"action"`
+	Page    string `json:"page"`
+	Comment string `json:"comment"`
+
+	Repo struct {
+		ID            int64  `json:"id"`
+		Name          string `json:"name"`
+		FullName      string `json:"full_name"`
+		URL           string `json:"html_url"`
+		Private       bool   `json:"private"`
+		DefaultBranch string `json:"default_branch"`
+		Owner         struct {
+			Name     string `json:"name"`
+			Email    string `json:"email"`
+			Username string `json:"username"`
+		} `json:"owner"`
+	} `json:"repository"`
+
+	Sender struct {
+		ID       int64  `json:"id"`
+		Login    string `json:"login"`
+		Username string `json:"username"`
+		Email    string `json:"email"`
+		Avatar   string `json:"avatar_url"`
+	} `json:"sender"`
+}
+
+type commitVerification struct {
+	Commit struct {
+		Verification struct {
+			Verified bool   `json:"verified"`
+			Reason   string `json:"reason"`
+		} `json:"verification"`
+	} `json:"commit"`
+}
+
+type collaboratorPermission struct {
+	Permission string `json:"permission"`
+}
@@ -278,6 +278,7 @@ func convertPullHook(from *webhook, merge bool) *model.Build {
 			from.PullRequest.Head.Ref,
 			from.PullRequest.Base.Ref,
 		),
+		FromFork: from.PullRequest.Head.Repo.CloneURL != from.Repo.CloneURL,
 	}
 	if merge {
 		build.Ref = fmt.Sprintf(mergeRefs, from.PullRequest.Number)
@@ -199,6 +199,7 @@ func Test_helper(t *testing.T) {
 			g.Assert(build.Ref).Equal("refs/pull/42/merge")
 			g.Assert(build.Refspec).Equal("changes:master")
 			g.Assert(build.Remote).Equal("https://github.com/octocat/hello-world-fork")
+			g.Assert(build.FromFork).IsTrue()
 			g.Assert(build.Commit).Equal(from.PullRequest.Head.SHA)
 			g.Assert(build.Message).Equal(from.PullRequest.Title)
 			g.Assert(build.Title).Equal(from.PullRequest.Title)
@@ -432,7 +432,7 @@ func (c *client) Status(u *model.User, r *model.Repo, b *model.Build, link strin
 	case "deployment":
 		return deploymentStatus(client, r, b, link)
 	default:
-		return repoStatus(client, r, b, link, c.Context, proc)
+		return repoStatus(client, r, b, link, r.StatusContextOr(c.Context), proc)
 	}
 }
 
@@ -17,6 +17,8 @@ package remote
 //go:generate mockery -name Remote -output mock -case=underscore
 
 import (
+	"errors"
+	"io"
 	"net/http"
 
 	"github.com/woodpecker-ci/woodpecker/model"
@@ -92,6 +94,174 @@ type Refresher interface {
 	Refresh(*model.User) (bool, error)
 }
 
+// CommitVerifier fetches the signature verification status of a commit. It
+// returns true if the remote system verified the commit signature.
+type CommitVerifier interface {
+	CommitVerified(u *model.User, r *model.Repo, b *model.Build) (bool, error)
+}
+
+// TopicLister fetches the topics of a repository. Topics are fetched
+// separately from the repository details to avoid an extra request per
+// repository when listing repositories.
+type TopicLister interface {
+	Topics(u *model.User, r *model.Repo) ([]string, error)
+}
+
+// Dispatch represents a manually triggered build and the build parameters
+// provided by the user.
+type Dispatch struct {
+	Build  *model.Build
+	Params map[string]string
+}
+
+// Dispatcher resolves a manually triggered build of a repository ref into a
+// build with the manual event. The user must have push access to the
+// repository.
+type Dispatcher interface {
+	Dispatch(u *model.User, r *model.Repo, ref string, inputs map[string]string) (*Dispatch, error)
+}
+
+// SenderPermer fetches the permissions a hook sender holds on a repository.
+// It is used to evaluate trigger policies for users other than the
+// repository owner.
+type SenderPermer interface {
+	SenderPerm(u *model.User, r *model.Repo, login string) (*model.Perm, error)
+}
+
+// RefResolver resolves a branch, tag or commit of a repository to a commit
+// sha, so builds of a ref can be created at a fixed commit.
+type RefResolver interface {
+	ResolveRef(u *model.User, r *model.Repo, ref string) (string, error)
+}
+
+// ReleaseLister fetches the releases of a repository, e.g. to generate
+// release notes. Releases are returned one page at a time, newest first.
+type ReleaseLister interface {
+	ListReleases(u *model.User, r *model.Repo, page int) ([]*model.Release, error)
+}
+
+// ReleaseInput defines a release to create.
+type ReleaseInput struct {
+	Tag        string
+	Target     string
+	Name       string
+	Body       string
+	Draft      bool
+	Prerelease bool
+}
+
+// ReleasePublisher creates releases and uploads release assets, so
+// pipelines can publish releases without a plugin. Creating a release for
+// an existing tag returns the existing release.
+type ReleasePublisher interface {
+	CreateRelease(u *model.User, r *model.Repo, in *ReleaseInput) (*model.Release, error)
+	UploadReleaseAsset(u *model.User, r *model.Repo, release *model.Release, name string, data io.Reader) error
+}
+
+// CommitLister fetches the commits of a branch or commit of a repository,
+// e.g. to offer building a specific commit. Commits are returned one page
+// at a time, newest first.
+type CommitLister interface {
+	ListCommits(u *model.User, r *model.Repo, ref string, page int) ([]*model.Commit, error)
+}
+
+// PublicKeyLister fetches the SSH public keys of the user, e.g. to validate
+// or select the key used to clone over SSH.
+type PublicKeyLister interface {
+	ListPublicKeys(u *model.User) ([]*model.PublicKey, error)
+}
+
+// CombinedStatusGetter fetches the statuses reported for a commit, e.g. to
+// wait for another CI system before building.
+type CombinedStatusGetter interface {
+	GetCombinedStatus(u *model.User, r *model.Repo, sha string) (*model.CombinedStatus, error)
+}
+
+// StatusBatcher sends the commit statuses of several pipelines of a build at
+// once, e.g. when the build is created, with as few requests as possible.
+// The statuses of the same context are coalesced, the last one is sent.
+type StatusBatcher interface {
+	StatusBatch(u *model.User, r *model.Repo, b *model.Build, link string, procs []*model.Proc) error
+}
+
+// OrgTeamLister fetches the teams within an organization and whether the
+// user is a member of them, e.g. to scope organization secrets to teams.
+type OrgTeamLister interface {
+	OrgTeams(u *model.User, org string) ([]*model.OrgTeam, error)
+}
+
+// ChangedFilesLister fetches the files changed by a pull request, for
+// remotes whose hooks do not carry them. Paths are relative to the
+// repository root.
+type ChangedFilesLister interface {
+	ChangedFiles(u *model.User, r *model.Repo, b *model.Build) ([]string, error)
+}
+
+// DiffFetcher fetches the unified diff between two commits of a
+// repository, so pipelines can inspect the changes and not only the
+// changed files.
+type DiffFetcher interface {
+	CommitDiff(u *model.User, r *model.Repo, base, head string) ([]byte, error)
+}
+
+// ErrHookDeliveriesUnsupported is returned by HookDeliveryLister if the
+// remote version does not record the hook deliveries.
+var ErrHookDeliveriesUnsupported = errors.New("hook deliveries are not supported by the remote")
+
+// HookDeliveryLister fetches the recent deliveries of a repository webhook,
+// newest first, e.g. to debug why a push did not trigger a build.
+type HookDeliveryLister interface {
+	ListHookDeliveries(u *model.User, r *model.Repo, hookID int64) ([]*model.HookDelivery, error)
+}
+
+// ErrFileNotFound is returned by File if the file does not exist at the
+// commit, as opposed to failing to read it.
+var ErrFileNotFound = errors.New("file not found")
+
+// ErrRepoNoAccess is returned by RepoChecker if the repository exists but
+// the user has no access to it.
+var ErrRepoNoAccess = errors.New("no access to the repository")
+
+// RepoChecker checks whether a repository still exists without fetching
+// its details, e.g. to prune repositories that were deleted or renamed. It
+// returns false without an error if the repository is gone, and
+// ErrRepoNoAccess if the user cannot access it. Remotes hiding private
+// repositories may report them as gone to users without access.
+type RepoChecker interface {
+	RepoExists(u *model.User, owner, name string) (bool, error)
+}
+
+// ConfigChecker checks whether a repository has the pipeline config at its
+// default branch, e.g. to show which repositories can be activated without
+// fetching their pipelines. The path is a file or, with a trailing slash, a
+// folder. The result may be cached for some minutes.
+type ConfigChecker interface {
+	HasConfig(u *model.User, r *model.Repo, path string) (bool, error)
+}
+
+// BranchProtectionGetter fetches the protection settings of a branch, e.g.
+// to warn if the status context of the builds is not required to merge. An
+// unprotected branch is returned with Protected set to false.
+type BranchProtectionGetter interface {
+	BranchProtection(u *model.User, r *model.Repo, branch string) (*model.BranchProtection, error)
+}
+
+// CodeOwnersPaths are the locations of the CODEOWNERS file of a repository,
+// in the order they are looked up.
+var CodeOwnersPaths = []string{
+	".gitea/CODEOWNERS",
+	".github/CODEOWNERS",
+	"CODEOWNERS",
+	"docs/CODEOWNERS",
+}
+
+// CodeOwnersFetcher fetches the CODEOWNERS file of a repository at the ref,
+// e.g. to let only the owners of the changed files approve a pull request.
+// It returns nil without an error if the repository has no CODEOWNERS file.
+type CodeOwnersFetcher interface {
+	CodeOwners(u *model.User, r *model.Repo, ref string) ([]byte, error)
+}
+
 // Login authenticates the session and returns the
 // remote user details.
 func Login(c context.Context, w http.ResponseWriter, r *http.Request) (*model.User, error) {
@@ -88,6 +88,8 @@ func Load(mux *httptreemux.ContextMux, middleware ...gin.HandlerFunc) http.Handl
 		repo.GET("/logs/:number/:pid", server.GetProcLogs)
 		repo.GET("/logs/:number/:pid/:proc", server.GetBuildLogs)
 
+		repo.GET("/diff", server.GetRepoDiff)
+
 		repo.GET("/files/:number", server.FileList)
 		repo.GET("/files/:number/:proc/*file", server.FileGet)
 
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"github.com/woodpecker-ci/woodpecker/model"
+)
+
+// approvalRequired returns true if the build is blocked until a maintainer
+// approves it. Builds of protected repositories need approval unless sent
+// by the repository owner. Pull requests of external contributors, senders
+// without push access, need approval if the repository is protected for
+// external contributors. The approving maintainer is recorded as the build
+// reviewer.
+func approvalRequired(repo *model.Repo, user *model.User, build *model.Build, perm *model.Perm) bool {
+	if build.Sender == user.Login {
+		return false
+	}
+	if repo.IsGated {
+		return true
+	}
+	if repo.IsGatedExternal && build.Event == model.EventPull {
+		return perm == nil || !perm.Push
+	}
+	return false
+}
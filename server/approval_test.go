@@ -0,0 +1,73 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/woodpecker-ci/woodpecker/model"
+)
+
+func TestApprovalRequired(t *testing.T) {
+	t.Parallel()
+
+	owner := &model.User{Login: "octocat"}
+
+	testTable := []struct {
+		name     string
+		repo     *model.Repo
+		build    *model.Build
+		perm     *model.Perm
+		expected bool
+	}{
+		{
+			name:     "Pull request of a non-member is blocked",
+			repo:     &model.Repo{IsGatedExternal: true},
+			build:    &model.Build{Event: model.EventPull, Sender: "stranger"},
+			perm:     &model.Perm{Pull: true},
+			expected: true,
+		},
+		{
+			name:     "Pull request of a sender without known permissions is blocked",
+			repo:     &model.Repo{IsGatedExternal: true},
+			build:    &model.Build{Event: model.EventPull, Sender: "stranger"},
+			expected: true,
+		},
+		{
+			name:     "Pull request of a member runs immediately",
+			repo:     &model.Repo{IsGatedExternal: true},
+			build:    &model.Build{Event: model.EventPull, Sender: "member"},
+			perm:     &model.Perm{Pull: true, Push: true},
+			expected: false,
+		},
+		{
+			name:     "Push of a non-member runs immediately",
+			repo:     &model.Repo{IsGatedExternal: true},
+			build:    &model.Build{Event: model.EventPush, Sender: "stranger"},
+			expected: false,
+		},
+		{
+			name:     "Pull request of the owner runs immediately",
+			repo:     &model.Repo{IsGated: true, IsGatedExternal: true},
+			build:    &model.Build{Event: model.EventPull, Sender: "octocat"},
+			expected: false,
+		},
+		{
+			name:     "Protected repository blocks members",
+			repo:     &model.Repo{IsGated: true},
+			build:    &model.Build{Event: model.EventPush, Sender: "member"},
+			perm:     &model.Perm{Pull: true, Push: true},
+			expected: true,
+		},
+		{
+			name:     "Unprotected repository runs pull requests of non-members",
+			repo:     &model.Repo{},
+			build:    &model.Build{Event: model.EventPull, Sender: "stranger"},
+			expected: false,
+		},
+	}
+
+	for _, tt := range testTable {
+		if blocked := approvalRequired(tt.repo, owner, tt.build, tt.perm); blocked != tt.expected {
+			t.Errorf("%s: want approval required %v, got %v", tt.name, tt.expected, blocked)
+		}
+	}
+}
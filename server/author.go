@@ -0,0 +1,43 @@
+package server
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/frontend/yaml"
+	"github.com/woodpecker-ci/woodpecker/model"
+	"github.com/woodpecker-ci/woodpecker/remote"
+)
+
+// authorIgnored returns true if the author matches one of the ignored
+// author patterns. Patterns are case-insensitive globs, e.g. renovate*.
+func authorIgnored(patterns []string, author string) bool {
+	if author == "" {
+		return false
+	}
+	author = strings.ToLower(author)
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(strings.ToLower(pattern), author); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// authorFiltered returns true if the build author is ignored and none of
+// the pipelines opts in to run for ignored authors.
+func authorFiltered(build *model.Build, remoteYamlConfigs []*remote.FileMeta) (bool, error) {
+	if !authorIgnored(Config.Pipeline.IgnoreAuthors, build.Author) {
+		return false, nil
+	}
+	for _, remoteYamlConfig := range remoteYamlConfigs {
+		parsedPipelineConfig, err := yaml.ParseString(string(remoteYamlConfig.Data))
+		if err != nil {
+			return false, err
+		}
+		if parsedPipelineConfig.AllowIgnoredAuthors {
+			return false, nil
+		}
+	}
+	return true, nil
+}
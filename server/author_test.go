@@ -0,0 +1,98 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/woodpecker-ci/woodpecker/model"
+	"github.com/woodpecker-ci/woodpecker/remote"
+)
+
+func TestAuthorIgnored(t *testing.T) {
+	t.Parallel()
+
+	patterns := []string{"renovate*", "dependabot[bot]"}
+
+	tests := []struct {
+		author  string
+		ignored bool
+	}{
+		{author: "renovate-bot", ignored: true},
+		{author: "Renovate", ignored: true},
+		{author: "octocat", ignored: false},
+		{author: "", ignored: false},
+	}
+
+	for _, test := range tests {
+		if got := authorIgnored(patterns, test.author); got != test.ignored {
+			t.Errorf("author %q: expected ignored %v, got %v", test.author, test.ignored, got)
+		}
+	}
+}
+
+func TestIgnoredAuthorPipelines(t *testing.T) {
+	Config.Pipeline.IgnoreAuthors = []string{"renovate*"}
+	defer func() { Config.Pipeline.IgnoreAuthors = nil }()
+
+	yamls := []*remote.FileMeta{
+		&remote.FileMeta{Name: "build", Data: []byte(`
+pipeline:
+  build:
+    image: scratch
+`)},
+		&remote.FileMeta{Name: "deps", Data: []byte(`
+allow_ignored_authors: true
+pipeline:
+  build:
+    image: scratch
+`)},
+	}
+
+	tests := []struct {
+		author   string
+		filtered bool
+		skipped  []bool
+	}{
+		{author: "renovate-bot", filtered: false, skipped: []bool{true, false}},
+		{author: "octocat", filtered: false, skipped: []bool{false, false}},
+	}
+
+	for _, test := range tests {
+		build := &model.Build{Event: model.EventPush, Author: test.author}
+
+		filtered, err := authorFiltered(build, yamls)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filtered != test.filtered {
+			t.Errorf("author %s: expected filtered %v, got %v", test.author, test.filtered, filtered)
+		}
+
+		b := procBuilder{
+			Repo:  &model.Repo{},
+			Curr:  build,
+			Last:  &model.Build{},
+			Netrc: &model.Netrc{},
+			Secs:  []*model.Secret{},
+			Regs:  []*model.Registry{},
+			Link:  "",
+			Yamls: yamls,
+		}
+		buildItems, err := b.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, item := range buildItems {
+			if skipped := item.Proc.State == model.StatusSkipped; skipped != test.skipped[i] {
+				t.Errorf("author %s: expected pipeline %s skipped %v, got %v", test.author, item.Proc.Name, test.skipped[i], skipped)
+			}
+		}
+	}
+
+	filtered, err := authorFiltered(&model.Build{Author: "renovate-bot"}, yamls[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filtered {
+		t.Error("expected the build to be filtered when no pipeline allows ignored authors")
+	}
+}
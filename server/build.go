@@ -256,6 +256,16 @@ func PostApproval(c *gin.Context) {
 		return
 	}
 
+	// pull requests of external contributors are approved by the code
+	// owners of the changed files, if the repository defines any.
+	if repo.IsGatedExternal && build.Event == model.EventPull {
+		owners := fetchCodeOwners(remote_, user, repo, build)
+		if reason := codeOwnersDenied(remote_, user, owners, build); reason != "" {
+			c.String(403, reason)
+			return
+		}
+	}
+
 	// fetch the build file from the database
 	configs, err := Config.Storage.Config.ConfigsForBuild(build.ID)
 	if err != nil {
@@ -264,6 +274,14 @@ func PostApproval(c *gin.Context) {
 		return
 	}
 
+	// the settings versioned in the repository apply as for the hook
+	settings, err := fetchRepoSettings(remote_, user, repo, build)
+	if err != nil {
+		c.String(400, err.Error())
+		return
+	}
+	repo = settings.Apply(repo)
+
 	netrc, err := remote_.Netrc(user, repo)
 	if err != nil {
 		c.String(500, "failed to generate netrc file. %s", err)
@@ -284,18 +302,11 @@ func PostApproval(c *gin.Context) {
 	if err != nil {
 		logrus.Debugf("Error getting secrets for %s#%d. %s", repo.FullName, build.Number, err)
 	}
+	secs = forkSecrets(repo, build, repo.Config, secs)
 	regs, err := Config.Services.Registries.RegistryList(repo)
 	if err != nil {
 		logrus.Debugf("Error getting registry credentials for %s#%d. %s", repo.FullName, build.Number, err)
 	}
-	envs := map[string]string{}
-	if Config.Services.Environ != nil {
-		globals, _ := Config.Services.Environ.EnvironList(repo)
-		for _, global := range globals {
-			envs[global.Name] = global.Value
-		}
-	}
-
 	var yamls []*remote.FileMeta
 	for _, y := range configs {
 		yamls = append(yamls, &remote.FileMeta{Data: []byte(y.Data), Name: y.Name})
@@ -310,7 +321,10 @@ func PostApproval(c *gin.Context) {
 		Regs:  regs,
 		Link:  Config.Server.Host,
 		Yamls: yamls,
-		Envs:  envs,
+		Envs:  globalEnvirons(repo),
+
+		CommitVerified: commitVerified(remote_, user, repo, build),
+		File:           remoteFile(remote_, user, repo, build),
 	}
 	buildItems, err := b.Build()
 	if err != nil {
@@ -326,19 +340,7 @@ func PostApproval(c *gin.Context) {
 		logrus.Errorf("error persisting procs %s/%d: %s", repo.FullName, build.Number, err)
 	}
 
-	defer func() {
-		for _, item := range buildItems {
-			uri := fmt.Sprintf("%s/%s/%d", Config.Server.Host, repo.FullName, build.Number)
-			if len(buildItems) > 1 {
-				err = remote_.Status(user, repo, build, uri, item.Proc)
-			} else {
-				err = remote_.Status(user, repo, build, uri, nil)
-			}
-			if err != nil {
-				logrus.Errorf("error setting commit status for %s/%d: %v", repo.FullName, build.Number, err)
-			}
-		}
-	}()
+	defer sendStatuses(remote_, user, repo, build, buildItems)
 
 	publishToTopic(c, build, repo, model.Enqueued)
 	queueBuild(build, repo, buildItems)
@@ -437,6 +439,14 @@ func PostBuild(c *gin.Context) {
 		return
 	}
 
+	// the settings versioned in the repository apply as for the hook
+	settings, err := fetchRepoSettings(remote_, user, repo, build)
+	if err != nil {
+		c.String(400, err.Error())
+		return
+	}
+	repo = settings.Apply(repo)
+
 	netrc, err := remote_.Netrc(user, repo)
 	if err != nil {
 		logrus.Errorf("failure to generate netrc for %s. %s", repo.FullName, err)
@@ -444,6 +454,18 @@ func PostBuild(c *gin.Context) {
 		return
 	}
 
+	// when only rerunning the failed pipelines, the pipelines that
+	// succeeded in the previous build are not built again.
+	var prev []*model.Proc
+	if failed, _ := strconv.ParseBool(c.Query("failed")); failed {
+		prev, err = store.FromContext(c).ProcList(build)
+		if err != nil {
+			logrus.Errorf("failure to get procs of %s#%d. %s", repo.FullName, num, err)
+			c.AbortWithError(500, err)
+			return
+		}
+	}
+
 	build.ID = 0
 	build.Number = 0
 	build.Parent = num
@@ -475,11 +497,12 @@ func PostBuild(c *gin.Context) {
 		return
 	}
 
-	// Read query string parameters into buildParams, exclude reserved params
+	// Read query string parameters into buildParams, exclude reserved params.
+	// Build parameters take precedence over the server-wide environment.
 	var buildParams = map[string]string{}
 	for key, val := range c.Request.URL.Query() {
 		switch key {
-		case "fork", "event", "deploy_to":
+		case "fork", "event", "deploy_to", "failed":
 		default:
 			// We only accept string literals, because build parameters will be
 			// injected as environment variables
@@ -494,17 +517,11 @@ func PostBuild(c *gin.Context) {
 	if err != nil {
 		logrus.Debugf("Error getting secrets for %s#%d. %s", repo.FullName, build.Number, err)
 	}
+	secs = forkSecrets(repo, build, repo.Config, secs)
 	regs, err := Config.Services.Registries.RegistryList(repo)
 	if err != nil {
 		logrus.Debugf("Error getting registry credentials for %s#%d. %s", repo.FullName, build.Number, err)
 	}
-	if Config.Services.Environ != nil {
-		globals, _ := Config.Services.Environ.EnvironList(repo)
-		for _, global := range globals {
-			buildParams[global.Name] = global.Value
-		}
-	}
-
 	var yamls []*remote.FileMeta
 	for _, y := range configs {
 		yamls = append(yamls, &remote.FileMeta{Data: []byte(y.Data), Name: y.Name})
@@ -519,9 +536,17 @@ func PostBuild(c *gin.Context) {
 		Regs:  regs,
 		Link:  Config.Server.Host,
 		Yamls: yamls,
-		Envs:  buildParams,
+		Envs:  globalEnvirons(repo),
+
+		Params:         buildParams,
+		CommitVerified: commitVerified(remote_, user, repo, build),
+		Prev:           prev,
+		File:           remoteFile(remote_, user, repo, build),
 	}
 	buildItems, err := b.Build()
+	if err == nil && prev != nil && len(buildItems) == 0 {
+		err = fmt.Errorf("No failed pipelines to rerun")
+	}
 	if err != nil {
 		build.Status = model.StatusError
 		build.Started = time.Now().Unix()
@@ -608,3 +633,33 @@ var deleteStr = `[
 	  "out": "logs purged by %s on %s\n"
 	}
 ]`
+
+// sendStatuses sends the commit statuses of the pipelines of a new build, a
+// status per pipeline if there are several. Remotes supporting it get them
+// in a single batch.
+func sendStatuses(remote_ remote.Remote, user *model.User, repo *model.Repo, build *model.Build, items []*buildItem) {
+	if len(items) == 0 {
+		return
+	}
+	uri := fmt.Sprintf("%s/%s/%d", Config.Server.Host, repo.FullName, build.Number)
+
+	procs := []*model.Proc{nil}
+	if len(items) > 1 {
+		procs = procs[:0]
+		for _, item := range items {
+			procs = append(procs, item.Proc)
+		}
+	}
+
+	if batcher, ok := remote_.(remote.StatusBatcher); ok {
+		if err := batcher.StatusBatch(user, repo, build, uri, procs); err != nil {
+			logrus.Errorf("error setting commit status for %s/%d: %v", repo.FullName, build.Number, err)
+		}
+		return
+	}
+	for _, proc := range procs {
+		if err := remote_.Status(user, repo, build, uri, proc); err != nil {
+			logrus.Errorf("error setting commit status for %s/%d: %v", repo.FullName, build.Number, err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/woodpecker-ci/woodpecker/model"
+	"github.com/woodpecker-ci/woodpecker/remote/mocks"
+)
+
+type batchingRemote struct {
+	*mocks.Remote
+	batches [][]*model.Proc
+}
+
+func (r *batchingRemote) StatusBatch(u *model.User, repo *model.Repo, b *model.Build, link string, procs []*model.Proc) error {
+	r.batches = append(r.batches, procs)
+	return nil
+}
+
+func TestSendStatuses(t *testing.T) {
+	t.Parallel()
+
+	var items []*buildItem
+	for i := 1; i <= 10; i++ {
+		items = append(items, &buildItem{Proc: &model.Proc{PID: i}})
+	}
+	user, repo, build := &model.User{}, &model.Repo{FullName: "octocat/hello-world"}, &model.Build{Number: 1}
+
+	r := new(mocks.Remote)
+	r.On("Status", user, repo, build, mock.Anything, mock.Anything).Return(nil)
+	sendStatuses(r, user, repo, build, items)
+	r.AssertNumberOfCalls(t, "Status", 10)
+
+	batcher := &batchingRemote{Remote: new(mocks.Remote)}
+	sendStatuses(batcher, user, repo, build, items)
+	if len(batcher.batches) != 1 || len(batcher.batches[0]) != 10 {
+		t.Errorf("Want the statuses of the pipelines sent in a single batch, got %v", batcher.batches)
+	}
+	batcher.AssertNotCalled(t, "Status")
+
+	batcher.batches = nil
+	sendStatuses(batcher, user, repo, build, items[:1])
+	if len(batcher.batches) != 1 || len(batcher.batches[0]) != 1 || batcher.batches[0][0] != nil {
+		t.Errorf("Want the status of the build for a single pipeline, got %v", batcher.batches)
+	}
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/woodpecker-ci/woodpecker/model"
+	"github.com/woodpecker-ci/woodpecker/remote"
+)
+
+// codeOwners are the rules of a CODEOWNERS file, in file order.
+type codeOwners []codeOwnersRule
+
+type codeOwnersRule struct {
+	pattern *regexp.Regexp
+	owners  []string
+}
+
+// parseCodeOwners parses a CODEOWNERS file. Lines with an invalid pattern
+// are ignored.
+func parseCodeOwners(data []byte) codeOwners {
+	var rules codeOwners
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i != -1 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		pattern, err := codeOwnersPattern(fields[0])
+		if err != nil {
+			continue
+		}
+		rules = append(rules, codeOwnersRule{pattern: pattern, owners: fields[1:]})
+	}
+	return rules
+}
+
+// codeOwnersPattern converts a gitignore style pattern to a regular
+// expression. Patterns without a slash match at any depth, and patterns
+// matching a directory match all the files below it.
+func codeOwnersPattern(pattern string) (*regexp.Regexp, error) {
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	pattern = strings.Trim(pattern, "/")
+
+	var expr strings.Builder
+	if anchored {
+		expr.WriteString("^")
+	} else {
+		expr.WriteString("^(.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			expr.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			expr.WriteString("[^/]*")
+		case pattern[i] == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	expr.WriteString("(/.*)?$")
+	return regexp.Compile(expr.String())
+}
+
+// Owners returns the owners of the file. The last matching rule wins, a
+// rule without owners leaves the file unowned.
+func (o codeOwners) Owners(file string) []string {
+	file = strings.TrimPrefix(file, "/")
+	for i := len(o) - 1; i >= 0; i-- {
+		if o[i].pattern.MatchString(file) {
+			return o[i].owners
+		}
+	}
+	return nil
+}
+
+// Approvers returns the owners of any of the files, sorted.
+func (o codeOwners) Approvers(files []string) []string {
+	set := map[string]bool{}
+	for _, file := range files {
+		for _, owner := range o.Owners(file) {
+			set[owner] = true
+		}
+	}
+	approvers := make([]string, 0, len(set))
+	for owner := range set {
+		approvers = append(approvers, owner)
+	}
+	sort.Strings(approvers)
+	return approvers
+}
+
+// fetchCodeOwners fetches the CODEOWNERS file of the branch of the build,
+// which is the target branch of pull requests, so a pull request cannot
+// change its own approvers. It returns nil if the repository has no
+// CODEOWNERS file or it cannot be fetched.
+func fetchCodeOwners(remote_ remote.Remote, user *model.User, repo *model.Repo, build *model.Build) codeOwners {
+	if fetcher, ok := remote_.(remote.CodeOwnersFetcher); ok {
+		data, err := fetcher.CodeOwners(user, repo, build.Branch)
+		if err != nil {
+			logrus.Debugf("cannot fetch the CODEOWNERS file of %s. %s", repo.FullName, err)
+			return nil
+		}
+		return parseCodeOwners(data)
+	}
+
+	// remotes without support look the file up at the usual locations.
+	base := *build
+	base.Commit = build.Branch
+	for _, path := range remote.CodeOwnersPaths {
+		data, err := remote_.File(user, repo, &base, path)
+		if err == nil {
+			return parseCodeOwners(data)
+		}
+	}
+	return nil
+}
+
+// codeOwnersDenied returns why the user cannot approve the build, or an
+// empty string if the user can. Without the changed files the approvers are
+// unknown, so nobody can approve the build of a repository with owners.
+func codeOwnersDenied(remote_ remote.Remote, user *model.User, owners codeOwners, build *model.Build) string {
+	if len(owners) == 0 {
+		return ""
+	}
+	if len(build.ChangedFiles) == 0 {
+		return "the changed files of the build are unknown, the code owners cannot approve it"
+	}
+	approvers := owners.Approvers(build.ChangedFiles)
+	if len(approvers) != 0 && !isCodeOwner(remote_, user, approvers) {
+		return "only the code owners of the changed files can approve the build: " + strings.Join(approvers, ", ")
+	}
+	return ""
+}
+
+// isCodeOwner returns true if the user is one of the owners, either by
+// login, email or membership of an owning @org/team team.
+func isCodeOwner(remote_ remote.Remote, user *model.User, owners []string) bool {
+	for _, owner := range owners {
+		switch {
+		case strings.EqualFold(owner, "@"+user.Login):
+			return true
+		case user.Email != "" && strings.EqualFold(owner, user.Email):
+			return true
+		case strings.HasPrefix(owner, "@") && strings.Contains(owner, "/"):
+			if isTeamMember(remote_, user, strings.TrimPrefix(owner, "@")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isTeamMember(remote_ remote.Remote, user *model.User, team string) bool {
+	lister, ok := remote_.(remote.OrgTeamLister)
+	if !ok {
+		return false
+	}
+	parts := strings.SplitN(team, "/", 2)
+	teams, err := lister.OrgTeams(user, parts[0])
+	if err != nil {
+		logrus.Debugf("cannot list the teams of %s. %s", parts[0], err)
+		return false
+	}
+	for _, t := range teams {
+		if strings.EqualFold(t.Name, parts[1]) && t.Member {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/woodpecker-ci/woodpecker/model"
+	"github.com/woodpecker-ci/woodpecker/remote/mocks"
+)
+
+func TestCodeOwners(t *testing.T) {
+	t.Parallel()
+
+	owners := parseCodeOwners([]byte(`
+# default owners
+*       @octocat
+*.go    @gopher @org/backend
+/docs/  @org/docs # documentation
+apps/   @spaceghost
+**/vendor/**
+/build/logs/ @logger
+`))
+
+	testTable := []struct {
+		file   string
+		owners []string
+	}{
+		{file: "README.md", owners: []string{"@octocat"}},
+		{file: "server/build.go", owners: []string{"@gopher", "@org/backend"}},
+		{file: "docs/index.md", owners: []string{"@org/docs"}},
+		{file: "web/docs/index.md", owners: []string{"@octocat"}},
+		{file: "web/apps/main.js", owners: []string{"@spaceghost"}},
+		{file: "vendor/github.com/pkg/errors/errors.go", owners: nil},
+		{file: "build/logs/debug.log", owners: []string{"@logger"}},
+	}
+	for _, tt := range testTable {
+		got := owners.Owners(tt.file)
+		if len(got) == 0 && len(tt.owners) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.owners) {
+			t.Errorf("%s: want owners %v, got %v", tt.file, tt.owners, got)
+		}
+	}
+
+	approvers := owners.Approvers([]string{"server/build.go", "docs/index.md", "docs/usage.md"})
+	if want := []string{"@gopher", "@org/backend", "@org/docs"}; !reflect.DeepEqual(approvers, want) {
+		t.Errorf("Want approvers %v, got %v", want, approvers)
+	}
+}
+
+func TestFetchCodeOwnersMissing(t *testing.T) {
+	t.Parallel()
+
+	r := new(mocks.Remote)
+	r.On("File", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("file not found"))
+
+	build := &model.Build{Branch: "main", Commit: "d670460b4b4aece5915caf5c68d12f560a9fe3e4", ChangedFiles: []string{"server/build.go"}}
+	owners := fetchCodeOwners(r, &model.User{}, &model.Repo{}, build)
+	if approvers := owners.Approvers(build.ChangedFiles); len(approvers) != 0 {
+		t.Errorf("Want no approvers without a CODEOWNERS file, got %v", approvers)
+	}
+	for _, call := range r.Calls {
+		if ref := call.Arguments.Get(2).(*model.Build).Commit; ref != "main" {
+			t.Errorf("Want the CODEOWNERS file fetched from the target branch, got %s", ref)
+		}
+	}
+	r.AssertNumberOfCalls(t, "File", 4)
+}
+
+func TestIsCodeOwner(t *testing.T) {
+	t.Parallel()
+
+	owners := []string{"@gopher", "octocat@github.com", "@org/backend"}
+	if !isCodeOwner(new(mocks.Remote), &model.User{Login: "Gopher"}, owners) {
+		t.Errorf("Want owner matched by login")
+	}
+	if !isCodeOwner(new(mocks.Remote), &model.User{Login: "octocat", Email: "octocat@github.com"}, owners) {
+		t.Errorf("Want owner matched by email")
+	}
+	if isCodeOwner(new(mocks.Remote), &model.User{Login: "spaceghost"}, owners) {
+		t.Errorf("Want no owner without a team lister")
+	}
+}
+
+func TestCodeOwnersDenied(t *testing.T) {
+	t.Parallel()
+
+	owners := parseCodeOwners([]byte("*.go @gopher\n"))
+	gopher, octocat := &model.User{Login: "gopher"}, &model.User{Login: "octocat"}
+
+	build := &model.Build{ChangedFiles: []string{"main.go"}}
+	if reason := codeOwnersDenied(new(mocks.Remote), gopher, owners, build); reason != "" {
+		t.Errorf("Want the code owner allowed to approve, got %q", reason)
+	}
+	if reason := codeOwnersDenied(new(mocks.Remote), octocat, owners, build); reason == "" {
+		t.Errorf("Want other users denied approving")
+	}
+	if reason := codeOwnersDenied(new(mocks.Remote), octocat, nil, build); reason != "" {
+		t.Errorf("Want any user allowed to approve without code owners, got %q", reason)
+	}
+
+	unknown := &model.Build{}
+	if reason := codeOwnersDenied(new(mocks.Remote), gopher, owners, unknown); reason == "" {
+		t.Errorf("Want the approval denied if the changed files are unknown")
+	}
+	if reason := codeOwnersDenied(new(mocks.Remote), gopher, nil, unknown); reason != "" {
+		t.Errorf("Want any user allowed to approve without code owners, got %q", reason)
+	}
+}
@@ -1,7 +1,13 @@
 package server
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/woodpecker-ci/woodpecker/model"
@@ -13,6 +19,11 @@ type configFetcher struct {
 	user    *model.User
 	repo    *model.Repo
 	build   *model.Build
+
+	// paths are the config paths tried in order, the resolved template
+	// and its default. path is the one the configuration was read from.
+	paths []string
+	path  string
 }
 
 func NewConfigFetcher(remote remote.Remote, user *model.User, repo *model.Repo, build *model.Build) *configFetcher {
@@ -25,53 +36,248 @@ func NewConfigFetcher(remote remote.Remote, user *model.User, repo *model.Repo,
 }
 
 func (cf *configFetcher) Fetch() (files []*remote.FileMeta, err error) {
-	var file []byte
-
+	cf.paths, err = configPaths(cf.repo.Config, cf.repo, cf.build)
+	if err != nil {
+		return nil, err
+	}
+	if cf.repo.ConfigRepo != "" {
+		return cf.fetchExternal()
+	}
 	for i := 0; i < 5; i++ {
 		select {
 		case <-time.After(time.Second * time.Duration(i)):
+			files, err = cf.fetch(cf.configBuild())
+			if err == nil {
+				return files, nil
+			}
 
-			// either a file
-			if !strings.HasSuffix(cf.repo.Config, "/") {
-				file, err = cf.remote_.File(cf.user, cf.repo, cf.build, cf.repo.Config)
+			// or the configuration of the default branch, if the branch
+			// has none rather than failing to read it.
+			if cf.repo.BranchFallback && cf.repo.Branch != "" && cf.build.Branch != cf.repo.Branch && configNotFound(err) {
+				build := *cf.build
+				build.Commit = cf.repo.Branch
+				files, err = cf.fetch(&build)
 				if err == nil {
-					return []*remote.FileMeta{{
-						Name: cf.repo.Config,
-						Data: file,
-					}}, nil
+					return files, nil
 				}
 			}
 
-			// or a folder
-			if strings.HasSuffix(cf.repo.Config, "/") {
-				files, err = cf.remote_.Dir(cf.user, cf.repo, cf.build, strings.TrimSuffix(cf.repo.Config, "/"))
-				if err == nil {
-					return filterPipelineFiles(files), nil
-				}
+			return nil, err
+		}
+	}
+
+	return []*remote.FileMeta{}, nil
+}
+
+// configNotFound returns true if the error confirms that the configuration
+// does not exist. Remotes not returning remote.ErrFileNotFound are matched
+// by the not found status in the error.
+func configNotFound(err error) bool {
+	if errors.Is(err, remote.ErrFileNotFound) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "404") || strings.Contains(msg, "not found")
+}
+
+// ConfigPath returns the config path the configuration was read from, which
+// is resolved for the build if the config path of the repository is a
+// template.
+func (cf *configFetcher) ConfigPath() string {
+	if cf.path == "" {
+		return cf.repo.Config
+	}
+	return cf.path
+}
+
+// configBuild returns the build to read the pipeline configuration from. If
+// the build sets a config ref the configuration is read at that ref, while
+// the build itself still clones the build commit.
+func (cf *configFetcher) configBuild() *model.Build {
+	if cf.build.ConfigRef == "" || cf.build.ConfigRef == cf.build.Commit {
+		return cf.build
+	}
+	build := *cf.build
+	build.Commit = cf.build.ConfigRef
+	return &build
+}
+
+// fetch returns the pipeline configuration at the build commit.
+func (cf *configFetcher) fetch(build *model.Build) (files []*remote.FileMeta, err error) {
+	return cf.fetchFrom(cf.repo, build, "")
+}
+
+// fetchExternal returns the pipeline configuration from the external config
+// repository of the repository. The configuration is read at the pinned ref
+// from the folder named after the building repository, e.g. the
+// configuration of octocat/hello-world is read from
+// octocat/hello-world/.drone.yml.
+func (cf *configFetcher) fetchExternal() ([]*remote.FileMeta, error) {
+	owner, name, ref, err := parseConfigRepo(cf.repo.ConfigRepo)
+	if err != nil {
+		return nil, err
+	}
+	fullName := owner + "/" + name
+	if !configRepoAllowed(fullName) {
+		return nil, fmt.Errorf("Config repository %s is not allowed", fullName)
+	}
+	repo := &model.Repo{
+		Owner:    owner,
+		Name:     name,
+		FullName: fullName,
+	}
+	return cf.fetchFrom(repo, &model.Build{Commit: ref}, cf.repo.FullName+"/")
+}
+
+// fetchFrom returns the pipeline configuration of the repository, read from
+// the repository at the build commit below the path prefix. The names of the
+// returned files are relative to the prefix.
+func (cf *configFetcher) fetchFrom(repo *model.Repo, build *model.Build, prefix string) (files []*remote.FileMeta, err error) {
+	var file []byte
+
+	for _, config := range cf.paths {
+		// either a file
+		if !strings.HasSuffix(config, "/") {
+			file, err = cf.remote_.File(cf.user, repo, build, prefix+config)
+			if err == nil {
+				cf.path = config
+				return []*remote.FileMeta{{
+					Name: config,
+					Data: file,
+				}}, nil
 			}
+		}
 
-			// or fallback
-			if cf.repo.Fallback {
-				file, err = cf.remote_.File(cf.user, cf.repo, cf.build, ".drone.yml")
-				if err == nil {
-					return []*remote.FileMeta{{
-						Name: ".drone.yml",
-						Data: file,
-					}}, nil
+		// or a folder
+		if strings.HasSuffix(config, "/") {
+			files, err = cf.remote_.Dir(cf.user, repo, build, strings.TrimSuffix(prefix+config, "/"))
+			if err == nil {
+				for _, file := range files {
+					file.Name = strings.TrimPrefix(file.Name, prefix)
 				}
+				cf.path = config
+				return filterPipelineFiles(files), nil
 			}
+		}
+	}
 
-			return nil, err
+	// or fallback
+	if cf.repo.Fallback {
+		file, err = cf.remote_.File(cf.user, repo, build, prefix+fallbackConfigPath)
+		if err == nil {
+			return []*remote.FileMeta{{
+				Name: fallbackConfigPath,
+				Data: file,
+			}}, nil
 		}
 	}
 
-	return []*remote.FileMeta{}, nil
+	return nil, err
+}
+
+// fallbackConfigPath is the config file read if the repository falls back
+// to it when its configured config path does not exist.
+const fallbackConfigPath = ".drone.yml"
+
+// configPathData is the metadata a templated config path is resolved
+// against, e.g. .woodpecker/{{.Branch}}/.
+type configPathData struct {
+	Branch string
+	Event  string
+	Owner  string
+	Name   string
+}
+
+// configPaths returns the config paths to read the configuration from. A
+// templated config path is resolved against the build, with the path before
+// the template as the default if the resolved path does not exist, e.g.
+// .woodpecker/{{.Branch}}/ defaults to .woodpecker/. The server default is
+// used if the template has no path before it.
+func configPaths(config string, repo *model.Repo, build *model.Build) ([]string, error) {
+	i := strings.Index(config, "{{")
+	if i == -1 {
+		return []string{config}, nil
+	}
+
+	tmpl, err := template.New("config").Parse(config)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid config path %s: %s", config, err)
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, configPathData{
+		Branch: build.Branch,
+		Event:  build.Event,
+		Owner:  repo.Owner,
+		Name:   repo.Name,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Invalid config path %s: %s", config, err)
+	}
+	resolved := buf.String()
+	if !validConfigPath(resolved) {
+		return nil, fmt.Errorf("Invalid config path %s resolved from %s", resolved, config)
+	}
+
+	def := config[:strings.LastIndex(config[:i], "/")+1]
+	if def == "" {
+		def = Config.Server.RepoConfig
+	}
+	if def == resolved {
+		return []string{resolved}, nil
+	}
+	return []string{resolved, def}, nil
+}
+
+// validConfigPath returns true if the path is a clean path relative to the
+// repository root, so a resolved template cannot leave the repository.
+func validConfigPath(p string) bool {
+	trimmed := strings.TrimSuffix(p, "/")
+	if trimmed == "" || path.IsAbs(trimmed) || path.Clean(trimmed) != trimmed {
+		return false
+	}
+	for _, part := range strings.Split(trimmed, "/") {
+		if part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// parseConfigRepo parses an external config repository, given as
+// owner/name@ref. The ref is required so the configuration cannot change
+// without the repository setting changing.
+func parseConfigRepo(s string) (owner, name, ref string, err error) {
+	parts := strings.SplitN(s, "@", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", "", fmt.Errorf("Invalid config repository %s, must be pinned to a ref as owner/name@ref", s)
+	}
+	names := strings.SplitN(parts[0], "/", 2)
+	if len(names) != 2 || names[0] == "" || names[1] == "" {
+		return "", "", "", fmt.Errorf("Invalid config repository %s, expected owner/name@ref", s)
+	}
+	return names[0], names[1], parts[1], nil
+}
+
+// configRepoAllowed returns true if the server allows reading pipeline
+// configurations from the repository. The allowlist holds owner/name
+// patterns, e.g. acme/*.
+func configRepoAllowed(fullName string) bool {
+	for _, pattern := range Config.Pipeline.ConfigRepos {
+		if match, _ := filepath.Match(pattern, fullName); match {
+			return true
+		}
+	}
+	return false
 }
 
 func filterPipelineFiles(files []*remote.FileMeta) []*remote.FileMeta {
 	var res []*remote.FileMeta
 
 	for _, file := range files {
+		// the repository settings are not a pipeline
+		if file.Name == repoSettingsPath {
+			continue
+		}
 		if strings.HasSuffix(file.Name, ".yml") || strings.HasSuffix(file.Name, ".yaml") {
 			res = append(res, file)
 		}
@@ -2,6 +2,7 @@ package server_test
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/mock"
@@ -187,3 +188,263 @@ func TestFetch(t *testing.T) {
 		})
 	}
 }
+
+func TestFetchBranchFallback(t *testing.T) {
+	t.Parallel()
+
+	const commit = "89ab7b2d6bfb347144ac7c557e638ab402848fee"
+
+	testTable := []struct {
+		name              string
+		onBranch          bool
+		branchError       error
+		onDefault         bool
+		expectedFileNames []string
+		expectedError     bool
+	}{
+		{
+			name:              "Present on branch",
+			onBranch:          true,
+			onDefault:         true,
+			expectedFileNames: []string{".woodpecker.yml"},
+		},
+		{
+			name:              "Absent on branch, present on default branch",
+			onBranch:          false,
+			onDefault:         true,
+			expectedFileNames: []string{".woodpecker.yml"},
+		},
+		{
+			name:          "Absent everywhere",
+			onBranch:      false,
+			onDefault:     false,
+			expectedError: true,
+		},
+		{
+			name:          "Failing to read the branch",
+			branchError:   errors.New("connection refused"),
+			onDefault:     true,
+			expectedError: true,
+		},
+		{
+			name:              "Absent on branch as reported by the remote",
+			branchError:       fmt.Errorf(".woodpecker.yml: %w", remote.ErrFileNotFound),
+			onDefault:         true,
+			expectedFileNames: []string{".woodpecker.yml"},
+		},
+	}
+
+	atRef := func(ref string) interface{} {
+		return mock.MatchedBy(func(b *model.Build) bool { return b.Commit == ref })
+	}
+	found := func(ok bool) error {
+		if ok {
+			return nil
+		}
+		return errors.New("File not found")
+	}
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &model.Repo{Owner: "laszlocph", Name: "drone-multipipeline", Config: ".woodpecker.yml", Branch: "master", BranchFallback: true}
+
+			r := new(mocks.Remote)
+			branchErr := tt.branchError
+			if branchErr == nil {
+				branchErr = found(tt.onBranch)
+			}
+			r.On("File", mock.Anything, mock.Anything, atRef(commit), ".woodpecker.yml").Return([]byte{}, branchErr)
+			r.On("File", mock.Anything, mock.Anything, atRef("master"), ".woodpecker.yml").Return([]byte{}, found(tt.onDefault))
+
+			configFetcher := server.NewConfigFetcher(
+				r,
+				&model.User{Token: "xxx"},
+				repo,
+				&model.Build{Commit: commit, Branch: "feature"},
+			)
+			files, err := configFetcher.Fetch()
+			if tt.expectedError && err == nil {
+				t.Fatal("expected an error")
+			} else if !tt.expectedError && err != nil {
+				t.Fatal("error fetching config:", err)
+			}
+			if len(files) != len(tt.expectedFileNames) {
+				t.Fatal("expected some other pipeline files", tt.expectedFileNames, files)
+			}
+			if tt.onBranch || (tt.branchError != nil && tt.expectedError) {
+				r.AssertNotCalled(t, "File", mock.Anything, mock.Anything, atRef("master"), ".woodpecker.yml")
+			}
+		})
+	}
+}
+
+func TestFetchConfigRef(t *testing.T) {
+	t.Parallel()
+
+	const (
+		commit    = "89ab7b2d6bfb347144ac7c557e638ab402848fee"
+		configRef = "refs/heads/protected"
+	)
+
+	atRef := func(ref string) interface{} {
+		return mock.MatchedBy(func(b *model.Build) bool { return b.Commit == ref })
+	}
+
+	repo := &model.Repo{Owner: "laszlocph", Name: "drone-multipipeline", Config: ".woodpecker.yml", Branch: "master"}
+	build := &model.Build{Commit: commit, ConfigRef: configRef, Branch: "feature"}
+
+	r := new(mocks.Remote)
+	r.On("File", mock.Anything, mock.Anything, atRef(configRef), ".woodpecker.yml").Return([]byte("pinned"), nil)
+	r.On("File", mock.Anything, mock.Anything, atRef(commit), ".woodpecker.yml").Return([]byte("unpinned"), nil)
+
+	files, err := server.NewConfigFetcher(r, &model.User{Token: "xxx"}, repo, build).Fetch()
+	if err != nil {
+		t.Fatal("error fetching config:", err)
+	}
+	if len(files) != 1 || string(files[0].Data) != "pinned" {
+		t.Fatal("expected the config at the config ref", files)
+	}
+	r.AssertNotCalled(t, "File", mock.Anything, mock.Anything, atRef(commit), ".woodpecker.yml")
+
+	if build.Commit != commit {
+		t.Errorf("expected the build commit to be kept, got %s", build.Commit)
+	}
+}
+
+func TestFetchConfigRepo(t *testing.T) {
+	server.Config.Pipeline.ConfigRepos = []string{"acme/*"}
+	defer func() { server.Config.Pipeline.ConfigRepos = nil }()
+
+	const commit = "89ab7b2d6bfb347144ac7c557e638ab402848fee"
+
+	atRef := func(ref string) interface{} {
+		return mock.MatchedBy(func(b *model.Build) bool { return b.Commit == ref })
+	}
+	inRepo := func(fullName string) interface{} {
+		return mock.MatchedBy(func(r *model.Repo) bool { return r.FullName == fullName })
+	}
+
+	t.Run("File", func(t *testing.T) {
+		repo := &model.Repo{Owner: "octocat", Name: "hello-world", FullName: "octocat/hello-world", Config: ".woodpecker.yml", ConfigRepo: "acme/pipelines@v1"}
+
+		r := new(mocks.Remote)
+		r.On("File", mock.Anything, inRepo("acme/pipelines"), atRef("v1"), "octocat/hello-world/.woodpecker.yml").Return([]byte("external"), nil)
+
+		files, err := server.NewConfigFetcher(r, &model.User{Token: "xxx"}, repo, &model.Build{Commit: commit}).Fetch()
+		if err != nil {
+			t.Fatal("error fetching config:", err)
+		}
+		if len(files) != 1 || files[0].Name != ".woodpecker.yml" || string(files[0].Data) != "external" {
+			t.Fatal("expected the config of the external repository", files)
+		}
+	})
+
+	t.Run("Folder", func(t *testing.T) {
+		repo := &model.Repo{Owner: "octocat", Name: "hello-world", FullName: "octocat/hello-world", Config: ".woodpecker/", ConfigRepo: "acme/pipelines@v1"}
+
+		r := new(mocks.Remote)
+		r.On("Dir", mock.Anything, inRepo("acme/pipelines"), atRef("v1"), "octocat/hello-world/.woodpecker").Return([]*remote.FileMeta{
+			{Name: "octocat/hello-world/.woodpecker/build.yml", Data: []byte{}},
+			{Name: "octocat/hello-world/.woodpecker/README.md", Data: []byte{}},
+		}, nil)
+
+		files, err := server.NewConfigFetcher(r, &model.User{Token: "xxx"}, repo, &model.Build{Commit: commit}).Fetch()
+		if err != nil {
+			t.Fatal("error fetching config:", err)
+		}
+		if len(files) != 1 || files[0].Name != ".woodpecker/build.yml" {
+			t.Fatal("expected the pipeline files relative to the repository folder", files)
+		}
+	})
+
+	t.Run("Disallowed", func(t *testing.T) {
+		repo := &model.Repo{Owner: "octocat", Name: "hello-world", FullName: "octocat/hello-world", Config: ".woodpecker.yml", ConfigRepo: "evil/pipelines@v1"}
+
+		r := new(mocks.Remote)
+		_, err := server.NewConfigFetcher(r, &model.User{Token: "xxx"}, repo, &model.Build{Commit: commit}).Fetch()
+		if err == nil || err.Error() != "Config repository evil/pipelines is not allowed" {
+			t.Fatal("expected the config repository to be disallowed, got", err)
+		}
+		r.AssertNotCalled(t, "File", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Unpinned", func(t *testing.T) {
+		repo := &model.Repo{Owner: "octocat", Name: "hello-world", FullName: "octocat/hello-world", Config: ".woodpecker.yml", ConfigRepo: "acme/pipelines"}
+
+		r := new(mocks.Remote)
+		if _, err := server.NewConfigFetcher(r, &model.User{Token: "xxx"}, repo, &model.Build{Commit: commit}).Fetch(); err == nil {
+			t.Fatal("expected an error for a config repository without a ref")
+		}
+	})
+}
+
+func TestFetchConfigTemplate(t *testing.T) {
+	t.Parallel()
+
+	const commit = "89ab7b2d6bfb347144ac7c557e638ab402848fee"
+
+	testTable := []struct {
+		name              string
+		branch            string
+		present           bool
+		expectedPath      string
+		expectedFileNames []string
+		expectedError     bool
+	}{
+		{
+			name:              "Branch folder present",
+			branch:            "staging",
+			present:           true,
+			expectedPath:      ".woodpecker/staging/",
+			expectedFileNames: []string{".woodpecker/staging/deploy.yml"},
+		},
+		{
+			name:              "Branch folder absent",
+			branch:            "feature",
+			expectedPath:      ".woodpecker/",
+			expectedFileNames: []string{".woodpecker/build.yml"},
+		},
+		{
+			name:          "Branch leaving the repository",
+			branch:        "../../etc",
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &model.Repo{Owner: "laszlocph", Name: "drone-multipipeline", Config: ".woodpecker/{{.Branch}}/"}
+
+			found := error(nil)
+			if !tt.present {
+				found = errors.New("Folder not found")
+			}
+			r := new(mocks.Remote)
+			r.On("Dir", mock.Anything, mock.Anything, mock.Anything, ".woodpecker/"+tt.branch).Return([]*remote.FileMeta{
+				{Name: ".woodpecker/" + tt.branch + "/deploy.yml", Data: []byte{}},
+			}, found)
+			r.On("Dir", mock.Anything, mock.Anything, mock.Anything, ".woodpecker").Return([]*remote.FileMeta{
+				{Name: ".woodpecker/build.yml", Data: []byte{}},
+			}, nil)
+
+			configFetcher := server.NewConfigFetcher(r, &model.User{Token: "xxx"}, repo, &model.Build{Commit: commit, Branch: tt.branch})
+			files, err := configFetcher.Fetch()
+			if tt.expectedError {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				r.AssertNotCalled(t, "Dir", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			if err != nil {
+				t.Fatal("error fetching config:", err)
+			}
+			if len(files) != len(tt.expectedFileNames) || files[0].Name != tt.expectedFileNames[0] {
+				t.Fatal("expected some other pipeline files", tt.expectedFileNames, files)
+			}
+			if got := configFetcher.ConfigPath(); got != tt.expectedPath {
+				t.Errorf("expected the config path %s, got %s", tt.expectedPath, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/frontend/yaml"
+	"github.com/woodpecker-ci/woodpecker/model"
+	"github.com/woodpecker-ci/woodpecker/remote"
+)
+
+// remoteFile returns a function that fetches files of the repository at
+// the build commit.
+func remoteFile(remote_ remote.Remote, user *model.User, repo *model.Repo, build *model.Build) func(string) ([]byte, error) {
+	return func(name string) ([]byte, error) {
+		return remote_.File(user, repo, build, name)
+	}
+}
+
+// envFileCache holds the parsed environment files of a build. It is shared
+// by the configuration files compiled concurrently.
+type envFileCache struct {
+	sync.Mutex
+	files map[string]map[string]string
+}
+
+// loadEnvFiles merges the variables of the environment files of the
+// containers into their environment. Variables set explicitly in the
+// environment of a container take precedence. Fetched files are kept in
+// the cache, so a file shared by several containers is fetched once.
+func (b *procBuilder) loadEnvFiles(parsed *yaml.Config, cache *envFileCache) error {
+	if b.File == nil {
+		return nil
+	}
+
+	var containers []*yaml.Container
+	containers = append(containers, parsed.Pipeline.Containers...)
+	containers = append(containers, parsed.Services.Containers...)
+
+	for _, container := range containers {
+		for _, name := range container.EnvFile {
+			envs, err := cache.load(name, b.File)
+			if err != nil {
+				return err
+			}
+
+			if container.Environment == nil {
+				container.Environment = map[string]string{}
+			}
+			for k, v := range envs {
+				if _, ok := container.Environment[k]; !ok {
+					container.Environment[k] = v
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// load returns the variables of the environment file, fetching and parsing
+// it if it is not cached yet.
+func (c *envFileCache) load(name string, file func(string) ([]byte, error)) (map[string]string, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if envs, ok := c.files[name]; ok {
+		return envs, nil
+	}
+	data, err := file(name)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot get environment file %s: %s", name, err)
+	}
+	envs, err := parseEnvFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid environment file %s: %s", name, err)
+	}
+	c.files[name] = envs
+	return envs, nil
+}
+
+// parseEnvFile parses the KEY=value lines of an environment file. Empty
+// lines, lines starting with # and an export prefix are ignored, quotes
+// around values are removed.
+func parseEnvFile(data []byte) (map[string]string, error) {
+	envs := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		kvpair := strings.SplitN(line, "=", 2)
+		key := strings.TrimSpace(kvpair[0])
+		if len(kvpair) != 2 || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("line %d: expected KEY=value", n)
+		}
+		value := strings.TrimSpace(kvpair[1])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		envs[key] = value
+	}
+	return envs, scanner.Err()
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/woodpecker-ci/woodpecker/model"
+	"github.com/woodpecker-ci/woodpecker/remote"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	t.Parallel()
+
+	envs, err := parseEnvFile([]byte(`
+# database settings
+DB_HOST=localhost
+export DB_PORT=5432
+DB_NAME="app"
+DB_OPTS='sslmode=disable'
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"DB_HOST": "localhost",
+		"DB_PORT": "5432",
+		"DB_NAME": "app",
+		"DB_OPTS": "sslmode=disable",
+	}
+	if len(envs) != len(want) {
+		t.Errorf("want %d variables, got %v", len(want), envs)
+	}
+	for k, v := range want {
+		if envs[k] != v {
+			t.Errorf("want %s=%s, got %s", k, v, envs[k])
+		}
+	}
+
+	if _, err := parseEnvFile([]byte("FOO=bar\ninvalid\n")); err == nil || err.Error() != "line 2: expected KEY=value" {
+		t.Errorf("expected a parse error, got %v", err)
+	}
+}
+
+func TestEnvFile(t *testing.T) {
+	t.Parallel()
+
+	files := map[string]string{
+		".env": "DB_HOST=localhost\nDB_PORT=5432\n",
+	}
+	fetch := func(name string) ([]byte, error) {
+		data, ok := files[name]
+		if !ok {
+			return nil, errors.New("file not found")
+		}
+		return []byte(data), nil
+	}
+
+	b := procBuilder{
+		Repo:  &model.Repo{},
+		Curr:  &model.Build{},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Link:  "",
+		File:  fetch,
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Data: []byte(`
+skip_clone: true
+pipeline:
+  test:
+    image: golang
+    environment_file: .env
+    environment:
+      DB_PORT: "5433"
+`)},
+		},
+	}
+
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := buildItems[0].Config.Stages[0].Steps[0].Environment
+	if env["DB_HOST"] != "localhost" {
+		t.Errorf("want DB_HOST from the environment file, got %q", env["DB_HOST"])
+	}
+	if env["DB_PORT"] != "5433" {
+		t.Errorf("want DB_PORT from the step environment, got %q", env["DB_PORT"])
+	}
+
+	b.Yamls = []*remote.FileMeta{
+		&remote.FileMeta{Data: []byte(`
+pipeline:
+  test:
+    image: golang
+    environment_file: missing.env
+`)},
+	}
+	_, err = b.Build()
+	if err == nil || err.Error() != "Cannot get environment file missing.env: file not found" {
+		t.Errorf("expected a missing file error, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/woodpecker-ci/woodpecker/model"
+)
+
+// globalEnvirons returns the server-wide environment variables injected
+// into the builds of the repository.
+func globalEnvirons(repo *model.Repo) map[string]string {
+	envs := map[string]string{}
+	if Config.Services.Environ == nil {
+		return envs
+	}
+	globals, err := Config.Services.Environ.EnvironList(repo)
+	if err != nil {
+		logrus.Debugf("cannot get the global environment for %s. %s", repo.FullName, err)
+		return envs
+	}
+	for _, global := range globals {
+		envs[global.Name] = global.Value
+	}
+	return envs
+}
+
+// splitGlobalEnvirons splits the global environment into the defaults the
+// repository, the pipelines and their steps can override, and the variables
+// the server lists to override them, all of them if the list contains *.
+// Built-in variables are never overridden.
+func splitGlobalEnvirons(envs map[string]string, override []string, builtin map[string]string) (defaults, overrides map[string]string) {
+	defaults, overrides = map[string]string{}, map[string]string{}
+	for k, v := range envs {
+		if _, ok := builtin[k]; !ok && overridesEnviron(override, k) {
+			overrides[k] = v
+		} else {
+			defaults[k] = v
+		}
+	}
+	return defaults, overrides
+}
+
+func overridesEnviron(override []string, name string) bool {
+	for _, o := range override {
+		if o == "*" || o == name {
+			return true
+		}
+	}
+	return false
+}
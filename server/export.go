@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/backend"
+)
+
+// exportMask replaces the secret values of an exported configuration.
+const exportMask = "********"
+
+// exportCredentials are the step environment variables holding credentials
+// that are not registered as secrets of the configuration.
+var exportCredentials = []string{
+	"CI_NETRC_PASSWORD",
+	"DRONE_NETRC_PASSWORD",
+}
+
+// ExportJSON returns the compiled configuration of the pipeline as JSON for
+// a standalone runner, with the resolved environment, steps, volumes and
+// networks. The output is stable for the same configuration. Unless secrets
+// are included, the secret values, registry passwords and netrc passwords
+// are masked wherever they appear.
+func (item *buildItem) ExportJSON(secrets bool) ([]byte, error) {
+	config := item.Config
+	if !secrets {
+		var err error
+		if config, err = maskConfig(config); err != nil {
+			return nil, err
+		}
+	}
+	return json.MarshalIndent(config, "", "  ")
+}
+
+// maskConfig returns a copy of the configuration with the credentials
+// replaced by the mask, the configuration itself is not modified.
+func maskConfig(config *backend.Config) (*backend.Config, error) {
+	out, err := copyConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// longer values first, a secret containing another is masked whole.
+	var values []string
+	for _, sec := range out.Secrets {
+		if sec.Value != "" {
+			values = append(values, sec.Value)
+		}
+		sec.Value = exportMask
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+	var pairs []string
+	for _, value := range values {
+		pairs = append(pairs, value, exportMask)
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	for _, stage := range out.Stages {
+		for _, step := range stage.Steps {
+			for k, v := range step.Environment {
+				step.Environment[k] = replacer.Replace(v)
+			}
+			for _, k := range exportCredentials {
+				if _, ok := step.Environment[k]; ok {
+					step.Environment[k] = exportMask
+				}
+			}
+			if step.AuthConfig.Password != "" {
+				step.AuthConfig.Password = exportMask
+			}
+		}
+	}
+	return out, nil
+}
+
+// copyConfig returns a deep copy of the configuration.
+func copyConfig(config *backend.Config) (*backend.Config, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	out := new(backend.Config)
+	return out, json.Unmarshal(data, out)
+}
@@ -0,0 +1,8 @@
+package server
+
+// The fork config gate is exported to the external tests, which can use the
+// remotes importing this package.
+var (
+	ChangedFiles      = changedFiles
+	ForkConfigChanged = forkConfigChanged
+)
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/woodpecker-ci/woodpecker/model"
+)
+
+// Fork config policies, see forkConfigChanged.
+const (
+	ForkConfigAllow        = "allow"
+	ForkConfigApprove      = "approve"
+	ForkConfigStripSecrets = "strip-secrets"
+)
+
+// forkConfigChanged returns true if the build is a pull request from a fork
+// changing the pipeline configuration, e.g. to expose the secrets. Builds
+// whose changed files are not known are assumed to change it. The fallback
+// config file is configuration too if the repository falls back to it.
+func forkConfigChanged(repo *model.Repo, build *model.Build, configPath string) bool {
+	if !build.FromFork || build.Event != model.EventPull {
+		return false
+	}
+	if len(build.ChangedFiles) == 0 {
+		return true
+	}
+	for _, file := range build.ChangedFiles {
+		if isConfigFile(file, configPath) || (repo.Fallback && file == fallbackConfigPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// isConfigFile returns true if the file is the config file, is in the config
+// folder or is the repository settings file. A templated config folder
+// covers all the folders it may resolve to.
+func isConfigFile(file, configPath string) bool {
+	if configPath == "" {
+		configPath = Config.Server.RepoConfig
+	}
+	if file == repoSettingsPath {
+		return true
+	}
+	if i := strings.Index(configPath, "{{"); i != -1 {
+		return strings.HasPrefix(file, configPath[:i])
+	}
+	if strings.HasSuffix(configPath, "/") {
+		return strings.HasPrefix(file, configPath)
+	}
+	return file == configPath
+}
+
+// forkApprovalRequired returns true if the build needs approval as a pull
+// request from a fork changing the pipeline configuration. As for other
+// approvals, builds sent by the repository owner do not.
+func forkApprovalRequired(user *model.User, repo *model.Repo, build *model.Build, configPath string) bool {
+	return Config.Pipeline.ForkConfigPolicy == ForkConfigApprove &&
+		build.Sender != user.Login &&
+		forkConfigChanged(repo, build, configPath)
+}
+
+// forkSecrets returns the secrets exposed to the build, none for a pull
+// request from a fork changing the pipeline configuration if the policy
+// strips the secrets.
+func forkSecrets(repo *model.Repo, build *model.Build, configPath string, secs []*model.Secret) []*model.Secret {
+	if Config.Pipeline.ForkConfigPolicy == ForkConfigStripSecrets && forkConfigChanged(repo, build, configPath) {
+		return nil
+	}
+	return secs
+}
+
+// forkConfigScope returns the repository and config path to check the files
+// changed by a fork against. A configuration read from a secret cannot be
+// changed by a pull request, only the repository settings file can.
+func forkConfigScope(repo *model.Repo, configPath string, fromSecret bool) (*model.Repo, string) {
+	if !fromSecret {
+		return repo, configPath
+	}
+	scoped := *repo
+	scoped.Fallback = false
+	return &scoped, repoSettingsPath
+}
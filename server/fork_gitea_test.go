@@ -0,0 +1,67 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/woodpecker-ci/woodpecker/model"
+	"github.com/woodpecker-ci/woodpecker/remote/gitea"
+	"github.com/woodpecker-ci/woodpecker/remote/gitea/fixtures"
+	"github.com/woodpecker-ci/woodpecker/server"
+)
+
+func TestForkConfigChangedGiteaHook(t *testing.T) {
+	t.Parallel()
+
+	var diff string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gordon/hello-world/compare/master...0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c.diff" {
+			w.Write([]byte(diff))
+			return
+		}
+		fixtures.Handler().ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	remote_, _ := gitea.New(gitea.Opts{URL: srv.URL})
+
+	// the pull request of the fixture, sent from a fork.
+	payload := strings.NewReplacer(
+		`"sha": "9353195a19e45482665306e466c832c46560532d"`, `"sha": "9353195a19e45482665306e466c832c46560532d", "repo": {"id": 35129377}`,
+		`"sha": "0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c"`, `"sha": "0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c", "repo": {"id": 35129378}`,
+	).Replace(fixtures.HookPullRequest)
+
+	testTable := []struct {
+		name     string
+		diff     string
+		expected bool
+	}{
+		{
+			name:     "Fork changing the config file",
+			diff:     "diff --git a/.drone.yml b/.drone.yml\n--- a/.drone.yml\n+++ b/.drone.yml\n",
+			expected: true,
+		},
+		{
+			name:     "Fork changing other files",
+			diff:     "diff --git a/README.md b/README.md\n--- a/README.md\n+++ b/README.md\n",
+			expected: false,
+		},
+	}
+	for _, tt := range testTable {
+		diff = tt.diff
+		req, _ := http.NewRequest("POST", "/hook", strings.NewReader(payload))
+		req.Header.Set("X-Gitea-Event", "pull_request")
+		repo, build, err := remote_.Hook(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !build.FromFork {
+			t.Fatalf("%s: want a build from a fork", tt.name)
+		}
+		build.ChangedFiles = server.ChangedFiles(remote_, &model.User{}, repo, build)
+		if got := server.ForkConfigChanged(repo, build, ".drone.yml"); got != tt.expected {
+			t.Errorf("%s: want changed config %v, got %v with the changed files %v", tt.name, tt.expected, got, build.ChangedFiles)
+		}
+	}
+}
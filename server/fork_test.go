@@ -0,0 +1,151 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/woodpecker-ci/woodpecker/model"
+)
+
+func TestForkConfigChanged(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name       string
+		repo       *model.Repo
+		build      *model.Build
+		configPath string
+		expected   bool
+	}{
+		{
+			name:       "Fork changing the config file",
+			build:      &model.Build{Event: model.EventPull, FromFork: true, ChangedFiles: []string{"main.go", ".drone.yml"}},
+			configPath: ".drone.yml",
+			expected:   true,
+		},
+		{
+			name:       "Fork changing a file of the config folder",
+			build:      &model.Build{Event: model.EventPull, FromFork: true, ChangedFiles: []string{".woodpecker/test.yml"}},
+			configPath: ".woodpecker/",
+			expected:   true,
+		},
+		{
+			name:       "Fork changing a folder of a templated config folder",
+			build:      &model.Build{Event: model.EventPull, FromFork: true, ChangedFiles: []string{".woodpecker/main/build.yml"}},
+			configPath: ".woodpecker/{{.Branch}}/",
+			expected:   true,
+		},
+		{
+			name:       "Fork changing the repository settings",
+			build:      &model.Build{Event: model.EventPull, FromFork: true, ChangedFiles: []string{repoSettingsPath}},
+			configPath: ".drone.yml",
+			expected:   true,
+		},
+		{
+			name:       "Fork with unknown changed files",
+			build:      &model.Build{Event: model.EventPull, FromFork: true},
+			configPath: ".drone.yml",
+			expected:   true,
+		},
+		{
+			name:       "Fork not changing the config",
+			build:      &model.Build{Event: model.EventPull, FromFork: true, ChangedFiles: []string{"main.go", "docs/.drone.yml"}},
+			configPath: ".drone.yml",
+			expected:   false,
+		},
+		{
+			name:       "Fork changing the fallback config file",
+			repo:       &model.Repo{Fallback: true},
+			build:      &model.Build{Event: model.EventPull, FromFork: true, ChangedFiles: []string{".drone.yml"}},
+			configPath: ".woodpecker/",
+			expected:   true,
+		},
+		{
+			name:       "Fork changing the fallback config file of a repository without fallback",
+			build:      &model.Build{Event: model.EventPull, FromFork: true, ChangedFiles: []string{".drone.yml"}},
+			configPath: ".woodpecker/",
+			expected:   false,
+		},
+		{
+			name:       "Pull request of the repository changing the config",
+			build:      &model.Build{Event: model.EventPull, ChangedFiles: []string{".drone.yml"}},
+			configPath: ".drone.yml",
+			expected:   false,
+		},
+	}
+
+	for _, tt := range testTable {
+		if tt.repo == nil {
+			tt.repo = &model.Repo{}
+		}
+		if changed := forkConfigChanged(tt.repo, tt.build, tt.configPath); changed != tt.expected {
+			t.Errorf("%s: want %v, got %v", tt.name, tt.expected, changed)
+		}
+	}
+}
+
+func TestForkConfigPolicy(t *testing.T) {
+	defer func() { Config.Pipeline.ForkConfigPolicy = "" }()
+
+	user := &model.User{Login: "octocat"}
+	repo := &model.Repo{}
+	secs := []*model.Secret{{Name: "token"}}
+	touching := &model.Build{Event: model.EventPull, FromFork: true, Sender: "attacker", ChangedFiles: []string{".drone.yml"}}
+	other := &model.Build{Event: model.EventPull, FromFork: true, Sender: "contributor", ChangedFiles: []string{"main.go"}}
+
+	Config.Pipeline.ForkConfigPolicy = ForkConfigApprove
+	if !forkApprovalRequired(user, repo, touching, ".drone.yml") {
+		t.Errorf("Want approval for a fork changing the config")
+	}
+	if forkApprovalRequired(user, repo, other, ".drone.yml") {
+		t.Errorf("Want no approval for a fork not changing the config")
+	}
+	if len(forkSecrets(repo, touching, ".drone.yml", secs)) != 1 {
+		t.Errorf("Want the secrets kept once approved")
+	}
+
+	Config.Pipeline.ForkConfigPolicy = ForkConfigStripSecrets
+	if forkApprovalRequired(user, repo, touching, ".drone.yml") {
+		t.Errorf("Want no approval if the policy strips the secrets")
+	}
+	if len(forkSecrets(repo, touching, ".drone.yml", secs)) != 0 {
+		t.Errorf("Want the secrets stripped for a fork changing the config")
+	}
+	if len(forkSecrets(repo, other, ".drone.yml", secs)) != 1 {
+		t.Errorf("Want the secrets kept for a fork not changing the config")
+	}
+
+	// a repository falling back to the fallback config file builds the
+	// file the fork changed.
+	fallback := &model.Build{Event: model.EventPull, FromFork: true, Sender: "attacker", ChangedFiles: []string{".drone.yml"}}
+	if len(forkSecrets(&model.Repo{Fallback: true}, fallback, ".woodpecker/", secs)) != 0 {
+		t.Errorf("Want the secrets stripped for a fork changing the fallback config")
+	}
+
+	Config.Pipeline.ForkConfigPolicy = ForkConfigAllow
+	if forkApprovalRequired(user, repo, touching, ".drone.yml") || len(forkSecrets(repo, touching, ".drone.yml", secs)) != 1 {
+		t.Errorf("Want forks changing the config allowed by default")
+	}
+}
+
+func TestForkConfigScope(t *testing.T) {
+	t.Parallel()
+
+	repo := &model.Repo{Fallback: true}
+	build := &model.Build{Event: model.EventPull, FromFork: true, ChangedFiles: []string{".drone.yml"}}
+
+	scoped, configPath := forkConfigScope(repo, ".drone.yml", false)
+	if !forkConfigChanged(scoped, build, configPath) {
+		t.Errorf("Want the config file of the repository changed by the fork")
+	}
+	scoped, configPath = forkConfigScope(repo, ".drone.yml", true)
+	if forkConfigChanged(scoped, build, configPath) {
+		t.Errorf("Want the config file ignored if the configuration is read from a secret")
+	}
+	if !repo.Fallback {
+		t.Errorf("Want the repository not modified")
+	}
+	build.ChangedFiles = []string{repoSettingsPath}
+	if !forkConfigChanged(scoped, build, configPath) {
+		t.Errorf("Want the repository settings changed by the fork")
+	}
+}
@@ -24,7 +24,6 @@ import (
 	"fmt"
 	"math/rand"
 	"net/http"
-	"regexp"
 	"strconv"
 	"time"
 
@@ -42,8 +41,6 @@ import (
 	"github.com/woodpecker-ci/woodpecker/cncd/queue"
 )
 
-var skipRe = regexp.MustCompile(`\[(?i:ci *skip|skip *ci)\]`)
-
 func init() {
 	rand.Seed(time.Now().UnixNano())
 }
@@ -92,10 +89,14 @@ func PostHook(c *gin.Context) {
 		c.Writer.WriteHeader(400)
 		return
 	}
+	if build.ConfigRef == "" {
+		build.ConfigRef = build.Commit
+	}
 
-	// skip the build if any case-insensitive combination of the words "skip" and "ci"
-	// wrapped in square brackets appear in the commit message
-	skipMatch := skipRe.FindString(build.Message)
+	// skip the build if the commit message contains a skip marker, by
+	// default any case-insensitive combination of the words "skip" and "ci"
+	// wrapped in square brackets
+	skipMatch := skipMarker(Config.Pipeline.SkipMarkers, Config.Pipeline.SkipEvents, build)
 	if len(skipMatch) > 0 {
 		logrus.Infof("ignoring hook. %s found in %s", skipMatch, build.Commit)
 		c.Writer.WriteHeader(204)
@@ -138,7 +139,8 @@ func PostHook(c *gin.Context) {
 	if (build.Event == model.EventPush && repo.AllowPush) ||
 		(build.Event == model.EventPull && repo.AllowPull) ||
 		(build.Event == model.EventDeploy && repo.AllowDeploy) ||
-		(build.Event == model.EventTag && repo.AllowTag) {
+		(build.Event == model.EventTag && repo.AllowTag) ||
+		(build.Event == model.EventWiki && repo.AllowPush && wikiEnabled(repo)) {
 		skipped = false
 	}
 
@@ -165,16 +167,65 @@ func PostHook(c *gin.Context) {
 		}
 	}
 
-	// fetch the build file from the remote
+	// hooks without a commit, e.g. wiki edits, build the head of the branch.
+	if build.Commit == "" {
+		if err := resolveHead(remote_, user, repo, build); err != nil {
+			logrus.Errorf("failure to resolve the head of %s in %s. %s", build.Ref, repo.FullName, err)
+			c.AbortWithError(400, err)
+			return
+		}
+	}
+
+	// the path conditions, the fork config policy and the code owners
+	// need the files changed by pull requests, which some hooks omit.
+	if build.Event == model.EventPull && len(build.ChangedFiles) == 0 {
+		build.ChangedFiles = changedFiles(remote_, user, repo, build)
+	}
+
+	// reject the build if the hook sender lacks the permission required by
+	// the trigger policy for this event.
+	if _, ok := Config.Pipeline.TriggerPolicy[build.Event]; ok {
+		perm := senderPerm(remote_, user, repo, build)
+		if ok, reason := triggerAllowed(Config.Pipeline.TriggerPolicy, build, perm); !ok {
+			logrus.Infof("ignoring hook. %s: %s", repo.FullName, reason)
+			c.String(403, reason)
+			return
+		}
+	}
+
+	// the settings versioned in the repository apply as defaults, within
+	// the limits set by the server and the admins.
+	settings, err := fetchRepoSettings(remote_, user, repo, build)
+	if err != nil {
+		logrus.Errorf("failure to read the repository settings from hook for %s. %s", repo.FullName, err)
+		c.AbortWithError(400, err)
+		return
+	}
+	if !settings.EventEnabled(build.Event) {
+		logrus.Infof("ignoring hook. repo %s settings disable %s events.", repo.FullName, build.Event)
+		c.Writer.WriteHeader(204)
+		return
+	}
+	repo = settings.Apply(repo)
+
+	// fetch the build file from the config secret or the remote
 	configFetcher := &configFetcher{remote_: remote_, user: user, repo: repo, build: build}
-	remoteYamlConfigs, err := configFetcher.Fetch()
+	remoteYamlConfigs, fromSecret, err := fetchConfig(configFetcher, repo, build)
 	if err != nil {
 		logrus.Errorf("error: %s: cannot find %s in %s: %s", repo.FullName, repo.Config, build.Ref, err)
 		c.AbortWithError(404, err)
 		return
 	}
 
-	filtered, err := branchFiltered(build, remoteYamlConfigs)
+	// the manifest of the config folder is not a pipeline itself
+	pipelineConfigs, err := manifestOrder(remoteYamlConfigs, configFetcher.ConfigPath())
+	if err != nil {
+		logrus.Errorf("failure to read the pipeline manifest from hook for %s. %s", repo.FullName, err)
+		c.AbortWithError(400, err)
+		return
+	}
+
+	filtered, err := branchFiltered(build, pipelineConfigs)
 	if err != nil {
 		logrus.Errorf("failure to parse yaml from hook for %s. %s", repo.FullName, err)
 		c.AbortWithError(400, err)
@@ -184,7 +235,19 @@ func PostHook(c *gin.Context) {
 		return
 	}
 
-	if zeroSteps(build, remoteYamlConfigs) {
+	ignored, err := authorFiltered(build, pipelineConfigs)
+	if err != nil {
+		logrus.Errorf("failure to parse yaml from hook for %s. %s", repo.FullName, err)
+		c.AbortWithError(400, err)
+		return
+	}
+	if ignored {
+		logrus.Infof("ignoring hook. builds of %s are ignored for %s.", build.Author, repo.FullName)
+		c.String(200, "Builds of this author are ignored")
+		return
+	}
+
+	if zeroSteps(build, pipelineConfigs) {
 		c.String(200, "Step conditions yield zero runnable steps")
 		return
 	}
@@ -194,7 +257,12 @@ func PostHook(c *gin.Context) {
 	build.Verified = true
 	build.Status = model.StatusPending
 
-	if repo.IsGated && build.Sender != user.Login {
+	var perm *model.Perm
+	if repo.IsGatedExternal && build.Event == model.EventPull && build.Sender != user.Login {
+		perm = senderPerm(remote_, user, repo, build)
+	}
+	forkRepo, forkConfigPath := forkConfigScope(repo, configFetcher.ConfigPath(), fromSecret)
+	if approvalRequired(repo, user, build, perm) || forkApprovalRequired(user, forkRepo, build, forkConfigPath) {
 		build.Status = model.StatusBlocked
 	}
 
@@ -207,7 +275,7 @@ func PostHook(c *gin.Context) {
 
 	// persist the build config for historical correctness, restarts, etc
 	for _, remoteYamlConfig := range remoteYamlConfigs {
-		_, err := findOrPersistPipelineConfig(repo, build, remoteYamlConfig)
+		_, err := findOrPersistPipelineConfig(configFetcher.ConfigPath(), build, remoteYamlConfig)
 		if err != nil {
 			logrus.Errorf("failure to find or persist build config for %s. %s", repo.FullName, err)
 			c.AbortWithError(500, err)
@@ -227,18 +295,13 @@ func PostHook(c *gin.Context) {
 		return
 	}
 
-	envs := map[string]string{}
-	if Config.Services.Environ != nil {
-		globals, _ := Config.Services.Environ.EnvironList(repo)
-		for _, global := range globals {
-			envs[global.Name] = global.Value
-		}
-	}
+	envs := globalEnvirons(repo)
 
 	secs, err := Config.Services.Secrets.SecretListBuild(repo, build)
 	if err != nil {
 		logrus.Debugf("Error getting secrets for %s#%d. %s", repo.FullName, build.Number, err)
 	}
+	secs = forkSecrets(forkRepo, build, forkConfigPath, secs)
 
 	regs, err := Config.Services.Registries.RegistryList(repo)
 	if err != nil {
@@ -258,14 +321,22 @@ func PostHook(c *gin.Context) {
 		Envs:  envs,
 		Link:  Config.Server.Host,
 		Yamls: remoteYamlConfigs,
+
+		ConfigPath:     configFetcher.ConfigPath(),
+		CommitVerified: commitVerified(remote_, user, repo, build),
+		File:           remoteFile(remote_, user, repo, build),
 	}
-	buildItems, err := b.Build()
+	result, err := b.Result()
 	if err != nil {
 		if _, err = UpdateToStatusError(store.FromContext(c), *build, err); err != nil {
 			logrus.Errorf("Error setting error status of build for %s#%d. %s", repo.FullName, build.Number, err)
 		}
 		return
 	}
+	for _, skipped := range result.Skipped {
+		logrus.Debugf("%s#%d: skipping pipeline %s, %s", repo.FullName, build.Number, skipped.Name, skipped.Reason)
+	}
+	buildItems := result.Items
 	build = setBuildStepsOnBuild(b.Curr, buildItems)
 
 	err = store.FromContext(c).ProcCreate(build.Procs)
@@ -273,24 +344,41 @@ func PostHook(c *gin.Context) {
 		logrus.Errorf("error persisting procs %s/%d: %s", repo.FullName, build.Number, err)
 	}
 
-	defer func() {
-		for _, item := range buildItems {
-			uri := fmt.Sprintf("%s/%s/%d", Config.Server.Host, repo.FullName, build.Number)
-			if len(buildItems) > 1 {
-				err = remote_.Status(user, repo, build, uri, item.Proc)
-			} else {
-				err = remote_.Status(user, repo, build, uri, nil)
-			}
-			if err != nil {
-				logrus.Errorf("error setting commit status for %s/%d: %v", repo.FullName, build.Number, err)
-			}
-		}
-	}()
+	defer sendStatuses(remote_, user, repo, build, buildItems)
 
 	publishToTopic(c, build, repo, model.Enqueued)
 	queueBuild(build, repo, buildItems)
 }
 
+// commitVerified returns true if commit verification is enabled and the
+// remote verified the signature of the build commit.
+func commitVerified(remote_ remote.Remote, user *model.User, repo *model.Repo, build *model.Build) bool {
+	verifier, ok := remote_.(remote.CommitVerifier)
+	if !ok || !Config.Pipeline.VerifyCommit {
+		return false
+	}
+	verified, err := verifier.CommitVerified(user, repo, build)
+	if err != nil {
+		logrus.Debugf("Error getting commit verification for %s#%d. %s", repo.FullName, build.Number, err)
+	}
+	return verified
+}
+
+// changedFiles returns the files changed by the pull request of the build,
+// or nil if the remote cannot list them. Unknown changed files are treated
+// as changing the pipeline configuration.
+func changedFiles(remote_ remote.Remote, user *model.User, repo *model.Repo, build *model.Build) []string {
+	lister, ok := remote_.(remote.ChangedFilesLister)
+	if !ok {
+		return nil
+	}
+	files, err := lister.ChangedFiles(user, repo, build)
+	if err != nil {
+		logrus.Debugf("Error getting the changed files of %s %s. %s", repo.FullName, build.Ref, err)
+	}
+	return files
+}
+
 func branchFiltered(build *model.Build, remoteYamlConfigs []*remote.FileMeta) (bool, error) {
 	for _, remoteYamlConfig := range remoteYamlConfigs {
 		parsedPipelineConfig, err := yaml.ParseString(string(remoteYamlConfig.Data))
@@ -329,7 +417,25 @@ func zeroSteps(build *model.Build, remoteYamlConfigs []*remote.FileMeta) bool {
 	return false
 }
 
-func findOrPersistPipelineConfig(repo *model.Repo, build *model.Build, remoteYamlConfig *remote.FileMeta) (*model.Config, error) {
+// resolveHead sets the commit of the build to the head of its branch, for
+// hooks that carry no commit.
+func resolveHead(remote_ remote.Remote, user *model.User, repo *model.Repo, build *model.Build) error {
+	dispatcher, ok := remote_.(remote.Dispatcher)
+	if !ok {
+		return fmt.Errorf("cannot resolve the head of %s, the remote does not support it", build.Ref)
+	}
+	dispatch, err := dispatcher.Dispatch(user, repo, build.Ref, nil)
+	if err != nil {
+		return err
+	}
+	build.Commit = dispatch.Build.Commit
+	if build.ConfigRef == "" {
+		build.ConfigRef = build.Commit
+	}
+	return nil
+}
+
+func findOrPersistPipelineConfig(configPath string, build *model.Build, remoteYamlConfig *remote.FileMeta) (*model.Config, error) {
 	sha := shasum(remoteYamlConfig.Data)
 	conf, err := Config.Storage.Config.ConfigFindIdentical(build.RepoID, sha)
 	if err != nil {
@@ -337,7 +443,7 @@ func findOrPersistPipelineConfig(repo *model.Repo, build *model.Build, remoteYam
 			RepoID: build.RepoID,
 			Data:   string(remoteYamlConfig.Data),
 			Hash:   sha,
-			Name:   sanitizePath(remoteYamlConfig.Name, repo.Config),
+			Name:   sanitizePath(remoteYamlConfig.Name, configPath),
 		}
 		err = Config.Storage.Config.ConfigCreate(conf)
 		if err != nil {
@@ -395,6 +501,9 @@ func queueBuild(build *model.Build, repo *model.Repo, buildItems []*buildItem) {
 		task.Labels["repo"] = repo.FullName
 		task.Dependencies = taskIds(item.DependsOn, buildItems)
 		task.RunOn = item.RunsOn
+		task.ExcludeLabels = item.ExcludeLabels
+		task.LabelExprs = item.LabelExprs
+		task.FailFast = item.FailFast
 		task.DepStatus = make(map[string]string)
 
 		task.Data, _ = json.Marshal(rpc.Pipeline{
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/woodpecker-ci/woodpecker/remote"
+	"gopkg.in/yaml.v3"
+)
+
+// manifestName is the name of the manifest listing the pipeline files of a
+// config folder.
+const manifestName = "index.yml"
+
+// manifest lists the pipeline files of a config folder, relative to the
+// folder, in the order they are processed.
+type manifest struct {
+	Files []string `yaml:"files"`
+}
+
+// manifestOrder returns the pipeline files in the order they are
+// processed. If the config folder contains a manifest, exactly the files it
+// lists are returned in the listed order, otherwise all files sorted by
+// name.
+func manifestOrder(yamls []*remote.FileMeta, folder string) ([]*remote.FileMeta, error) {
+	sorted := make([]*remote.FileMeta, len(yamls))
+	copy(sorted, yamls)
+	sort.Sort(remote.ByName(sorted))
+
+	if !strings.HasSuffix(folder, "/") {
+		return sorted, nil
+	}
+
+	byName := map[string]*remote.FileMeta{}
+	for _, y := range yamls {
+		byName[y.Name] = y
+	}
+	index, ok := byName[path.Join(folder, manifestName)]
+	if !ok {
+		return sorted, nil
+	}
+
+	m := new(manifest)
+	if err := yaml.Unmarshal(index.Data, m); err != nil {
+		return nil, fmt.Errorf("Invalid pipeline manifest %s: %s", index.Name, err)
+	}
+	if len(m.Files) == 0 {
+		return nil, fmt.Errorf("Invalid pipeline manifest %s: no files listed", index.Name)
+	}
+
+	var ordered []*remote.FileMeta
+	for _, name := range m.Files {
+		y, ok := byName[path.Join(folder, name)]
+		if !ok || y == index {
+			return nil, fmt.Errorf("Pipeline manifest %s lists %s, which does not exist", index.Name, name)
+		}
+		ordered = append(ordered, y)
+	}
+	return ordered, nil
+}
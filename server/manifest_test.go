@@ -0,0 +1,91 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/woodpecker-ci/woodpecker/model"
+	"github.com/woodpecker-ci/woodpecker/remote"
+)
+
+func TestManifestOrder(t *testing.T) {
+	t.Parallel()
+
+	pipeline := []byte("pipeline:\n  build:\n    image: scratch\n")
+	yamls := func(manifest string) []*remote.FileMeta {
+		files := []*remote.FileMeta{
+			{Name: ".woodpecker/build.yml", Data: pipeline},
+			{Name: ".woodpecker/deploy.yml", Data: pipeline},
+			{Name: ".woodpecker/lint.yml", Data: pipeline},
+		}
+		if manifest != "" {
+			files = append(files, &remote.FileMeta{Name: ".woodpecker/index.yml", Data: []byte(manifest)})
+		}
+		return files
+	}
+
+	testTable := []struct {
+		name  string
+		yamls []*remote.FileMeta
+		procs []string
+		err   string
+	}{
+		{
+			name:  "Sorted by name without a manifest",
+			yamls: yamls(""),
+			procs: []string{"build", "deploy", "lint"},
+		},
+		{
+			name:  "Listed files in the listed order",
+			yamls: yamls("files: [ lint.yml, build.yml ]"),
+			procs: []string{"lint", "build"},
+		},
+		{
+			name:  "Listed file missing",
+			yamls: yamls("files: [ lint.yml, test.yml ]"),
+			err:   "Pipeline manifest .woodpecker/index.yml lists test.yml, which does not exist",
+		},
+		{
+			name:  "Manifest listing no files",
+			yamls: yamls("files: []"),
+			err:   "Invalid pipeline manifest .woodpecker/index.yml: no files listed",
+		},
+	}
+
+	for _, tt := range testTable {
+		b := procBuilder{
+			Repo:  &model.Repo{Config: ".woodpecker/"},
+			Curr:  &model.Build{},
+			Last:  &model.Build{},
+			Netrc: &model.Netrc{},
+			Secs:  []*model.Secret{},
+			Regs:  []*model.Registry{},
+			Link:  "",
+			Yamls: tt.yamls,
+		}
+
+		buildItems, err := b.Build()
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: want error %q, got %v", tt.name, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		var procs []string
+		for _, item := range buildItems {
+			procs = append(procs, item.Proc.Name)
+		}
+		if len(procs) != len(tt.procs) {
+			t.Errorf("%s: want pipelines %v, got %v", tt.name, tt.procs, procs)
+			continue
+		}
+		for i := range procs {
+			if procs[i] != tt.procs[i] {
+				t.Errorf("%s: want pipelines %v, got %v", tt.name, tt.procs, procs)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package server
+
+import "github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/frontend/yaml"
+
+// NotifyConfig are the notifications of a build, declared by its pipelines
+// with the notify element. Delivering them is left to the server, matching
+// their conditions against the finished build.
+type NotifyConfig struct {
+	Targets []NotifyTarget
+}
+
+// NotifyTarget is a notification declared by a pipeline.
+type NotifyTarget struct {
+	// Pipeline is the name of the pipeline declaring the notification.
+	Pipeline string
+
+	Email   []string
+	Webhook string
+	When    yaml.Constraints
+}
+
+// notifyTargets returns the notifications declared by the pipeline.
+func notifyTargets(pipeline string, notify []yaml.Notify) []NotifyTarget {
+	var targets []NotifyTarget
+	for _, n := range notify {
+		targets = append(targets, NotifyTarget{
+			Pipeline: pipeline,
+			Email:    n.Email,
+			Webhook:  n.Webhook,
+			When:     n.When,
+		})
+	}
+	return targets
+}
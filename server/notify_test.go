@@ -0,0 +1,88 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/woodpecker-ci/woodpecker/model"
+	"github.com/woodpecker-ci/woodpecker/remote"
+)
+
+func TestNotifyConfig(t *testing.T) {
+	t.Parallel()
+
+	b := procBuilder{
+		Repo:  &model.Repo{},
+		Curr:  &model.Build{Event: model.EventPush, Branch: "master"},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Name: "build", Data: []byte(`
+pipeline:
+  build:
+    image: golang:${GO_VERSION}
+notify:
+  - email: [ team@example.com, lead@example.com ]
+    when:
+      status: [ failure ]
+  - webhook: https://chat.example.com/hooks/build
+matrix:
+  GO_VERSION: [ "1.19", "1.20" ]
+`)},
+			&remote.FileMeta{Name: "release", Data: []byte(`
+branches: release
+pipeline:
+  release:
+    image: golang
+notify:
+  - email: release@example.com
+`)},
+		},
+	}
+
+	result, err := b.Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets := result.Notify.Targets
+	if len(targets) != 2 {
+		t.Fatalf("Want the notifications of the matrix declared once and none of skipped pipelines, got %v", targets)
+	}
+	if targets[0].Pipeline != "build" || !reflect.DeepEqual(targets[0].Email, []string{"team@example.com", "lead@example.com"}) {
+		t.Errorf("Want the email notification of the build pipeline, got %v", targets[0])
+	}
+	if !reflect.DeepEqual(targets[0].When.Status.Include, []string{"failure"}) {
+		t.Errorf("Want the email notification on failure, got %v", targets[0].When.Status)
+	}
+	if targets[1].Webhook != "https://chat.example.com/hooks/build" || len(targets[1].When.Status.Include) != 0 {
+		t.Errorf("Want the unconditional webhook notification, got %v", targets[1])
+	}
+}
+
+func TestNotifyConfigInvalid(t *testing.T) {
+	t.Parallel()
+
+	b := procBuilder{
+		Repo:  &model.Repo{},
+		Curr:  &model.Build{Event: model.EventPush},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Data: []byte(`
+pipeline:
+  build:
+    image: golang
+notify:
+  - webhook: ftp://chat.example.com
+`)},
+		},
+	}
+
+	if _, err := b.Result(); err == nil {
+		t.Errorf("Want an invalid notification target to fail the build")
+	}
+}
@@ -0,0 +1,29 @@
+package server
+
+import (
+	"github.com/woodpecker-ci/woodpecker/model"
+)
+
+// eventPolicy returns the build events the pipelines of the repository can
+// run for and the source of the policy. The repository policy, set by an
+// admin, overrides the server policy. No events means all events are
+// allowed.
+func eventPolicy(repo *model.Repo) ([]string, string) {
+	if len(repo.AllowedEvents) != 0 {
+		return repo.AllowedEvents, "repository"
+	}
+	return Config.Pipeline.AllowedEvents, "server"
+}
+
+// wikiEnabled returns true if the event policy lists the wiki event. Wiki
+// builds are opt-in, as pipelines without event conditions would otherwise
+// run on every wiki edit.
+func wikiEnabled(repo *model.Repo) bool {
+	events, _ := eventPolicy(repo)
+	return containsEvent(events, model.EventWiki)
+}
+
+// eventAllowed returns true if the event policy allows the event.
+func eventAllowed(events []string, event string) bool {
+	return len(events) == 0 || containsEvent(events, event)
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/woodpecker-ci/woodpecker/model"
+	"github.com/woodpecker-ci/woodpecker/remote"
+)
+
+func TestEventPolicy(t *testing.T) {
+	Config.Pipeline.AllowedEvents = []string{model.EventPush, model.EventTag}
+	defer func() { Config.Pipeline.AllowedEvents = nil }()
+
+	testTable := []struct {
+		name   string
+		repo   *model.Repo
+		event  string
+		reason string
+	}{
+		{
+			name:  "Event allowed by the server policy",
+			repo:  &model.Repo{},
+			event: model.EventPush,
+		},
+		{
+			name:   "Event disallowed by the server policy",
+			repo:   &model.Repo{},
+			event:  model.EventPull,
+			reason: "event pull_request is not allowed by the server policy",
+		},
+		{
+			name:  "Event allowed by the repository policy",
+			repo:  &model.Repo{AllowedEvents: []string{model.EventPull}},
+			event: model.EventPull,
+		},
+		{
+			name:   "Event disallowed by the repository policy",
+			repo:   &model.Repo{AllowedEvents: []string{model.EventPull}},
+			event:  model.EventPush,
+			reason: "event push is not allowed by the repository policy",
+		},
+	}
+
+	for _, tt := range testTable {
+		b := procBuilder{
+			Repo:  tt.repo,
+			Curr:  &model.Build{Event: tt.event},
+			Last:  &model.Build{},
+			Netrc: &model.Netrc{},
+			Secs:  []*model.Secret{},
+			Regs:  []*model.Registry{},
+			Link:  "",
+			Yamls: []*remote.FileMeta{
+				&remote.FileMeta{Name: "test", Data: []byte(`
+pipeline:
+  test:
+    image: golang
+when:
+  event: [ push, pull_request ]
+`)},
+			},
+		}
+
+		result, err := b.Result()
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if tt.reason == "" {
+			if len(result.Skipped) != 0 {
+				t.Errorf("%s: want the pipeline to run, got skipped %v", tt.name, result.Skipped)
+			}
+			continue
+		}
+		if len(result.Skipped) != 1 || result.Skipped[0].Reason != tt.reason {
+			t.Errorf("%s: want the pipeline skipped with %q, got %v", tt.name, tt.reason, result.Skipped)
+		}
+	}
+}
+
+func TestWikiEnabled(t *testing.T) {
+	if wikiEnabled(&model.Repo{}) {
+		t.Errorf("Want wiki builds disabled without an event policy")
+	}
+	if !wikiEnabled(&model.Repo{AllowedEvents: []string{model.EventPush, model.EventWiki}}) {
+		t.Errorf("Want wiki builds enabled by the repository policy")
+	}
+
+	Config.Pipeline.AllowedEvents = []string{model.EventWiki}
+	defer func() { Config.Pipeline.AllowedEvents = nil }()
+	if !wikiEnabled(&model.Repo{}) {
+		t.Errorf("Want wiki builds enabled by the server policy")
+	}
+	if wikiEnabled(&model.Repo{AllowedEvents: []string{model.EventPush}}) {
+		t.Errorf("Want the repository policy to take precedence")
+	}
+}
+
+func TestWikiEventFilter(t *testing.T) {
+	t.Parallel()
+
+	b := procBuilder{
+		Repo:  &model.Repo{AllowedEvents: []string{model.EventPush, model.EventWiki}},
+		Curr:  &model.Build{Event: model.EventWiki, Branch: "main"},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Name: "docs", Data: []byte(`
+pipeline:
+  docs:
+    image: golang
+  publish:
+    image: plugins/docker
+    when:
+      event: push
+when:
+  event: [ push, wiki ]
+`)},
+			&remote.FileMeta{Name: "test", Data: []byte(`
+pipeline:
+  test:
+    image: golang
+when:
+  event: push
+`)},
+		},
+	}
+
+	result, err := b.Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].Name != "test" {
+		t.Fatalf("Want the pipeline of other events skipped, got %v", result.Skipped)
+	}
+	if result.Items[0].Proc.Name != "docs" || result.Items[0].Proc.State == model.StatusSkipped {
+		t.Fatalf("Want the pipeline targeting wiki events built")
+	}
+	stages := result.Items[0].Config.Stages
+	if len(stages) != 2 || stages[1].Alias != "docs" {
+		t.Errorf("Want the steps of other events skipped for a wiki build")
+	}
+}
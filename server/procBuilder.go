@@ -18,16 +18,22 @@ import (
 	"fmt"
 	"math/rand"
 	"net/url"
+	"regexp"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/drone/envsubst"
+	"github.com/sirupsen/logrus"
 	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/backend"
 	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/frontend"
 	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/frontend/yaml"
 	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/frontend/yaml/compiler"
 	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/frontend/yaml/linter"
 	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/frontend/yaml/matrix"
+	"github.com/woodpecker-ci/woodpecker/cncd/queue"
 	"github.com/woodpecker-ci/woodpecker/model"
 	"github.com/woodpecker-ci/woodpecker/remote"
 )
@@ -43,6 +49,33 @@ type procBuilder struct {
 	Link  string
 	Yamls []*remote.FileMeta
 	Envs  map[string]string
+
+	// Params are the parameters of a manually restarted or promoted build.
+	// Unlike Envs they take precedence over the environment of the
+	// pipelines and their steps.
+	Params map[string]string
+
+	// CommitVerified is true if the remote verified the commit signature.
+	CommitVerified bool
+
+	// Prev are the procs of the build being rerun. If set, only the
+	// pipelines that did not succeed and their dependents are built.
+	Prev []*model.Proc
+
+	// File fetches a file of the repository at the build commit, used to
+	// load the environment files of the steps. If nil, environment files
+	// are not loaded.
+	File func(name string) ([]byte, error)
+
+	// ConfigPath is the config path the Yamls were read from, if the config
+	// path of the repository is a template. It defaults to the config path
+	// of the repository.
+	ConfigPath string
+
+	// Prefix replaces the random prefix of the container names, e.g. to
+	// compile reproducible configurations. If empty, a random prefix is
+	// used so builds never share container names.
+	Prefix string
 }
 
 type buildItem struct {
@@ -52,90 +85,576 @@ type buildItem struct {
 	DependsOn []string
 	RunsOn    []string
 	Config    *backend.Config
+
+	// ConcurrencyGroup is the key shared by the runs of this pipeline that
+	// supersede each other, empty if the pipeline sets no concurrency.
+	ConcurrencyGroup string
+	CancelInProgress bool
+
+	// StepCount and ServiceCount are the number of steps and services the
+	// pipeline compiled to, zero if the pipeline is skipped.
+	StepCount    int
+	ServiceCount int
+
+	// ExcludeLabels are the agent labels the pipeline must not run on.
+	ExcludeLabels map[string]string
+
+	// LabelExprs are the expressions the agent labels must match, e.g.
+	// memory > 4G, in place of the equality of Labels.
+	LabelExprs []*queue.LabelExpr
+
+	// RunIfDepsSkipped is true if the pipeline runs even if all the
+	// pipelines it depends on are skipped.
+	RunIfDepsSkipped bool
+
+	// FailFast is true if the failure of the pipeline cancels the other
+	// pipelines of the build. It is set on all the items of a build.
+	FailFast bool
+}
+
+// buildResult is the outcome of compiling the pipelines of a build.
+type buildResult struct {
+	// Items are the compiled pipelines, including the skipped ones.
+	Items []*buildItem
+
+	// Skipped are the pipelines that are skipped or not built at all,
+	// with the reason.
+	Skipped []skippedPipeline
+
+	// Warnings are problems of the pipelines that do not fail the build.
+	Warnings []string
+
+	// MatrixCount is the number of pipelines the matrices expanded to.
+	MatrixCount int
+
+	// Plan are the items in execution levels, the items of a level only
+	// depend on the items of the levels before it.
+	Plan [][]*buildItem
+
+	// ProcCount is the number of procs the items create, the pipelines
+	// and their steps.
+	ProcCount int
+
+	// Notify are the notifications the pipelines of the build declare,
+	// delivered by the server once the build is done.
+	Notify NotifyConfig
 }
 
+// skippedPipeline names a pipeline that is not run and the reason why.
+type skippedPipeline struct {
+	Name   string
+	Reason string
+}
+
+// Build compiles the pipelines of the build. See Result for the reasons
+// pipelines are skipped.
 func (b *procBuilder) Build() ([]*buildItem, error) {
+	result, err := b.Result()
+	if err != nil {
+		return nil, err
+	}
+	return result.Items, nil
+}
+
+// Result compiles the pipelines of the build and reports the pipelines that
+// are skipped and the warnings raised while compiling them. Configuration
+// files are compiled concurrently, the result is the same as compiling them
+// one after the other.
+func (b *procBuilder) Result() (*buildResult, error) {
 	var items []*buildItem
+	result := new(buildResult)
 
-	sort.Sort(remote.ByName(b.Yamls))
+	yamls, err := b.yamls()
+	if err != nil {
+		return nil, err
+	}
 
-	pidSequence := 1
+	names, err := pipelineNames(yamls, b.configPath(), Config.Pipeline.NameCollision, Config.Pipeline.NameTransform)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, y := range b.Yamls {
-		// matrix axes
-		axes, err := matrix.ParseString(string(y.Data))
-		if err != nil {
-			return nil, err
+	allowedEvents, policy := eventPolicy(b.Repo)
+	shared := &buildShared{
+		names:         names,
+		marker:        skipMarker(Config.Pipeline.SkipMarkers, Config.Pipeline.SkipEvents, b.Curr),
+		envFiles:      &envFileCache{files: map[string]map[string]string{}},
+		allowedEvents: allowedEvents,
+		policy:        policy,
+	}
+
+	// the pids of the pipelines of a file follow the pids reserved by the
+	// files before it, one per matrix axis, so the files can be compiled
+	// concurrently with their final pids.
+	pids := make([]int, len(yamls))
+	pid := 1
+	for i, y := range yamls {
+		pids[i] = pid
+		pid += b.pidCount(y)
+	}
+
+	files := make([]*fileResult, len(yamls))
+	sem := make(chan struct{}, buildWorkers)
+	var wg sync.WaitGroup
+	for i, y := range yamls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, y *remote.FileMeta) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			files[i] = b.buildFile(y, i, pids[i], shared)
+		}(i, y)
+	}
+	wg.Wait()
+
+	failFast := false
+	for _, file := range files {
+		if file.err != nil {
+			return nil, file.err
 		}
-		if len(axes) == 0 {
-			axes = append(axes, matrix.Axis{})
+		items = append(items, file.items...)
+		result.Skipped = append(result.Skipped, file.skipped...)
+		result.Warnings = append(result.Warnings, file.warnings...)
+		result.Notify.Targets = append(result.Notify.Targets, file.notify...)
+		result.MatrixCount += file.matrixCount
+		failFast = failFast || file.failFast
+	}
+
+	// fail_fast is a build level option, any running pipeline setting it
+	// applies it to the whole build.
+	for _, item := range items {
+		item.FailFast = failFast
+	}
+
+	filtered := filterItemsWithMissingDependencies(items)
+	for _, item := range items {
+		if !containsItemWithName(item.Proc.Name, filtered) {
+			result.Skipped = append(result.Skipped, skippedPipeline{Name: item.Proc.Name, Reason: "depends on a pipeline that is not built"})
 		}
+	}
+	items = filtered
 
-		for _, axis := range axes {
-			proc := &model.Proc{
-				BuildID: b.Curr.ID,
-				PID:     pidSequence,
-				PGID:    pidSequence,
-				State:   model.StatusPending,
-				Environ: axis,
-				Name:    sanitizePath(y.Name, b.Repo.Config),
+	for _, item := range skipItemsWithSkippedDependencies(items) {
+		result.Skipped = append(result.Skipped, skippedPipeline{Name: item.Proc.Name, Reason: "depends only on skipped pipelines"})
+	}
+
+	if b.Prev != nil {
+		filtered = filterItemsToRerun(items, b.Prev)
+		for _, item := range items {
+			if !containsItemWithPID(item.Proc.PID, filtered) {
+				result.Skipped = append(result.Skipped, skippedPipeline{Name: item.Proc.Name, Reason: "succeeded in the previous build"})
 			}
+		}
+		items = filtered
+	}
 
-			metadata := metadataFromStruct(b.Repo, b.Curr, b.Last, proc, b.Link)
-			environ := b.environmentVariables(metadata, axis)
+	result.Plan, err = executionPlan(items)
+	if err != nil {
+		return nil, err
+	}
 
-			// substitute vars
-			substituted, err := b.envsubst_(string(y.Data), environ)
-			if err != nil {
-				return nil, err
-			}
+	result.ProcCount = countProcs(items)
+	if err := checkProcLimit(result.ProcCount); err != nil {
+		return nil, err
+	}
+	result.Items = items
+	return result, nil
+}
 
-			// parse yaml pipeline
-			parsed, err := yaml.ParseString(substituted)
-			if err != nil {
-				return nil, err
-			}
+// yamls returns the pipeline files of the build in the order they are
+// processed. If enabled, a configuration read from a secret replaces the
+// files of the repository.
+func (b *procBuilder) yamls() ([]*remote.FileMeta, error) {
+	secret, err := secretConfig(b.Secs, b.Curr.Event, Config.Pipeline.ConfigSecret, b.configPath(), Config.Pipeline.ConfigSecretSize)
+	if err != nil {
+		return nil, err
+	}
+	if secret != nil {
+		return []*remote.FileMeta{secret}, nil
+	}
+	return manifestOrder(b.Yamls, b.configPath())
+}
+
+func (b *procBuilder) configPath() string {
+	if b.ConfigPath == "" {
+		return b.Repo.Config
+	}
+	return b.ConfigPath
+}
+
+// buildWorkers is the maximum number of configuration files compiled
+// concurrently.
+var buildWorkers = runtime.NumCPU()
+
+// buildShared holds the state shared by the configuration files of a build.
+type buildShared struct {
+	names         []string
+	marker        string
+	envFiles      *envFileCache
+	allowedEvents []string
+	policy        string
+}
+
+// fileResult holds the pipelines compiled from a configuration file.
+type fileResult struct {
+	items       []*buildItem
+	skipped     []skippedPipeline
+	warnings    []string
+	notify      []NotifyTarget
+	matrixCount int
+	failFast    bool
+	err         error
+}
+
+// buildFile compiles the pipelines of the configuration file at the index,
+// one per matrix axis, numbering them starting at pid.
+func (b *procBuilder) buildFile(y *remote.FileMeta, index, pid int, shared *buildShared) *fileResult {
+	result := new(fileResult)
+
+	// pipelines of components not affected by the changed files are
+	// skipped before parsing them, monorepos do not pay for the pipelines
+	// of the other components. All pipelines are built if the changed
+	// files are not known. As the matrix is not expanded, the file is a
+	// single skipped pipeline and counts as one matrix entry, pipelines
+	// depending on it are skipped as for any skipped pipeline.
+	if !pipelinePaths(y.Data).Match(b.Curr.ChangedFiles, b.Curr.Message) {
+		proc := &model.Proc{
+			BuildID: b.Curr.ID,
+			PID:     pid,
+			PGID:    pid,
+			State:   model.StatusSkipped,
+			Name:    shared.names[index],
+		}
+		result.items = append(result.items, &buildItem{Proc: proc, Config: new(backend.Config)})
+		result.skipped = append(result.skipped, skippedPipeline{Name: proc.Name, Reason: "no changed files match the pipeline paths"})
+		result.matrixCount = 1
+		return result
+	}
+
+	// matrix axes, the axes sourced from topics use the repository topics
+	axes, err := matrix.ParseEventTopics(y.Data, b.Curr.Event, b.Repo.Topics)
+	if err != nil {
+		return &fileResult{err: err}
+	}
+	if len(axes) == 0 {
+		axes = append(axes, matrix.Axis{})
+	}
+	if err := checkMatrixLimit(b.Repo, y.Name, len(axes)); err != nil {
+		return &fileResult{err: err}
+	}
+	result.matrixCount = len(axes)
+
+	for _, axis := range axes {
+		proc := &model.Proc{
+			BuildID: b.Curr.ID,
+			PID:     pid,
+			PGID:    pid,
+			State:   model.StatusPending,
+			Environ: axis,
+			Name:    shared.names[index],
+		}
+
+		metadata := metadataFromStruct(b.Repo, b.Curr, b.Last, proc, b.Link)
+		metadata.Curr.Commit.Verified = b.CommitVerified
+		environ := b.environmentVariables(metadata, axis)
+
+		// substitute vars and parse yaml pipeline
+		parsed, err := b.parse(string(y.Data), environ, axis)
+		if err != nil {
+			return &fileResult{err: err}
+		}
 
-			// lint pipeline
+		// lint pipeline, parse errors are caught above even if linting
+		// is skipped
+		if !skipLint(b.Repo) {
 			lerr := linter.New(
 				linter.WithTrusted(b.Repo.IsTrusted),
 			).Lint(parsed)
 			if lerr != nil {
-				return nil, lerr
+				return &fileResult{err: lerr}
 			}
+		}
+
+		// the pipeline level when only gates on the build event and the
+		// ref of tags, steps are further filtered by their own when
+		// constraints.
+		var reason string
+		switch {
+		case shared.marker != "":
+			reason = fmt.Sprintf("commit message contains %s", shared.marker)
+		case !parsed.Branches.Match(b.Curr.Branch):
+			reason = fmt.Sprintf("branch %s does not match the pipeline branches", b.Curr.Branch)
+		case !eventAllowed(shared.allowedEvents, b.Curr.Event):
+			reason = fmt.Sprintf("event %s is not allowed by the %s policy", b.Curr.Event, shared.policy)
+		case parsed.Manual && b.Curr.Event != model.EventManual:
+			reason = "pipeline only runs for manual builds"
+		case !parsed.When.Event.Match(b.Curr.Event):
+			reason = fmt.Sprintf("event %s does not match the pipeline events", b.Curr.Event)
+		case !tagMatch(parsed.When.Ref, b.Curr):
+			reason = fmt.Sprintf("ref %s does not match the pipeline refs", b.Curr.Ref)
+		case authorIgnored(Config.Pipeline.IgnoreAuthors, b.Curr.Author) && !parsed.AllowIgnoredAuthors:
+			reason = fmt.Sprintf("builds of %s are ignored", b.Curr.Author)
+		}
+		if reason != "" {
+			proc.State = model.StatusSkipped
+			result.skipped = append(result.skipped, skippedPipeline{Name: proc.Name, Reason: reason})
+		}
 
-			if !parsed.Branches.Match(b.Curr.Branch) {
-				proc.State = model.StatusSkipped
+		if proc.State != model.StatusSkipped {
+			result.failFast = result.failFast || parsed.FailFast
+			// the notifications of a matrix are declared once, by its
+			// first pipeline that runs.
+			if result.notify == nil {
+				result.notify = notifyTargets(proc.Name, parsed.Notify)
+			}
+			if err := b.loadEnvFiles(parsed, shared.envFiles); err != nil {
+				return &fileResult{err: err}
 			}
+		}
 
-			metadata.SetPlatform(parsed.Platform)
+		// the platform is only known once the pipeline is parsed, the
+		// variables used for substitution still hold the default.
+		metadata.SetPlatform(parsed.Platform)
+		platform := metadata.Sys.Arch
+		environ["CI_SYSTEM_ARCH"] = platform
+		environ["DRONE_ARCH"] = platform
 
-			ir := b.toInternalRepresentation(parsed, environ, metadata, proc.ID)
+		proc.EnvironSnapshot = environSnapshot(Config.Pipeline.EnvironSnapshot, b.procSecrets(axis), environ, b.Envs, b.Params)
 
-			if len(ir.Stages) == 0 {
-				continue
+		ir, err := b.toInternalRepresentation(parsed, environ, metadata, b.containerPrefix(index, proc))
+		if err != nil {
+			return &fileResult{err: err}
+		}
+
+		if missing := missingSecrets(parsed, metadata, b.Secs); len(missing) != 0 && proc.State != model.StatusSkipped {
+			if Config.Pipeline.FailOnMissingSecrets {
+				return &fileResult{err: fmt.Errorf("Missing secrets %s", strings.Join(missing, ", "))}
+			}
+			warning := fmt.Sprintf("pipeline %s references missing secrets %s", proc.Name, strings.Join(missing, ", "))
+			logrus.Warnf("%s: %s", b.Repo.FullName, warning)
+			result.warnings = append(result.warnings, warning)
+		}
+
+		if undefined := undefinedVariables(string(y.Data), environ); len(undefined) != 0 && proc.State != model.StatusSkipped {
+			switch Config.Pipeline.UndefinedVariables {
+			case UndefinedVariablesFail:
+				return &fileResult{err: fmt.Errorf("Undefined variables %s", strings.Join(undefined, ", "))}
+			case UndefinedVariablesWarn:
+				warning := fmt.Sprintf("pipeline %s references undefined variables %s", proc.Name, strings.Join(undefined, ", "))
+				logrus.Warnf("%s: %s", b.Repo.FullName, warning)
+				result.warnings = append(result.warnings, warning)
 			}
+		}
+
+		if len(ir.Stages) == 0 {
+			if proc.State != model.StatusSkipped {
+				result.skipped = append(result.skipped, skippedPipeline{Name: proc.Name, Reason: "no steps match the build"})
+			}
+			continue
+		}
+
+		labels, labelExprs, err := parseLabels(parsed.Labels)
+		if err != nil {
+			return &fileResult{err: err}
+		}
+
+		item := &buildItem{
+			Proc:      proc,
+			Config:    ir,
+			Labels:    labels,
+			DependsOn: parsed.DependsOn,
+			RunsOn:    parsed.RunsOn,
+			Platform:  platform,
+
+			ConcurrencyGroup: concurrencyGroup(parsed.Concurrency, b.Repo, b.Curr, proc.Name),
+			CancelInProgress: parsed.Concurrency.CancelInProgress,
+
+			ExcludeLabels:    parsed.ExcludeLabels,
+			LabelExprs:       labelExprs,
+			RunIfDepsSkipped: parsed.RunIfDepsSkipped,
+		}
+		if proc.State != model.StatusSkipped {
+			item.StepCount, item.ServiceCount = countSteps(ir)
+		}
+
+		result.items = append(result.items, item)
+		pid++
+	}
+	return result
+}
+
+// pidCount returns the number of pids reserved by the configuration file,
+// one per matrix axis. A matrix that cannot be parsed reserves one, its
+// errors are reported when compiling the file. Matrix entries without steps
+// leave their pid unused.
+func (b *procBuilder) pidCount(y *remote.FileMeta) int {
+	if !pipelinePaths(y.Data).Match(b.Curr.ChangedFiles, b.Curr.Message) {
+		return 1
+	}
+	axes, err := matrix.ParseEventTopics(y.Data, b.Curr.Event, b.Repo.Topics)
+	if err != nil || len(axes) == 0 {
+		return 1
+	}
+	return len(axes)
+}
+
+// pipelinePaths returns the paths of the pipeline, read from the raw
+// configuration without substituting variables. A configuration that cannot
+// be read matches all the paths, its errors are reported when parsing it.
+func pipelinePaths(data []byte) *yaml.ConstraintPath {
+	paths, err := yaml.ParsePaths(data)
+	if err != nil {
+		return new(yaml.ConstraintPath)
+	}
+	return paths
+}
+
+// parseLabels splits the labels of a pipeline into the labels matched by
+// equality and the label expressions, e.g. memory: "> 4G", sorted by key.
+func parseLabels(labels map[string]string) (map[string]string, []*queue.LabelExpr, error) {
+	plain := map[string]string{}
+	var exprs []*queue.LabelExpr
+	for k, v := range labels {
+		expr, err := queue.ParseLabelExpr(k, v)
+		if err != nil {
+			return nil, nil, err
+		}
+		if expr == nil {
+			plain[k] = v
+			continue
+		}
+		exprs = append(exprs, expr)
+	}
+	sort.Slice(exprs, func(i, j int) bool {
+		return exprs[i].Key < exprs[j].Key
+	})
+	return plain, exprs, nil
+}
+
+// missingSecrets returns the sorted names of the secrets requested by the
+// steps matching the build metadata that do not exist. Secrets that exist
+// but are not exposed to the build event are not reported, as pipelines
+// commonly run without secrets for some events.
+func missingSecrets(parsed *yaml.Config, metadata frontend.Metadata, secs []*model.Secret) []string {
+	exists := map[string]bool{}
+	for _, sec := range secs {
+		exists[strings.ToLower(sec.Name)] = true
+	}
 
-			item := &buildItem{
-				Proc:      proc,
-				Config:    ir,
-				Labels:    parsed.Labels,
-				DependsOn: parsed.DependsOn,
-				RunsOn:    parsed.RunsOn,
-				Platform:  metadata.Sys.Arch,
+	seen := map[string]bool{}
+	var missing []string
+	for _, containers := range [][]*yaml.Container{
+		parsed.Clone.Containers,
+		parsed.Services.Containers,
+		parsed.Pipeline.Containers,
+	} {
+		for _, container := range containers {
+			if !container.Constraints.Match(metadata) {
+				continue
 			}
-			if item.Labels == nil {
-				item.Labels = map[string]string{}
+			for _, requested := range container.Secrets.Secrets {
+				name := strings.ToLower(requested.Source)
+				if exists[name] || seen[name] {
+					continue
+				}
+				seen[name] = true
+				missing = append(missing, requested.Source)
 			}
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
 
-			items = append(items, item)
-			pidSequence++
+// countSteps returns the number of steps and services of the compiled
+// pipeline.
+func countSteps(ir *backend.Config) (steps, services int) {
+	for _, stage := range ir.Stages {
+		if strings.HasSuffix(stage.Name, "_services") {
+			services += len(stage.Steps)
+		} else {
+			steps += len(stage.Steps)
 		}
 	}
+	return steps, services
+}
+
+// concurrencyGroup returns the concurrency group key of the pipeline. The
+// group defaults to the build branch and is scoped to the repository and
+// pipeline name.
+func concurrencyGroup(conf yaml.Concurrency, repo *model.Repo, build *model.Build, name string) string {
+	if conf.Group == "" && !conf.CancelInProgress {
+		return ""
+	}
+	group := conf.Group
+	if group == "" {
+		group = build.Branch
+	}
+	return fmt.Sprintf("%s/%s/%s", repo.FullName, name, group)
+}
+
+// executionPlan sorts the items into execution levels by their
+// dependencies. Items depend on all the items with the names they depend
+// on, e.g. all the pipelines of a matrix. Dependencies on items that are
+// not built are ignored, and cyclic dependencies are rejected.
+func executionPlan(items []*buildItem) ([][]*buildItem, error) {
+	byName := map[string][]*buildItem{}
+	for _, item := range items {
+		byName[item.Proc.Name] = append(byName[item.Proc.Name], item)
+	}
 
-	items = filterItemsWithMissingDependencies(items)
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := map[*buildItem]int{}
+	level := map[*buildItem]int{}
+	var stack []string
+	var visit func(item *buildItem) error
+	visit = func(item *buildItem) error {
+		switch state[item] {
+		case visited:
+			return nil
+		case visiting:
+			for i, name := range stack {
+				if name == item.Proc.Name {
+					cycle := append(append([]string{}, stack[i:]...), name)
+					return fmt.Errorf("Pipeline %s has a cyclic dependency %s", name, strings.Join(cycle, " -> "))
+				}
+			}
+		}
+		state[item] = visiting
+		stack = append(stack, item.Proc.Name)
+		for _, dep := range item.DependsOn {
+			for _, d := range byName[dep] {
+				if err := visit(d); err != nil {
+					return err
+				}
+				if level[d]+1 > level[item] {
+					level[item] = level[d] + 1
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[item] = visited
+		return nil
+	}
 
-	return items, nil
+	var plan [][]*buildItem
+	for _, item := range items {
+		if err := visit(item); err != nil {
+			return nil, err
+		}
+		for len(plan) <= level[item] {
+			plan = append(plan, nil)
+		}
+	}
+	for _, item := range items {
+		plan[level[item]] = append(plan[level[item]], item)
+	}
+	return plan, nil
 }
 
 func filterItemsWithMissingDependencies(items []*buildItem) []*buildItem {
@@ -163,6 +682,122 @@ func filterItemsWithMissingDependencies(items []*buildItem) []*buildItem {
 	return items
 }
 
+// skipItemsWithSkippedDependencies marks the items whose dependencies are
+// all skipped as skipped, unless the pipeline runs if its dependencies are
+// skipped. Unlike a missing dependency, a skipped dependency does not drop
+// the item, it is kept as a skipped pipeline. The newly skipped items are
+// returned.
+func skipItemsWithSkippedDependencies(items []*buildItem) []*buildItem {
+	var skipped []*buildItem
+	for changed := true; changed; {
+		changed = false
+		for _, item := range items {
+			if item.Proc.State == model.StatusSkipped || item.RunIfDepsSkipped || len(item.DependsOn) == 0 {
+				continue
+			}
+			if !allDependenciesSkipped(item, items) {
+				continue
+			}
+			item.Proc.State = model.StatusSkipped
+			item.StepCount, item.ServiceCount = 0, 0
+			skipped = append(skipped, item)
+			// Repeat to handle transitive deps
+			changed = true
+		}
+	}
+	return skipped
+}
+
+func allDependenciesSkipped(item *buildItem, items []*buildItem) bool {
+	for _, dep := range item.DependsOn {
+		for _, other := range items {
+			if other.Proc.Name == dep && other.Proc.State != model.StatusSkipped {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// tagMatch returns true if the tag of a tag build matches the ref
+// constraint. Patterns are globs matched against both the full ref, e.g.
+// refs/tags/v1.0.0, and the tag name, e.g. v1.0.0. Other events always
+// match.
+func tagMatch(ref yaml.Constraint, build *model.Build) bool {
+	if build.Event != model.EventTag {
+		return true
+	}
+	tag := strings.TrimPrefix(build.Ref, "refs/tags/")
+	if ref.Excludes(build.Ref) || ref.Excludes(tag) {
+		return false
+	}
+	return len(ref.Include) == 0 || ref.Includes(build.Ref) || ref.Includes(tag)
+}
+
+// filterItemsToRerun returns the items whose pipeline did not succeed in
+// the previous build, together with the items depending on them. Pipelines
+// are matched by pid, which is stable as long as the configuration is.
+func filterItemsToRerun(items []*buildItem, prev []*model.Proc) []*buildItem {
+	succeeded := map[int]bool{}
+	for _, proc := range prev {
+		if proc.PPID == 0 && proc.State == model.StatusSuccess {
+			succeeded[proc.PID] = true
+		}
+	}
+	// pipelines cannot be matched if the names moved to another pid.
+	for _, proc := range prev {
+		for _, item := range items {
+			if proc.PPID == 0 && proc.PID == item.Proc.PID && proc.Name != item.Proc.Name {
+				return items
+			}
+		}
+	}
+
+	rerun := map[int]bool{}
+	rerunNames := map[string]bool{}
+	for _, item := range items {
+		if !succeeded[item.Proc.PID] {
+			rerun[item.Proc.PID] = true
+			rerunNames[item.Proc.Name] = true
+		}
+	}
+	// dependents are added until no further pipeline is affected, so
+	// transitive dependencies are rerun too.
+	for changed := true; changed; {
+		changed = false
+		for _, item := range items {
+			if rerun[item.Proc.PID] {
+				continue
+			}
+			for _, dep := range item.DependsOn {
+				if rerunNames[dep] {
+					rerun[item.Proc.PID] = true
+					rerunNames[item.Proc.Name] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	filtered := make([]*buildItem, 0)
+	for _, item := range items {
+		if rerun[item.Proc.PID] {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
+
+func containsItemWithPID(pid int, items []*buildItem) bool {
+	for _, item := range items {
+		if pid == item.Proc.PID {
+			return true
+		}
+	}
+	return false
+}
+
 func containsItemWithName(name string, items []*buildItem) bool {
 	for _, item := range items {
 		if name == item.Proc.Name {
@@ -172,9 +807,43 @@ func containsItemWithName(name string, items []*buildItem) bool {
 	return false
 }
 
-func (b *procBuilder) envsubst_(y string, environ map[string]string) (string, error) {
+// parse substitutes the environment variables in the yaml and parses it.
+// The workspace variables reflect the compiled workspace, so the yaml is
+// substituted once more if it overrides the default workspace.
+func (b *procBuilder) parse(y string, environ map[string]string, axis matrix.Axis) (*yaml.Config, error) {
+	c := compiler.New(compiler.WithWorkspaceFromURL("/drone", b.Repo.Link))
+	parsed, err := b.parseWorkspace(y, environ, axis, c.Workspace(new(yaml.Config)))
+	if err != nil {
+		return nil, err
+	}
+	if workspace := c.Workspace(parsed); workspace != environ["CI_WORKSPACE"] {
+		return b.parseWorkspace(y, environ, axis, workspace)
+	}
+	return parsed, nil
+}
+
+func (b *procBuilder) parseWorkspace(y string, environ map[string]string, axis matrix.Axis, workspace string) (*yaml.Config, error) {
+	environ["CI_WORKSPACE"] = workspace
+	environ["DRONE_WORKSPACE"] = workspace
+
+	substituted, err := b.envsubst_(y, environ, axis)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.ParseString(substituted)
+}
+
+// envsubst_ substitutes the environment variables in the yaml. Multiline
+// values are quoted, except matrix values that are numbers or booleans,
+// which are substituted as bare scalars.
+func (b *procBuilder) envsubst_(y string, environ map[string]string, axis matrix.Axis) (string, error) {
 	return envsubst.Eval(y, func(name string) string {
 		env := environ[name]
+		if _, ok := axis[name]; ok {
+			if scalar, ok := axisScalar(env); ok {
+				return scalar
+			}
+		}
 		if strings.Contains(env, "\n") {
 			env = fmt.Sprintf("%q", env)
 		}
@@ -182,26 +851,158 @@ func (b *procBuilder) envsubst_(y string, environ map[string]string) (string, er
 	})
 }
 
+// axisScalar returns the matrix value without surrounding whitespace if it
+// is a number or a boolean, e.g. a port defined as a block with a trailing
+// newline, so it is not quoted as a string.
+func axisScalar(value string) (string, bool) {
+	trimmed := strings.TrimSpace(value)
+	if _, err := strconv.ParseFloat(trimmed, 64); err == nil {
+		return trimmed, true
+	}
+	switch trimmed {
+	case "true", "True", "TRUE", "false", "False", "FALSE":
+		return trimmed, true
+	}
+	return value, false
+}
+
+// branchEnviron returns the environment variables the repository defines
+// for the branch. The rules are applied in order, later matching rules
+// override the variables of earlier ones.
+func branchEnviron(repo *model.Repo, branch string) map[string]string {
+	environ := map[string]string{}
+	for _, rule := range repo.BranchEnviron {
+		if !rule.Match(branch) {
+			continue
+		}
+		for k, v := range rule.Environ {
+			environ[k] = v
+		}
+	}
+	return environ
+}
+
+// skipLint returns true if the pipelines of the repository are not linted.
+// Linting can only be skipped for trusted repositories, either for all of
+// them by the server or for a single repository by an admin.
+func skipLint(repo *model.Repo) bool {
+	return repo.IsTrusted && (repo.SkipLint || Config.Pipeline.SkipLintTrusted)
+}
+
+// checkMatrixLimit returns an error if the matrix of the pipeline expands to
+// more pipelines than allowed. The repository limit, set by an admin,
+// overrides the server limit. A limit of zero means no limit.
+func checkMatrixLimit(repo *model.Repo, name string, count int) error {
+	limit, source := Config.Pipeline.MatrixLimit, "server"
+	if repo.MatrixLimit > 0 {
+		limit, source = repo.MatrixLimit, "repository"
+	}
+	if limit > 0 && count > limit {
+		return fmt.Errorf("matrix of %s expands to %d pipelines, exceeding the %s limit of %d", name, count, source, limit)
+	}
+	return nil
+}
+
+// countProcs returns the number of procs setBuildStepsOnBuild creates for
+// the items, one per pipeline and one per step, skipped or not.
+func countProcs(items []*buildItem) int {
+	count := len(items)
+	for _, item := range items {
+		for _, stage := range item.Config.Stages {
+			count += len(stage.Steps)
+		}
+	}
+	return count
+}
+
+// checkProcLimit returns an error if the build creates more procs than
+// allowed by the server, so huge builds do not stress the database. A limit
+// of zero means no limit.
+func checkProcLimit(count int) error {
+	limit := Config.Pipeline.ProcLimit
+	if limit > 0 && count > limit {
+		return fmt.Errorf("build creates %d procs, pipelines and their steps, exceeding the server limit of %d", count, limit)
+	}
+	return nil
+}
+
+// environmentVariables returns the environment variables of the build. The
+// branch environment of the repository is applied first, so it can neither
+// shadow the build metadata nor the matrix variables.
 func (b *procBuilder) environmentVariables(metadata frontend.Metadata, axis matrix.Axis) map[string]string {
-	environ := metadata.Environ()
+	environ := branchEnviron(b.Repo, metadata.Curr.Commit.Branch)
+	for k, v := range metadata.Environ() {
+		environ[k] = v
+	}
 	for k, v := range metadata.EnvironDrone() {
 		environ[k] = v
 	}
 	for k, v := range axis {
 		environ[k] = v
 	}
+	if Config.Pipeline.TraceContext {
+		environ["TRACEPARENT"] = traceParent(b.Repo, b.Curr)
+	}
 	return environ
 }
 
-func (b *procBuilder) toInternalRepresentation(parsed *yaml.Config, environ map[string]string, metadata frontend.Metadata, procID int64) *backend.Config {
-	var secrets []compiler.Secret
+// reservedEnviron returns true if the environment variable is in the
+// namespace of the built-in build metadata.
+func reservedEnviron(name string) bool {
+	return strings.HasPrefix(name, "CI_") || strings.HasPrefix(name, "DRONE_")
+}
+
+// procSecrets returns the secrets exposed to the pipeline of the matrix axis.
+func (b *procBuilder) procSecrets(axis matrix.Axis) []*model.Secret {
+	var secs []*model.Secret
+	for _, sec := range b.Secs {
+		if sec.Match(b.Curr.Event) && sec.MatchAxis(axis) {
+			secs = append(secs, sec)
+		}
+	}
+	return secs
+}
+
+// containerPrefix returns the prefix of the container names of the pipeline
+// compiled for the proc from the configuration file at the index.
+func (b *procBuilder) containerPrefix(index int, proc *model.Proc) string {
+	if b.Prefix != "" {
+		return fmt.Sprintf("%s_%d_%d", b.Prefix, index, proc.PID)
+	}
+	return fmt.Sprintf("%d_%d", proc.ID, rand.Int())
+}
+
+func (b *procBuilder) toInternalRepresentation(parsed *yaml.Config, environ map[string]string, metadata frontend.Metadata, prefix string) (*backend.Config, error) {
+	var secs []*model.Secret
 	for _, sec := range b.Secs {
-		if !sec.Match(b.Curr.Event) {
+		if !sec.Match(b.Curr.Event) || !sec.MatchAxis(metadata.Job.Matrix) {
 			continue
 		}
+		// secrets are injected as environment variables and would silently
+		// shadow the build metadata. Matrix and branch variables are not
+		// reserved.
+		if name := strings.ToUpper(sec.Name); reservedEnviron(name) {
+			if _, ok := environ[name]; ok {
+				return nil, fmt.Errorf("Secret %s collides with a reserved environment variable", sec.Name)
+			}
+		}
+		secs = append(secs, sec)
+	}
+
+	templates, err := resolveSecretTemplates(secs, environ, b.Envs, b.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	var secrets []compiler.Secret
+	for _, sec := range secs {
+		value := sec.Value
+		if sec.Template {
+			value = templates[sec.Name]
+		}
 		secrets = append(secrets, compiler.Secret{
 			Name:  sec.Name,
-			Value: sec.Value,
+			Value: value,
 			Match: sec.Images,
 		})
 	}
@@ -216,9 +1017,54 @@ func (b *procBuilder) toInternalRepresentation(parsed *yaml.Config, environ map[
 		})
 	}
 
-	return compiler.New(
-		compiler.WithEnviron(environ),
-		compiler.WithEnviron(b.Envs),
+	// secrets can hold registry credentials as well, registries take
+	// precedence over secrets for the same address.
+	for _, sec := range b.Secs {
+		username, password, ok := sec.RegistryCredentials()
+		if !ok || !sec.Match(b.Curr.Event) || !sec.MatchAxis(metadata.Job.Matrix) || hasRegistry(b.Regs, sec.Registry) {
+			continue
+		}
+		registries = append(registries, compiler.Registry{
+			Hostname: sec.Registry,
+			Username: username,
+			Password: password,
+		})
+	}
+
+	// the store does not guarantee an order, sort secrets and registries
+	// so the compiled configuration is reproducible.
+	sort.SliceStable(secrets, func(i, j int) bool {
+		return secrets[i].Name < secrets[j].Name
+	})
+	sort.SliceStable(registries, func(i, j int) bool {
+		if registries[i].Hostname != registries[j].Hostname {
+			return registries[i].Hostname < registries[j].Hostname
+		}
+		return registries[i].Username < registries[j].Username
+	})
+
+	// the branch and global environment are defaults the pipeline and its
+	// steps can override, the global environment winning over the branch
+	// environment. The build metadata, matrix variables and build parameters
+	// override the pipeline, the parameters winning. Global variables the
+	// server lists as overrides replace all but the build metadata.
+	branch := branchEnviron(b.Repo, metadata.Curr.Commit.Branch)
+	builtin := map[string]string{}
+	for k, v := range environ {
+		if value, ok := branch[k]; !ok || value != v {
+			builtin[k] = v
+		}
+	}
+	globals, overrides := splitGlobalEnvirons(b.Envs, Config.Pipeline.EnvironOverride, builtin)
+
+	lfs := cloneLFS(parsed.CloneOpts.LFS, Config.Pipeline.CloneLFS)
+
+	config := compiler.New(
+		compiler.WithDefaultEnviron(branch),
+		compiler.WithDefaultEnviron(globals),
+		compiler.WithEnviron(builtin),
+		compiler.WithEnviron(b.Params),
+		compiler.WithOverrideEnviron(overrides),
 		compiler.WithEscalated(Config.Pipeline.Privileged...),
 		compiler.WithResourceLimit(Config.Pipeline.Limits.MemSwapLimit, Config.Pipeline.Limits.MemLimit, Config.Pipeline.Limits.ShmSize, Config.Pipeline.Limits.CPUQuota, Config.Pipeline.Limits.CPUShares, Config.Pipeline.Limits.CPUSet),
 		compiler.WithVolumes(Config.Pipeline.Volumes...),
@@ -232,19 +1078,64 @@ func (b *procBuilder) toInternalRepresentation(parsed *yaml.Config, environ map[
 			),
 			b.Repo.IsPrivate,
 		),
-		compiler.WithRegistry(registries...),
-		compiler.WithSecret(secrets...),
-		compiler.WithPrefix(
-			fmt.Sprintf(
-				"%d_%d",
-				procID,
-				rand.Int(),
+		// the lfs endpoint may require authentication for public
+		// repositories too, the netrc is limited to the clone steps.
+		compiler.WithOption(
+			compiler.WithCloneNetrc(
+				b.Netrc.Login,
+				b.Netrc.Password,
+				b.Netrc.Machine,
 			),
+			!b.Repo.IsPrivate && lfs,
 		),
+		compiler.WithRegistry(registries...),
+		compiler.WithRegistryMirror(Config.Pipeline.RegistryMirror, Config.Pipeline.MirroredRegistries...),
+		compiler.WithSecret(secrets...),
+		compiler.WithPrefix(prefix),
 		compiler.WithProxy(),
 		compiler.WithWorkspaceFromURL("/drone", b.Repo.Link),
+		compiler.WithCloneDepth(cloneDepth(parsed.CloneOpts.Depth, Config.Pipeline.CloneMaxDepth)),
+		compiler.WithCloneTags(parsed.CloneOpts.Tags),
+		compiler.WithCloneLFS(lfs),
+		compiler.WithSkipClone(!parsed.CloneOpts.When.Match(metadata)),
+		compiler.WithCacheVolumes(Config.Pipeline.CacheVolumes && b.Repo.IsTrusted),
+		compiler.WithCloneEnviron(Config.Pipeline.CloneEnviron),
 		compiler.WithMetadata(metadata),
 	).Compile(parsed)
+	config.Secrets = appendRedactions(config.Secrets)
+	return config, nil
+}
+
+// hasRegistry returns true if the registry address is in the list.
+func hasRegistry(regs []*model.Registry, address string) bool {
+	for _, reg := range regs {
+		if reg.Address == address {
+			return true
+		}
+	}
+	return false
+}
+
+// cloneDepth returns the depth of the default clone step. A zero depth
+// clones the full history unless the server limits the clone depth.
+func cloneDepth(requested *int, max int) int {
+	depth := max
+	if requested != nil {
+		depth = *requested
+	}
+	if max > 0 && (depth <= 0 || depth > max) {
+		depth = max
+	}
+	return depth
+}
+
+// cloneLFS returns true if the default clone step fetches the git lfs
+// objects, the pipeline setting takes precedence over the server default.
+func cloneLFS(requested *bool, def bool) bool {
+	if requested != nil {
+		return *requested
+	}
+	return def
 }
 
 func setBuildStepsOnBuild(build *model.Build, buildItems []*buildItem) *model.Build {
@@ -259,6 +1150,10 @@ func setBuildStepsOnBuild(build *model.Build, buildItems []*buildItem) *model.Bu
 	for _, item := range buildItems {
 		for _, stage := range item.Config.Stages {
 			var gid int
+			name := stage.DisplayName
+			if name == "" {
+				name = stage.Alias
+			}
 			for _, step := range stage.Steps {
 				pidSequence++
 				if gid == 0 {
@@ -271,6 +1166,7 @@ func setBuildStepsOnBuild(build *model.Build, buildItems []*buildItem) *model.Bu
 					PPID:    item.Proc.PID,
 					PGID:    gid,
 					State:   model.StatusPending,
+					Stage:   name,
 				}
 				if item.Proc.State == model.StatusSkipped {
 					proc.State = model.StatusSkipped
@@ -358,6 +1254,88 @@ func metadataFromStruct(repo *model.Repo, build, last *model.Build, proc *model.
 	}
 }
 
+// Pipeline name collision policies, see pipelineNames.
+const (
+	NameCollisionAllow        = "allow"
+	NameCollisionFail         = "fail"
+	NameCollisionDisambiguate = "disambiguate"
+)
+
+// NameTransform rewrites the pipeline names derived from the configuration
+// file names, replacing the matches of the pattern with the replacement,
+// e.g. to strip a common prefix of the files.
+type NameTransform struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// apply returns the transformed name, or the name if no transform is set.
+func (t *NameTransform) apply(name string) string {
+	if t == nil || t.Pattern == nil {
+		return name
+	}
+	return t.Pattern.ReplaceAllString(name, t.Replacement)
+}
+
+// validPipelineName matches the names a name transform may produce, names
+// that can be referenced by depends_on.
+var validPipelineName = regexp.MustCompile(`^[\w.\-/]+$`)
+
+// pipelineNames returns the names of the pipelines of the configuration
+// files. Two files may sanitize to the same name, e.g. .woodpecker/ci.yml
+// and .woodpecker/.ci.yml, which makes depends_on ambiguous. By default the
+// pipelines share the name, the fail policy fails the build and with the
+// disambiguate policy the first file keeps the name while the others are
+// named by their path instead. The transform, if set, is applied to the
+// sanitized names and must result in valid names.
+func pipelineNames(yamls []*remote.FileMeta, folder, collision string, transform *NameTransform) ([]string, error) {
+	names := make([]string, len(yamls))
+	files := map[string][]int{}
+	for i, y := range yamls {
+		names[i] = transform.apply(sanitizePath(y.Name, folder))
+		files[names[i]] = append(files[names[i]], i)
+	}
+	if transform != nil {
+		for i, name := range names {
+			if !validPipelineName.MatchString(name) {
+				return nil, fmt.Errorf("Pipeline %s is renamed to %q by the name transform, which is not a valid pipeline name", yamls[i].Name, name)
+			}
+		}
+	}
+
+	if collision != NameCollisionFail && collision != NameCollisionDisambiguate {
+		return names, nil
+	}
+	for i := range yamls {
+		indexes := files[transform.apply(sanitizePath(yamls[i].Name, folder))]
+		if len(indexes) < 2 {
+			continue
+		}
+		if collision == NameCollisionFail {
+			var paths []string
+			for _, j := range indexes {
+				paths = append(paths, yamls[j].Name)
+			}
+			return nil, fmt.Errorf("Pipelines %s share the name %s, rename the files to tell them apart", strings.Join(paths, ", "), names[i])
+		}
+		if indexes[0] != i {
+			names[i] = strings.TrimSuffix(yamls[i].Name, ".yml")
+		}
+	}
+
+	// the paths of files are unique, but a path may still collide with the
+	// sanitized name of another file, or the path of a file without the
+	// yml suffix.
+	seen := map[string]string{}
+	for i, name := range names {
+		if other, ok := seen[name]; ok {
+			return nil, fmt.Errorf("Pipelines %s and %s share the name %s, rename the files to tell them apart", other, yamls[i].Name, name)
+		}
+		seen[name] = yamls[i].Name
+	}
+	return names, nil
+}
+
 func sanitizePath(path string, configFolder string) string {
 	path = strings.TrimSuffix(path, ".yml")
 	path = strings.TrimPrefix(path, configFolder)
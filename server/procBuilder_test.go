@@ -15,9 +15,22 @@
 package server
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/backend"
+	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/frontend/yaml/matrix"
 	"github.com/woodpecker-ci/woodpecker/model"
 	"github.com/woodpecker-ci/woodpecker/remote"
 )
@@ -58,6 +71,43 @@ pipeline:
 	}
 }
 
+func TestMatrixTypedEnvsubst(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"number", "8080", "port: 8080"},
+		{"number block", "8080\n", "port: 8080"},
+		{"float block", "1.10\n", "port: 1.10"},
+		{"boolean block", "true\n", "port: true"},
+		{"string", "http", "port: http"},
+		{"string block", "http\n", `port: "http\n"`},
+	}
+	b := procBuilder{}
+	for _, tt := range tests {
+		axis := matrix.Axis{"PORT": tt.value}
+		got, err := b.envsubst_("port: ${PORT}", map[string]string{"PORT": tt.value}, axis)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: want %s, got %s", tt.name, tt.want, got)
+		}
+	}
+
+	// only matrix values are substituted as scalars
+	got, err := b.envsubst_("msg: ${MSG}", map[string]string{"MSG": "42\n"}, matrix.Axis{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != `msg: "42\n"` {
+		t.Errorf("Want the multiline variable quoted, got %s", got)
+	}
+}
+
 func TestMultiPipeline(t *testing.T) {
 	t.Parallel()
 
@@ -138,6 +188,74 @@ depends_on:
 	}
 }
 
+func TestRerunFailedPipelines(t *testing.T) {
+	t.Parallel()
+
+	b := procBuilder{
+		Repo:  &model.Repo{},
+		Curr:  &model.Build{},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Link:  "",
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Name: "build", Data: []byte(`
+pipeline:
+  build:
+    image: scratch
+`)},
+			&remote.FileMeta{Name: "deploy", Data: []byte(`
+pipeline:
+  deploy:
+    image: scratch
+
+depends_on:
+  - test
+`)},
+			&remote.FileMeta{Name: "lint", Data: []byte(`
+pipeline:
+  lint:
+    image: scratch
+`)},
+			&remote.FileMeta{Name: "notify", Data: []byte(`
+pipeline:
+  notify:
+    image: scratch
+
+depends_on:
+  - deploy
+`)},
+			&remote.FileMeta{Name: "test", Data: []byte(`
+pipeline:
+  test:
+    image: scratch
+`)},
+		},
+		Prev: []*model.Proc{
+			{PID: 1, Name: "build", State: model.StatusSuccess},
+			{PID: 2, Name: "deploy", State: model.StatusSkipped},
+			{PID: 3, Name: "lint", State: model.StatusSuccess},
+			{PID: 4, Name: "notify", State: model.StatusSkipped},
+			{PID: 5, Name: "test", State: model.StatusFailure},
+			{PID: 6, PPID: 5, Name: "test", State: model.StatusFailure},
+		},
+	}
+
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, item := range buildItems {
+		names = append(names, item.Proc.Name)
+	}
+	if len(names) != 3 || names[0] != "deploy" || names[1] != "notify" || names[2] != "test" {
+		t.Errorf("Should only rerun the failed pipeline and its dependents, got %v", names)
+	}
+}
+
 func TestRunsOn(t *testing.T) {
 	t.Parallel()
 
@@ -393,3 +511,3107 @@ pipeline:
 		t.Fatal("Build step should be a children of the stage")
 	}
 }
+
+func TestTreeStageNames(t *testing.T) {
+	t.Parallel()
+
+	build := &model.Build{}
+
+	b := procBuilder{
+		Repo:  &model.Repo{},
+		Curr:  build,
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Data: []byte(`
+pipeline:
+  backend:
+    image: golang
+    group: test
+  frontend:
+    image: node
+    group: test
+  publish:
+    image: plugins/docker
+stages:
+  test: Unit tests
+`)},
+		},
+	}
+
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	build = setBuildStepsOnBuild(build, buildItems)
+
+	stages := map[string]string{}
+	groups := map[string]int{}
+	for _, proc := range build.Procs[1:] {
+		stages[proc.Name] = proc.Stage
+		groups[proc.Name] = proc.PGID
+	}
+	want := map[string]string{
+		"clone":    "clone",
+		"backend":  "Unit tests",
+		"frontend": "Unit tests",
+		"publish":  "publish",
+	}
+	if !reflect.DeepEqual(stages, want) {
+		t.Errorf("Want the stage names %v, got %v", want, stages)
+	}
+	if groups["backend"] != groups["frontend"] || groups["backend"] == groups["publish"] {
+		t.Errorf("Want the steps of a stage grouped, got %v", groups)
+	}
+	if build.Procs[0].Stage != "" {
+		t.Errorf("Want no stage on the pipeline proc, got %s", build.Procs[0].Stage)
+	}
+}
+
+func TestPipelineEventFilter(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name    string
+		event   string
+		ref     string
+		when    string
+		skipped bool
+	}{
+		{
+			name:    "Matching event",
+			event:   model.EventPush,
+			when:    "when:\n  event: [push, pull_request]\n",
+			skipped: false,
+		},
+		{
+			name:    "Non-matching event",
+			event:   model.EventTag,
+			when:    "when:\n  event: [push, pull_request]\n",
+			skipped: true,
+		},
+		{
+			name:    "Default matches all events",
+			event:   model.EventDeploy,
+			when:    "",
+			skipped: false,
+		},
+		{
+			name:    "Matching tag name",
+			event:   model.EventTag,
+			ref:     "refs/tags/v1.0.0",
+			when:    "when:\n  event: tag\n  ref: v*\n",
+			skipped: false,
+		},
+		{
+			name:    "Matching tag ref",
+			event:   model.EventTag,
+			ref:     "refs/tags/v1.0.0",
+			when:    "when:\n  ref: refs/tags/v*\n",
+			skipped: false,
+		},
+		{
+			name:    "Non-matching tag",
+			event:   model.EventTag,
+			ref:     "refs/tags/nightly",
+			when:    "when:\n  event: tag\n  ref: v*\n",
+			skipped: true,
+		},
+		{
+			name:    "Excluded tag",
+			event:   model.EventTag,
+			ref:     "refs/tags/v1.0.0-rc1",
+			when:    "when:\n  ref:\n    exclude: [ v*-rc* ]\n",
+			skipped: true,
+		},
+		{
+			name:    "Ref does not filter other events",
+			event:   model.EventPush,
+			ref:     "refs/heads/master",
+			when:    "when:\n  ref: v*\n",
+			skipped: false,
+		},
+	}
+
+	for _, tt := range testTable {
+		b := procBuilder{
+			Repo:  &model.Repo{},
+			Curr:  &model.Build{Event: tt.event, Ref: tt.ref},
+			Last:  &model.Build{},
+			Netrc: &model.Netrc{},
+			Secs:  []*model.Secret{},
+			Regs:  []*model.Registry{},
+			Link:  "",
+			Yamls: []*remote.FileMeta{
+				&remote.FileMeta{Data: []byte(`
+pipeline:
+  build:
+    image: scratch
+` + tt.when)},
+			},
+		}
+
+		buildItems, err := b.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(buildItems) != 1 {
+			t.Fatalf("%s: should have generated 1 buildItem", tt.name)
+		}
+		if skipped := buildItems[0].Proc.State == model.StatusSkipped; skipped != tt.skipped {
+			t.Fatalf("%s: expected skipped to be %v", tt.name, tt.skipped)
+		}
+	}
+}
+
+func TestCloneDepth(t *testing.T) {
+	t.Parallel()
+
+	zero, hundred := 0, 100
+
+	testTable := []struct {
+		name      string
+		requested *int
+		max       int
+		expected  int
+	}{
+		{name: "Default is a full clone", requested: nil, max: 0, expected: 0},
+		{name: "Depth 0 is a full clone", requested: &zero, max: 0, expected: 0},
+		{name: "Depth is honored", requested: &hundred, max: 0, expected: 100},
+		{name: "Default is the server max", requested: nil, max: 50, expected: 50},
+		{name: "Full clone is limited by the server max", requested: &zero, max: 50, expected: 50},
+		{name: "Depth is limited by the server max", requested: &hundred, max: 50, expected: 50},
+	}
+
+	for _, tt := range testTable {
+		if depth := cloneDepth(tt.requested, tt.max); depth != tt.expected {
+			t.Errorf("%s: want depth %d, got %d", tt.name, tt.expected, depth)
+		}
+	}
+}
+
+func TestCloneSettings(t *testing.T) {
+	t.Parallel()
+
+	b := procBuilder{
+		Repo:  &model.Repo{},
+		Curr:  &model.Build{},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Link:  "",
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Data: []byte(`
+clone_settings:
+  depth: 0
+  tags: true
+pipeline:
+  build:
+    image: scratch
+`)},
+		},
+	}
+
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := buildItems[0].Config.Stages[0].Steps[0]
+	if clone.Environment["PLUGIN_DEPTH"] != "0" {
+		t.Fatal("Depth 0 should disable shallow clone")
+	}
+	if clone.Environment["PLUGIN_TAGS"] != "true" {
+		t.Fatal("Tags should be fetched when requested")
+	}
+}
+
+func TestCloneWhen(t *testing.T) {
+	t.Parallel()
+
+	yamls := []*remote.FileMeta{
+		&remote.FileMeta{Name: "gated", Data: []byte(`
+clone_settings:
+  when:
+    event: [ push, pull_request ]
+pipeline:
+  build:
+    image: scratch
+`)},
+		&remote.FileMeta{Name: "custom", Data: []byte(`
+clone_settings:
+  when:
+    event: [ push, pull_request ]
+clone:
+  git:
+    image: octocat/custom-git-plugin
+pipeline:
+  build:
+    image: scratch
+`)},
+		&remote.FileMeta{Name: "default", Data: []byte(`
+pipeline:
+  build:
+    image: scratch
+`)},
+	}
+
+	testdata := []struct {
+		event string
+		clone map[string]bool
+	}{
+		{event: model.EventPush, clone: map[string]bool{"gated": true, "custom": true, "default": true}},
+		{event: model.EventManual, clone: map[string]bool{"gated": false, "custom": false, "default": true}},
+	}
+	for _, test := range testdata {
+		b := procBuilder{
+			Repo:  &model.Repo{},
+			Curr:  &model.Build{Event: test.event},
+			Last:  &model.Build{},
+			Netrc: &model.Netrc{},
+			Secs:  []*model.Secret{},
+			Regs:  []*model.Registry{},
+			Yamls: yamls,
+		}
+		buildItems, err := b.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range buildItems {
+			cloned := strings.Contains(item.Config.Stages[0].Name, "_clone")
+			if want := test.clone[item.Proc.Name]; cloned != want {
+				t.Errorf("Want the pipeline %s of a %s build cloned %v, got %v", item.Proc.Name, test.event, want, cloned)
+			}
+		}
+	}
+}
+
+func TestCloneLFS(t *testing.T) {
+	Config.Pipeline.CloneLFS = true
+	defer func() { Config.Pipeline.CloneLFS = false }()
+
+	b := procBuilder{
+		Repo:  &model.Repo{},
+		Curr:  &model.Build{},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{Login: "octocat", Password: "password", Machine: "github.com"},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Link:  "",
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Name: "lfs", Data: []byte(`
+pipeline:
+  build:
+    image: scratch
+`)},
+			&remote.FileMeta{Name: "pointers", Data: []byte(`
+clone_settings:
+  lfs: false
+pipeline:
+  build:
+    image: scratch
+`)},
+		},
+	}
+
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := buildItems[0].Config.Stages[0].Steps[0]
+	if clone.Environment["PLUGIN_LFS"] != "true" {
+		t.Errorf("Want LFS objects fetched by default")
+	}
+	if clone.Environment["CI_NETRC_MACHINE"] != "github.com" {
+		t.Errorf("Want the netrc in the clone step of a public repository fetching LFS objects")
+	}
+	if _, ok := buildItems[0].Config.Stages[1].Steps[0].Environment["CI_NETRC_PASSWORD"]; ok {
+		t.Errorf("Want the netrc of a public repository only in the clone step")
+	}
+
+	clone = buildItems[1].Config.Stages[0].Steps[0]
+	if _, ok := clone.Environment["PLUGIN_LFS"]; ok {
+		t.Errorf("Want LFS objects not fetched when the pipeline disables it")
+	}
+	if _, ok := clone.Environment["CI_NETRC_PASSWORD"]; ok {
+		t.Errorf("Want no netrc for a public repository not fetching LFS objects")
+	}
+}
+
+func TestRegistryMirror(t *testing.T) {
+	Config.Pipeline.RegistryMirror = "mirror.internal"
+	defer func() { Config.Pipeline.RegistryMirror = "" }()
+
+	b := procBuilder{
+		Repo:  &model.Repo{},
+		Curr:  &model.Build{},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{{Address: "mirror.internal", Username: "octocat", Password: "password"}},
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Data: []byte(`
+pipeline:
+  build:
+    image: golang:1.20
+`)},
+		},
+	}
+
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	build := buildItems[0].Config.Stages[1].Steps[0]
+	if build.Image != "mirror.internal/golang:1.20" {
+		t.Errorf("Want the image pulled from the mirror, got %s", build.Image)
+	}
+	if build.AuthConfig.Username != "octocat" {
+		t.Errorf("Want the credentials of the mirror")
+	}
+}
+
+func TestPipelinePaths(t *testing.T) {
+	t.Parallel()
+
+	yamls := []*remote.FileMeta{
+		// not parsed unless the component is affected, it would fail the
+		// build otherwise.
+		&remote.FileMeta{Name: ".drone/frontend.yml", Data: []byte(`
+paths: [ "frontend/*" ]
+`)},
+		&remote.FileMeta{Name: ".drone/backend.yml", Data: []byte(`
+paths:
+  include: [ "backend/*" ]
+pipeline:
+  build:
+    image: scratch
+`)},
+	}
+
+	b := procBuilder{
+		Repo:  &model.Repo{Config: ".drone/"},
+		Curr:  &model.Build{ChangedFiles: []string{"backend/main.go"}},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Yamls: yamls,
+	}
+
+	result, err := b.Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Items) != 2 {
+		t.Fatalf("Want a proc per pipeline, got %d", len(result.Items))
+	}
+	// sorted by name
+	backend, frontend := result.Items[0], result.Items[1]
+	if frontend.Proc.State != model.StatusSkipped || len(frontend.Config.Stages) != 0 {
+		t.Errorf("Want the unaffected pipeline skipped without steps")
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].Reason != "no changed files match the pipeline paths" {
+		t.Errorf("Want the unaffected pipeline reported as skipped, got %v", result.Skipped)
+	}
+	if backend.Proc.State == model.StatusSkipped || len(backend.Config.Stages) == 0 {
+		t.Errorf("Want the affected pipeline built")
+	}
+
+	// the changed files are unknown, all the pipelines are parsed.
+	b.Curr = &model.Build{}
+	if _, err := b.Build(); err == nil {
+		t.Errorf("Want all the pipelines parsed if the changed files are unknown")
+	}
+}
+
+func TestPathsSkippedDependency(t *testing.T) {
+	t.Parallel()
+
+	yamls := []*remote.FileMeta{
+		&remote.FileMeta{Name: ".drone/frontend.yml", Data: []byte(`
+paths: [ "frontend/*" ]
+pipeline:
+  build:
+    image: node:${NODE_VERSION}
+matrix:
+  NODE_VERSION: [ 14, 16 ]
+`)},
+		&remote.FileMeta{Name: ".drone/e2e.yml", Data: []byte(`
+pipeline:
+  test:
+    image: scratch
+depends_on: [ frontend ]
+`)},
+		&remote.FileMeta{Name: ".drone/report.yml", Data: []byte(`
+pipeline:
+  report:
+    image: scratch
+depends_on: [ frontend ]
+run_if_deps_skipped: true
+`)},
+	}
+
+	b := procBuilder{
+		Repo:  &model.Repo{Config: ".drone/"},
+		Curr:  &model.Build{ChangedFiles: []string{"backend/main.go"}},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Yamls: yamls,
+	}
+
+	result, err := b.Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Items) != 3 || result.MatrixCount != 3 {
+		t.Fatalf("Want the skipped matrix as a single pipeline, got %d pipelines and %d matrix entries", len(result.Items), result.MatrixCount)
+	}
+	states := map[string]string{}
+	for _, item := range result.Items {
+		states[item.Proc.Name] = item.Proc.State
+	}
+	if states["frontend"] != model.StatusSkipped {
+		t.Errorf("Want the unaffected pipeline skipped, got %s", states["frontend"])
+	}
+	if states["e2e"] != model.StatusSkipped {
+		t.Errorf("Want the pipeline depending on the unaffected pipeline skipped, got %s", states["e2e"])
+	}
+	if states["report"] == model.StatusSkipped {
+		t.Errorf("Want the pipeline running if its dependencies are skipped built")
+	}
+	var reasons []string
+	for _, skipped := range result.Skipped {
+		reasons = append(reasons, skipped.Name+": "+skipped.Reason)
+	}
+	want := []string{"frontend: no changed files match the pipeline paths", "e2e: depends only on skipped pipelines"}
+	if !reflect.DeepEqual(reasons, want) {
+		t.Errorf("Want the skipped pipelines %v, got %v", want, reasons)
+	}
+}
+
+func TestCloneEnviron(t *testing.T) {
+	Config.Pipeline.CloneEnviron = map[string]string{"GIT_SSL_NO_VERIFY": "true"}
+	defer func() { Config.Pipeline.CloneEnviron = nil }()
+
+	b := procBuilder{
+		Repo:  &model.Repo{},
+		Curr:  &model.Build{},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Link:  "",
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Data: []byte(`
+pipeline:
+  build:
+    image: scratch
+`)},
+		},
+	}
+
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := buildItems[0].Config.Stages[0].Steps[0]
+	if clone.Environment["GIT_SSL_NO_VERIFY"] != "true" {
+		t.Fatal("Clone step should get the clone environment")
+	}
+	build := buildItems[0].Config.Stages[1].Steps[0]
+	if _, ok := build.Environment["GIT_SSL_NO_VERIFY"]; ok {
+		t.Fatal("Build steps should not get the clone environment")
+	}
+}
+
+func TestWorkspace(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name     string
+		yaml     string
+		expected string
+	}{
+		{
+			name:     "Default workspace is derived from the repository link",
+			yaml:     "",
+			expected: "/drone/src/github.com/octocat/hello-world",
+		},
+		{
+			name:     "Custom workspace base and path",
+			yaml:     "workspace:\n  base: /go\n  path: src/github.com/octocat/hello-world/cmd\n",
+			expected: "/go/src/github.com/octocat/hello-world/cmd",
+		},
+		{
+			name:     "Custom workspace path",
+			yaml:     "workspace:\n  path: services/api\n",
+			expected: "/drone/services/api",
+		},
+	}
+
+	for _, tt := range testTable {
+		b := procBuilder{
+			Repo:  &model.Repo{Link: "https://github.com/octocat/hello-world"},
+			Curr:  &model.Build{},
+			Last:  &model.Build{},
+			Netrc: &model.Netrc{},
+			Secs:  []*model.Secret{},
+			Regs:  []*model.Registry{},
+			Link:  "",
+			Yamls: []*remote.FileMeta{
+				&remote.FileMeta{Data: []byte(tt.yaml + `
+pipeline:
+  build:
+    image: scratch
+`)},
+			},
+		}
+
+		buildItems, err := b.Build()
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		stages := buildItems[0].Config.Stages
+		step := stages[len(stages)-1].Steps[0]
+		if step.WorkingDir != tt.expected {
+			t.Errorf("%s: want working dir %s, got %s", tt.name, tt.expected, step.WorkingDir)
+		}
+		if step.Environment["CI_WORKSPACE"] != tt.expected {
+			t.Errorf("%s: want CI_WORKSPACE %s, got %s", tt.name, tt.expected, step.Environment["CI_WORKSPACE"])
+		}
+	}
+}
+
+func TestWorkspaceTraversal(t *testing.T) {
+	t.Parallel()
+
+	b := procBuilder{
+		Repo:  &model.Repo{Link: "https://github.com/octocat/hello-world"},
+		Curr:  &model.Build{},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Link:  "",
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Data: []byte(`
+workspace:
+  path: ../../etc
+pipeline:
+  build:
+    image: scratch
+`)},
+		},
+	}
+
+	if _, err := b.Build(); err == nil {
+		t.Fatal("Workspace path should not be allowed to leave the workspace base")
+	}
+}
+
+func TestStepDirectory(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name      string
+		directory string
+		expected  string
+		err       bool
+	}{
+		{
+			name:     "Default directory is the workspace root",
+			expected: "/drone/src/github.com/octocat/hello-world",
+		},
+		{
+			name:      "Directory is relative to the workspace",
+			directory: "services/api",
+			expected:  "/drone/src/github.com/octocat/hello-world/services/api",
+		},
+		{
+			name:      "Absolute directory is rejected",
+			directory: "/etc",
+			err:       true,
+		},
+		{
+			name:      "Directory cannot leave the workspace",
+			directory: "services/../../../etc",
+			err:       true,
+		},
+	}
+
+	for _, tt := range testTable {
+		b := procBuilder{
+			Repo:  &model.Repo{Link: "https://github.com/octocat/hello-world"},
+			Curr:  &model.Build{},
+			Last:  &model.Build{},
+			Netrc: &model.Netrc{},
+			Secs:  []*model.Secret{},
+			Regs:  []*model.Registry{},
+			Link:  "",
+			Yamls: []*remote.FileMeta{
+				&remote.FileMeta{Data: []byte(`
+pipeline:
+  build:
+    image: scratch
+    directory: "` + tt.directory + `"
+    commands:
+      - make
+`)},
+			},
+		}
+
+		buildItems, err := b.Build()
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		stages := buildItems[0].Config.Stages
+		step := stages[len(stages)-1].Steps[0]
+		if step.WorkingDir != tt.expected {
+			t.Errorf("%s: want working dir %s, got %s", tt.name, tt.expected, step.WorkingDir)
+		}
+	}
+}
+
+func TestWorkspaceEnvsubst(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name     string
+		yaml     string
+		expected string
+	}{
+		{
+			name:     "Default workspace base",
+			yaml:     "",
+			expected: "/drone/src/github.com/octocat/hello-world",
+		},
+		{
+			name:     "Custom workspace base",
+			yaml:     "workspace:\n  base: /go\n  path: src/app\n",
+			expected: "/go/src/app",
+		},
+	}
+
+	for _, tt := range testTable {
+		b := procBuilder{
+			Repo:  &model.Repo{Link: "https://github.com/octocat/hello-world"},
+			Curr:  &model.Build{},
+			Last:  &model.Build{},
+			Netrc: &model.Netrc{},
+			Secs:  []*model.Secret{},
+			Regs:  []*model.Registry{},
+			Link:  "",
+			Yamls: []*remote.FileMeta{
+				&remote.FileMeta{Data: []byte(tt.yaml + `
+pipeline:
+  build:
+    image: scratch
+    environment:
+      - BUILD_DIR=${CI_WORKSPACE}/build
+      - LEGACY_DIR=${DRONE_WORKSPACE}
+`)},
+			},
+		}
+
+		buildItems, err := b.Build()
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		stages := buildItems[0].Config.Stages
+		step := stages[len(stages)-1].Steps[0]
+		if step.WorkingDir != tt.expected {
+			t.Errorf("%s: want working dir %s, got %s", tt.name, tt.expected, step.WorkingDir)
+		}
+		if step.Environment["BUILD_DIR"] != tt.expected+"/build" {
+			t.Errorf("%s: want CI_WORKSPACE substituted with %s, got %s", tt.name, tt.expected, step.Environment["BUILD_DIR"])
+		}
+		if step.Environment["LEGACY_DIR"] != tt.expected {
+			t.Errorf("%s: want DRONE_WORKSPACE substituted with %s, got %s", tt.name, tt.expected, step.Environment["LEGACY_DIR"])
+		}
+	}
+}
+
+func TestConcurrencyGroup(t *testing.T) {
+	t.Parallel()
+
+	b := procBuilder{
+		Repo:  &model.Repo{FullName: "octocat/hello-world"},
+		Curr:  &model.Build{Branch: "main"},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Link:  "",
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Name: "build", Data: []byte(`
+concurrency:
+  cancel-in-progress: true
+pipeline:
+  build:
+    image: scratch
+`)},
+			&remote.FileMeta{Name: "deploy", Data: []byte(`
+concurrency:
+  group: deploy-${CI_COMMIT_BRANCH}
+pipeline:
+  deploy:
+    image: scratch
+`)},
+			&remote.FileMeta{Name: "lint", Data: []byte(`
+pipeline:
+  lint:
+    image: scratch
+`)},
+		},
+	}
+
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buildItems) != 3 {
+		t.Fatal("Should have generated 3 buildItems")
+	}
+
+	testTable := []struct {
+		group  string
+		cancel bool
+	}{
+		{group: "octocat/hello-world/build/main", cancel: true},
+		{group: "octocat/hello-world/deploy/deploy-main", cancel: false},
+		{group: "", cancel: false},
+	}
+
+	for i, tt := range testTable {
+		item := buildItems[i]
+		if item.ConcurrencyGroup != tt.group {
+			t.Errorf("%s: want concurrency group %q, got %q", item.Proc.Name, tt.group, item.ConcurrencyGroup)
+		}
+		if item.CancelInProgress != tt.cancel {
+			t.Errorf("%s: want cancel in progress %t, got %t", item.Proc.Name, tt.cancel, item.CancelInProgress)
+		}
+	}
+}
+
+func TestStepCount(t *testing.T) {
+	t.Parallel()
+
+	b := procBuilder{
+		Repo:  &model.Repo{},
+		Curr:  &model.Build{Branch: "main"},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Link:  "",
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Name: "build", Data: []byte(`
+pipeline:
+  build:
+    image: scratch
+  test:
+    image: scratch
+  integration:
+    image: scratch
+    group: checks
+  lint:
+    image: scratch
+    group: checks
+services:
+  database:
+    image: mysql
+  cache:
+    image: redis
+`)},
+			&remote.FileMeta{Name: "skipped", Data: []byte(`
+branches: release
+pipeline:
+  deploy:
+    image: scratch
+services:
+  database:
+    image: mysql
+`)},
+		},
+	}
+
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buildItems) != 2 {
+		t.Fatal("Should have generated 2 buildItems")
+	}
+
+	// the clone step and four pipeline steps
+	if buildItems[0].StepCount != 5 {
+		t.Errorf("Should have counted 5 steps, got %d", buildItems[0].StepCount)
+	}
+	if buildItems[0].ServiceCount != 2 {
+		t.Errorf("Should have counted 2 services, got %d", buildItems[0].ServiceCount)
+	}
+	if buildItems[1].StepCount != 0 || buildItems[1].ServiceCount != 0 {
+		t.Errorf("Skipped pipeline should count no steps, got %d steps and %d services", buildItems[1].StepCount, buildItems[1].ServiceCount)
+	}
+}
+
+func TestMatrixServices(t *testing.T) {
+	t.Parallel()
+
+	b := procBuilder{
+		Repo:  &model.Repo{},
+		Curr:  &model.Build{},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Link:  "",
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Data: []byte(`
+pipeline:
+  test:
+    image: golang
+    commands:
+      - go test
+services:
+  postgres:
+    image: postgres
+    when:
+      matrix:
+        DB: postgres
+matrix:
+  DB:
+    - postgres
+    - sqlite
+`)},
+		},
+	}
+
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buildItems) != 2 {
+		t.Fatal("Should have generated 2 buildItems")
+	}
+
+	for _, item := range buildItems {
+		var services []string
+		for _, stage := range item.Config.Stages {
+			if strings.HasSuffix(stage.Name, "_services") {
+				for _, step := range stage.Steps {
+					services = append(services, step.Alias)
+				}
+			}
+		}
+
+		switch item.Proc.Environ["DB"] {
+		case "postgres":
+			if len(services) != 1 || services[0] != "postgres" {
+				t.Errorf("postgres axis should include the postgres service, got %v", services)
+			}
+		case "sqlite":
+			if len(services) != 0 {
+				t.Errorf("sqlite axis should not include any service, got %v", services)
+			}
+			if item.ServiceCount != 0 {
+				t.Errorf("sqlite axis should count no services, got %d", item.ServiceCount)
+			}
+		default:
+			t.Errorf("Unexpected matrix axis %v", item.Proc.Environ)
+		}
+	}
+}
+
+func TestStepOutputs(t *testing.T) {
+	t.Parallel()
+
+	b := procBuilder{
+		Repo:  &model.Repo{},
+		Curr:  &model.Build{},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Link:  "",
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Data: []byte(`
+skip_clone: true
+pipeline:
+  build:
+    image: scratch
+    outputs: [ version ]
+    commands:
+      - echo 1.0.0 > $CI_STEP_OUTPUTS/version
+  deploy:
+    image: scratch
+    environment:
+      VERSION: "{{ steps.build.outputs.version }}"
+    commands:
+      - echo $VERSION
+`)},
+		},
+	}
+
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stages := buildItems[0].Config.Stages
+	if len(stages) != 2 {
+		t.Fatal("Should have compiled 2 stages")
+	}
+
+	build := stages[0].Steps[0]
+	if build.Environment["CI_STEP_OUTPUTS"] != "/drone/.outputs/build" {
+		t.Errorf("Should have set the outputs directory, got %q", build.Environment["CI_STEP_OUTPUTS"])
+	}
+	script, _ := base64.StdEncoding.DecodeString(build.Environment["CI_SCRIPT"])
+	if !strings.Contains(string(script), `mkdir -p "/drone/.outputs/build"`) {
+		t.Errorf("Should have created the outputs directory, got script %s", script)
+	}
+
+	deploy := stages[1].Steps[0]
+	if _, ok := deploy.Environment["VERSION"]; ok {
+		t.Error("Should have removed the output reference from the environment")
+	}
+	script, _ = base64.StdEncoding.DecodeString(deploy.Environment["CI_SCRIPT"])
+	if !strings.Contains(string(script), `export VERSION="$(cat "/drone/.outputs/build/version")"`) {
+		t.Errorf("Should have exported the referenced output, got script %s", script)
+	}
+}
+
+func TestStepOutputsForwardReference(t *testing.T) {
+	t.Parallel()
+
+	b := procBuilder{
+		Repo:  &model.Repo{},
+		Curr:  &model.Build{},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Link:  "",
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Data: []byte(`
+pipeline:
+  deploy:
+    image: scratch
+    environment:
+      VERSION: "{{ steps.build.outputs.version }}"
+    commands:
+      - echo $VERSION
+  build:
+    image: scratch
+    outputs: [ version ]
+    commands:
+      - echo 1.0.0 > $CI_STEP_OUTPUTS/version
+`)},
+		},
+	}
+
+	if _, err := b.Build(); err == nil {
+		t.Fatal("Should not reference the outputs of a later step")
+	}
+}
+
+func TestSecretNameCollision(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name      string
+		secret    string
+		expectErr bool
+	}{
+		{name: "Reserved metadata variable", secret: "ci_commit_sha", expectErr: true},
+		{name: "Legacy metadata variable", secret: "DRONE_BRANCH", expectErr: true},
+		{name: "Safe secret name", secret: "docker_password", expectErr: false},
+	}
+
+	for _, tt := range testTable {
+		b := procBuilder{
+			Repo:  &model.Repo{},
+			Curr:  &model.Build{Event: model.EventPush},
+			Last:  &model.Build{},
+			Netrc: &model.Netrc{},
+			Secs:  []*model.Secret{{Name: tt.secret, Value: "secret"}},
+			Regs:  []*model.Registry{},
+			Link:  "",
+			Yamls: []*remote.FileMeta{
+				&remote.FileMeta{Data: []byte(`
+pipeline:
+  build:
+    image: scratch
+`)},
+			},
+		}
+
+		_, err := b.Build()
+		if tt.expectErr && err == nil {
+			t.Errorf("%s: should reject secret %s", tt.name, tt.secret)
+		} else if !tt.expectErr && err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+		}
+		if tt.expectErr && err != nil && !strings.Contains(err.Error(), tt.secret) {
+			t.Errorf("%s: error should report the secret name, got %s", tt.name, err)
+		}
+	}
+	// matrix variables are not reserved, a secret may share their name.
+	b := procBuilder{
+		Repo:  &model.Repo{},
+		Curr:  &model.Build{Event: model.EventPush},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{{Name: "region", Value: "secret"}},
+		Regs:  []*model.Registry{},
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Data: []byte(`
+matrix:
+  REGION: [ eu, us ]
+pipeline:
+  build:
+    image: scratch
+`)},
+		},
+	}
+	if _, err := b.Build(); err != nil {
+		t.Errorf("Want a secret named like a matrix variable accepted, got %s", err)
+	}
+}
+
+func TestMissingSecrets(t *testing.T) {
+	Config.Pipeline.FailOnMissingSecrets = true
+	defer func() { Config.Pipeline.FailOnMissingSecrets = false }()
+
+	testTable := []struct {
+		name      string
+		secs      []*model.Secret
+		expectErr bool
+	}{
+		{
+			name:      "Missing required secret",
+			secs:      []*model.Secret{{Name: "docker_username"}},
+			expectErr: true,
+		},
+		{
+			name:      "Satisfied secrets",
+			secs:      []*model.Secret{{Name: "docker_username"}, {Name: "DOCKER_PASSWORD"}},
+			expectErr: false,
+		},
+		{
+			name:      "Secret not exposed to the build event",
+			secs:      []*model.Secret{{Name: "docker_username"}, {Name: "docker_password", Events: []string{model.EventTag}}},
+			expectErr: false,
+		},
+	}
+
+	for _, tt := range testTable {
+		b := procBuilder{
+			Repo:  &model.Repo{},
+			Curr:  &model.Build{Event: model.EventPush},
+			Last:  &model.Build{},
+			Netrc: &model.Netrc{},
+			Secs:  tt.secs,
+			Regs:  []*model.Registry{},
+			Link:  "",
+			Yamls: []*remote.FileMeta{
+				&remote.FileMeta{Data: []byte(`
+pipeline:
+  publish:
+    image: plugins/docker
+    secrets: [ docker_username, docker_password ]
+  deploy:
+    image: scratch
+    secrets: [ ssh_key ]
+    when:
+      event: deployment
+`)},
+			},
+		}
+
+		_, err := b.Build()
+		if tt.expectErr && err == nil {
+			t.Errorf("%s: should fail the build", tt.name)
+		} else if !tt.expectErr && err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+		}
+		if tt.expectErr && err != nil && err.Error() != "Missing secrets docker_password" {
+			t.Errorf("%s: should list the missing secrets, got %s", tt.name, err)
+		}
+	}
+}
+
+func TestUndefinedVariables(t *testing.T) {
+	defer func() { Config.Pipeline.UndefinedVariables = "" }()
+
+	testTable := []struct {
+		mode    string
+		warning string
+		err     string
+	}{
+		{mode: ""},
+		{mode: UndefinedVariablesIgnore},
+		{mode: UndefinedVariablesWarn, warning: "pipeline build references undefined variables IMGAE_TAG, REGISTRY"},
+		{mode: UndefinedVariablesFail, err: "Undefined variables IMGAE_TAG, REGISTRY"},
+	}
+
+	for _, tt := range testTable {
+		Config.Pipeline.UndefinedVariables = tt.mode
+		b := procBuilder{
+			Repo:  &model.Repo{},
+			Curr:  &model.Build{Event: model.EventPush},
+			Last:  &model.Build{},
+			Netrc: &model.Netrc{},
+			Secs:  []*model.Secret{},
+			Regs:  []*model.Registry{},
+			Yamls: []*remote.FileMeta{
+				&remote.FileMeta{Name: "build", Data: []byte(`
+pipeline:
+  build:
+    image: "${REGISTRY}golang:${IMGAE_TAG}"
+    commands:
+      - echo ${DRONE_COMMIT_BRANCH} ${TAG:-latest} ${REGISTRY}
+`)},
+			},
+		}
+
+		result, err := b.Result()
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("mode %q: want error %q, got %v", tt.mode, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("mode %q: %s", tt.mode, err)
+			continue
+		}
+		if tt.warning == "" && len(result.Warnings) != 0 {
+			t.Errorf("mode %q: want no warnings, got %v", tt.mode, result.Warnings)
+		}
+		if tt.warning != "" && (len(result.Warnings) != 1 || result.Warnings[0] != tt.warning) {
+			t.Errorf("mode %q: want warning %q, got %v", tt.mode, tt.warning, result.Warnings)
+		}
+	}
+}
+
+func TestUndefinedVariablesEscaped(t *testing.T) {
+	t.Parallel()
+
+	undefined := undefinedVariables("echo $${HOME} ${A} ${B=b} ${C:+c} ${D/x/${E}}", map[string]string{"D": "d"})
+	if !reflect.DeepEqual(undefined, []string{"A", "E"}) {
+		t.Errorf("Want the undefined variables without escapes and defaults, got %v", undefined)
+	}
+}
+
+func TestSecretAndRegistryOrdering(t *testing.T) {
+	t.Parallel()
+
+	secs := []*model.Secret{
+		{Name: "zeta", Value: "z"},
+		{Name: "alpha", Value: "a"},
+		{Name: "mu", Value: "m"},
+	}
+	regs := []*model.Registry{
+		{Address: "docker.io", Username: "second", Password: "b"},
+		{Address: "docker.io", Username: "first", Password: "a"},
+		{Address: "quay.io", Username: "quay", Password: "q"},
+	}
+
+	build := func(secs []*model.Secret, regs []*model.Registry) ([]string, string) {
+		b := procBuilder{
+			Repo:  &model.Repo{},
+			Curr:  &model.Build{},
+			Last:  &model.Build{},
+			Netrc: &model.Netrc{},
+			Secs:  secs,
+			Regs:  regs,
+			Link:  "",
+			Yamls: []*remote.FileMeta{
+				&remote.FileMeta{Data: []byte(`
+pipeline:
+  build:
+    image: golang
+    commands:
+      - go test
+`)},
+			},
+		}
+
+		buildItems, err := b.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, sec := range buildItems[0].Config.Secrets {
+			// skip the encodings registered for redaction
+			if !strings.Contains(sec.Name, "_") {
+				names = append(names, sec.Name)
+			}
+		}
+		stages := buildItems[0].Config.Stages
+		return names, stages[len(stages)-1].Steps[0].AuthConfig.Username
+	}
+
+	names, username := build(secs, regs)
+	if strings.Join(names, ",") != "alpha,mu,zeta" {
+		t.Errorf("Want secrets sorted by name, got %v", names)
+	}
+	if username != "first" {
+		t.Errorf("Want registry credentials of first, got %s", username)
+	}
+
+	reversedSecs := []*model.Secret{secs[2], secs[1], secs[0]}
+	reversedRegs := []*model.Registry{regs[2], regs[1], regs[0]}
+	reversedNames, reversedUsername := build(reversedSecs, reversedRegs)
+	if strings.Join(reversedNames, ",") != strings.Join(names, ",") {
+		t.Errorf("Want stable secret ordering, got %v and %v", names, reversedNames)
+	}
+	if reversedUsername != username {
+		t.Errorf("Want stable registry ordering, got %s and %s", username, reversedUsername)
+	}
+}
+
+func TestExcludeLabels(t *testing.T) {
+	t.Parallel()
+
+	b := procBuilder{
+		Repo:  &model.Repo{},
+		Curr:  &model.Build{},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Link:  "",
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Data: []byte(`
+pipeline:
+  build:
+    image: scratch
+labels:
+  platform: linux/amd64
+exclude_labels:
+  gpu: "true"
+`)},
+		},
+	}
+
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buildItems[0].Labels["platform"] != "linux/amd64" {
+		t.Errorf("Want labels attached to the build item, got %v", buildItems[0].Labels)
+	}
+	if len(buildItems[0].ExcludeLabels) != 1 || buildItems[0].ExcludeLabels["gpu"] != "true" {
+		t.Errorf("Want exclude labels attached to the build item, got %v", buildItems[0].ExcludeLabels)
+	}
+}
+
+func TestLabelExprs(t *testing.T) {
+	t.Parallel()
+
+	b := procBuilder{
+		Repo:  &model.Repo{},
+		Curr:  &model.Build{},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Link:  "",
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Data: []byte(`
+pipeline:
+  build:
+    image: scratch
+labels:
+  platform: linux/amd64
+  memory: "> 4G"
+  arch: in (amd64, arm64)
+`)},
+		},
+	}
+
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := buildItems[0]
+	if len(item.Labels) != 1 || item.Labels["platform"] != "linux/amd64" {
+		t.Errorf("Want only plain labels matched by equality, got %v", item.Labels)
+	}
+	if len(item.LabelExprs) != 2 {
+		t.Fatalf("Want 2 label expressions, got %d", len(item.LabelExprs))
+	}
+	if got := item.LabelExprs[0].String(); got != "arch in (amd64, arm64)" {
+		t.Errorf("Want label expressions sorted by key, got %s", got)
+	}
+	if got := item.LabelExprs[1].String(); got != "memory > 4G" {
+		t.Errorf("Want comparison label expression, got %s", got)
+	}
+
+	b.Yamls[0].Data = []byte(`
+pipeline:
+  build:
+    image: scratch
+labels:
+  memory: "> lots"
+`)
+	if _, err := b.Build(); err == nil {
+		t.Errorf("Want an error for an invalid label expression")
+	}
+}
+
+func TestMatrixAllowedValues(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name     string
+		platform string
+		err      bool
+	}{
+		{
+			name:     "Axis value in the allowed set",
+			platform: "linux/arm64",
+		},
+		{
+			name:     "Axis value outside of the allowed set",
+			platform: "linux/amd46",
+			err:      true,
+		},
+	}
+
+	for _, tt := range testTable {
+		b := procBuilder{
+			Repo:  &model.Repo{},
+			Curr:  &model.Build{},
+			Last:  &model.Build{},
+			Netrc: &model.Netrc{},
+			Secs:  []*model.Secret{},
+			Regs:  []*model.Registry{},
+			Link:  "",
+			Yamls: []*remote.FileMeta{
+				&remote.FileMeta{Data: []byte(fmt.Sprintf(`
+pipeline:
+  build:
+    image: scratch
+matrix:
+  PLATFORM:
+    - linux/amd64
+    - %s
+  allowed:
+    PLATFORM:
+      - linux/amd64
+      - linux/arm64
+`, tt.platform))},
+			},
+		}
+
+		buildItems, err := b.Build()
+		if tt.err {
+			if err == nil || !strings.Contains(err.Error(), tt.platform) {
+				t.Errorf("%s: want an error naming %s, got %v", tt.name, tt.platform, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if len(buildItems) != 2 {
+			t.Errorf("%s: want 2 build items, got %d", tt.name, len(buildItems))
+		}
+	}
+}
+
+func TestRegistrySecrets(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name     string
+		regs     []*model.Registry
+		event    string
+		expected string
+	}{
+		{
+			name:     "Registry secret provides credentials",
+			event:    model.EventPush,
+			expected: "octocat",
+		},
+		{
+			name:     "Registry takes precedence over registry secret",
+			event:    model.EventPush,
+			regs:     []*model.Registry{{Address: "registry.example.com", Username: "registry", Password: "pass"}},
+			expected: "registry",
+		},
+		{
+			name:     "Registry secret not exposed to the event",
+			event:    model.EventPull,
+			expected: "",
+		},
+	}
+
+	for _, tt := range testTable {
+		b := procBuilder{
+			Repo:  &model.Repo{},
+			Curr:  &model.Build{Event: tt.event},
+			Last:  &model.Build{},
+			Netrc: &model.Netrc{},
+			Secs: []*model.Secret{
+				{
+					Name:     "registry_credentials",
+					Value:    "octocat:pass",
+					Registry: "registry.example.com",
+					Events:   []string{model.EventPush},
+				},
+			},
+			Regs: tt.regs,
+			Link: "",
+			Yamls: []*remote.FileMeta{
+				&remote.FileMeta{Data: []byte(`
+pipeline:
+  build:
+    image: registry.example.com/octocat/golang
+`)},
+			},
+		}
+		buildItems, err := b.Build()
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		stages := buildItems[0].Config.Stages
+		auth := stages[len(stages)-1].Steps[0].AuthConfig
+		if auth.Username != tt.expected {
+			t.Errorf("%s: want registry username %q, got %q", tt.name, tt.expected, auth.Username)
+		}
+	}
+}
+
+func TestMatrixEvent(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name  string
+		event string
+		procs int
+	}{
+		{
+			name:  "Full matrix for pushes",
+			event: model.EventPush,
+			procs: 4,
+		},
+		{
+			name:  "Reduced matrix for pull requests",
+			event: model.EventPull,
+			procs: 1,
+		},
+	}
+
+	for _, tt := range testTable {
+		b := procBuilder{
+			Repo:  &model.Repo{},
+			Curr:  &model.Build{Event: tt.event},
+			Last:  &model.Build{},
+			Netrc: &model.Netrc{},
+			Secs:  []*model.Secret{},
+			Regs:  []*model.Registry{},
+			Link:  "",
+			Yamls: []*remote.FileMeta{
+				&remote.FileMeta{Data: []byte(`
+pipeline:
+  build:
+    image: golang:${GO_VERSION}
+matrix:
+  GO_VERSION: [ 1.15, 1.16 ]
+  PLATFORM: [ linux/amd64, linux/arm64 ]
+matrix_pull_request:
+  GO_VERSION: [ 1.16 ]
+  PLATFORM: [ linux/amd64 ]
+`)},
+			},
+		}
+
+		buildItems, err := b.Build()
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if len(buildItems) != tt.procs {
+			t.Errorf("%s: want %d build items, got %d", tt.name, tt.procs, len(buildItems))
+		}
+	}
+}
+
+func TestBuildResult(t *testing.T) {
+	t.Parallel()
+
+	b := procBuilder{
+		Repo:  &model.Repo{},
+		Curr:  &model.Build{Event: model.EventPush, Branch: "develop"},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Link:  "",
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Name: "build", Data: []byte(`
+pipeline:
+  build:
+    image: scratch
+    secrets: [ token ]
+matrix:
+  GO_VERSION: [ 1.15, 1.16 ]
+`)},
+			&remote.FileMeta{Name: "deploy", Data: []byte(`
+pipeline:
+  deploy:
+    image: scratch
+depends_on:
+  - publish
+`)},
+			&remote.FileMeta{Name: "docs", Data: []byte(`
+skip_clone: true
+pipeline:
+  docs:
+    image: scratch
+    when:
+      event: tag
+`)},
+			&remote.FileMeta{Name: "release", Data: []byte(`
+branches: master
+pipeline:
+  release:
+    image: scratch
+`)},
+		},
+	}
+
+	result, err := b.Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.MatrixCount != 5 {
+		t.Errorf("Want 5 pipelines from the matrix expansion, got %d", result.MatrixCount)
+	}
+	if len(result.Items) != 3 {
+		t.Errorf("Want 3 build items, got %d", len(result.Items))
+	}
+
+	reasons := map[string]string{}
+	for _, skipped := range result.Skipped {
+		reasons[skipped.Name] = skipped.Reason
+	}
+	want := map[string]string{
+		"release": "branch develop does not match the pipeline branches",
+		"docs":    "no steps match the build",
+		"deploy":  "depends on a pipeline that is not built",
+	}
+	for name, reason := range want {
+		if reasons[name] != reason {
+			t.Errorf("Want pipeline %s skipped as %q, got %q", name, reason, reasons[name])
+		}
+	}
+
+	if len(result.Warnings) != 2 || result.Warnings[0] != "pipeline build references missing secrets token" {
+		t.Errorf("Want a missing secrets warning per matrix pipeline, got %v", result.Warnings)
+	}
+}
+
+func TestMatrixLimit(t *testing.T) {
+	Config.Pipeline.MatrixLimit = 3
+	defer func() { Config.Pipeline.MatrixLimit = 0 }()
+
+	testTable := []struct {
+		name  string
+		limit int
+		err   string
+	}{
+		{
+			name: "Server limit",
+			err:  "matrix of .drone.yml expands to 4 pipelines, exceeding the server limit of 3",
+		},
+		{
+			name:  "Repository limit lower than the server limit",
+			limit: 2,
+			err:   "matrix of .drone.yml expands to 4 pipelines, exceeding the repository limit of 2",
+		},
+		{
+			name:  "Repository limit higher than the server limit",
+			limit: 4,
+		},
+	}
+
+	for _, tt := range testTable {
+		b := procBuilder{
+			Repo:  &model.Repo{MatrixLimit: tt.limit},
+			Curr:  &model.Build{},
+			Last:  &model.Build{},
+			Netrc: &model.Netrc{},
+			Secs:  []*model.Secret{},
+			Regs:  []*model.Registry{},
+			Link:  "",
+			Yamls: []*remote.FileMeta{
+				&remote.FileMeta{Name: ".drone.yml", Data: []byte(`
+pipeline:
+  build:
+    image: golang:${GO_VERSION}
+matrix:
+  GO_VERSION: [ 1.15, 1.16 ]
+  PLATFORM: [ linux/amd64, linux/arm64 ]
+`)},
+			},
+		}
+
+		buildItems, err := b.Build()
+		if tt.err == "" {
+			if err != nil {
+				t.Fatalf("%s: %s", tt.name, err)
+			}
+			if len(buildItems) != 4 {
+				t.Errorf("%s: want 4 build items, got %d", tt.name, len(buildItems))
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%s: want error %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestSkipLint(t *testing.T) {
+	testTable := []struct {
+		name string
+		repo *model.Repo
+		yaml string
+		err  bool
+	}{
+		{
+			name: "Lint untrusted repositories",
+			repo: &model.Repo{SkipLint: true},
+			yaml: "pipeline:\n  build:\n    image: golang\n    privileged: true\n",
+			err:  true,
+		},
+		{
+			name: "Skip lint for trusted repositories",
+			repo: &model.Repo{IsTrusted: true, SkipLint: true},
+			yaml: "pipeline:\n  build:\n    image: golang\n    commands: []\n    directory: /tmp\n",
+		},
+		{
+			name: "Lint trusted repositories by default",
+			repo: &model.Repo{IsTrusted: true},
+			yaml: "pipeline:\n  build:\n    image: golang\n    commands: []\n    directory: /tmp\n",
+			err:  true,
+		},
+		{
+			name: "Catch parse errors when skipping lint",
+			repo: &model.Repo{IsTrusted: true, SkipLint: true},
+			yaml: "pipeline: [",
+			err:  true,
+		},
+	}
+
+	for _, tt := range testTable {
+		b := procBuilder{
+			Repo:  tt.repo,
+			Curr:  &model.Build{},
+			Last:  &model.Build{},
+			Netrc: &model.Netrc{},
+			Secs:  []*model.Secret{},
+			Regs:  []*model.Registry{},
+			Link:  "",
+			Yamls: []*remote.FileMeta{
+				&remote.FileMeta{Data: []byte(tt.yaml)},
+			},
+		}
+
+		_, err := b.Build()
+		if tt.err && err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+		if !tt.err && err != nil {
+			t.Errorf("%s: unexpected error %s", tt.name, err)
+		}
+	}
+}
+
+func BenchmarkBuildLint(b *testing.B) {
+	var yaml strings.Builder
+	yaml.WriteString("pipeline:\n")
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&yaml, "  step%d:\n    image: golang\n    commands: [ go test ./... ]\n", i)
+	}
+	yaml.WriteString("matrix:\n  GO_VERSION: [ 1.15, 1.16, 1.17, 1.18 ]\n")
+
+	for _, skip := range []bool{false, true} {
+		name := "lint"
+		if skip {
+			name = "skip lint"
+		}
+		b.Run(name, func(b *testing.B) {
+			builder := procBuilder{
+				Repo:  &model.Repo{IsTrusted: true, SkipLint: skip},
+				Curr:  &model.Build{},
+				Last:  &model.Build{},
+				Netrc: &model.Netrc{},
+				Yamls: []*remote.FileMeta{
+					&remote.FileMeta{Data: []byte(yaml.String())},
+				},
+			}
+			for i := 0; i < b.N; i++ {
+				if _, err := builder.Build(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestDependsOnSkipped(t *testing.T) {
+	t.Parallel()
+
+	b := procBuilder{
+		Repo:  &model.Repo{},
+		Curr:  &model.Build{Branch: "main"},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Link:  "",
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Name: "lint", Data: []byte(`
+branches: [ dev ]
+pipeline:
+  build:
+    image: scratch
+`)},
+			&remote.FileMeta{Name: "deploy", Data: []byte(`
+pipeline:
+  build:
+    image: scratch
+depends_on: [ lint ]
+`)},
+			&remote.FileMeta{Name: "notify", Data: []byte(`
+pipeline:
+  build:
+    image: scratch
+depends_on: [ deploy ]
+`)},
+			&remote.FileMeta{Name: "report", Data: []byte(`
+pipeline:
+  build:
+    image: scratch
+depends_on: [ lint ]
+run_if_deps_skipped: true
+`)},
+			&remote.FileMeta{Name: "publish", Data: []byte(`
+pipeline:
+  build:
+    image: scratch
+depends_on: [ release ]
+`)},
+		},
+	}
+
+	result, err := b.Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	states := map[string]string{}
+	for _, item := range result.Items {
+		states[item.Proc.Name] = item.Proc.State
+	}
+	want := map[string]string{
+		"lint":   model.StatusSkipped,
+		"deploy": model.StatusSkipped,
+		"notify": model.StatusSkipped,
+		"report": model.StatusPending,
+	}
+	if len(states) != len(want) {
+		t.Errorf("want build items %v, got %v", want, states)
+	}
+	for name, state := range want {
+		if states[name] != state {
+			t.Errorf("want pipeline %s %s, got %q", name, state, states[name])
+		}
+	}
+
+	reasons := map[string]string{}
+	for _, skipped := range result.Skipped {
+		reasons[skipped.Name] = skipped.Reason
+	}
+	if reasons["deploy"] != "depends only on skipped pipelines" || reasons["notify"] != "depends only on skipped pipelines" {
+		t.Errorf("want pipelines skipped for their skipped dependencies, got %v", reasons)
+	}
+	if reasons["publish"] != "depends on a pipeline that is not built" {
+		t.Errorf("want pipeline publish dropped for its missing dependency, got %q", reasons["publish"])
+	}
+}
+
+func TestPipelinePlatform(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name     string
+		yaml     string
+		platform string
+	}{
+		{
+			name:     "Default platform",
+			yaml:     "pipeline:\n  build:\n    image: scratch\n",
+			platform: "linux/amd64",
+		},
+		{
+			name:     "Pipeline platform",
+			yaml:     "platform: linux/arm64\npipeline:\n  build:\n    image: scratch\n",
+			platform: "linux/arm64",
+		},
+	}
+
+	for _, tt := range testTable {
+		b := procBuilder{
+			Repo:  &model.Repo{},
+			Curr:  &model.Build{},
+			Last:  &model.Build{},
+			Netrc: &model.Netrc{},
+			Secs:  []*model.Secret{},
+			Regs:  []*model.Registry{},
+			Link:  "",
+			Yamls: []*remote.FileMeta{
+				&remote.FileMeta{Data: []byte(tt.yaml)},
+			},
+		}
+
+		buildItems, err := b.Build()
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if buildItems[0].Platform != tt.platform {
+			t.Errorf("%s: want platform %s, got %s", tt.name, tt.platform, buildItems[0].Platform)
+		}
+		for _, stage := range buildItems[0].Config.Stages {
+			for _, step := range stage.Steps {
+				if arch := step.Environment["CI_SYSTEM_ARCH"]; arch != tt.platform {
+					t.Errorf("%s: want step %s arch %s, got %s", tt.name, step.Name, tt.platform, arch)
+				}
+			}
+		}
+	}
+}
+
+func TestManualPipeline(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name   string
+		event  string
+		states map[string]string
+	}{
+		{
+			name:  "Skip manual pipelines for pushes",
+			event: model.EventPush,
+			states: map[string]string{
+				"build":  model.StatusPending,
+				"deploy": model.StatusSkipped,
+				"notify": model.StatusSkipped,
+			},
+		},
+		{
+			name:  "Run manual pipelines for manual builds",
+			event: model.EventManual,
+			states: map[string]string{
+				"build":  model.StatusPending,
+				"deploy": model.StatusPending,
+				"notify": model.StatusPending,
+			},
+		},
+	}
+
+	for _, tt := range testTable {
+		b := procBuilder{
+			Repo:  &model.Repo{},
+			Curr:  &model.Build{Event: tt.event},
+			Last:  &model.Build{},
+			Netrc: &model.Netrc{},
+			Secs:  []*model.Secret{},
+			Regs:  []*model.Registry{},
+			Link:  "",
+			Yamls: []*remote.FileMeta{
+				&remote.FileMeta{Name: "build", Data: []byte(`
+pipeline:
+  build:
+    image: scratch
+`)},
+				&remote.FileMeta{Name: "deploy", Data: []byte(`
+manual: true
+pipeline:
+  deploy:
+    image: scratch
+depends_on: [ build ]
+`)},
+				&remote.FileMeta{Name: "notify", Data: []byte(`
+pipeline:
+  notify:
+    image: scratch
+depends_on: [ deploy ]
+`)},
+			},
+		}
+
+		buildItems, err := b.Build()
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if len(buildItems) != len(tt.states) {
+			t.Errorf("%s: want %d build items, got %d", tt.name, len(tt.states), len(buildItems))
+		}
+		for _, item := range buildItems {
+			if state := tt.states[item.Proc.Name]; item.Proc.State != state {
+				t.Errorf("%s: want pipeline %s %s, got %s", tt.name, item.Proc.Name, state, item.Proc.State)
+			}
+		}
+	}
+}
+
+func TestStepArtifacts(t *testing.T) {
+	t.Parallel()
+
+	b := procBuilder{
+		Repo:  &model.Repo{Link: "https://github.com/octocat/hello-world"},
+		Curr:  &model.Build{},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Link:  "",
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Data: []byte(`
+pipeline:
+  build:
+    image: scratch
+    commands:
+      - make
+    artifacts:
+      - dist/app
+      - coverage.out
+`)},
+		},
+	}
+
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stages := buildItems[0].Config.Stages
+	step := stages[len(stages)-1].Steps[0]
+	want := []string{
+		"/drone/src/github.com/octocat/hello-world/dist/app",
+		"/drone/src/github.com/octocat/hello-world/coverage.out",
+	}
+	if len(step.Artifacts) != len(want) {
+		t.Fatalf("want artifacts %v, got %v", want, step.Artifacts)
+	}
+	for i := range want {
+		if step.Artifacts[i] != want[i] {
+			t.Errorf("want artifact %s, got %s", want[i], step.Artifacts[i])
+		}
+	}
+}
+
+func TestBranchEnviron(t *testing.T) {
+	t.Parallel()
+
+	repo := &model.Repo{
+		BranchEnviron: []model.BranchEnviron{
+			{Branch: "*", Environ: map[string]string{"DEPLOY_ENV": "staging", "REGION": "eu"}},
+			{Branch: "main", Environ: map[string]string{"DEPLOY_ENV": "prod", "CI_COMMIT_BRANCH": "shadowed"}},
+		},
+	}
+
+	testTable := []struct {
+		name      string
+		branch    string
+		deployEnv string
+	}{
+		{
+			name:      "Main branch",
+			branch:    "main",
+			deployEnv: "prod",
+		},
+		{
+			name:      "Feature branch",
+			branch:    "develop",
+			deployEnv: "staging",
+		},
+	}
+
+	for _, tt := range testTable {
+		b := procBuilder{
+			Repo:  repo,
+			Curr:  &model.Build{Branch: tt.branch},
+			Last:  &model.Build{},
+			Netrc: &model.Netrc{},
+			Secs:  []*model.Secret{},
+			Regs:  []*model.Registry{},
+			Link:  "",
+			Yamls: []*remote.FileMeta{
+				&remote.FileMeta{Data: []byte(`
+pipeline:
+  deploy:
+    image: scratch
+    environment:
+      TARGET: ${DEPLOY_ENV}-${REGION}
+`)},
+			},
+		}
+
+		buildItems, err := b.Build()
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		step := buildItems[0].Config.Stages[1].Steps[0]
+		if env := step.Environment["DEPLOY_ENV"]; env != tt.deployEnv {
+			t.Errorf("%s: want DEPLOY_ENV %s, got %s", tt.name, tt.deployEnv, env)
+		}
+		if branch := step.Environment["CI_COMMIT_BRANCH"]; branch != tt.branch {
+			t.Errorf("%s: want the build metadata to take precedence, got branch %s", tt.name, branch)
+		}
+		if target, want := step.Environment["TARGET"], tt.deployEnv+"-eu"; target != want {
+			t.Errorf("%s: want substituted TARGET %s, got %s", tt.name, want, target)
+		}
+	}
+}
+
+func TestBranchEnvironMatrix(t *testing.T) {
+	t.Parallel()
+
+	b := procBuilder{
+		Repo: &model.Repo{
+			BranchEnviron: []model.BranchEnviron{
+				{Branch: "main", Environ: map[string]string{"GO_VERSION": "1.14"}},
+			},
+		},
+		Curr:  &model.Build{Branch: "main"},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Link:  "",
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Data: []byte(`
+pipeline:
+  build:
+    image: golang:${GO_VERSION}
+matrix:
+  GO_VERSION:
+    - 1.15
+`)},
+		},
+	}
+
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := buildItems[0].Config.Stages[1].Steps[0]
+	if env := step.Environment["GO_VERSION"]; env != "1.15" {
+		t.Errorf("want matrix variables to take precedence, got GO_VERSION %s", env)
+	}
+	if step.Image != "docker.io/library/golang:1.15" {
+		t.Errorf("want the matrix variable substituted, got image %s", step.Image)
+	}
+}
+
+func TestBuildConcurrent(t *testing.T) {
+	defer func(workers int) { buildWorkers = workers }(buildWorkers)
+
+	var yamls []*remote.FileMeta
+	for i := 0; i < 20; i++ {
+		data := fmt.Sprintf(`
+pipeline:
+  build:
+    image: golang
+    commands:
+      - go test ./pkg%d
+matrix:
+  GO_VERSION: [ 1.15, 1.16 ]
+`, i)
+		if i%5 == 0 {
+			data = fmt.Sprintf(`
+pipeline:
+  build:
+    image: golang
+    commands:
+      - echo job ${CI_JOB_NUMBER} of pkg%d
+`, i)
+		}
+		yamls = append(yamls, &remote.FileMeta{Name: fmt.Sprintf("pkg%d.yml", i), Data: []byte(data)})
+	}
+	build := func(workers int) []*buildItem {
+		buildWorkers = workers
+		b := procBuilder{
+			Repo:   &model.Repo{},
+			Curr:   &model.Build{},
+			Last:   &model.Build{},
+			Netrc:  &model.Netrc{},
+			Yamls:  yamls,
+			Prefix: "test",
+		}
+		items, err := b.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return items
+	}
+
+	serial, parallel := build(1), build(8)
+	if len(serial) != len(parallel) {
+		t.Fatalf("want %d build items, got %d", len(serial), len(parallel))
+	}
+	for i := range serial {
+		s, p := serial[i], parallel[i]
+		if s.Proc.PID != p.Proc.PID || s.Proc.PGID != p.Proc.PGID || s.Proc.Name != p.Proc.Name {
+			t.Errorf("want proc %d %s, got proc %d %s", s.Proc.PID, s.Proc.Name, p.Proc.PID, p.Proc.Name)
+		}
+		if got, want := p.Proc.Environ["GO_VERSION"], s.Proc.Environ["GO_VERSION"]; got != want {
+			t.Errorf("want proc %d GO_VERSION %s, got %s", s.Proc.PID, want, got)
+		}
+		sStep, pStep := s.Config.Stages[1].Steps[0], p.Config.Stages[1].Steps[0]
+		if got, want := pStep.Environment["CI_JOB_NUMBER"], strconv.Itoa(s.Proc.PID); got != want {
+			t.Errorf("want proc %d CI_JOB_NUMBER %s, got %s", s.Proc.PID, want, got)
+		}
+		if pStep.Environment["CI_SCRIPT"] != sStep.Environment["CI_SCRIPT"] {
+			t.Errorf("want proc %d script to match the serial build", s.Proc.PID)
+		}
+		if suffix := fmt.Sprintf("_%d_step_0", s.Proc.PID); !strings.HasSuffix(pStep.Name, suffix) {
+			t.Errorf("want proc %d container %s named after its pid", s.Proc.PID, pStep.Name)
+		}
+	}
+}
+
+func BenchmarkBuildManyFiles(b *testing.B) {
+	var yamls []*remote.FileMeta
+	for i := 0; i < 100; i++ {
+		yamls = append(yamls, &remote.FileMeta{
+			Name: fmt.Sprintf("services/svc%d.yml", i),
+			Data: []byte(fmt.Sprintf("pipeline:\n  build:\n    image: golang\n    commands: [ go test ./svc%d/... ]\n", i)),
+		})
+	}
+	builder := procBuilder{
+		Repo:  &model.Repo{IsTrusted: true},
+		Curr:  &model.Build{},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Yamls: yamls,
+	}
+	for i := 0; i < b.N; i++ {
+		if _, err := builder.Build(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestBuildGolden(t *testing.T) {
+	build := func() []byte {
+		b := procBuilder{
+			Repo: &model.Repo{
+				FullName:  "octocat/hello-world",
+				Link:      "https://github.com/octocat/hello-world",
+				Clone:     "https://github.com/octocat/hello-world.git",
+				Branch:    "main",
+				Config:    ".drone/",
+				IsPrivate: true,
+			},
+			Curr: &model.Build{
+				ID:      42,
+				Number:  7,
+				Event:   model.EventPush,
+				Branch:  "main",
+				Ref:     "refs/heads/main",
+				Commit:  "d670460b4b4aece5915caf5c68d12f560a9fe3e4",
+				Message: "Update the README",
+				Author:  "octocat",
+				Created: 1600000000,
+				Started: 1600000060,
+			},
+			Last:   &model.Build{Number: 6, Status: model.StatusSuccess, Created: 1599990000},
+			Netrc:  &model.Netrc{Machine: "github.com", Login: "octocat", Password: "hunter2"},
+			Secs:   []*model.Secret{{Name: "token", Value: "s3cr3t", Events: []string{model.EventPush}}},
+			Regs:   []*model.Registry{{Address: "docker.io", Username: "octocat", Password: "hunter2"}},
+			Link:   "https://ci.example.com",
+			Prefix: "golden",
+			Yamls: []*remote.FileMeta{
+				{Name: ".drone/build.yml", Data: []byte(`
+pipeline:
+  build:
+    image: golang:${GO_VERSION}
+    commands:
+      - go test ./...
+  publish:
+    image: plugins/docker
+    repo: octocat/hello-world
+    tags: [ latest, "${DRONE_BUILD_NUMBER}" ]
+    build_args: { GO_VERSION: "${GO_VERSION}", CI: "true" }
+    secrets: [ token ]
+services:
+  database:
+    image: postgres
+matrix:
+  GO_VERSION: [ "1.15", "1.16" ]
+`)},
+				{Name: ".drone/deploy.yml", Data: []byte(`
+pipeline:
+  deploy:
+    image: alpine
+    commands:
+      - echo deploying job ${CI_JOB_NUMBER}
+depends_on: [ build ]
+`)},
+			},
+		}
+		items, err := b.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := json.MarshalIndent(items, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	got := build()
+	if again := build(); !bytes.Equal(got, again) {
+		t.Fatal("Want the same compiled configuration for the same build")
+	}
+
+	golden := filepath.Join("testdata", "procBuilder.golden")
+	if *update {
+		if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Want the compiled configuration to match %s, run the test with -update to update it", golden)
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	t.Parallel()
+
+	b := procBuilder{
+		Repo:  &model.Repo{IsPrivate: true},
+		Curr:  &model.Build{Event: model.EventPush},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{Machine: "github.com", Login: "octocat", Password: "n3trc"},
+		Secs: []*model.Secret{
+			{Name: "token", Value: "s3cr3t", Events: []string{model.EventPush}},
+		},
+		Regs: []*model.Registry{{Address: "docker.io", Username: "octocat", Password: "hunter2"}},
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Data: []byte(`
+pipeline:
+  publish:
+    image: plugins/docker
+    environment:
+      AUTHORIZATION: Bearer s3cr3t
+    secrets: [ token ]
+services:
+  database:
+    image: postgres
+`)},
+		},
+	}
+
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := buildItems[0]
+
+	data, err := item.ExportJSON(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := new(backend.Config)
+	if err := json.Unmarshal(data, config); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config, item.Config) {
+		t.Errorf("Want the exported configuration to round trip")
+	}
+	if len(config.Stages) == 0 || len(config.Networks) == 0 || len(config.Volumes) == 0 || len(config.Secrets) == 0 {
+		t.Errorf("Want the stages, networks, volumes and secrets exported, got %s", data)
+	}
+	if again, _ := item.ExportJSON(true); !bytes.Equal(data, again) {
+		t.Errorf("Want the same JSON for the same configuration")
+	}
+
+	masked, err := item.ExportJSON(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []string{"s3cr3t", "n3trc", "hunter2"} {
+		if bytes.Contains(masked, []byte(value)) {
+			t.Errorf("Want the credential %s masked, got %s", value, masked)
+		}
+	}
+	config = new(backend.Config)
+	if err := json.Unmarshal(masked, config); err != nil {
+		t.Fatal(err)
+	}
+	step := config.Stages[len(config.Stages)-1].Steps[0]
+	if got, want := step.Environment["AUTHORIZATION"], "Bearer ********"; got != want {
+		t.Errorf("Want the secret masked in the environment %q, got %q", want, got)
+	}
+	if got, want := step.Environment["TOKEN"], "********"; got != want {
+		t.Errorf("Want the secret masked %q, got %q", want, got)
+	}
+	if !bytes.Contains(data, []byte("s3cr3t")) {
+		t.Errorf("Want the secret values exported when included")
+	}
+	if item.Config.Secrets[0].Value == "********" {
+		t.Errorf("Want the compiled configuration not modified by masking")
+	}
+}
+
+func TestSecretRedactions(t *testing.T) {
+	t.Parallel()
+
+	b := procBuilder{
+		Repo:  &model.Repo{},
+		Curr:  &model.Build{Event: model.EventPush},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs: []*model.Secret{
+			{Name: "password", Value: "p@ss word/1", Events: []string{model.EventPush}},
+			{Name: "key", Value: "-----BEGIN KEY-----\nMIIEow\n-----END KEY-----", Events: []string{model.EventPush}},
+		},
+		Regs: []*model.Registry{},
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Data: []byte(`
+pipeline:
+  build:
+    image: alpine
+    secrets: [ password, key ]
+    commands:
+      - echo $PASSWORD | base64
+`)},
+		},
+	}
+
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	masked := map[string]bool{}
+	for _, sec := range buildItems[0].Config.Secrets {
+		if sec.Mask {
+			masked[sec.Value] = true
+		}
+	}
+	for _, value := range []string{
+		"p@ss word/1",
+		"cEBzcyB3b3JkLzE",     // base64
+		"p%40ss+word%2F1",     // query escaped
+		"p@ss%20word%2F1",     // path escaped
+		"-----BEGIN KEY-----", // lines of multiline secrets
+		"MIIEow",
+		"-----BEGIN KEY-----\\nMIIEow\\n-----END KEY-----", // json escaped
+	} {
+		if !masked[value] {
+			t.Errorf("Want %s registered for redaction", value)
+		}
+	}
+}
+
+func TestSecretMatrixAxes(t *testing.T) {
+	t.Parallel()
+
+	b := procBuilder{
+		Repo:  &model.Repo{},
+		Curr:  &model.Build{Event: model.EventPush},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs: []*model.Secret{
+			{Name: "token", Value: "any"},
+			{Name: "aws_key", Value: "aws", Matrix: map[string]string{"cloud": "aws"}},
+			{Name: "aws_linux_key", Value: "aws-linux", Matrix: map[string]string{"cloud": "aws", "os": "linux"}},
+			{Name: "gcp_key", Value: "gcp", Matrix: map[string]string{"cloud": "gcp"}, Events: []string{model.EventTag}},
+			{Name: "region_key", Value: "region", Matrix: map[string]string{"region": "*"}},
+		},
+		Regs: []*model.Registry{},
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Data: []byte(`
+pipeline:
+  build:
+    image: alpine
+    secrets: [ token, aws_key, aws_linux_key, gcp_key, region_key ]
+    commands:
+      - echo deploying
+
+matrix:
+  cloud:
+    - aws
+    - gcp
+  os:
+    - linux
+    - windows
+`)},
+		},
+	}
+
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buildItems) != 4 {
+		t.Fatalf("Want 4 pipelines, got %d", len(buildItems))
+	}
+	for _, item := range buildItems {
+		axis := item.Proc.Environ
+		want := []string{"TOKEN"}
+		if axis["cloud"] == "aws" {
+			want = append(want, "AWS_KEY")
+			if axis["os"] == "linux" {
+				want = append(want, "AWS_LINUX_KEY")
+			}
+		}
+
+		env := item.Config.Stages[1].Steps[0].Environment
+		var got []string
+		for _, name := range []string{"TOKEN", "AWS_KEY", "AWS_LINUX_KEY", "GCP_KEY", "REGION_KEY"} {
+			if _, ok := env[name]; ok {
+				got = append(got, name)
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Want secrets %v for axis %v, got %v", want, axis, got)
+		}
+	}
+}
+
+func TestPipelineEnvironOverride(t *testing.T) {
+	Config.Pipeline.EnvironOverride = []string{"PROXY", "DEBUG", "CI_REPO"}
+	defer func() { Config.Pipeline.EnvironOverride = nil }()
+
+	b := procBuilder{
+		Repo: &model.Repo{
+			FullName: "octocat/hello-world",
+			BranchEnviron: []model.BranchEnviron{
+				{Branch: "*", Environ: map[string]string{"PROXY": "http://branch", "REGION": "eu-west"}},
+			},
+		},
+		Curr:  &model.Build{Event: model.EventPush, Branch: "master"},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Envs: map[string]string{
+			"PROXY":   "http://proxy",
+			"DEBUG":   "false",
+			"REGION":  "eu",
+			"CI_REPO": "overridden",
+		},
+		Params: map[string]string{"PROXY": "http://params"},
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Data: []byte(`
+environment:
+  REGION: us
+pipeline:
+  build:
+    image: golang
+    environment:
+      PROXY: http://step
+      DEBUG: "true"
+`)},
+		},
+	}
+
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := buildItems[0].Config.Stages[0].Steps[0]
+	build := buildItems[0].Config.Stages[1].Steps[0]
+	for name, want := range map[string]string{
+		"PROXY":   "http://proxy",        // override over step, branch and parameters
+		"DEBUG":   "false",               // override with a false value
+		"REGION":  "us",                  // pipeline over global default
+		"CI_REPO": "octocat/hello-world", // built-in never overridden
+	} {
+		if got := build.Environment[name]; got != want {
+			t.Errorf("Want %s=%s, got %q", name, want, got)
+		}
+	}
+	if got := clone.Environment["PROXY"]; got != "http://proxy" {
+		t.Errorf("Want the override in the clone step, got PROXY %q", got)
+	}
+}
+
+func TestPipelineEnvironPrecedence(t *testing.T) {
+	t.Parallel()
+
+	b := procBuilder{
+		Repo: &model.Repo{
+			BranchEnviron: []model.BranchEnviron{
+				{Branch: "*", Environ: map[string]string{"DEPLOY_ENV": "staging", "LOG_LEVEL": "debug"}},
+			},
+		},
+		Curr:   &model.Build{Event: model.EventPush, Branch: "master"},
+		Last:   &model.Build{},
+		Netrc:  &model.Netrc{},
+		Envs:   map[string]string{"REGION": "eu", "PROXY": "http://proxy"},
+		Params: map[string]string{"VERSION": "1.2.3"},
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Data: []byte(`
+environment:
+  DEPLOY_ENV: prod
+  REGION: us
+  VERSION: 0.0.0
+  GO_VERSION: overridden
+pipeline:
+  build:
+    image: golang
+  deploy:
+    image: alpine
+    environment:
+      REGION: ap
+matrix:
+  GO_VERSION:
+    - 1.16
+`)},
+		},
+	}
+
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	build := buildItems[0].Config.Stages[1].Steps[0]
+	deploy := buildItems[0].Config.Stages[2].Steps[0]
+	for _, step := range []*backend.Step{build, deploy} {
+		for name, want := range map[string]string{
+			"DEPLOY_ENV": "prod",         // pipeline over branch environment
+			"LOG_LEVEL":  "debug",        // branch environment
+			"PROXY":      "http://proxy", // global environment
+			"VERSION":    "1.2.3",        // build parameters over pipeline
+			"GO_VERSION": "1.16",         // matrix over pipeline
+		} {
+			if got := step.Environment[name]; got != want {
+				t.Errorf("Want %s=%s in step %s, got %q", name, want, step.Alias, got)
+			}
+		}
+	}
+	if got := build.Environment["REGION"]; got != "us" {
+		t.Errorf("Want the pipeline environment to override the global environment, got REGION %q", got)
+	}
+	if got := deploy.Environment["REGION"]; got != "ap" {
+		t.Errorf("Want the step environment to override the pipeline environment, got REGION %q", got)
+	}
+}
+
+func TestExecutionPlan(t *testing.T) {
+	t.Parallel()
+
+	item := func(name string, deps ...string) *buildItem {
+		return &buildItem{Proc: &model.Proc{Name: name}, DependsOn: deps}
+	}
+	levels := func(plan [][]*buildItem) string {
+		var out []string
+		for _, level := range plan {
+			var names []string
+			for _, item := range level {
+				names = append(names, item.Proc.Name)
+			}
+			out = append(out, strings.Join(names, ","))
+		}
+		return strings.Join(out, " | ")
+	}
+
+	testTable := []struct {
+		name  string
+		items []*buildItem
+		want  string
+	}{
+		{
+			name:  "linear",
+			items: []*buildItem{item("deploy", "test"), item("test", "build"), item("build")},
+			want:  "build | test | deploy",
+		},
+		{
+			name:  "diamond",
+			items: []*buildItem{item("build"), item("lint", "build"), item("test", "build"), item("deploy", "lint", "test")},
+			want:  "build | lint,test | deploy",
+		},
+		{
+			name:  "parallel",
+			items: []*buildItem{item("backend"), item("frontend"), item("docs")},
+			want:  "backend,frontend,docs",
+		},
+		{
+			name:  "matrix",
+			items: []*buildItem{item("test"), item("test"), item("release", "test"), item("notify", "release")},
+			want:  "test,test | release | notify",
+		},
+	}
+	for _, tt := range testTable {
+		plan, err := executionPlan(tt.items)
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if got := levels(plan); got != tt.want {
+			t.Errorf("%s: want plan %s, got %s", tt.name, tt.want, got)
+		}
+	}
+
+	_, err := executionPlan([]*buildItem{item("build", "deploy"), item("test", "build"), item("deploy", "test")})
+	if err == nil {
+		t.Fatal("Want an error for a cyclic dependency")
+	}
+	if want := "Pipeline build has a cyclic dependency build -> deploy -> test -> build"; err.Error() != want {
+		t.Errorf("Want error %q, got %q", want, err)
+	}
+}
+
+func TestBuildResultPlan(t *testing.T) {
+	t.Parallel()
+
+	b := procBuilder{
+		Repo:  &model.Repo{Config: ".woodpecker/"},
+		Curr:  &model.Build{},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Yamls: []*remote.FileMeta{
+			{Name: ".woodpecker/deploy.yml", Data: []byte("pipeline:\n  deploy:\n    image: alpine\ndepends_on: [ build ]\n")},
+			{Name: ".woodpecker/build.yml", Data: []byte("pipeline:\n  build:\n    image: alpine\n")},
+		},
+	}
+	result, err := b.Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Plan) != 2 || result.Plan[0][0].Proc.Name != "build" || result.Plan[1][0].Proc.Name != "deploy" {
+		t.Errorf("Want build planned before deploy, got %d levels", len(result.Plan))
+	}
+
+	b.Yamls[1].Data = []byte("pipeline:\n  build:\n    image: alpine\ndepends_on: [ deploy ]\n")
+	if _, err := b.Result(); err == nil {
+		t.Errorf("Want an error for cyclic pipeline dependencies")
+	}
+}
+
+func TestPipelineNames(t *testing.T) {
+	t.Parallel()
+
+	yamls := []*remote.FileMeta{
+		{Name: ".woodpecker/.ci.yml"},
+		{Name: ".woodpecker/build.yml"},
+		{Name: ".woodpecker/ci.yml"},
+	}
+
+	if _, err := pipelineNames(yamls, ".woodpecker/", NameCollisionFail, nil); err == nil {
+		t.Errorf("Want an error for colliding pipeline names")
+	} else if !strings.Contains(err.Error(), ".woodpecker/.ci.yml, .woodpecker/ci.yml") {
+		t.Errorf("Want the error to name the colliding files, got %s", err)
+	}
+	names, err := pipelineNames(yamls, ".woodpecker/", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ci", "build", "ci"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Want colliding pipeline names allowed by default, got %v", names)
+	}
+
+	names, err = pipelineNames(yamls, ".woodpecker/", NameCollisionDisambiguate, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"ci", "build", ".woodpecker/ci"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("Want names %v, got %v", want, names)
+	}
+
+	names, err = pipelineNames(yamls[1:2], ".woodpecker/", NameCollisionFail, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"build"}) {
+		t.Errorf("Want names without collisions unchanged, got %v", names)
+	}
+}
+
+func TestPipelineNameTransform(t *testing.T) {
+	t.Parallel()
+
+	transform := &NameTransform{
+		Pattern:     regexp.MustCompile(`^pipelines/(\w+)-ci$`),
+		Replacement: "${1}",
+	}
+	yamls := []*remote.FileMeta{
+		{Name: ".woodpecker/pipelines/backend-ci.yml"},
+		{Name: ".woodpecker/pipelines/frontend-ci.yml"},
+		{Name: ".woodpecker/release.yml"},
+	}
+	names, err := pipelineNames(yamls, ".woodpecker/", NameCollisionFail, transform)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"backend", "frontend", "release"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Want names %v, got %v", want, names)
+	}
+
+	// the transform may map files to the name of another file.
+	yamls = append(yamls, &remote.FileMeta{Name: ".woodpecker/backend.yml"})
+	if _, err := pipelineNames(yamls, ".woodpecker/", NameCollisionFail, transform); err == nil {
+		t.Errorf("Want an error for pipeline names colliding after the transform")
+	} else if !strings.Contains(err.Error(), "share the name backend") {
+		t.Errorf("Want the error to name the colliding name, got %s", err)
+	}
+	names, err = pipelineNames(yamls, ".woodpecker/", NameCollisionDisambiguate, transform)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"backend", "frontend", "release", ".woodpecker/backend"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Want names %v, got %v", want, names)
+	}
+
+	invalid := &NameTransform{Pattern: regexp.MustCompile(`.*`), Replacement: "my pipeline"}
+	if _, err := pipelineNames(yamls[2:3], ".woodpecker/", NameCollisionFail, invalid); err == nil {
+		t.Errorf("Want an error for an invalid pipeline name")
+	}
+	empty := &NameTransform{Pattern: regexp.MustCompile(`^release$`)}
+	if _, err := pipelineNames(yamls[2:3], ".woodpecker/", NameCollisionFail, empty); err == nil {
+		t.Errorf("Want an error for an empty pipeline name")
+	}
+}
+
+func TestBuildNameTransform(t *testing.T) {
+	defer func(transform *NameTransform) {
+		Config.Pipeline.NameTransform = transform
+	}(Config.Pipeline.NameTransform)
+	Config.Pipeline.NameTransform = &NameTransform{
+		Pattern: regexp.MustCompile(`^pipelines/`),
+	}
+
+	b := procBuilder{
+		Repo:  &model.Repo{Config: ".woodpecker/"},
+		Curr:  &model.Build{},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Yamls: []*remote.FileMeta{
+			{Name: ".woodpecker/pipelines/build.yml", Data: []byte("pipeline:\n  build:\n    image: alpine\n")},
+			{Name: ".woodpecker/pipelines/deploy.yml", Data: []byte("pipeline:\n  deploy:\n    image: alpine\ndepends_on: [ build ]\n")},
+		},
+	}
+	result, err := b.Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Plan) != 2 || result.Plan[0][0].Proc.Name != "build" || result.Plan[1][0].Proc.Name != "deploy" {
+		t.Errorf("Want the transformed names used by depends_on, got %d levels", len(result.Plan))
+	}
+}
+
+func TestBuildNameCollision(t *testing.T) {
+	defer func(collision string) {
+		Config.Pipeline.NameCollision = collision
+	}(Config.Pipeline.NameCollision)
+
+	b := procBuilder{
+		Repo:  &model.Repo{Config: ".woodpecker/"},
+		Curr:  &model.Build{},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Yamls: []*remote.FileMeta{
+			{Name: ".woodpecker/.ci.yml", Data: []byte("pipeline:\n  lint:\n    image: alpine\n")},
+			{Name: ".woodpecker/ci.yml", Data: []byte("pipeline:\n  test:\n    image: alpine\n")},
+			{Name: ".woodpecker/deploy.yml", Data: []byte("pipeline:\n  deploy:\n    image: alpine\ndepends_on: [ .woodpecker/ci ]\n")},
+		},
+	}
+
+	Config.Pipeline.NameCollision = NameCollisionAllow
+	if _, err := b.Build(); err != nil {
+		t.Errorf("Want colliding pipeline names allowed, got %s", err)
+	}
+
+	Config.Pipeline.NameCollision = NameCollisionFail
+	if _, err := b.Build(); err == nil {
+		t.Errorf("Want an error for colliding pipeline names")
+	}
+
+	Config.Pipeline.NameCollision = NameCollisionDisambiguate
+	result, err := b.Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Items) != 3 {
+		t.Fatalf("Want 3 pipelines, got %d", len(result.Items))
+	}
+	if len(result.Plan) != 2 || len(result.Plan[1]) != 1 || result.Plan[1][0].Proc.Name != "deploy" {
+		t.Errorf("Want deploy planned after the pipeline it depends on")
+	}
+	var names []string
+	for _, item := range result.Plan[0] {
+		names = append(names, item.Proc.Name)
+	}
+	sort.Strings(names)
+	if want := []string{".woodpecker/ci", "ci"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Want the first pipeline to keep its name and the other renamed, got %v", names)
+	}
+}
+
+func TestFailFast(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name     string
+		deploy   string
+		failFast bool
+	}{
+		{
+			name: "Do not fail fast by default",
+			deploy: `
+pipeline:
+  deploy:
+    image: scratch
+`,
+		},
+		{
+			name: "Fail fast for the whole build",
+			deploy: `
+fail_fast: true
+pipeline:
+  deploy:
+    image: scratch
+`,
+			failFast: true,
+		},
+		{
+			name: "Ignore fail fast of skipped pipelines",
+			deploy: `
+fail_fast: true
+branches: release
+pipeline:
+  deploy:
+    image: scratch
+`,
+		},
+	}
+
+	for _, tt := range testTable {
+		b := procBuilder{
+			Repo:  &model.Repo{},
+			Curr:  &model.Build{Event: model.EventPush, Branch: "master"},
+			Last:  &model.Build{},
+			Netrc: &model.Netrc{},
+			Secs:  []*model.Secret{},
+			Regs:  []*model.Registry{},
+			Link:  "",
+			Yamls: []*remote.FileMeta{
+				&remote.FileMeta{Name: "build", Data: []byte(`
+pipeline:
+  build:
+    image: scratch
+`)},
+				&remote.FileMeta{Name: "deploy", Data: []byte(tt.deploy)},
+			},
+		}
+
+		buildItems, err := b.Build()
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if len(buildItems) != 2 {
+			t.Fatalf("%s: want 2 build items, got %d", tt.name, len(buildItems))
+		}
+		for _, item := range buildItems {
+			if item.FailFast != tt.failFast {
+				t.Errorf("%s: want pipeline %s fail fast %v, got %v", tt.name, item.Proc.Name, tt.failFast, item.FailFast)
+			}
+		}
+	}
+}
+
+func TestMatrixTopics(t *testing.T) {
+	t.Parallel()
+
+	testTable := []struct {
+		name    string
+		topics  []string
+		environ []string
+	}{
+		{
+			name:    "Expand the matrix to the language topics",
+			topics:  []string{"lang-python", "lang-go", "docker"},
+			environ: []string{"go", "python"},
+		},
+		{
+			name:    "Build the inline languages without topics",
+			environ: []string{"go"},
+		},
+	}
+
+	for _, tt := range testTable {
+		b := procBuilder{
+			Repo:  &model.Repo{Topics: tt.topics},
+			Curr:  &model.Build{Event: model.EventPush},
+			Last:  &model.Build{},
+			Netrc: &model.Netrc{},
+			Secs:  []*model.Secret{},
+			Regs:  []*model.Registry{},
+			Link:  "",
+			Yamls: []*remote.FileMeta{
+				&remote.FileMeta{Data: []byte(`
+matrix:
+  topics:
+    LANGUAGE: lang-
+  LANGUAGE:
+    - go
+pipeline:
+  test:
+    image: ${LANGUAGE}
+`)},
+			},
+		}
+
+		buildItems, err := b.Build()
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		var languages []string
+		for _, item := range buildItems {
+			languages = append(languages, item.Proc.Environ["LANGUAGE"])
+		}
+		sort.Strings(languages)
+		if !reflect.DeepEqual(languages, tt.environ) {
+			t.Errorf("%s: want matrix languages %v, got %v", tt.name, tt.environ, languages)
+		}
+	}
+}
+
+func TestProcLimit(t *testing.T) {
+	defer func() { Config.Pipeline.ProcLimit = 0 }()
+
+	testTable := []struct {
+		name  string
+		limit int
+		err   string
+	}{
+		{
+			name: "No limit",
+		},
+		{
+			name:  "At the limit",
+			limit: 5,
+		},
+		{
+			name:  "Over the limit",
+			limit: 4,
+			err:   "build creates 5 procs, pipelines and their steps, exceeding the server limit of 4",
+		},
+	}
+
+	for _, tt := range testTable {
+		Config.Pipeline.ProcLimit = tt.limit
+
+		b := procBuilder{
+			Repo:  &model.Repo{},
+			Curr:  &model.Build{},
+			Last:  &model.Build{},
+			Netrc: &model.Netrc{},
+			Secs:  []*model.Secret{},
+			Regs:  []*model.Registry{},
+			Link:  "",
+			Yamls: []*remote.FileMeta{
+				&remote.FileMeta{Name: "a", Data: []byte(`
+skip_clone: true
+pipeline:
+  build:
+    image: scratch
+  test:
+    image: scratch
+`)},
+				&remote.FileMeta{Name: "b", Data: []byte(`
+skip_clone: true
+pipeline:
+  deploy:
+    image: scratch
+`)},
+			},
+		}
+
+		result, err := b.Result()
+		if tt.err == "" {
+			if err != nil {
+				t.Fatalf("%s: %s", tt.name, err)
+			}
+			if result.ProcCount != 5 {
+				t.Errorf("%s: want 5 procs, got %d", tt.name, result.ProcCount)
+			}
+			if procs := setBuildStepsOnBuild(&model.Build{}, result.Items).Procs; len(procs) != result.ProcCount {
+				t.Errorf("%s: want %d procs created, got %d", tt.name, result.ProcCount, len(procs))
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%s: want error %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestCacheVolumes(t *testing.T) {
+	defer func(enabled bool) { Config.Pipeline.CacheVolumes = enabled }(Config.Pipeline.CacheVolumes)
+
+	testdata := []struct {
+		enabled bool
+		trusted bool
+		caches  int
+	}{
+		{enabled: false, trusted: true, caches: 0},
+		{enabled: true, trusted: false, caches: 0},
+		{enabled: true, trusted: true, caches: 1},
+	}
+	for _, test := range testdata {
+		Config.Pipeline.CacheVolumes = test.enabled
+		b := procBuilder{
+			Repo:  &model.Repo{IsTrusted: test.trusted},
+			Curr:  &model.Build{Event: model.EventPush},
+			Last:  &model.Build{},
+			Netrc: &model.Netrc{},
+			Secs:  []*model.Secret{},
+			Regs:  []*model.Registry{},
+			Yamls: []*remote.FileMeta{
+				&remote.FileMeta{Data: []byte(`
+cache:
+  - node_modules
+pipeline:
+  build:
+    image: node
+`)},
+			},
+		}
+
+		buildItems, err := b.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(buildItems[0].Config.Caches); got != test.caches {
+			t.Errorf("Want %d caches with cache volumes %v for a trusted repository %v, got %d", test.caches, test.enabled, test.trusted, got)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/backend"
+)
+
+// minRedactionLength is the minimum length of a line of a multiline secret
+// to be redacted on its own, shorter lines would redact unrelated output.
+const minRedactionLength = 4
+
+// appendRedactions registers the common encodings of the masked secrets,
+// and the lines of multiline secrets, as masked secrets of their own. The
+// logs are redacted line by line, and secrets printed encoded, e.g. in an
+// authorization header, would not be redacted otherwise.
+func appendRedactions(secrets []*backend.Secret) []*backend.Secret {
+	seen := map[string]bool{}
+	for _, sec := range secrets {
+		if sec.Mask {
+			seen[sec.Value] = true
+		}
+	}
+
+	var redactions []*backend.Secret
+	add := func(name, value string, minLength int) {
+		if len(value) < minLength || seen[value] {
+			return
+		}
+		seen[value] = true
+		redactions = append(redactions, &backend.Secret{
+			Name:  name,
+			Value: value,
+			Mask:  true,
+		})
+	}
+	for _, sec := range secrets {
+		if !sec.Mask || sec.Value == "" {
+			continue
+		}
+		// the unpadded encodings also match the padded ones.
+		add(sec.Name+"_base64", base64.RawStdEncoding.EncodeToString([]byte(sec.Value)), 1)
+		add(sec.Name+"_base64url", base64.RawURLEncoding.EncodeToString([]byte(sec.Value)), 1)
+		add(sec.Name+"_query", url.QueryEscape(sec.Value), 1)
+		add(sec.Name+"_path", url.PathEscape(sec.Value), 1)
+		if quoted, err := json.Marshal(sec.Value); err == nil {
+			add(sec.Name+"_json", strings.Trim(string(quoted), `"`), 1)
+		}
+		if strings.Contains(sec.Value, "\n") {
+			for _, line := range strings.Split(sec.Value, "\n") {
+				add(sec.Name+"_line", strings.TrimSpace(line), minRedactionLength)
+			}
+		}
+	}
+	return append(secrets, redactions...)
+}
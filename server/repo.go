@@ -22,6 +22,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/securecookie"
+	"github.com/sirupsen/logrus"
 
 	"github.com/woodpecker-ci/woodpecker/model"
 	"github.com/woodpecker-ci/woodpecker/remote"
@@ -88,6 +89,7 @@ func PostRepo(c *gin.Context) {
 	if err == nil {
 		repo.Update(from)
 	}
+	repo.Topics = repoTopics(remote, user, repo)
 
 	err = store.UpdateRepo(c, repo)
 	if err != nil {
@@ -108,7 +110,7 @@ func PatchRepo(c *gin.Context) {
 		return
 	}
 
-	if (in.IsTrusted != nil || in.Timeout != nil) && !user.Admin {
+	if (in.IsTrusted != nil || in.Timeout != nil || in.MatrixLimit != nil || in.SkipLint != nil || in.AllowedEvents != nil) && !user.Admin {
 		c.String(403, "Insufficient privileges")
 		return
 	}
@@ -152,6 +154,47 @@ func PatchRepo(c *gin.Context) {
 	if in.Fallback != nil {
 		repo.Fallback = *in.Fallback
 	}
+	if in.BranchFallback != nil {
+		repo.BranchFallback = *in.BranchFallback
+	}
+	if in.IsGatedExternal != nil {
+		repo.IsGatedExternal = *in.IsGatedExternal
+	}
+	if in.StatusContext != nil {
+		repo.StatusContext = *in.StatusContext
+	}
+	if in.MatrixLimit != nil {
+		repo.MatrixLimit = *in.MatrixLimit
+	}
+	if in.SkipLint != nil {
+		repo.SkipLint = *in.SkipLint
+	}
+	if in.AllowedEvents != nil {
+		repo.AllowedEvents = *in.AllowedEvents
+	}
+	if in.ConfigRepo != nil {
+		if *in.ConfigRepo != "" {
+			owner, name, _, err := parseConfigRepo(*in.ConfigRepo)
+			if err != nil {
+				c.String(400, err.Error())
+				return
+			}
+			if !configRepoAllowed(owner + "/" + name) {
+				c.String(400, "Config repository %s/%s is not allowed", owner, name)
+				return
+			}
+		}
+		repo.ConfigRepo = *in.ConfigRepo
+	}
+	if in.BranchEnviron != nil {
+		for _, environ := range *in.BranchEnviron {
+			if err := environ.Validate(); err != nil {
+				c.String(400, err.Error())
+				return
+			}
+		}
+		repo.BranchEnviron = *in.BranchEnviron
+	}
 
 	err := store.UpdateRepo(c, repo)
 	if err != nil {
@@ -246,6 +289,8 @@ func RepairRepo(c *gin.Context) {
 		if repo.IsPrivate != from.IsPrivate {
 			repo.ResetVisibility()
 		}
+		repo.Description = from.Description
+		repo.Topics = repoTopics(remote, user, repo)
 		store.UpdateRepo(c, repo)
 	}
 
@@ -321,3 +366,55 @@ func MoveRepo(c *gin.Context) {
 	}
 	c.Writer.WriteHeader(http.StatusOK)
 }
+
+// GetRepoDiff returns the unified diff between the base and head commits
+// of the repository, if supported by the remote.
+func GetRepoDiff(c *gin.Context) {
+	remote_ := remote.FromContext(c)
+	repo := session.Repo(c)
+
+	base, head := c.Query("base"), c.Query("head")
+	if base == "" || head == "" {
+		c.String(400, "The base and head commits are required")
+		return
+	}
+
+	fetcher, ok := remote_.(remote.DiffFetcher)
+	if !ok {
+		c.String(501, "The remote does not support fetching diffs")
+		return
+	}
+
+	user, err := store.GetUser(c, repo.UserID)
+	if err != nil {
+		c.AbortWithError(500, err)
+		return
+	}
+	if refresher, ok := remote_.(remote.Refresher); ok {
+		ok, _ := refresher.Refresh(user)
+		if ok {
+			store.UpdateUser(c, user)
+		}
+	}
+
+	diff, err := fetcher.CommitDiff(user, repo, base, head)
+	if err != nil {
+		c.String(500, "Error fetching the diff. %s", err)
+		return
+	}
+	c.Data(200, "text/plain; charset=utf-8", diff)
+}
+
+// repoTopics returns the repository topics if supported by the remote.
+func repoTopics(remote_ remote.Remote, user *model.User, repo *model.Repo) []string {
+	lister, ok := remote_.(remote.TopicLister)
+	if !ok {
+		return repo.Topics
+	}
+	topics, err := lister.Topics(user, repo)
+	if err != nil {
+		logrus.Debugf("Error getting topics for %s. %s", repo.FullName, err)
+		return repo.Topics
+	}
+	return topics
+}
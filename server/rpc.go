@@ -83,10 +83,36 @@ var Config = struct {
 		AuthToken string
 	}
 	Pipeline struct {
-		Limits     model.ResourceLimit
-		Volumes    []string
-		Networks   []string
-		Privileged []string
+		Limits               model.ResourceLimit
+		Volumes              []string
+		Networks             []string
+		Privileged           []string
+		VerifyCommit         bool
+		CloneMaxDepth        int
+		CloneLFS             bool
+		FailOnMissingSecrets bool
+		TriggerPolicy        map[string]string
+		IgnoreAuthors        []string
+		CloneEnviron         map[string]string
+		SkipMarkers          []string
+		SkipEvents           []string
+		MatrixLimit          int
+		ProcLimit            int
+		SkipLintTrusted      bool
+		AllowedEvents        []string
+		ConfigRepos          []string
+		NameCollision        string
+		NameTransform        *NameTransform
+		EnvironSnapshot      string
+		TraceContext         bool
+		ForkConfigPolicy     string
+		UndefinedVariables   string
+		RegistryMirror       string
+		MirroredRegistries   []string
+		EnvironOverride      []string
+		ConfigSecret         string
+		ConfigSecretSize     int64
+		CacheVolumes         bool
 	}
 }{}
 
@@ -362,6 +388,9 @@ func (s *RPC) Done(c context.Context, id string, state rpc.State) error {
 		log.Printf("error: done: cannot update proc_id %d state: %s", proc.ID, err)
 	}
 
+	// the task is only known to the queue until it is acknowledged
+	failFast := taskFailFast(s.queue.Info(c), id)
+
 	var queueErr error
 	if proc.Failing() {
 		queueErr = s.queue.Error(c, id, fmt.Errorf("Proc finished with exitcode %d, %s", state.ExitCode, state.Error))
@@ -375,6 +404,12 @@ func (s *RPC) Done(c context.Context, id string, state rpc.State) error {
 	procs, _ := s.store.ProcList(build)
 	s.completeChildrenIfParentCompleted(procs, proc)
 
+	// the cancelled pipelines are killed, which must not cancel again.
+	if failFast && proc.Failing() && proc.State != model.StatusKilled {
+		cancelSiblings(c, s.queue, s.store, procs, proc)
+		procs, _ = s.store.ProcList(build)
+	}
+
 	if !isThereRunningStage(procs) {
 		if build, err = UpdateStatusToDone(s.store, *build, buildStatus(procs), proc.Stopped); err != nil {
 			log.Printf("error: done: cannot update build_id %d final state: %s", build.ID, err)
@@ -434,6 +469,73 @@ func (s *RPC) completeChildrenIfParentCompleted(procs []*model.Proc, completedPr
 	}
 }
 
+// taskFailFast returns true if the running task fails fast.
+func taskFailFast(info queue.InfoT, id string) bool {
+	for _, task := range info.Running {
+		if task.ID == id {
+			return task.FailFast
+		}
+	}
+	return false
+}
+
+// cancelSiblings cancels the pipelines of the build still running or
+// pending after the failed pipeline, like cancelling the build. Pipelines
+// running on failure are not cancelled, so they run once the pipelines they
+// depend on are done.
+func cancelSiblings(c context.Context, q queue.Queue, store UpdateProcStore, procs []*model.Proc, failed *model.Proc) {
+	runsOnFailure := map[string]bool{}
+	info := q.Info(c)
+	for _, tasks := range [][]*queue.Task{info.Pending, info.WaitingOnDeps, info.Running} {
+		for _, task := range tasks {
+			for _, status := range task.RunOn {
+				if status == queue.StatusFailure {
+					runsOnFailure[task.ID] = true
+				}
+			}
+		}
+	}
+
+	var toCancel, toEvict []string
+	evicted := map[int]bool{}
+	for _, p := range procs {
+		id := fmt.Sprint(p.ID)
+		if p.PPID != 0 || p.ID == failed.ID || runsOnFailure[id] {
+			continue
+		}
+		switch p.State {
+		case model.StatusRunning:
+			toCancel = append(toCancel, id)
+		case model.StatusPending:
+			toEvict = append(toEvict, id)
+			evicted[p.PID] = true
+		}
+	}
+	if len(toCancel) == 0 && len(toEvict) == 0 {
+		return
+	}
+	logrus.Debugf("fail fast after proc %d: cancel %v, evict %v", failed.ID, toCancel, toEvict)
+
+	q.EvictAtOnce(c, toEvict)
+	q.ErrorAtOnce(c, toEvict, queue.ErrCancel)
+	q.ErrorAtOnce(c, toCancel, queue.ErrCancel)
+
+	// running pipelines are updated when the agents stop on the cancel
+	// signal, pending ones are never picked up.
+	for _, p := range procs {
+		var err error
+		switch {
+		case p.PPID == 0 && evicted[p.PID]:
+			_, err = UpdateProcToStatusKilled(store, *p)
+		case p.PPID != 0 && evicted[p.PPID] && p.State == model.StatusPending:
+			_, err = UpdateProcToStatusSkipped(store, *p, 0)
+		}
+		if err != nil {
+			log.Printf("error: done: cannot update proc_id %d state: %s", p.ID, err)
+		}
+	}
+}
+
 func isThereRunningStage(procs []*model.Proc) bool {
 	for _, p := range procs {
 		if p.PPID == 0 {
@@ -503,13 +605,28 @@ func createFilterFunc(filter rpc.Filter) (queue.Filter, error) {
 	}
 
 	return func(task *queue.Task) bool {
+		for k, v := range task.ExcludeLabels {
+			if label, ok := filter.Labels[k]; ok && label == v {
+				return false
+			}
+		}
+
+		// label expressions match the agent labels in place of equality.
+		matched := map[string]bool{}
+		for _, e := range task.LabelExprs {
+			if !e.Match(filter.Labels) {
+				return false
+			}
+			matched[e.Key] = true
+		}
+
 		if st != nil {
 			match, _ := st.Eval(expr.NewRow(task.Labels))
 			return match
 		}
 
 		for k, v := range filter.Labels {
-			if task.Labels[k] != v {
+			if !matched[k] && task.Labels[k] != v {
 				return false
 			}
 		}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/rpc"
+	"github.com/woodpecker-ci/woodpecker/cncd/queue"
+	"github.com/woodpecker-ci/woodpecker/model"
+)
+
+func TestCreateFilterFuncExcludeLabels(t *testing.T) {
+	t.Parallel()
+
+	task := &queue.Task{
+		Labels:        map[string]string{"platform": "linux/amd64"},
+		ExcludeLabels: map[string]string{"gpu": "true"},
+	}
+
+	testTable := []struct {
+		name   string
+		filter rpc.Filter
+		match  bool
+	}{
+		{
+			name:   "agent with an excluded label",
+			filter: rpc.Filter{Labels: map[string]string{"gpu": "true"}, Expr: "platform = 'linux/amd64'"},
+			match:  false,
+		},
+		{
+			name:   "agent with a different label value",
+			filter: rpc.Filter{Labels: map[string]string{"gpu": "false"}, Expr: "platform = 'linux/amd64'"},
+			match:  true,
+		},
+		{
+			name:   "agent without the excluded label",
+			filter: rpc.Filter{Labels: map[string]string{"platform": "linux/amd64"}},
+			match:  true,
+		},
+	}
+
+	for _, tt := range testTable {
+		fn, err := createFilterFunc(tt.filter)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if match := fn(task); match != tt.match {
+			t.Errorf("%s: want match %v, got %v", tt.name, tt.match, match)
+		}
+	}
+}
+
+func TestCreateFilterFuncLabelExprs(t *testing.T) {
+	t.Parallel()
+
+	memory, _ := queue.ParseLabelExpr("memory", "> 4G")
+	arch, _ := queue.ParseLabelExpr("arch", "in (amd64, arm64)")
+	task := &queue.Task{
+		Labels:     map[string]string{"platform": "linux/amd64"},
+		LabelExprs: []*queue.LabelExpr{memory, arch},
+	}
+
+	testTable := []struct {
+		name   string
+		filter rpc.Filter
+		match  bool
+	}{
+		{
+			name:   "agent matching the expressions",
+			filter: rpc.Filter{Labels: map[string]string{"platform": "linux/amd64", "memory": "16G", "arch": "arm64"}},
+			match:  true,
+		},
+		{
+			name:   "agent with too little memory",
+			filter: rpc.Filter{Labels: map[string]string{"platform": "linux/amd64", "memory": "2G", "arch": "amd64"}},
+			match:  false,
+		},
+		{
+			name:   "agent without the label",
+			filter: rpc.Filter{Labels: map[string]string{"platform": "linux/amd64", "arch": "amd64"}},
+			match:  false,
+		},
+		{
+			name:   "agent expression filter",
+			filter: rpc.Filter{Labels: map[string]string{"memory": "8G", "arch": "s390x"}, Expr: "platform = 'linux/amd64'"},
+			match:  false,
+		},
+	}
+
+	for _, tt := range testTable {
+		fn, err := createFilterFunc(tt.filter)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if match := fn(task); match != tt.match {
+			t.Errorf("%s: want match %v, got %v", tt.name, tt.match, match)
+		}
+	}
+}
+
+// recordProcStore records the state procs are updated to by id.
+type recordProcStore map[int64]string
+
+func (s recordProcStore) ProcUpdate(proc *model.Proc) error {
+	s[proc.ID] = proc.State
+	return nil
+}
+
+func TestCancelSiblings(t *testing.T) {
+	t.Parallel()
+
+	c := context.Background()
+	q := queue.New()
+	q.PushAtOnce(c, []*queue.Task{
+		{ID: "1", FailFast: true},
+		{ID: "2", FailFast: true},
+		{ID: "3", FailFast: true, Dependencies: []string{"1"}, DepStatus: map[string]string{}},
+		{ID: "4", FailFast: true, Dependencies: []string{"1"}, DepStatus: map[string]string{}, RunOn: []string{"failure"}},
+	})
+	for i := 0; i < 2; i++ {
+		if _, err := q.Poll(c, func(*queue.Task) bool { return true }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !taskFailFast(q.Info(c), "1") {
+		t.Errorf("Want the running task to fail fast")
+	}
+	if taskFailFast(q.Info(c), "3") {
+		t.Errorf("Want pending tasks to be unknown")
+	}
+
+	failed := &model.Proc{ID: 1, PID: 1, State: model.StatusFailure}
+	procs := []*model.Proc{
+		failed,
+		{ID: 2, PID: 2, State: model.StatusRunning},
+		{ID: 3, PID: 3, State: model.StatusPending},
+		{ID: 4, PID: 4, State: model.StatusPending},
+		{ID: 5, PID: 5, PPID: 3, State: model.StatusPending},
+		{ID: 6, PID: 6, PPID: 4, State: model.StatusPending},
+	}
+	q.Error(c, "1", errors.New("exit code 1"))
+
+	store := recordProcStore{}
+	cancelSiblings(c, q, store, procs, failed)
+
+	want := map[int64]string{3: model.StatusKilled, 5: model.StatusSkipped}
+	if !reflect.DeepEqual(map[int64]string(store), want) {
+		t.Errorf("Want procs updated to %v, got %v", want, store)
+	}
+	info := q.Info(c)
+	if len(info.Running) != 0 {
+		t.Errorf("Want the running sibling cancelled, got %v", info.Running)
+	}
+	queued := append(info.Pending, info.WaitingOnDeps...)
+	if len(queued) != 1 || queued[0].ID != "4" {
+		t.Errorf("Want the pipeline running on failure queued, got %v", queued)
+	}
+}
@@ -48,11 +48,14 @@ func PostSecret(c *gin.Context) {
 		return
 	}
 	secret := &model.Secret{
-		RepoID: repo.ID,
-		Name:   in.Name,
-		Value:  in.Value,
-		Events: in.Events,
-		Images: in.Images,
+		RepoID:   repo.ID,
+		Name:     in.Name,
+		Value:    in.Value,
+		Events:   in.Events,
+		Images:   in.Images,
+		Registry: in.Registry,
+		Template: in.Template,
+		Matrix:   in.Matrix,
 	}
 	if err := secret.Validate(); err != nil {
 		c.String(400, "Error inserting secret. %s", err)
@@ -86,6 +89,7 @@ func PatchSecret(c *gin.Context) {
 	}
 	if in.Value != "" {
 		secret.Value = in.Value
+		secret.Template = in.Template
 	}
 	if len(in.Events) != 0 {
 		secret.Events = in.Events
@@ -93,6 +97,12 @@ func PatchSecret(c *gin.Context) {
 	if len(in.Images) != 0 {
 		secret.Images = in.Images
 	}
+	if in.Registry != "" {
+		secret.Registry = in.Registry
+	}
+	if len(in.Matrix) != 0 {
+		secret.Matrix = in.Matrix
+	}
 
 	if err := secret.Validate(); err != nil {
 		c.String(400, "Error updating secret. %s", err)
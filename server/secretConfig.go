@@ -0,0 +1,104 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/woodpecker-ci/woodpecker/cncd/pipeline/pipeline/frontend/yaml"
+	"github.com/woodpecker-ci/woodpecker/model"
+	"github.com/woodpecker-ci/woodpecker/remote"
+)
+
+// defaultSecretConfigSize is the default maximum size of a pipeline
+// configuration read from a secret, once decoded.
+const defaultSecretConfigSize = 512 * 1024
+
+// secretConfig returns the pipeline configuration stored in the secret
+// named name, or nil if the build has no such secret. The secret value is
+// the base64 encoded yaml, optionally gzip compressed, and must not exceed
+// limit bytes once decoded, or the default size if the limit is not set.
+func secretConfig(secs []*model.Secret, event, name, configPath string, limit int64) (*remote.FileMeta, error) {
+	if name == "" {
+		return nil, nil
+	}
+	if limit <= 0 {
+		limit = defaultSecretConfigSize
+	}
+	for _, sec := range secs {
+		if sec.Name != name || !sec.Match(event) {
+			continue
+		}
+		data, err := decodeSecretConfig(sec.Value, limit)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid pipeline configuration in secret %s: %s", name, err)
+		}
+		if _, err := yaml.ParseBytes(data); err != nil {
+			return nil, fmt.Errorf("Invalid pipeline configuration in secret %s: %s", name, err)
+		}
+		return &remote.FileMeta{Name: secretConfigName(configPath, name), Data: data}, nil
+	}
+	return nil, nil
+}
+
+// decodeSecretConfig decodes a base64 encoded, optionally gzip compressed,
+// pipeline configuration. Compressed data is only read up to the limit, so
+// a small secret cannot expand to an arbitrarily large configuration.
+func decodeSecretConfig(value string, limit int64) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("not base64 encoded: %s", err)
+	}
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip data: %s", err)
+		}
+		defer r.Close()
+		data, err = ioutil.ReadAll(io.LimitReader(r, limit+1))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip data: %s", err)
+		}
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("exceeds the maximum size of %d bytes", limit)
+	}
+	return data, nil
+}
+
+// secretConfigName returns the file name of a pipeline configuration read
+// from a secret, the config path of the repository or a file named after
+// the secret in the config folder.
+func secretConfigName(configPath, name string) string {
+	if strings.HasSuffix(configPath, "/") {
+		return path.Join(configPath, name+".yml")
+	}
+	return configPath
+}
+
+// fetchConfig returns the pipeline configuration of the build, read from the
+// config secret if the build has one, or else from the repository. It also
+// reports whether the configuration was read from the secret, in which case
+// the repository files are not fetched at all.
+func fetchConfig(cf *configFetcher, repo *model.Repo, build *model.Build) ([]*remote.FileMeta, bool, error) {
+	if Config.Pipeline.ConfigSecret != "" {
+		secs, err := Config.Services.Secrets.SecretListBuild(repo, build)
+		if err != nil {
+			return nil, false, err
+		}
+		file, err := secretConfig(secs, build.Event, Config.Pipeline.ConfigSecret, cf.ConfigPath(), Config.Pipeline.ConfigSecretSize)
+		if err != nil {
+			return nil, false, err
+		}
+		if file != nil {
+			return []*remote.FileMeta{file}, true, nil
+		}
+	}
+	files, err := cf.Fetch()
+	return files, false, err
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/woodpecker-ci/woodpecker/model"
+	"github.com/woodpecker-ci/woodpecker/remote"
+	"github.com/woodpecker-ci/woodpecker/remote/mocks"
+)
+
+// buildSecrets is a secret service listing the same secrets for any build.
+type buildSecrets struct {
+	model.SecretService
+	secs []*model.Secret
+}
+
+func (s *buildSecrets) SecretListBuild(*model.Repo, *model.Build) ([]*model.Secret, error) {
+	return s.secs, nil
+}
+
+func gzipBase64(t *testing.T, data string) string {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestSecretConfig(t *testing.T) {
+	defer func(name string) { Config.Pipeline.ConfigSecret = name }(Config.Pipeline.ConfigSecret)
+	Config.Pipeline.ConfigSecret = "pipeline_config"
+
+	b := procBuilder{
+		Repo:  &model.Repo{Config: ".drone.yml"},
+		Curr:  &model.Build{Event: model.EventPush},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs: []*model.Secret{
+			{Name: "pipeline_config", Value: gzipBase64(t, `
+pipeline:
+  private:
+    image: golang
+    commands: [ go test ]
+`)},
+		},
+		Regs: []*model.Registry{},
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Name: ".drone.yml", Data: []byte(`
+pipeline:
+  public:
+    image: alpine
+`)},
+		},
+	}
+
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buildItems) != 1 {
+		t.Fatalf("Want one pipeline, got %d", len(buildItems))
+	}
+	steps := buildItems[0].Config.Stages[1].Steps
+	if got := steps[0].Image; !strings.HasSuffix(got, "/golang:latest") {
+		t.Errorf("Want the pipeline of the secret, got the image %s", got)
+	}
+	if got := buildItems[0].Proc.Name; got != "drone" {
+		t.Errorf("Want the pipeline named after the config path, got %s", got)
+	}
+}
+
+func TestSecretConfigPlain(t *testing.T) {
+	t.Parallel()
+
+	value := base64.StdEncoding.EncodeToString([]byte("pipeline:\n  build:\n    image: golang\n"))
+	secs := []*model.Secret{{Name: "pipeline_config", Value: value}}
+
+	file, err := secretConfig(secs, model.EventPush, "pipeline_config", ".drone/", 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file == nil {
+		t.Fatal("Want the pipeline configuration of the secret")
+	}
+	if want := ".drone/pipeline_config.yml"; file.Name != want {
+		t.Errorf("Want the file name %s, got %s", want, file.Name)
+	}
+
+	if file, _ := secretConfig(secs, model.EventPush, "", ".drone.yml", 1024); file != nil {
+		t.Errorf("Want no configuration read from a secret if disabled")
+	}
+	secs[0].Events = []string{model.EventTag}
+	if file, _ := secretConfig(secs, model.EventPush, "pipeline_config", ".drone.yml", 1024); file != nil {
+		t.Errorf("Want no configuration read from a secret restricted to other events")
+	}
+}
+
+func TestSecretConfigInvalid(t *testing.T) {
+	t.Parallel()
+
+	large := "pipeline:\n  build:\n    image: golang\n" + strings.Repeat("# padding\n", 200)
+	testdata := []struct {
+		value string
+		err   string
+	}{
+		{
+			value: "not base64!",
+			err:   "Invalid pipeline configuration in secret pipeline_config: not base64 encoded",
+		},
+		{
+			value: base64.StdEncoding.EncodeToString([]byte{0x1f, 0x8b, 0x00}),
+			err:   "Invalid pipeline configuration in secret pipeline_config: invalid gzip data",
+		},
+		{
+			value: base64.StdEncoding.EncodeToString([]byte("pipeline: [")),
+			err:   "Invalid pipeline configuration in secret pipeline_config: yaml",
+		},
+		{
+			value: base64.StdEncoding.EncodeToString([]byte(large)),
+			err:   "Invalid pipeline configuration in secret pipeline_config: exceeds the maximum size of 1024 bytes",
+		},
+		{
+			value: gzipBase64(t, large),
+			err:   "Invalid pipeline configuration in secret pipeline_config: exceeds the maximum size of 1024 bytes",
+		},
+	}
+	for _, test := range testdata {
+		secs := []*model.Secret{{Name: "pipeline_config", Value: test.value}}
+		_, err := secretConfig(secs, model.EventPush, "pipeline_config", ".drone.yml", 1024)
+		if err == nil {
+			t.Errorf("Want an error for the secret value %q", test.value)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), test.err) {
+			t.Errorf("Want error %q, got %q", test.err, err)
+		}
+	}
+}
+
+func TestFetchConfigFromSecret(t *testing.T) {
+	defer func(name string, secrets model.SecretService) {
+		Config.Pipeline.ConfigSecret = name
+		Config.Services.Secrets = secrets
+	}(Config.Pipeline.ConfigSecret, Config.Services.Secrets)
+	Config.Pipeline.ConfigSecret = "pipeline_config"
+
+	repo := &model.Repo{Config: ".drone.yml"}
+	build := &model.Build{Event: model.EventPush}
+	value := base64.StdEncoding.EncodeToString([]byte("pipeline:\n  build:\n    image: golang\n"))
+
+	// the repository has no config file, it must not be fetched.
+	Config.Services.Secrets = &buildSecrets{secs: []*model.Secret{{Name: "pipeline_config", Value: value}}}
+	r := new(mocks.Remote)
+	files, fromSecret, err := fetchConfig(NewConfigFetcher(r, &model.User{}, repo, build), repo, build)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fromSecret || len(files) != 1 || files[0].Name != ".drone.yml" {
+		t.Errorf("Want the pipeline configuration of the secret, got %v", files)
+	}
+	r.AssertNotCalled(t, "File", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	// without the secret, the repository config file is fetched.
+	Config.Services.Secrets = &buildSecrets{}
+	r = new(mocks.Remote)
+	r.On("File", mock.Anything, mock.Anything, mock.Anything, ".drone.yml").Return([]byte("pipeline:\n  build:\n    image: alpine\n"), nil)
+	files, fromSecret, err = fetchConfig(NewConfigFetcher(r, &model.User{}, repo, build), repo, build)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fromSecret || len(files) != 1 {
+		t.Errorf("Want the pipeline configuration of the repository, got %v", files)
+	}
+}
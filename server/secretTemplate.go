@@ -0,0 +1,123 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/drone/envsubst"
+	"github.com/woodpecker-ci/woodpecker/model"
+)
+
+// resolveSecretTemplates resolves the values of the template secrets, keyed
+// by secret name. Templates reference other secrets and environment
+// variables by their environment variable name, e.g. ${DB_USER}; secrets
+// take precedence over the environments, later environments over earlier
+// ones. Template secrets referenced by a
+// template are resolved first. Errors only ever name secrets, never their
+// values.
+func resolveSecretTemplates(secs []*model.Secret, environs ...map[string]string) (map[string]string, error) {
+	r := &secretResolver{
+		secrets:  map[string]*model.Secret{},
+		environ:  map[string]string{},
+		resolved: map[string]string{},
+	}
+	for _, environ := range environs {
+		for k, v := range environ {
+			r.environ[k] = v
+		}
+	}
+	for _, sec := range secs {
+		r.secrets[strings.ToUpper(sec.Name)] = sec
+	}
+
+	values := map[string]string{}
+	for _, sec := range secs {
+		if !sec.Template {
+			continue
+		}
+		value, err := r.resolve(sec)
+		if err != nil {
+			return nil, err
+		}
+		values[sec.Name] = value
+	}
+	return values, nil
+}
+
+type secretResolver struct {
+	secrets  map[string]*model.Secret
+	environ  map[string]string
+	resolved map[string]string
+	stack    []string
+}
+
+func (r *secretResolver) resolve(sec *model.Secret) (string, error) {
+	if !sec.Template {
+		return sec.Value, nil
+	}
+	if value, ok := r.resolved[sec.Name]; ok {
+		return value, nil
+	}
+	for i, name := range r.stack {
+		if name == sec.Name {
+			cycle := append(append([]string{}, r.stack[i:]...), sec.Name)
+			return "", fmt.Errorf("Secret %s has a cyclic reference %s", sec.Name, strings.Join(cycle, " -> "))
+		}
+	}
+	r.stack = append(r.stack, sec.Name)
+	defer func() { r.stack = r.stack[:len(r.stack)-1] }()
+
+	var err error
+	value, evalErr := envsubst.Eval(sec.Value, func(name string) string {
+		ref, ok := r.secrets[name]
+		if !ok {
+			return r.environ[name]
+		}
+		if err != nil {
+			return ""
+		}
+		// a template must not expose a secret to images it is not
+		// available to.
+		if !imagesSubset(sec.Images, ref.Images) {
+			err = fmt.Errorf("Secret %s cannot reference secret %s, which is limited to other images", sec.Name, ref.Name)
+			return ""
+		}
+		v, refErr := r.resolve(ref)
+		if refErr != nil {
+			err = refErr
+		}
+		return v
+	})
+	if err != nil {
+		return "", err
+	}
+	if evalErr != nil {
+		return "", fmt.Errorf("Invalid template in secret %s: %s", sec.Name, evalErr)
+	}
+	r.resolved[sec.Name] = value
+	return value, nil
+}
+
+// imagesSubset returns true if a secret limited to the images can use a
+// secret limited to the allowed images. An empty list means all images.
+func imagesSubset(images, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	if len(images) == 0 {
+		return false
+	}
+	for _, image := range images {
+		found := false
+		for _, a := range allowed {
+			if image == a {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
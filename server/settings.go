@@ -0,0 +1,84 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/woodpecker-ci/woodpecker/model"
+	"github.com/woodpecker-ci/woodpecker/remote"
+	"gopkg.in/yaml.v3"
+)
+
+// repoSettingsPath is the path of the settings file a repository versions
+// its defaults in.
+const repoSettingsPath = ".woodpecker/settings.yml"
+
+// repoSettings are the defaults of a repository versioned in its settings
+// file. A repository cannot grant itself more than the server and its admin
+// allow: the events only narrow the event policy and trust can only be
+// dropped.
+type repoSettings struct {
+	// Config is the config path, used if the repository uses the server
+	// default.
+	Config string `yaml:"config"`
+
+	// Events are the build events the repository builds, hooks of other
+	// events are ignored. The event policy still applies to the pipelines.
+	Events []string `yaml:"events"`
+
+	// Trusted set to false drops the trusted capabilities, e.g. privileged
+	// steps, of a repository trusted by an admin. It never grants them.
+	Trusted *bool `yaml:"trusted"`
+}
+
+// parseRepoSettings parses the settings file of a repository.
+func parseRepoSettings(data []byte) (*repoSettings, error) {
+	settings := new(repoSettings)
+	if err := yaml.Unmarshal(data, settings); err != nil {
+		return nil, fmt.Errorf("Invalid repository settings %s: %s", repoSettingsPath, err)
+	}
+	if settings.Config != "" && !validConfigPath(settings.Config) {
+		return nil, fmt.Errorf("Invalid repository settings %s: invalid config path %s", repoSettingsPath, settings.Config)
+	}
+	return settings, nil
+}
+
+// fetchRepoSettings fetches the settings file of the repository at the
+// config ref of the build. It returns nil if the repository has no settings
+// file, and an error if the file cannot be fetched, as the settings may
+// restrict the build.
+func fetchRepoSettings(remote_ remote.Remote, user *model.User, repo *model.Repo, build *model.Build) (*repoSettings, error) {
+	at := *build
+	if build.ConfigRef != "" {
+		at.Commit = build.ConfigRef
+	}
+	data, err := remote_.File(user, repo, &at, repoSettingsPath)
+	if err != nil && configNotFound(err) {
+		logrus.Debugf("no repository settings for %s. %s", repo.FullName, err)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Cannot fetch the repository settings %s: %s", repoSettingsPath, err)
+	}
+	return parseRepoSettings(data)
+}
+
+// EventEnabled returns true if the repository builds the event.
+func (s *repoSettings) EventEnabled(event string) bool {
+	return s == nil || len(s.Events) == 0 || containsEvent(s.Events, event)
+}
+
+// Apply returns a copy of the repository with the settings applied.
+func (s *repoSettings) Apply(repo *model.Repo) *model.Repo {
+	if s == nil {
+		return repo
+	}
+	applied := *repo
+	if s.Config != "" && repo.Config == Config.Server.RepoConfig {
+		applied.Config = s.Config
+	}
+	if s.Trusted != nil && !*s.Trusted {
+		applied.IsTrusted = false
+	}
+	return &applied
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/woodpecker-ci/woodpecker/model"
+	"github.com/woodpecker-ci/woodpecker/remote"
+	"github.com/woodpecker-ci/woodpecker/remote/mocks"
+)
+
+func TestRepoSettings(t *testing.T) {
+	Config.Server.RepoConfig = ".drone.yml"
+	defer func() { Config.Server.RepoConfig = "" }()
+
+	settings, err := parseRepoSettings([]byte(`
+config: .woodpecker/
+events: [ push, tag ]
+trusted: false
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := &model.Repo{Config: ".drone.yml", IsTrusted: true}
+	applied := settings.Apply(repo)
+	if applied.Config != ".woodpecker/" {
+		t.Errorf("Want the config path of the settings, got %s", applied.Config)
+	}
+	if applied.IsTrusted {
+		t.Errorf("Want the trusted capabilities dropped")
+	}
+	if repo.Config != ".drone.yml" || !repo.IsTrusted {
+		t.Errorf("Want the repository left unchanged")
+	}
+
+	custom := settings.Apply(&model.Repo{Config: ".ci/"})
+	if custom.Config != ".ci/" {
+		t.Errorf("Want the config path set in the UI kept, got %s", custom.Config)
+	}
+
+	for event, enabled := range map[string]bool{
+		model.EventPush: true,
+		model.EventTag:  true,
+		model.EventPull: false,
+	} {
+		if settings.EventEnabled(event) != enabled {
+			t.Errorf("Want event %s enabled %v", event, enabled)
+		}
+	}
+
+	var none *repoSettings
+	if !none.EventEnabled(model.EventPull) || none.Apply(repo) != repo {
+		t.Errorf("Want a repository without settings unchanged")
+	}
+}
+
+func TestRepoSettingsPolicyCapped(t *testing.T) {
+	Config.Pipeline.AllowedEvents = []string{model.EventPush}
+	defer func() { Config.Pipeline.AllowedEvents = nil }()
+
+	settings, err := parseRepoSettings([]byte(`
+events: [ push, tag ]
+trusted: true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if settings.Apply(&model.Repo{}).IsTrusted {
+		t.Errorf("Want the settings to never trust a repository")
+	}
+
+	// the settings enable tags, the server policy still skips their
+	// pipelines.
+	b := procBuilder{
+		Repo:  settings.Apply(&model.Repo{}),
+		Curr:  &model.Build{Event: model.EventTag},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Data: []byte(`
+pipeline:
+  build:
+    image: scratch
+`)},
+		},
+	}
+	if !settings.EventEnabled(model.EventTag) {
+		t.Fatalf("Want tags enabled by the settings")
+	}
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buildItems) != 1 || buildItems[0].Proc.State != model.StatusSkipped {
+		t.Errorf("Want the pipeline skipped by the server policy")
+	}
+}
+
+func TestRepoSettingsInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, data := range []string{
+		"events: push: tag",
+		"config: ../other/",
+	} {
+		if _, err := parseRepoSettings([]byte(data)); err == nil {
+			t.Errorf("Want an error for the settings %q", data)
+		}
+	}
+}
+
+func TestFetchRepoSettings(t *testing.T) {
+	t.Parallel()
+
+	atRef := func(ref string) interface{} {
+		return mock.MatchedBy(func(b *model.Build) bool { return b.Commit == ref })
+	}
+
+	r := new(mocks.Remote)
+	r.On("File", mock.Anything, mock.Anything, atRef("pinned"), repoSettingsPath).Return([]byte("trusted: false"), nil)
+	r.On("File", mock.Anything, mock.Anything, atRef("none"), repoSettingsPath).Return(nil, errors.New("File not found"))
+
+	settings, err := fetchRepoSettings(r, &model.User{}, &model.Repo{}, &model.Build{Commit: "head", ConfigRef: "pinned"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings == nil || settings.Trusted == nil || *settings.Trusted {
+		t.Errorf("Want the settings at the config ref, got %v", settings)
+	}
+
+	settings, err = fetchRepoSettings(r, &model.User{}, &model.Repo{}, &model.Build{Commit: "none"})
+	if err != nil || settings != nil {
+		t.Errorf("Want no settings without a settings file, got %v, %v", settings, err)
+	}
+
+	r.On("File", mock.Anything, mock.Anything, atRef("failing"), repoSettingsPath).Return(nil, errors.New("connection refused"))
+	if _, err = fetchRepoSettings(r, &model.User{}, &model.Repo{}, &model.Build{Commit: "failing"}); err == nil {
+		t.Errorf("Want an error if the settings file cannot be fetched")
+	}
+}
+
+func TestRepoSettingsNotAPipeline(t *testing.T) {
+	t.Parallel()
+
+	files := filterPipelineFiles([]*remote.FileMeta{
+		{Name: ".woodpecker/build.yml"},
+		{Name: repoSettingsPath},
+	})
+	if len(files) != 1 || files[0].Name != ".woodpecker/build.yml" {
+		t.Errorf("Want the settings file left out of the pipelines, got %v", files)
+	}
+}
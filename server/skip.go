@@ -0,0 +1,42 @@
+package server
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/woodpecker-ci/woodpecker/model"
+)
+
+// skipRe matches the default skip markers, any case-insensitive combination
+// of the words "skip" and "ci" wrapped in square brackets.
+var skipRe = regexp.MustCompile(`\[(?i:ci *skip|skip *ci)\]`)
+
+// skipMarker returns the skip marker found in the commit message of the
+// build, or an empty string if the build must not be skipped. Markers are
+// matched case-insensitively, the default markers are used if none are
+// configured. If events are configured, only builds of these events can be
+// skipped.
+func skipMarker(markers, events []string, build *model.Build) string {
+	if len(events) != 0 && !containsEvent(events, build.Event) {
+		return ""
+	}
+	if len(markers) == 0 {
+		return skipRe.FindString(build.Message)
+	}
+	message := strings.ToLower(build.Message)
+	for _, marker := range markers {
+		if marker != "" && strings.Contains(message, strings.ToLower(marker)) {
+			return marker
+		}
+	}
+	return ""
+}
+
+func containsEvent(events []string, event string) bool {
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/woodpecker-ci/woodpecker/model"
+	"github.com/woodpecker-ci/woodpecker/remote"
+)
+
+func TestSkipMarker(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		markers []string
+		events  []string
+		build   *model.Build
+		want    string
+	}{
+		{
+			name:  "skip ci",
+			build: &model.Build{Event: model.EventPush, Message: "update readme [skip ci]"},
+			want:  "[skip ci]",
+		},
+		{
+			name:  "ci skip",
+			build: &model.Build{Event: model.EventPush, Message: "update readme [ci skip]"},
+			want:  "[ci skip]",
+		},
+		{
+			name:  "upper case",
+			build: &model.Build{Event: model.EventPush, Message: "update readme [SKIP CI]"},
+			want:  "[SKIP CI]",
+		},
+		{
+			name:  "without space",
+			build: &model.Build{Event: model.EventPush, Message: "update readme [skipci]"},
+			want:  "[skipci]",
+		},
+		{
+			name:  "no marker",
+			build: &model.Build{Event: model.EventPush, Message: "skip the ci config"},
+			want:  "",
+		},
+		{
+			name:    "custom marker",
+			markers: []string{"[no build]"},
+			build:   &model.Build{Event: model.EventPush, Message: "update readme [No Build]"},
+			want:    "[no build]",
+		},
+		{
+			name:    "default marker with custom markers",
+			markers: []string{"[no build]"},
+			build:   &model.Build{Event: model.EventPush, Message: "update readme [skip ci]"},
+			want:    "",
+		},
+		{
+			name:   "event that can be skipped",
+			events: []string{model.EventPush, model.EventPull},
+			build:  &model.Build{Event: model.EventPull, Message: "update readme [skip ci]"},
+			want:   "[skip ci]",
+		},
+		{
+			name:   "tag event that cannot be skipped",
+			events: []string{model.EventPush, model.EventPull},
+			build:  &model.Build{Event: model.EventTag, Message: "release [skip ci]"},
+			want:   "",
+		},
+		{
+			name:   "deploy event that cannot be skipped",
+			events: []string{model.EventPush, model.EventPull},
+			build:  &model.Build{Event: model.EventDeploy, Message: "release [skip ci]"},
+			want:   "",
+		},
+	}
+
+	for _, test := range tests {
+		if got := skipMarker(test.markers, test.events, test.build); got != test.want {
+			t.Errorf("%s: want marker %q, got %q", test.name, test.want, got)
+		}
+	}
+}
+
+func TestSkipMarkerBuild(t *testing.T) {
+	t.Parallel()
+
+	b := procBuilder{
+		Repo:  &model.Repo{},
+		Curr:  &model.Build{Event: model.EventPush, Message: "update readme [ci skip]"},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Link:  "",
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Name: "lint", Data: []byte(`
+pipeline:
+  lint:
+    image: scratch
+`)},
+			&remote.FileMeta{Name: "test", Data: []byte(`
+pipeline:
+  test:
+    image: scratch
+`)},
+		},
+	}
+
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range buildItems {
+		if item.Proc.State != model.StatusSkipped {
+			t.Errorf("Pipeline %s should be skipped", item.Proc.Name)
+		}
+	}
+}
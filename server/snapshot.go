@@ -0,0 +1,65 @@
+package server
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/woodpecker-ci/woodpecker/model"
+)
+
+// Environment snapshot modes, see environSnapshot.
+const (
+	EnvironSnapshotNone   = "none"
+	EnvironSnapshotKeys   = "keys"
+	EnvironSnapshotValues = "values"
+)
+
+// environSnapshot returns the environment a pipeline is compiled with for
+// auditing, sorted by key. The keys mode only records the keys, the values
+// mode records the values as well. The values of secrets, and of variables
+// containing the value of a secret, are never recorded. It returns nil if
+// the mode is none or unknown.
+func environSnapshot(mode string, secs []*model.Secret, environs ...map[string]string) []model.EnvironVar {
+	if mode != EnvironSnapshotKeys && mode != EnvironSnapshotValues {
+		return nil
+	}
+
+	vars := map[string]model.EnvironVar{}
+	for _, environ := range environs {
+		for k, v := range environ {
+			vars[k] = model.EnvironVar{Key: k, Value: v}
+		}
+	}
+	for k, v := range vars {
+		if containsSecret(v.Value, secs) {
+			vars[k] = model.EnvironVar{Key: k, Secret: true}
+		}
+	}
+	for _, sec := range secs {
+		name := strings.ToUpper(sec.Name)
+		vars[name] = model.EnvironVar{Key: name, Secret: true}
+	}
+
+	snapshot := make([]model.EnvironVar, 0, len(vars))
+	for _, v := range vars {
+		if mode == EnvironSnapshotKeys {
+			v.Value = ""
+		}
+		snapshot = append(snapshot, v)
+	}
+	sort.Slice(snapshot, func(i, j int) bool {
+		return snapshot[i].Key < snapshot[j].Key
+	})
+	return snapshot
+}
+
+// containsSecret returns true if the value contains the value of one of the
+// secrets.
+func containsSecret(value string, secs []*model.Secret) bool {
+	for _, sec := range secs {
+		if sec.Value != "" && strings.Contains(value, sec.Value) {
+			return true
+		}
+	}
+	return false
+}
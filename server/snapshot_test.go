@@ -0,0 +1,106 @@
+package server
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/woodpecker-ci/woodpecker/model"
+	"github.com/woodpecker-ci/woodpecker/remote"
+)
+
+func TestEnvironSnapshot(t *testing.T) {
+	t.Parallel()
+
+	secs := []*model.Secret{
+		{Name: "password", Value: "correct-horse"},
+	}
+	environ := map[string]string{
+		"CI_REPO":      "octocat/hello-world",
+		"DATABASE_URL": "postgres://root:correct-horse@db",
+	}
+	envs := map[string]string{
+		"REGION": "eu",
+	}
+
+	testTable := []struct {
+		mode string
+		want []model.EnvironVar
+	}{
+		{mode: EnvironSnapshotNone, want: nil},
+		{mode: "", want: nil},
+		{mode: EnvironSnapshotKeys, want: []model.EnvironVar{
+			{Key: "CI_REPO"},
+			{Key: "DATABASE_URL", Secret: true},
+			{Key: "PASSWORD", Secret: true},
+			{Key: "REGION"},
+		}},
+		{mode: EnvironSnapshotValues, want: []model.EnvironVar{
+			{Key: "CI_REPO", Value: "octocat/hello-world"},
+			{Key: "DATABASE_URL", Secret: true},
+			{Key: "PASSWORD", Secret: true},
+			{Key: "REGION", Value: "eu"},
+		}},
+	}
+	for _, tt := range testTable {
+		got := environSnapshot(tt.mode, secs, environ, envs)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Want snapshot %v for mode %q, got %v", tt.want, tt.mode, got)
+		}
+	}
+}
+
+func TestBuildEnvironSnapshot(t *testing.T) {
+	defer func(mode string) {
+		Config.Pipeline.EnvironSnapshot = mode
+	}(Config.Pipeline.EnvironSnapshot)
+	Config.Pipeline.EnvironSnapshot = EnvironSnapshotValues
+
+	b := procBuilder{
+		Repo:  &model.Repo{FullName: "octocat/hello-world"},
+		Curr:  &model.Build{Event: model.EventPush, Branch: "master"},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs: []*model.Secret{
+			{Name: "password", Value: "correct-horse"},
+			{Name: "tag_token", Value: "tag-only", Events: []string{model.EventTag}},
+		},
+		Envs: map[string]string{"REGION": "eu"},
+		Yamls: []*remote.FileMeta{
+			{Name: "a", Data: []byte("pipeline:\n  build:\n    image: alpine\n")},
+			{Name: "b", Data: []byte("pipeline:\n  test:\n    image: alpine\n")},
+		},
+	}
+
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range buildItems {
+		vars := map[string]model.EnvironVar{}
+		for i, v := range item.Proc.EnvironSnapshot {
+			if i != 0 && item.Proc.EnvironSnapshot[i-1].Key >= v.Key {
+				t.Errorf("Want the snapshot sorted by key, got %s after %s", v.Key, item.Proc.EnvironSnapshot[i-1].Key)
+			}
+			vars[v.Key] = v
+		}
+		if got := vars["CI_REPO"].Value; got != "octocat/hello-world" {
+			t.Errorf("Want the metadata in the snapshot, got CI_REPO %q", got)
+		}
+		if got := vars["CI_COMMIT_BRANCH"].Value; got != "master" {
+			t.Errorf("Want the metadata in the snapshot, got CI_COMMIT_BRANCH %q", got)
+		}
+		if got := vars["REGION"].Value; got != "eu" {
+			t.Errorf("Want the global environment in the snapshot, got REGION %q", got)
+		}
+		if v, ok := vars["PASSWORD"]; !ok || !v.Secret || v.Value != "" {
+			t.Errorf("Want the secret key in the snapshot without its value, got %+v", v)
+		}
+		if _, ok := vars["TAG_TOKEN"]; ok {
+			t.Errorf("Want secrets not exposed to the event left out of the snapshot")
+		}
+		if got, want := vars["CI_JOB_NUMBER"].Value, strconv.Itoa(item.Proc.PID); got != want {
+			t.Errorf("Want job number %s in the snapshot, got %s", want, got)
+		}
+	}
+}
@@ -17,6 +17,7 @@ package server
 import (
 	"time"
 
+	"github.com/sirupsen/logrus"
 	"github.com/woodpecker-ci/woodpecker/model"
 	"github.com/woodpecker-ci/woodpecker/remote"
 	"github.com/woodpecker-ci/woodpecker/store"
@@ -110,5 +111,79 @@ func (s *syncer) Sync(user *model.User) error {
 		return nil
 	}
 
+	if err := s.prune(user, repos); err != nil {
+		return err
+	}
+
 	return s.perms.PermFlush(user, unix)
 }
+
+// prune deactivates the repositories activated by the user that the remote
+// no longer lists and reports as gone, e.g. because they were deleted or
+// renamed. Repositories the user can no longer access are kept.
+func (s *syncer) prune(user *model.User, listed []*model.Repo) error {
+	checker, ok := s.remote.(remote.RepoChecker)
+	if !ok {
+		return nil
+	}
+	repos, err := s.store.RepoList(user)
+	if err != nil {
+		return err
+	}
+	for _, repo := range staleRepos(user, repos, listed) {
+		gone, err := s.repoGone(checker, user, repo)
+		if err != nil {
+			logrus.Debugf("cannot check if %s exists. %s", repo.FullName, err)
+			continue
+		}
+		if !gone {
+			continue
+		}
+		repo.IsActive = false
+		if err := s.store.UpdateRepo(repo); err != nil {
+			return err
+		}
+		logrus.Infof("deactivated %s, the repository no longer exists", repo.FullName)
+	}
+	return nil
+}
+
+// repoGone returns true if the repository no longer exists. The remote hides
+// the private repositories the user lost access to as if they were gone, so
+// a private repository is only gone if its owner cannot see it either. If
+// the owner has no account to confirm it with, the repository is kept.
+func (s *syncer) repoGone(checker remote.RepoChecker, user *model.User, repo *model.Repo) (bool, error) {
+	exists, err := checker.RepoExists(user, repo.Owner, repo.Name)
+	if err != nil || exists {
+		return false, err
+	}
+	if !repo.IsPrivate || repo.Owner == user.Login {
+		return true, nil
+	}
+	owner, err := s.store.GetUserLogin(repo.Owner)
+	if err != nil {
+		logrus.Infof("cannot confirm that the private repository %s is gone, keeping it", repo.FullName)
+		return false, nil
+	}
+	exists, err = checker.RepoExists(owner, repo.Owner, repo.Name)
+	if err != nil || exists {
+		return false, err
+	}
+	return true, nil
+}
+
+// staleRepos returns the active repositories of the user that are not in
+// the listed repositories.
+func staleRepos(user *model.User, repos, listed []*model.Repo) []*model.Repo {
+	names := map[string]bool{}
+	for _, repo := range listed {
+		names[repo.FullName] = true
+	}
+	var stale []*model.Repo
+	for _, repo := range repos {
+		if repo.IsActive && repo.UserID == user.ID && !names[repo.FullName] {
+			stale = append(stale, repo)
+		}
+	}
+	return stale
+}
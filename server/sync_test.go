@@ -0,0 +1,111 @@
+package server
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/woodpecker-ci/woodpecker/model"
+	"github.com/woodpecker-ci/woodpecker/store"
+)
+
+func TestStaleRepos(t *testing.T) {
+	t.Parallel()
+
+	user := &model.User{ID: 1}
+	repos := []*model.Repo{
+		{FullName: "octocat/listed", UserID: 1, IsActive: true},
+		{FullName: "octocat/deleted", UserID: 1, IsActive: true},
+		{FullName: "octocat/inactive", UserID: 1, IsActive: false},
+		{FullName: "octocat/other-owner", UserID: 2, IsActive: true},
+	}
+	listed := []*model.Repo{
+		{FullName: "octocat/listed"},
+	}
+
+	stale := staleRepos(user, repos, listed)
+	if len(stale) != 1 || stale[0].FullName != "octocat/deleted" {
+		t.Errorf("Want only the active unlisted repository of the user, got %v", stale)
+	}
+}
+
+// repoChecker reports the repositories visible to each user login.
+type repoChecker map[string][]string
+
+func (c repoChecker) RepoExists(u *model.User, owner, name string) (bool, error) {
+	for _, fullName := range c[u.Login] {
+		if fullName == owner+"/"+name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// userStore is a store of the registered users.
+type userStore struct {
+	store.Store
+	users []*model.User
+}
+
+func (s *userStore) GetUserLogin(login string) (*model.User, error) {
+	for _, user := range s.users {
+		if user.Login == login {
+			return user, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func TestRepoGone(t *testing.T) {
+	t.Parallel()
+
+	user := &model.User{ID: 1, Login: "octocat"}
+	s := &syncer{store: &userStore{users: []*model.User{
+		user,
+		{ID: 2, Login: "hubot"},
+	}}}
+	checker := repoChecker{
+		"hubot": {"hubot/private"},
+	}
+
+	testTable := []struct {
+		name     string
+		repo     *model.Repo
+		expected bool
+	}{
+		{
+			name:     "Public repository reported as gone",
+			repo:     &model.Repo{Owner: "hubot", Name: "deleted"},
+			expected: true,
+		},
+		{
+			name:     "Own private repository reported as gone",
+			repo:     &model.Repo{Owner: "octocat", Name: "private", IsPrivate: true},
+			expected: true,
+		},
+		{
+			name:     "Private repository still visible to its owner",
+			repo:     &model.Repo{Owner: "hubot", Name: "private", IsPrivate: true},
+			expected: false,
+		},
+		{
+			name:     "Private repository gone for its owner too",
+			repo:     &model.Repo{Owner: "hubot", Name: "deleted", IsPrivate: true},
+			expected: true,
+		},
+		{
+			name:     "Private repository of an unknown owner",
+			repo:     &model.Repo{Owner: "octo-org", Name: "private", IsPrivate: true},
+			expected: false,
+		},
+	}
+
+	for _, tt := range testTable {
+		gone, err := s.repoGone(checker, user, tt.repo)
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+		}
+		if gone != tt.expected {
+			t.Errorf("%s: want gone %v, got %v", tt.name, tt.expected, gone)
+		}
+	}
+}
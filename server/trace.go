@@ -0,0 +1,24 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/woodpecker-ci/woodpecker/model"
+)
+
+// traceParent returns the W3C trace context of the span of the build, see
+// https://www.w3.org/TR/trace-context/#traceparent-header. The trace and
+// span ids are derived from the repository and the build number, so all the
+// pipelines of a build share the same trace and the spans of deployments
+// they trigger can be correlated with the build.
+func traceParent(repo *model.Repo, build *model.Build) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("woodpecker/%d/%d", repo.ID, build.Number)))
+	traceID, spanID := sum[:16], sum[16:24]
+	// an all zero id is invalid, the sampled flag is always set as the
+	// build is recorded.
+	traceID[15] |= 1
+	spanID[7] |= 1
+	return fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(traceID), hex.EncodeToString(spanID))
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/woodpecker-ci/woodpecker/model"
+	"github.com/woodpecker-ci/woodpecker/remote"
+)
+
+func TestTraceContext(t *testing.T) {
+	Config.Pipeline.TraceContext = true
+	defer func() { Config.Pipeline.TraceContext = false }()
+
+	b := procBuilder{
+		Repo:  &model.Repo{ID: 1},
+		Curr:  &model.Build{Number: 42},
+		Last:  &model.Build{},
+		Netrc: &model.Netrc{},
+		Secs:  []*model.Secret{},
+		Regs:  []*model.Registry{},
+		Yamls: []*remote.FileMeta{
+			&remote.FileMeta{Name: "build", Data: []byte(`
+pipeline:
+  build:
+    image: scratch
+`)},
+			&remote.FileMeta{Name: "test", Data: []byte(`
+pipeline:
+  test:
+    image: scratch
+`)},
+		},
+	}
+
+	buildItems, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	traceparent := buildItems[0].Config.Stages[1].Steps[0].Environment["TRACEPARENT"]
+	if !regexp.MustCompile(`^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`).MatchString(traceparent) {
+		t.Errorf("Want a valid traceparent, got %q", traceparent)
+	}
+	if other := buildItems[1].Config.Stages[1].Steps[0].Environment["TRACEPARENT"]; other != traceparent {
+		t.Errorf("Want the pipelines of a build in the same trace, got %q and %q", traceparent, other)
+	}
+	if traceParent(b.Repo, b.Curr) != traceparent {
+		t.Errorf("Want the trace context deterministic per build")
+	}
+	if traceParent(b.Repo, &model.Build{Number: 43}) == traceparent {
+		t.Errorf("Want builds in different traces")
+	}
+
+	Config.Pipeline.TraceContext = false
+	buildItems, err = b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := buildItems[0].Config.Stages[1].Steps[0].Environment["TRACEPARENT"]; ok {
+		t.Errorf("Want no trace context unless enabled")
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/woodpecker-ci/woodpecker/model"
+	"github.com/woodpecker-ci/woodpecker/remote"
+)
+
+// ParseTriggerPolicy parses a list of event=permission pairs, e.g.
+// comment=push, into a trigger policy keyed by event.
+func ParseTriggerPolicy(rules []string) (map[string]string, error) {
+	policy := map[string]string{}
+	for _, rule := range rules {
+		parts := strings.SplitN(rule, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid trigger policy %s, expected event=permission", rule)
+		}
+		switch parts[1] {
+		case "pull", "push", "admin":
+		default:
+			return nil, fmt.Errorf("Invalid trigger policy %s, permission must be one of pull, push or admin", rule)
+		}
+		policy[parts[0]] = parts[1]
+	}
+	return policy, nil
+}
+
+// triggerAllowed evaluates the trigger policy for the build event against
+// the permissions of the hook sender. Events without a policy are allowed.
+// If the build is rejected the reason is returned.
+func triggerAllowed(policy map[string]string, build *model.Build, perm *model.Perm) (bool, string) {
+	required, ok := policy[build.Event]
+	if !ok {
+		return true, ""
+	}
+	if perm == nil {
+		perm = new(model.Perm)
+	}
+
+	var allowed bool
+	switch required {
+	case "pull":
+		allowed = perm.Pull
+	case "push":
+		allowed = perm.Push
+	case "admin":
+		allowed = perm.Admin
+	}
+	if !allowed {
+		return false, fmt.Sprintf("%s events require %s permission, which %s does not have", build.Event, required, build.Sender)
+	}
+	return true, ""
+}
+
+// senderPerm returns the permissions the hook sender holds on the
+// repository. Senders are treated as having no permissions if the remote
+// cannot look them up.
+func senderPerm(remote_ remote.Remote, user *model.User, repo *model.Repo, build *model.Build) *model.Perm {
+	if build.Sender == user.Login {
+		perm, err := remote_.Perm(user, repo.Owner, repo.Name)
+		if err != nil {
+			logrus.Debugf("cannot get permissions of %s for %s. %s", build.Sender, repo.FullName, err)
+			return new(model.Perm)
+		}
+		return perm
+	}
+	permer, ok := remote_.(remote.SenderPermer)
+	if !ok {
+		return new(model.Perm)
+	}
+	perm, err := permer.SenderPerm(user, repo, build.Sender)
+	if err != nil {
+		logrus.Debugf("cannot get permissions of %s for %s. %s", build.Sender, repo.FullName, err)
+		return new(model.Perm)
+	}
+	return perm
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/woodpecker-ci/woodpecker/model"
+)
+
+func TestTriggerAllowed(t *testing.T) {
+	t.Parallel()
+
+	policy, err := ParseTriggerPolicy([]string{"comment=push"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		build   *model.Build
+		perm    *model.Perm
+		allowed bool
+	}{
+		{
+			name:    "comment from non-member",
+			build:   &model.Build{Event: "comment", Sender: "stranger"},
+			perm:    &model.Perm{},
+			allowed: false,
+		},
+		{
+			name:    "comment from reader",
+			build:   &model.Build{Event: "comment", Sender: "reader"},
+			perm:    &model.Perm{Pull: true},
+			allowed: false,
+		},
+		{
+			name:    "comment from member",
+			build:   &model.Build{Event: "comment", Sender: "octocat"},
+			perm:    &model.Perm{Pull: true, Push: true},
+			allowed: true,
+		},
+		{
+			name:    "comment with unknown permissions",
+			build:   &model.Build{Event: "comment", Sender: "stranger"},
+			allowed: false,
+		},
+		{
+			name:    "event without policy",
+			build:   &model.Build{Event: model.EventPush, Sender: "stranger"},
+			perm:    &model.Perm{},
+			allowed: true,
+		},
+	}
+
+	for _, test := range tests {
+		allowed, reason := triggerAllowed(policy, test.build, test.perm)
+		if allowed != test.allowed {
+			t.Errorf("%s: expected allowed %v, got %v", test.name, test.allowed, allowed)
+		}
+		if !allowed && reason == "" {
+			t.Errorf("%s: expected a rejection reason", test.name)
+		}
+	}
+}
+
+func TestTriggerAllowedDefault(t *testing.T) {
+	t.Parallel()
+
+	allowed, _ := triggerAllowed(nil, &model.Build{Event: "comment", Sender: "stranger"}, nil)
+	if !allowed {
+		t.Error("expected builds to be allowed without a trigger policy")
+	}
+}
+
+func TestParseTriggerPolicy(t *testing.T) {
+	t.Parallel()
+
+	for _, rule := range []string{"comment", "=push", "comment=write"} {
+		if _, err := ParseTriggerPolicy([]string{rule}); err == nil {
+			t.Errorf("expected an error for trigger policy %q", rule)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"sort"
+
+	"github.com/drone/envsubst/parse"
+)
+
+// Undefined variable modes, the handling of variables the pipeline
+// references that are not defined for the build.
+const (
+	UndefinedVariablesIgnore = "ignore"
+	UndefinedVariablesWarn   = "warn"
+	UndefinedVariablesFail   = "fail"
+)
+
+// undefinedVariables returns the sorted names of the variables the yaml
+// references that are not defined, e.g. a typo that would otherwise be
+// substituted with an empty string. References with a default or an
+// alternate value, e.g. ${TAG:-latest}, are not reported.
+func undefinedVariables(y string, environ map[string]string) []string {
+	tree, err := parse.Parse(y)
+	if err != nil {
+		return nil
+	}
+	found := map[string]bool{}
+	collectUndefined(tree.Root, environ, found)
+
+	var names []string
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func collectUndefined(node parse.Node, environ map[string]string, found map[string]bool) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		for _, n := range node.Nodes {
+			collectUndefined(n, environ, found)
+		}
+	case *parse.FuncNode:
+		for _, n := range node.Args {
+			collectUndefined(n, environ, found)
+		}
+		if _, ok := environ[node.Param]; ok {
+			return
+		}
+		switch node.Name {
+		case "-", ":-", "=", ":=", "+", ":+":
+			return
+		}
+		found[node.Param] = true
+	}
+}
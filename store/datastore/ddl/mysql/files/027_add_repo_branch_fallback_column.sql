@@ -0,0 +1,5 @@
+-- name: alter-table-add-repo-branch-fallback
+ALTER TABLE repos ADD COLUMN repo_branch_fallback BOOLEAN
+
+-- name: update-table-set-repo-branch-fallback
+UPDATE repos SET repo_branch_fallback='false'
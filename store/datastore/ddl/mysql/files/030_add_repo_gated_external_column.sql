@@ -0,0 +1,5 @@
+-- name: alter-table-add-repo-gated-external
+ALTER TABLE repos ADD COLUMN repo_gated_external BOOLEAN
+
+-- name: update-table-set-repo-gated-external
+UPDATE repos SET repo_gated_external='false'
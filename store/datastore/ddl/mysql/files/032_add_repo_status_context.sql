@@ -0,0 +1,5 @@
+-- name: alter-table-add-repo-status-context
+ALTER TABLE repos ADD COLUMN repo_status_context VARCHAR(250)
+
+-- name: update-table-set-repo-status-context
+UPDATE repos SET repo_status_context=''
@@ -0,0 +1,5 @@
+-- name: alter-table-add-repo-config-repo
+ALTER TABLE repos ADD COLUMN repo_config_repo VARCHAR(500)
+
+-- name: update-table-set-repo-config-repo
+UPDATE repos SET repo_config_repo=''
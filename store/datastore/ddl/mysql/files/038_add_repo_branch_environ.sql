@@ -0,0 +1,5 @@
+-- name: alter-table-add-repo-branch-environ
+ALTER TABLE repos ADD COLUMN repo_branch_environ TEXT
+
+-- name: update-table-set-repo-branch-environ
+UPDATE repos SET repo_branch_environ='[]'
@@ -0,0 +1,7 @@
+-- name: alter-table-add-secret-matrix
+
+ALTER TABLE secrets ADD COLUMN secret_matrix VARCHAR(2000)
+
+-- name: update-table-set-secret-matrix
+
+UPDATE secrets SET secret_matrix='{}'
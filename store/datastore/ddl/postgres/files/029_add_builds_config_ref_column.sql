@@ -0,0 +1,5 @@
+-- name: alter-table-add-build-config-ref
+ALTER TABLE builds ADD COLUMN build_config_ref VARCHAR(250);
+
+-- name: update-table-set-build-config-ref
+UPDATE builds SET build_config_ref=build_commit;
@@ -0,0 +1,5 @@
+-- name: alter-table-add-secret-registry
+ALTER TABLE secrets ADD COLUMN secret_registry VARCHAR(250);
+
+-- name: update-table-set-secret-registry
+UPDATE secrets SET secret_registry='';
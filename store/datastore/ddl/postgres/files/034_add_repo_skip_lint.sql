@@ -0,0 +1,5 @@
+-- name: alter-table-add-repo-skip-lint
+ALTER TABLE repos ADD COLUMN repo_skip_lint BOOLEAN;
+
+-- name: update-table-set-repo-skip-lint
+UPDATE repos SET repo_skip_lint=false;
@@ -0,0 +1,5 @@
+-- name: alter-table-add-secret-template
+ALTER TABLE secrets ADD COLUMN secret_template BOOLEAN;
+
+-- name: update-table-set-secret-template
+UPDATE secrets SET secret_template=false;
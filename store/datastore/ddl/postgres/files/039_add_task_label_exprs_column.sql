@@ -0,0 +1,7 @@
+-- name: alter-table-add-task-label-exprs
+
+ALTER TABLE tasks ADD COLUMN task_label_exprs BYTEA;
+
+-- name: update-table-set-task-label-exprs
+
+UPDATE tasks SET task_label_exprs='null';
@@ -0,0 +1,7 @@
+-- name: alter-table-add-proc-environ-snapshot
+
+ALTER TABLE procs ADD COLUMN proc_environ_snapshot BYTEA;
+
+-- name: update-table-set-proc-environ-snapshot
+
+UPDATE procs SET proc_environ_snapshot='null';
@@ -0,0 +1,7 @@
+-- name: alter-table-add-task-fail-fast
+
+ALTER TABLE tasks ADD COLUMN task_fail_fast BOOLEAN;
+
+-- name: update-table-set-task-fail-fast
+
+UPDATE tasks SET task_fail_fast=false;
@@ -0,0 +1,7 @@
+-- name: alter-table-add-build-from-fork
+
+ALTER TABLE builds ADD COLUMN build_from_fork BOOLEAN;
+
+-- name: update-table-set-build-from-fork
+
+UPDATE builds SET build_from_fork=false;
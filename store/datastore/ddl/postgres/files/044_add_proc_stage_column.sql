@@ -0,0 +1,7 @@
+-- name: alter-table-add-proc-stage
+
+ALTER TABLE procs ADD COLUMN proc_stage VARCHAR(250);
+
+-- name: update-table-set-proc-stage
+
+UPDATE procs SET proc_stage='';
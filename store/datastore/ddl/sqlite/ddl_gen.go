@@ -204,6 +204,166 @@ var migrations = []struct {
 		name: "update-builds-set-changed_files",
 		stmt: updateBuildsSetChangedfiles,
 	},
+	{
+		name: "alter-table-add-repo-description",
+		stmt: alterTableAddRepoDescription,
+	},
+	{
+		name: "update-table-set-repo-description",
+		stmt: updateTableSetRepoDescription,
+	},
+	{
+		name: "alter-table-add-repo-topics",
+		stmt: alterTableAddRepoTopics,
+	},
+	{
+		name: "update-table-set-repo-topics",
+		stmt: updateTableSetRepoTopics,
+	},
+	{
+		name: "alter-table-add-repo-branch-fallback",
+		stmt: alterTableAddRepoBranchFallback,
+	},
+	{
+		name: "update-table-set-repo-branch-fallback",
+		stmt: updateTableSetRepoBranchFallback,
+	},
+	{
+		name: "alter-table-add-task-exclude-labels",
+		stmt: alterTableAddTaskExcludeLabels,
+	},
+	{
+		name: "update-table-set-task-exclude-labels",
+		stmt: updateTableSetTaskExcludeLabels,
+	},
+	{
+		name: "alter-table-add-build-config-ref",
+		stmt: alterTableAddBuildConfigRef,
+	},
+	{
+		name: "update-table-set-build-config-ref",
+		stmt: updateTableSetBuildConfigRef,
+	},
+	{
+		name: "alter-table-add-repo-gated-external",
+		stmt: alterTableAddRepoGatedExternal,
+	},
+	{
+		name: "update-table-set-repo-gated-external",
+		stmt: updateTableSetRepoGatedExternal,
+	},
+	{
+		name: "alter-table-add-secret-registry",
+		stmt: alterTableAddSecretRegistry,
+	},
+	{
+		name: "update-table-set-secret-registry",
+		stmt: updateTableSetSecretRegistry,
+	},
+	{
+		name: "alter-table-add-repo-status-context",
+		stmt: alterTableAddRepoStatusContext,
+	},
+	{
+		name: "update-table-set-repo-status-context",
+		stmt: updateTableSetRepoStatusContext,
+	},
+	{
+		name: "alter-table-add-repo-matrix-limit",
+		stmt: alterTableAddRepoMatrixLimit,
+	},
+	{
+		name: "update-table-set-repo-matrix-limit",
+		stmt: updateTableSetRepoMatrixLimit,
+	},
+	{
+		name: "alter-table-add-repo-skip-lint",
+		stmt: alterTableAddRepoSkipLint,
+	},
+	{
+		name: "update-table-set-repo-skip-lint",
+		stmt: updateTableSetRepoSkipLint,
+	},
+	{
+		name: "alter-table-add-repo-allowed-events",
+		stmt: alterTableAddRepoAllowedEvents,
+	},
+	{
+		name: "update-table-set-repo-allowed-events",
+		stmt: updateTableSetRepoAllowedEvents,
+	},
+	{
+		name: "alter-table-add-secret-template",
+		stmt: alterTableAddSecretTemplate,
+	},
+	{
+		name: "update-table-set-secret-template",
+		stmt: updateTableSetSecretTemplate,
+	},
+	{
+		name: "alter-table-add-repo-config-repo",
+		stmt: alterTableAddRepoConfigRepo,
+	},
+	{
+		name: "update-table-set-repo-config-repo",
+		stmt: updateTableSetRepoConfigRepo,
+	},
+	{
+		name: "alter-table-add-repo-branch-environ",
+		stmt: alterTableAddRepoBranchEnviron,
+	},
+	{
+		name: "update-table-set-repo-branch-environ",
+		stmt: updateTableSetRepoBranchEnviron,
+	},
+	{
+		name: "alter-table-add-task-label-exprs",
+		stmt: alterTableAddTaskLabelExprs,
+	},
+	{
+		name: "update-table-set-task-label-exprs",
+		stmt: updateTableSetTaskLabelExprs,
+	},
+	{
+		name: "alter-table-add-secret-matrix",
+		stmt: alterTableAddSecretMatrix,
+	},
+	{
+		name: "update-table-set-secret-matrix",
+		stmt: updateTableSetSecretMatrix,
+	},
+	{
+		name: "alter-table-add-proc-environ-snapshot",
+		stmt: alterTableAddProcEnvironSnapshot,
+	},
+	{
+		name: "update-table-set-proc-environ-snapshot",
+		stmt: updateTableSetProcEnvironSnapshot,
+	},
+	{
+		name: "alter-table-add-task-fail-fast",
+		stmt: alterTableAddTaskFailFast,
+	},
+	{
+		name: "update-table-set-task-fail-fast",
+		stmt: updateTableSetTaskFailFast,
+	},
+	{
+		name: "alter-table-add-build-from-fork",
+		stmt: alterTableAddBuildFromFork,
+	},
+	{
+		name: "update-table-set-build-from-fork",
+		stmt: updateTableSetBuildFromFork,
+	},
+	{
+		name: "alter-table-add-proc-stage",
+		stmt: alterTableAddProcStage,
+	},
+	{
+		name: "update-table-set-proc-stage",
+		stmt: updateTableSetProcStage,
+	},
 }
 
 // Migrate performs the database migration. If the migration fails
@@ -746,3 +906,239 @@ ALTER TABLE builds ADD COLUMN changed_files TEXT
 var updateBuildsSetChangedfiles = `
 UPDATE builds SET changed_files='[]'
 `
+
+//
+// 026_add_repo_description_topics_columns.sql
+//
+
+var alterTableAddRepoDescription = `
+ALTER TABLE repos ADD COLUMN repo_description TEXT
+`
+
+var updateTableSetRepoDescription = `
+UPDATE repos SET repo_description=''
+`
+
+var alterTableAddRepoTopics = `
+ALTER TABLE repos ADD COLUMN repo_topics TEXT
+`
+
+var updateTableSetRepoTopics = `
+UPDATE repos SET repo_topics='[]'
+`
+
+//
+// 027_add_repo_branch_fallback_column.sql
+//
+
+var alterTableAddRepoBranchFallback = `
+ALTER TABLE repos ADD COLUMN repo_branch_fallback BOOLEAN
+`
+
+var updateTableSetRepoBranchFallback = `
+UPDATE repos SET repo_branch_fallback='false'
+`
+
+//
+// 028_add_task_exclude_labels_column.sql
+//
+
+var alterTableAddTaskExcludeLabels = `
+ALTER TABLE tasks ADD COLUMN task_exclude_labels BLOB
+`
+
+var updateTableSetTaskExcludeLabels = `
+UPDATE tasks SET task_exclude_labels='null'
+`
+
+//
+// 029_add_builds_config_ref_column.sql
+//
+
+var alterTableAddBuildConfigRef = `
+ALTER TABLE builds ADD COLUMN build_config_ref TEXT
+`
+
+var updateTableSetBuildConfigRef = `
+UPDATE builds SET build_config_ref=build_commit
+`
+
+//
+// 030_add_repo_gated_external_column.sql
+//
+
+var alterTableAddRepoGatedExternal = `
+ALTER TABLE repos ADD COLUMN repo_gated_external BOOLEAN
+`
+
+var updateTableSetRepoGatedExternal = `
+UPDATE repos SET repo_gated_external='false'
+`
+
+//
+// 031_add_secret_registry_column.sql
+//
+
+var alterTableAddSecretRegistry = `
+ALTER TABLE secrets ADD COLUMN secret_registry TEXT
+`
+
+var updateTableSetSecretRegistry = `
+UPDATE secrets SET secret_registry=''
+`
+
+//
+// 032_add_repo_status_context.sql
+//
+
+var alterTableAddRepoStatusContext = `
+ALTER TABLE repos ADD COLUMN repo_status_context TEXT
+`
+
+var updateTableSetRepoStatusContext = `
+UPDATE repos SET repo_status_context=''
+`
+
+//
+// 033_add_repo_matrix_limit.sql
+//
+
+var alterTableAddRepoMatrixLimit = `
+ALTER TABLE repos ADD COLUMN repo_matrix_limit INTEGER
+`
+
+var updateTableSetRepoMatrixLimit = `
+UPDATE repos SET repo_matrix_limit=0
+`
+
+//
+// 034_add_repo_skip_lint.sql
+//
+
+var alterTableAddRepoSkipLint = `
+ALTER TABLE repos ADD COLUMN repo_skip_lint BOOLEAN
+`
+
+var updateTableSetRepoSkipLint = `
+UPDATE repos SET repo_skip_lint=0
+`
+
+//
+// 035_add_repo_allowed_events.sql
+//
+
+var alterTableAddRepoAllowedEvents = `
+ALTER TABLE repos ADD COLUMN repo_allowed_events TEXT
+`
+
+var updateTableSetRepoAllowedEvents = `
+UPDATE repos SET repo_allowed_events='[]'
+`
+
+//
+// 036_add_secret_template_column.sql
+//
+
+var alterTableAddSecretTemplate = `
+ALTER TABLE secrets ADD COLUMN secret_template BOOLEAN
+`
+
+var updateTableSetSecretTemplate = `
+UPDATE secrets SET secret_template=0
+`
+
+//
+// 037_add_repo_config_repo.sql
+//
+
+var alterTableAddRepoConfigRepo = `
+ALTER TABLE repos ADD COLUMN repo_config_repo TEXT
+`
+
+var updateTableSetRepoConfigRepo = `
+UPDATE repos SET repo_config_repo=''
+`
+
+//
+// 038_add_repo_branch_environ.sql
+//
+
+var alterTableAddRepoBranchEnviron = `
+ALTER TABLE repos ADD COLUMN repo_branch_environ TEXT
+`
+
+var updateTableSetRepoBranchEnviron = `
+UPDATE repos SET repo_branch_environ='[]'
+`
+
+//
+// 039_add_task_label_exprs_column.sql
+//
+
+var alterTableAddTaskLabelExprs = `
+ALTER TABLE tasks ADD COLUMN task_label_exprs BLOB
+`
+
+var updateTableSetTaskLabelExprs = `
+UPDATE tasks SET task_label_exprs='null'
+`
+
+//
+// 040_add_secret_matrix_column.sql
+//
+
+var alterTableAddSecretMatrix = `
+ALTER TABLE secrets ADD COLUMN secret_matrix TEXT
+`
+
+var updateTableSetSecretMatrix = `
+UPDATE secrets SET secret_matrix='{}'
+`
+
+//
+// 041_add_proc_environ_snapshot_column.sql
+//
+
+var alterTableAddProcEnvironSnapshot = `
+ALTER TABLE procs ADD COLUMN proc_environ_snapshot BLOB
+`
+
+var updateTableSetProcEnvironSnapshot = `
+UPDATE procs SET proc_environ_snapshot='null'
+`
+
+//
+// 042_add_task_fail_fast_column.sql
+//
+
+var alterTableAddTaskFailFast = `
+ALTER TABLE tasks ADD COLUMN task_fail_fast BOOLEAN
+`
+
+var updateTableSetTaskFailFast = `
+UPDATE tasks SET task_fail_fast=0
+`
+
+//
+// 043_add_build_from_fork_column.sql
+//
+
+var alterTableAddBuildFromFork = `
+ALTER TABLE builds ADD COLUMN build_from_fork BOOLEAN
+`
+
+var updateTableSetBuildFromFork = `
+UPDATE builds SET build_from_fork=0
+`
+
+//
+// 044_add_proc_stage_column.sql
+//
+
+var alterTableAddProcStage = `
+ALTER TABLE procs ADD COLUMN proc_stage TEXT
+`
+
+var updateTableSetProcStage = `
+UPDATE procs SET proc_stage=''
+`
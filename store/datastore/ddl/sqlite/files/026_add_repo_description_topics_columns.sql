@@ -0,0 +1,11 @@
+-- name: alter-table-add-repo-description
+ALTER TABLE repos ADD COLUMN repo_description TEXT
+
+-- name: update-table-set-repo-description
+UPDATE repos SET repo_description=''
+
+-- name: alter-table-add-repo-topics
+ALTER TABLE repos ADD COLUMN repo_topics TEXT
+
+-- name: update-table-set-repo-topics
+UPDATE repos SET repo_topics='[]'
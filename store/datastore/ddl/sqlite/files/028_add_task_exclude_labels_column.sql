@@ -0,0 +1,7 @@
+-- name: alter-table-add-task-exclude-labels
+
+ALTER TABLE tasks ADD COLUMN task_exclude_labels BLOB
+
+-- name: update-table-set-task-exclude-labels
+
+UPDATE tasks SET task_exclude_labels='null'
@@ -0,0 +1,5 @@
+-- name: alter-table-add-repo-matrix-limit
+ALTER TABLE repos ADD COLUMN repo_matrix_limit INTEGER
+
+-- name: update-table-set-repo-matrix-limit
+UPDATE repos SET repo_matrix_limit=0
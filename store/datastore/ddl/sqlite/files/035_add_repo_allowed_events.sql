@@ -0,0 +1,5 @@
+-- name: alter-table-add-repo-allowed-events
+ALTER TABLE repos ADD COLUMN repo_allowed_events TEXT
+
+-- name: update-table-set-repo-allowed-events
+UPDATE repos SET repo_allowed_events='[]'
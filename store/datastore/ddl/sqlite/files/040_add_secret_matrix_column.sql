@@ -0,0 +1,7 @@
+-- name: alter-table-add-secret-matrix
+
+ALTER TABLE secrets ADD COLUMN secret_matrix TEXT
+
+-- name: update-table-set-secret-matrix
+
+UPDATE secrets SET secret_matrix='{}'
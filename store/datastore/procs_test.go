@@ -40,6 +40,10 @@ func TestProcFind(t *testing.T) {
 			Machine:  "localhost",
 			Platform: "linux/amd64",
 			Environ:  map[string]string{"GOLANG": "tip"},
+			EnvironSnapshot: []model.EnvironVar{
+				{Key: "CI_REPO", Value: "octocat/hello-world"},
+				{Key: "PASSWORD", Secret: true},
+			},
 		},
 	})
 	if err != nil {
@@ -70,6 +74,11 @@ func TestProcFind(t *testing.T) {
 	if got, want := proc.Name, "build"; got != want {
 		t.Errorf("Want proc name %s, got %s", want, got)
 	}
+	if got, want := len(proc.EnvironSnapshot), 2; got != want {
+		t.Errorf("Want %d snapshot variables, got %d", want, got)
+	} else if !proc.EnvironSnapshot[1].Secret || proc.EnvironSnapshot[1].Value != "" {
+		t.Errorf("Want the secret snapshot variable without value")
+	}
 }
 
 func TestProcChild(t *testing.T) {
@@ -15,6 +15,8 @@
 package datastore
 
 import (
+	"encoding/json"
+
 	"github.com/russross/meddler"
 	"github.com/woodpecker-ci/woodpecker/model"
 	"github.com/woodpecker-ci/woodpecker/store/datastore/sql"
@@ -70,7 +72,19 @@ func (db *datastore) RepoListLatest(user *model.User) ([]*model.Feed, error) {
 func (db *datastore) RepoBatch(repos []*model.Repo) error {
 	stmt := sql.Lookup(db.driver, "repo-insert-ignore")
 	for _, repo := range repos {
-		_, err := db.Exec(stmt,
+		topics, err := json.Marshal(repo.Topics)
+		if err != nil {
+			return err
+		}
+		allowedEvents, err := json.Marshal(repo.AllowedEvents)
+		if err != nil {
+			return err
+		}
+		branchEnviron, err := json.Marshal(repo.BranchEnviron)
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(stmt,
 			repo.UserID,
 			repo.Owner,
 			repo.Name,
@@ -94,6 +108,16 @@ func (db *datastore) RepoBatch(repos []*model.Repo) error {
 			repo.Visibility,
 			repo.Counter,
 			repo.Fallback,
+			repo.Description,
+			string(topics),
+			repo.BranchFallback,
+			repo.IsGatedExternal,
+			repo.StatusContext,
+			repo.MatrixLimit,
+			repo.SkipLint,
+			string(allowedEvents),
+			repo.ConfigRepo,
+			string(branchEnviron),
 		)
 		if err != nil {
 			return err
@@ -140,9 +140,11 @@ func TestRepoList(t *testing.T) {
 	s.CreateUser(user)
 
 	repo1 := &model.Repo{
-		Owner:    "bradrydzewski",
-		Name:     "drone",
-		FullName: "bradrydzewski/drone",
+		Owner:       "bradrydzewski",
+		Name:        "drone",
+		FullName:    "bradrydzewski/drone",
+		Description: "a continuous integration platform",
+		Topics:      []string{"ci", "go"},
 	}
 	repo2 := &model.Repo{
 		Owner:    "drone",
@@ -177,6 +179,12 @@ func TestRepoList(t *testing.T) {
 	if got, want := repos[1].ID, repo2.ID; got != want {
 		t.Errorf("Want repository id %d, got %d", want, got)
 	}
+	if got, want := repos[0].Description, repo1.Description; got != want {
+		t.Errorf("Want repository description %q, got %q", want, got)
+	}
+	if got, want := len(repos[0].Topics), 2; got != want {
+		t.Errorf("Want %d repository topics, got %d", want, got)
+	}
 }
 
 func TestRepoListLatest(t *testing.T) {
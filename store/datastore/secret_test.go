@@ -33,6 +33,7 @@ func TestSecretFind(t *testing.T) {
 		Value:  "correct-horse-battery-staple",
 		Images: []string{"golang", "node"},
 		Events: []string{"push", "tag"},
+		Matrix: map[string]string{"cloud": "aws"},
 	})
 	if err != nil {
 		t.Errorf("Unexpected error: insert secret: %s", err)
@@ -65,6 +66,9 @@ func TestSecretFind(t *testing.T) {
 	if got, want := secret.Images[1], "node"; got != want {
 		t.Errorf("Want secret image %s, got %s", want, got)
 	}
+	if got, want := secret.Matrix["cloud"], "aws"; got != want {
+		t.Errorf("Want secret matrix cloud %s, got %s", want, got)
+	}
 }
 
 func TestSecretList(t *testing.T) {
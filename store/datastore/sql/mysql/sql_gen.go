@@ -310,6 +310,7 @@ SELECT
 ,proc_machine
 ,proc_platform
 ,proc_environ
+,proc_environ_snapshot
 FROM procs
 WHERE proc_id = ?
 `
@@ -330,6 +331,7 @@ SELECT
 ,proc_machine
 ,proc_platform
 ,proc_environ
+,proc_environ_snapshot
 FROM procs
 WHERE proc_build_id = ?
 ORDER BY proc_id ASC
@@ -351,6 +353,7 @@ proc_id
 ,proc_machine
 ,proc_platform
 ,proc_environ
+,proc_environ_snapshot
 FROM procs
 WHERE proc_build_id = ?
   AND proc_pid = ?
@@ -372,6 +375,7 @@ proc_id
 ,proc_machine
 ,proc_platform
 ,proc_environ
+,proc_environ_snapshot
 FROM procs
 WHERE proc_build_id = ?
   AND proc_ppid = ?
@@ -449,6 +453,16 @@ SELECT
 ,repo_visibility
 ,repo_counter
 ,repo_fallback
+,repo_description
+,repo_topics
+,repo_branch_fallback
+,repo_gated_external
+,repo_status_context
+,repo_matrix_limit
+,repo_skip_lint
+,repo_allowed_events
+,repo_config_repo
+,repo_branch_environ
 FROM repos
 INNER JOIN perms ON perms.perm_repo_id = repos.repo_id
 WHERE perms.perm_user_id = ?
@@ -480,7 +494,17 @@ INSERT IGNORE INTO repos (
 ,repo_visibility
 ,repo_counter
 ,repo_fallback
-) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
+,repo_description
+,repo_topics
+,repo_branch_fallback
+,repo_gated_external
+,repo_status_context
+,repo_matrix_limit
+,repo_skip_lint
+,repo_allowed_events
+,repo_config_repo
+,repo_branch_environ
+) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
 `
 
 var repoDelete = `
@@ -497,6 +521,9 @@ SELECT
 ,secret_events
 ,secret_conceal
 ,secret_skip_verify
+,secret_registry
+,secret_template
+,secret_matrix
 FROM secrets
 WHERE secret_repo_id = ?
 `
@@ -511,6 +538,9 @@ secret_id
 ,secret_events
 ,secret_conceal
 ,secret_skip_verify
+,secret_registry
+,secret_template
+,secret_matrix
 FROM secrets
 WHERE secret_repo_id = ?
   AND secret_name = ?
@@ -558,6 +588,9 @@ SELECT
 ,task_labels
 ,task_dependencies
 ,task_run_on
+,task_exclude_labels
+,task_label_exprs
+,task_fail_fast
 FROM tasks
 `
 
@@ -31,6 +31,16 @@ SELECT
 ,repo_visibility
 ,repo_counter
 ,repo_fallback
+,repo_description
+,repo_topics
+,repo_branch_fallback
+,repo_gated_external
+,repo_status_context
+,repo_matrix_limit
+,repo_skip_lint
+,repo_allowed_events
+,repo_config_repo
+,repo_branch_environ
 FROM repos
 INNER JOIN perms ON perms.perm_repo_id = repos.repo_id
 WHERE perms.perm_user_id = $1
@@ -62,7 +72,17 @@ INSERT INTO repos (
 ,repo_visibility
 ,repo_counter
 ,repo_fallback
-) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23)
+,repo_description
+,repo_topics
+,repo_branch_fallback
+,repo_gated_external
+,repo_status_context
+,repo_matrix_limit
+,repo_skip_lint
+,repo_allowed_events
+,repo_config_repo
+,repo_branch_environ
+) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24,$25,$26,$27,$28,$29,$30,$31,$32,$33)
 ON CONFLICT (repo_full_name) DO NOTHING
 
 -- name: repo-delete
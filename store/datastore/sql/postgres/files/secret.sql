@@ -9,6 +9,9 @@ SELECT
 ,secret_events
 ,secret_conceal
 ,secret_skip_verify
+,secret_registry
+,secret_template
+,secret_matrix
 FROM secrets
 WHERE secret_repo_id = $1
 
@@ -23,6 +26,9 @@ secret_id
 ,secret_events
 ,secret_conceal
 ,secret_skip_verify
+,secret_registry
+,secret_template
+,secret_matrix
 FROM secrets
 WHERE secret_repo_id = $1
   AND secret_name = $2
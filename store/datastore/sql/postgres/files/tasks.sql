@@ -6,6 +6,9 @@ SELECT
 ,task_labels
 ,task_dependencies
 ,task_run_on
+,task_exclude_labels
+,task_label_exprs
+,task_fail_fast
 FROM tasks
 
 -- name: task-delete
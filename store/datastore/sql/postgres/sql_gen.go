@@ -313,6 +313,7 @@ SELECT
 ,proc_machine
 ,proc_platform
 ,proc_environ
+,proc_environ_snapshot
 FROM procs
 WHERE proc_id = $1
 `
@@ -333,6 +334,7 @@ SELECT
 ,proc_machine
 ,proc_platform
 ,proc_environ
+,proc_environ_snapshot
 FROM procs
 WHERE proc_build_id = $1
 ORDER BY proc_id ASC
@@ -354,6 +356,7 @@ proc_id
 ,proc_machine
 ,proc_platform
 ,proc_environ
+,proc_environ_snapshot
 FROM procs
 WHERE proc_build_id = $1
   AND proc_pid      = $2
@@ -375,6 +378,7 @@ proc_id
 ,proc_machine
 ,proc_platform
 ,proc_environ
+,proc_environ_snapshot
 FROM procs
 WHERE proc_build_id = $1
   AND proc_ppid = $2
@@ -452,6 +456,16 @@ SELECT
 ,repo_visibility
 ,repo_counter
 ,repo_fallback
+,repo_description
+,repo_topics
+,repo_branch_fallback
+,repo_gated_external
+,repo_status_context
+,repo_matrix_limit
+,repo_skip_lint
+,repo_allowed_events
+,repo_config_repo
+,repo_branch_environ
 FROM repos
 INNER JOIN perms ON perms.perm_repo_id = repos.repo_id
 WHERE perms.perm_user_id = $1
@@ -483,7 +497,17 @@ INSERT INTO repos (
 ,repo_visibility
 ,repo_counter
 ,repo_fallback
-) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23)
+,repo_description
+,repo_topics
+,repo_branch_fallback
+,repo_gated_external
+,repo_status_context
+,repo_matrix_limit
+,repo_skip_lint
+,repo_allowed_events
+,repo_config_repo
+,repo_branch_environ
+) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24,$25,$26,$27,$28,$29,$30,$31,$32,$33)
 ON CONFLICT (repo_full_name) DO NOTHING
 `
 
@@ -502,6 +526,9 @@ SELECT
 ,secret_events
 ,secret_conceal
 ,secret_skip_verify
+,secret_registry
+,secret_template
+,secret_matrix
 FROM secrets
 WHERE secret_repo_id = $1
 `
@@ -516,6 +543,9 @@ secret_id
 ,secret_events
 ,secret_conceal
 ,secret_skip_verify
+,secret_registry
+,secret_template
+,secret_matrix
 FROM secrets
 WHERE secret_repo_id = $1
   AND secret_name = $2
@@ -563,6 +593,9 @@ SELECT
 ,task_labels
 ,task_dependencies
 ,task_run_on
+,task_exclude_labels
+,task_label_exprs
+,task_fail_fast
 FROM tasks
 `
 
@@ -15,6 +15,7 @@ SELECT
 ,proc_machine
 ,proc_platform
 ,proc_environ
+,proc_environ_snapshot
 FROM procs
 WHERE proc_id = ?
 
@@ -35,6 +36,7 @@ SELECT
 ,proc_machine
 ,proc_platform
 ,proc_environ
+,proc_environ_snapshot
 FROM procs
 WHERE proc_build_id = ?
 ORDER BY proc_id ASC
@@ -56,6 +58,7 @@ proc_id
 ,proc_machine
 ,proc_platform
 ,proc_environ
+,proc_environ_snapshot
 FROM procs
 WHERE proc_build_id = ?
   AND proc_pid = ?
@@ -77,6 +80,7 @@ proc_id
 ,proc_machine
 ,proc_platform
 ,proc_environ
+,proc_environ_snapshot
 FROM procs
 WHERE proc_build_id = ?
   AND proc_ppid = ?
@@ -31,6 +31,16 @@ SELECT
 ,repo_visibility
 ,repo_counter
 ,repo_fallback
+,repo_description
+,repo_topics
+,repo_branch_fallback
+,repo_gated_external
+,repo_status_context
+,repo_matrix_limit
+,repo_skip_lint
+,repo_allowed_events
+,repo_config_repo
+,repo_branch_environ
 FROM repos
 INNER JOIN perms ON perms.perm_repo_id = repos.repo_id
 WHERE perms.perm_user_id = ?
@@ -62,7 +72,17 @@ INSERT OR IGNORE INTO repos (
 ,repo_visibility
 ,repo_counter
 ,repo_fallback
-) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
+,repo_description
+,repo_topics
+,repo_branch_fallback
+,repo_gated_external
+,repo_status_context
+,repo_matrix_limit
+,repo_skip_lint
+,repo_allowed_events
+,repo_config_repo
+,repo_branch_environ
+) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
 
 -- name: repo-delete
 
@@ -28,9 +28,10 @@ func TestTaskList(t *testing.T) {
 	}()
 
 	s.TaskInsert(&model.Task{
-		ID:     "some_random_id",
-		Data:   []byte("foo"),
-		Labels: map[string]string{"foo": "bar"},
+		ID:       "some_random_id",
+		Data:     []byte("foo"),
+		Labels:   map[string]string{"foo": "bar"},
+		FailFast: true,
 	})
 
 	list, err := s.TaskList()
@@ -48,6 +49,9 @@ func TestTaskList(t *testing.T) {
 	if got, want := list[0].Data, "foo"; string(got) != want {
 		t.Errorf("Want task data %s, got %s", want, string(got))
 	}
+	if !list[0].FailFast {
+		t.Errorf("Want task fail fast")
+	}
 
 	err = s.TaskDelete("some_random_id")
 	if err != nil {
@@ -37,11 +37,23 @@ export default class Settings extends Component {
     this.handleTagChange = this.handleTagChange.bind(this);
     this.handleDeployChange = this.handleDeployChange.bind(this);
     this.handleTrustedChange = this.handleTrustedChange.bind(this);
+    this.handleSkipLintChange = this.handleSkipLintChange.bind(this);
     this.handleProtectedChange = this.handleProtectedChange.bind(this);
+    this.handleProtectedExternalChange = this.handleProtectedExternalChange.bind(
+      this,
+    );
     this.handleVisibilityChange = this.handleVisibilityChange.bind(this);
     this.handleTimeoutChange = this.handleTimeoutChange.bind(this);
+    this.handleMatrixLimitChange = this.handleMatrixLimitChange.bind(this);
+    this.handleAllowedEventsChange = this.handleAllowedEventsChange.bind(this);
+    this.handleBranchEnvironChange = this.handleBranchEnvironChange.bind(this);
     this.handlePathChange = this.handlePathChange.bind(this);
+    this.handleConfigRepoChange = this.handleConfigRepoChange.bind(this);
+    this.handleStatusContextChange = this.handleStatusContextChange.bind(this);
     this.handleFallbackChange = this.handleFallbackChange.bind(this);
+    this.handleBranchFallbackChange = this.handleBranchFallbackChange.bind(
+      this,
+    );
     this.handleChange = this.handleChange.bind(this);
   }
 
@@ -82,6 +94,25 @@ export default class Settings extends Component {
               />
               <span>Fallback to .drone.yml if path not exists</span>
             </label>
+            <label>
+              <input
+                type="checkbox"
+                checked={repo.branch_fallback}
+                onChange={this.handleBranchFallbackChange}
+              />
+              <span>Fallback to the default branch if path not exists</span>
+            </label>
+          </div>
+        </section>
+        <section>
+          <h2>Config Repository</h2>
+          <div>
+            <input
+              type="text"
+              defaultValue={repo.config_repo}
+              placeholder="owner/name@ref"
+              onBlur={this.handleConfigRepoChange}
+            />
           </div>
         </section>
         <section>
@@ -133,6 +164,14 @@ export default class Settings extends Component {
               />
               <span>Protected</span>
             </label>
+            <label>
+              <input
+                type="checkbox"
+                checked={repo.gated_external}
+                onChange={this.handleProtectedExternalChange}
+              />
+              <span>Protected for external contributors</span>
+            </label>
             <label>
               <input
                 type="checkbox"
@@ -141,6 +180,15 @@ export default class Settings extends Component {
               />
               <span>Trusted</span>
             </label>
+            <label>
+              <input
+                type="checkbox"
+                checked={repo.skip_lint}
+                disabled={!repo.trusted}
+                onChange={this.handleSkipLintChange}
+              />
+              <span>Skip linting</span>
+            </label>
           </div>
         </section>
 
@@ -191,6 +239,53 @@ export default class Settings extends Component {
             <span className={styles.minutes}>minutes</span>
           </div>
         </section>
+
+        <section>
+          <h2>Matrix Limit</h2>
+          <div>
+            <input
+              type="number"
+              value={repo.matrix_limit}
+              placeholder="Use the server default"
+              onBlur={this.handleMatrixLimitChange}
+            />
+          </div>
+        </section>
+
+        <section>
+          <h2>Allowed Events</h2>
+          <div>
+            <input
+              type="text"
+              defaultValue={(repo.allowed_events || []).join(", ")}
+              placeholder="Use the server default"
+              onBlur={this.handleAllowedEventsChange}
+            />
+          </div>
+        </section>
+
+        <section>
+          <h2>Branch Environment</h2>
+          <div>
+            <textarea
+              defaultValue={JSON.stringify(repo.branch_environ || [], null, 2)}
+              placeholder='[{"branch": "main", "environ": {"DEPLOY_ENV": "prod"}}]'
+              onBlur={this.handleBranchEnvironChange}
+            />
+          </div>
+        </section>
+
+        <section>
+          <h2>Status Context</h2>
+          <div>
+            <input
+              type="text"
+              value={repo.status_context}
+              placeholder="Use the server default"
+              onBlur={this.handleStatusContextChange}
+            />
+          </div>
+        </section>
       </div>
     );
   }
@@ -215,10 +310,18 @@ export default class Settings extends Component {
     this.handleChange("trusted", e.target.checked);
   }
 
+  handleSkipLintChange(e) {
+    this.handleChange("skip_lint", e.target.checked);
+  }
+
   handleProtectedChange(e) {
     this.handleChange("gated", e.target.checked);
   }
 
+  handleProtectedExternalChange(e) {
+    this.handleChange("gated_external", e.target.checked);
+  }
+
   handleVisibilityChange(e) {
     this.handleChange("visibility", e.target.value);
   }
@@ -227,14 +330,48 @@ export default class Settings extends Component {
     this.handleChange("timeout", parseInt(e.target.value));
   }
 
+  handleMatrixLimitChange(e) {
+    this.handleChange("matrix_limit", parseInt(e.target.value) || 0);
+  }
+
   handlePathChange(e) {
     this.handleChange("config_file", e.target.value);
   }
 
+  handleConfigRepoChange(e) {
+    this.handleChange("config_repo", e.target.value.trim());
+  }
+
+  handleStatusContextChange(e) {
+    this.handleChange("status_context", e.target.value);
+  }
+
+  handleAllowedEventsChange(e) {
+    const events = e.target.value
+      .split(",")
+      .map(event => event.trim())
+      .filter(event => event !== "");
+    this.handleChange("allowed_events", events);
+  }
+
+  handleBranchEnvironChange(e) {
+    let rules;
+    try {
+      rules = JSON.parse(e.target.value || "[]");
+    } catch (err) {
+      return;
+    }
+    this.handleChange("branch_environ", rules);
+  }
+
   handleFallbackChange(e) {
     this.handleChange("fallback", e.target.checked);
   }
 
+  handleBranchFallbackChange(e) {
+    this.handleChange("branch_fallback", e.target.checked);
+  }
+
   handleChange(prop, value) {
     const { dispatch, drone, repo } = this.props;
     let data = {};